@@ -0,0 +1,157 @@
+package jsonapi
+
+import "sync"
+
+// DocumentBuilder assembles a Document through chainable calls, for server
+// code that builds up data, included resources, links and meta piece by
+// piece instead of constructing a Document literal all at once. Setting
+// Data/DataArray clears any errors already added, and AddError clears any
+// data already set, so the two can never both end up on the built Document.
+//
+// Every method is safe to call from multiple goroutines on the same
+// builder, so a server fanning out to load several relationships in
+// parallel can have each goroutine call AddIncluded or Sideload directly
+// instead of funneling results through a channel first. Build reads the
+// builder's final state and should be called after all concurrent calls
+// have completed, e.g. following a sync.WaitGroup.Wait.
+type DocumentBuilder struct {
+	mu  sync.Mutex
+	doc *Document
+	err error
+}
+
+// NewDocumentBuilder starts a new, empty DocumentBuilder.
+func NewDocumentBuilder() *DocumentBuilder {
+	return &DocumentBuilder{doc: &Document{}}
+}
+
+// Data sets the builder's top-level "data" to a single resource object.
+func (b *DocumentBuilder) Data(d Data) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.doc.Errors = nil
+	b.doc.Data = &DataContainer{DataObject: &d}
+	return b
+}
+
+// DataArray sets the builder's top-level "data" to an array of resource
+// objects.
+func (b *DocumentBuilder) DataArray(d []Data) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.doc.Errors = nil
+	b.doc.Data = &DataContainer{DataArray: d}
+	return b
+}
+
+// AddError appends one error object, for a builder assembling an error
+// document instead of a data document.
+func (b *DocumentBuilder) AddError(e ErrorObject) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.doc.Data = nil
+	b.doc.Errors = append(b.doc.Errors, e)
+	return b
+}
+
+// AddIncluded appends one or more resources to the builder's "included"
+// array.
+func (b *DocumentBuilder) AddIncluded(d ...Data) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.doc.Included = append(b.doc.Included, d...)
+	return b
+}
+
+// Sideload marshals each of resources with the reflection-based marshaler
+// (see Marshal) and appends the results to the builder's "included" array:
+// each resource's own primary data, plus anything it pulled in through its
+// own relationships. A resource whose type+id is already in Included —
+// whether from an earlier Sideload call or the primary data itself — is
+// skipped, so calling Sideload with overlapping graphs of related objects
+// doesn't duplicate entries. This automates assembling a compound document
+// from a set of related objects, instead of building each Data by hand. A
+// resource that fails to marshal (e.g. one missing a `jsonapi:"primary,..."`
+// field) is recorded and surfaced by Err; the rest of resources are still
+// processed.
+func (b *DocumentBuilder) Sideload(resources ...interface{}) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seen := map[string]bool{}
+	if b.doc.Data != nil {
+		if b.doc.Data.DataObject != nil {
+			seen[b.doc.Data.DataObject.Type+":"+b.doc.Data.DataObject.ID] = true
+		}
+		for _, d := range b.doc.Data.DataArray {
+			seen[d.Type+":"+d.ID] = true
+		}
+	}
+	for _, d := range b.doc.Included {
+		seen[d.Type+":"+d.ID] = true
+	}
+
+	for _, resource := range resources {
+		doc, err := Marshal(resource)
+		if err != nil {
+			if b.err == nil {
+				b.err = err
+			}
+			continue
+		}
+
+		var found []Data
+		if doc.Data.DataObject != nil {
+			found = append(found, *doc.Data.DataObject)
+		}
+		found = append(found, doc.Data.DataArray...)
+		found = append(found, doc.Included...)
+
+		for _, d := range found {
+			key := d.Type + ":" + d.ID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			b.doc.Included = append(b.doc.Included, d)
+		}
+	}
+
+	return b
+}
+
+// Err returns the first error encountered by a Sideload call, or nil if
+// every resource marshaled successfully.
+func (b *DocumentBuilder) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// WithLinks sets the builder's top-level "links".
+func (b *DocumentBuilder) WithLinks(links *Links) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.doc.Links = links
+	return b
+}
+
+// WithMeta sets a single key in the builder's top-level "meta" map,
+// creating the map on first use.
+func (b *DocumentBuilder) WithMeta(key string, value interface{}) *DocumentBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.doc.Meta == nil {
+		b.doc.Meta = map[string]interface{}{}
+	}
+	b.doc.Meta[key] = value
+	return b
+}
+
+// Build returns the assembled Document. Call it only after any concurrent
+// calls into the builder have completed; Build itself does not lock, since
+// by the time it's meaningful to read a final result, nothing else should
+// still be writing to it.
+func (b *DocumentBuilder) Build() *Document {
+	return b.doc
+}
@@ -0,0 +1,150 @@
+package jsonapi
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDocumentBuilderAssemblesDataIncludedLinksMeta(t *testing.T) {
+	doc := NewDocumentBuilder().
+		Data(Data{Type: "articles", ID: "1"}).
+		AddIncluded(Data{Type: "people", ID: "9"}).
+		WithLinks(&Links{Self: "https://example.com/articles/1"}).
+		WithMeta("total", 1).
+		Build()
+
+	data, ok := doc.One()
+	if !ok || data.ID != "1" {
+		t.Fatalf("expected the built document to carry the single resource, got %+v, %v", data, ok)
+	}
+	if len(doc.Included) != 1 || doc.Included[0].ID != "9" {
+		t.Fatalf("unexpected included: %+v", doc.Included)
+	}
+	if doc.Links == nil || doc.Links.Self != "https://example.com/articles/1" {
+		t.Fatalf("unexpected links: %+v", doc.Links)
+	}
+	if doc.Meta["total"] != 1 {
+		t.Fatalf("unexpected meta: %+v", doc.Meta)
+	}
+}
+
+func TestDocumentBuilderAddErrorClearsData(t *testing.T) {
+	doc := NewDocumentBuilder().
+		Data(Data{Type: "articles", ID: "1"}).
+		AddError(NewValidationError("/data/attributes/title", "can't be blank")).
+		Build()
+
+	if doc.Data != nil {
+		t.Fatalf("expected AddError to clear any previously set data, got %+v", doc.Data)
+	}
+	if len(doc.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %+v", doc.Errors)
+	}
+}
+
+func TestDocumentBuilderSideloadAppendsResourceAndItsRelationships(t *testing.T) {
+	author := &testPerson{ID: "9", Name: "Alice"}
+	article := &testArticle{ID: "1", Title: "Hello", Author: author}
+
+	doc := NewDocumentBuilder().
+		Data(Data{Type: "articles", ID: "1"}).
+		Sideload(article).
+		Build()
+
+	// The article itself duplicates the primary data already set via Data,
+	// so only its related author ends up in Included.
+	if len(doc.Included) != 1 || doc.Included[0].Type != "people" || doc.Included[0].ID != "9" {
+		t.Fatalf("expected only the sideloaded author, got %+v", doc.Included)
+	}
+}
+
+func TestDocumentBuilderSideloadSkipsResourceAlreadyInIncludedOrData(t *testing.T) {
+	person := &testPerson{ID: "9", Name: "Alice"}
+
+	doc := NewDocumentBuilder().
+		Data(Data{Type: "people", ID: "9"}).
+		AddIncluded(Data{Type: "people", ID: "9"}).
+		Sideload(person).
+		Build()
+
+	if len(doc.Included) != 1 {
+		t.Fatalf("expected Sideload not to duplicate an already-present resource, got %+v", doc.Included)
+	}
+}
+
+func TestDocumentBuilderSideloadTerminatesOnRelationshipCycle(t *testing.T) {
+	author := &testPerson{ID: "9", Name: "Alice"}
+	article := &testArticle{ID: "1", Title: "Hello", Author: author}
+	author.Articles = []*testArticle{article}
+
+	doc := NewDocumentBuilder().
+		Data(Data{Type: "articles", ID: "1"}).
+		Sideload(article).
+		Build()
+
+	if len(doc.Included) != 1 || doc.Included[0].Type != "people" || doc.Included[0].ID != "9" {
+		t.Fatalf("expected Sideload to terminate with only the author included, got %+v", doc.Included)
+	}
+}
+
+func TestDocumentBuilderSideloadRecordsMarshalErrors(t *testing.T) {
+	b := NewDocumentBuilder().Sideload("not a struct")
+
+	if b.Err() == nil {
+		t.Fatal("expected Err to report the Marshal failure")
+	}
+}
+
+func TestDocumentBuilderDataClearsErrors(t *testing.T) {
+	doc := NewDocumentBuilder().
+		AddError(NewValidationError("/data/attributes/title", "can't be blank")).
+		Data(Data{Type: "articles", ID: "1"}).
+		Build()
+
+	if doc.Errors != nil {
+		t.Fatalf("expected Data to clear any previously added errors, got %+v", doc.Errors)
+	}
+	if _, ok := doc.One(); !ok {
+		t.Fatal("expected the final Data call to win")
+	}
+}
+
+func TestDocumentBuilderConcurrentAddIncludedAndSideloadAreRaceFree(t *testing.T) {
+	b := NewDocumentBuilder().Data(Data{Type: "articles", ID: "1"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		id := strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			b.AddIncluded(Data{Type: "comments", ID: id})
+		}()
+		go func() {
+			defer wg.Done()
+			b.Sideload(&testPerson{ID: "p" + id, Name: "Alice"})
+		}()
+	}
+	wg.Wait()
+
+	doc := b.Build()
+	if len(doc.Included) != 40 {
+		t.Fatalf("expected 40 included resources, got %d: %+v", len(doc.Included), doc.Included)
+	}
+	if b.Err() != nil {
+		t.Fatalf("unexpected Sideload error: %v", b.Err())
+	}
+}
+
+func BenchmarkDocumentBuilderConcurrentAddIncluded(b *testing.B) {
+	builder := NewDocumentBuilder()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			builder.AddIncluded(Data{Type: "comments", ID: strconv.Itoa(i)})
+			i++
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package jsonapi
+
+import "encoding/json"
+
+// BulkOutcomeMetaKey is the Data.Meta key SetBulkOutcome writes to and
+// BulkOutcome reads from, recording a single item's per-resource result in
+// a bulk create/update response. This is the per-item half of a convention
+// UnmarshalCollectionPartial's *BatchError already covers on decode; this
+// is its counterpart for a server assembling the response.
+const BulkOutcomeMetaKey = "outcome"
+
+// BulkSummaryMetaKey is the Document.Meta key SetBulkSummary writes to,
+// recording a bulk response's overall succeeded/failed counts.
+const BulkSummaryMetaKey = "bulk"
+
+// BulkOutcome is a bulk item's per-resource result: whether it succeeded,
+// and if not, the error describing why.
+type BulkOutcome struct {
+	Success bool         `json:"success"`
+	Error   *ErrorObject `json:"error,omitempty"`
+}
+
+// BulkSummary totals a bulk response's per-item outcomes.
+type BulkSummary struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// SetBulkOutcome records d's outcome under BulkOutcomeMetaKey: successful
+// if failure is nil, failed with failure's detail otherwise.
+func (d *Data) SetBulkOutcome(failure *ErrorObject) {
+	if d.Meta == nil {
+		d.Meta = map[string]interface{}{}
+	}
+	d.Meta[BulkOutcomeMetaKey] = BulkOutcome{Success: failure == nil, Error: failure}
+}
+
+// BulkOutcome reads back d's outcome as recorded by SetBulkOutcome. It
+// returns false if d.Meta carries no BulkOutcomeMetaKey entry, including
+// for a resource SetBulkOutcome was never called on.
+func (d *Data) BulkOutcome() (BulkOutcome, bool) {
+	v, ok := d.Meta[BulkOutcomeMetaKey]
+	if !ok {
+		return BulkOutcome{}, false
+	}
+	if outcome, ok := v.(BulkOutcome); ok {
+		return outcome, true
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return BulkOutcome{}, false
+	}
+	var outcome BulkOutcome
+	if err := json.Unmarshal(raw, &outcome); err != nil {
+		return BulkOutcome{}, false
+	}
+	return outcome, true
+}
+
+// SetBulkSummary tallies the BulkOutcomeMetaKey meta SetBulkOutcome set on
+// each of d's primary resources and records the totals on d's top-level
+// meta under BulkSummaryMetaKey, giving clients an at-a-glance result for
+// the whole bulk operation without walking every resource. A resource with
+// no recorded outcome isn't counted either way.
+func (d *Document) SetBulkSummary() {
+	var summary BulkSummary
+	d.Each(func(item *Data) error {
+		if outcome, ok := item.BulkOutcome(); ok {
+			if outcome.Success {
+				summary.Succeeded++
+			} else {
+				summary.Failed++
+			}
+		}
+		return nil
+	})
+	d.SetMeta(BulkSummaryMetaKey, summary)
+}
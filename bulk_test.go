@@ -0,0 +1,102 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBulkOutcomeRoundTripsSuccessAndFailure(t *testing.T) {
+	var ok Data
+	ok.SetBulkOutcome(nil)
+
+	outcome, found := ok.BulkOutcome()
+	if !found || !outcome.Success || outcome.Error != nil {
+		t.Fatalf("unexpected outcome: %+v, found=%v", outcome, found)
+	}
+
+	var failed Data
+	failed.SetBulkOutcome(&ErrorObject{Status: "422", Title: "Invalid", Detail: "name is required"})
+
+	outcome, found = failed.BulkOutcome()
+	if !found || outcome.Success || outcome.Error == nil || outcome.Error.Detail != "name is required" {
+		t.Fatalf("unexpected outcome: %+v, found=%v", outcome, found)
+	}
+}
+
+func TestBulkOutcomeReportsFalseWithoutOutcomeMeta(t *testing.T) {
+	var d Data
+	if _, ok := d.BulkOutcome(); ok {
+		t.Fatal("expected no outcome on a resource SetBulkOutcome was never called on")
+	}
+}
+
+func TestDocumentSetBulkSummaryTalliesMixedOutcomes(t *testing.T) {
+	ok1 := Data{Type: "widgets", ID: "1"}
+	ok1.SetBulkOutcome(nil)
+
+	ok2 := Data{Type: "widgets", ID: "2"}
+	ok2.SetBulkOutcome(nil)
+
+	failed := Data{Type: "widgets", ID: "3"}
+	failed.SetBulkOutcome(&ErrorObject{Status: "422", Title: "Invalid", Detail: "name is required"})
+
+	doc := &Document{Data: &DataContainer{DataArray: []Data{ok1, ok2, failed}}}
+	doc.SetBulkSummary()
+
+	v, ok := doc.GetMeta(BulkSummaryMetaKey)
+	if !ok {
+		t.Fatal("expected a bulk summary in top-level meta")
+	}
+	summary, ok := v.(BulkSummary)
+	if !ok {
+		t.Fatalf("expected a BulkSummary, got %T", v)
+	}
+	if summary.Succeeded != 2 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestDocumentSetBulkSummaryOnSingleResource(t *testing.T) {
+	d := Data{Type: "widgets", ID: "1"}
+	d.SetBulkOutcome(nil)
+
+	doc := &Document{Data: &DataContainer{DataObject: &d}}
+	doc.SetBulkSummary()
+
+	v, _ := doc.GetMeta(BulkSummaryMetaKey)
+	summary := v.(BulkSummary)
+	if summary.Succeeded != 1 || summary.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestBulkResponseRoundTripsOutcomeMetaThroughJSON(t *testing.T) {
+	ok := Data{Type: "widgets", ID: "1", Attributes: mustRawMessage(t, `{"name":"thing"}`)}
+	ok.SetBulkOutcome(nil)
+
+	failed := Data{Type: "widgets", ID: "2", Attributes: mustRawMessage(t, `{"name":"other"}`)}
+	failed.SetBulkOutcome(&ErrorObject{Status: "422", Title: "Invalid", Detail: "name already taken"})
+
+	doc := &Document{Data: &DataContainer{DataArray: []Data{ok, failed}}}
+	doc.SetBulkSummary()
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var decoded Document
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	okOutcome, found := decoded.Data.DataArray[0].BulkOutcome()
+	if !found || !okOutcome.Success {
+		t.Fatalf("unexpected outcome after round trip: %+v, found=%v", okOutcome, found)
+	}
+
+	failedOutcome, found := decoded.Data.DataArray[1].BulkOutcome()
+	if !found || failedOutcome.Success || failedOutcome.Error == nil || failedOutcome.Error.Detail != "name already taken" {
+		t.Fatalf("unexpected outcome after round trip: %+v, found=%v", failedOutcome, found)
+	}
+}
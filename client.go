@@ -0,0 +1,298 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// APIError is returned by Client when the server responds with a non-2xx
+// status, carrying the error objects from its JSON:API error document (or
+// none, if the body wasn't one) instead of leaving the caller to parse the
+// response body itself.
+type APIError struct {
+	StatusCode int
+	Errors     []ErrorObject
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("jsonapi: server responded with status %d", e.StatusCode)
+	}
+	detail := e.Errors[0].Detail
+	if detail == "" {
+		detail = e.Errors[0].Title
+	}
+	return fmt.Sprintf("jsonapi: server responded with status %d: %s", e.StatusCode, detail)
+}
+
+// Client is a minimal JSON:API client built on an *http.Client: it sets the
+// Accept and Content-Type headers, marshals request bodies through Marshal,
+// and decodes a successful response's primary resource through Unmarshal.
+// A non-2xx response is returned as an *APIError instead of being decoded
+// into the result.
+type Client struct {
+	// BaseURL is prefixed to every resource/collection URL Client builds,
+	// e.g. "https://example.com/api". A trailing slash is tolerated.
+	BaseURL string
+	// HTTPClient performs the actual requests. Defaults to
+	// http.DefaultClient when left nil.
+	HTTPClient *http.Client
+	// MaxPages caps the number of pages GetAll will follow via "next"
+	// links before giving up. Defaults to defaultMaxPages when left zero.
+	MaxPages int
+}
+
+// defaultMaxPages is the page count GetAll follows before returning
+// ErrTooManyPages, when Client.MaxPages is left at its zero value.
+const defaultMaxPages = 1000
+
+// ErrTooManyPages is returned by GetAll when a collection's "next" links
+// keep going past Client.MaxPages (or defaultMaxPages, if that's unset),
+// which is most likely a server whose "next" link cycles back on itself
+// rather than a collection that's actually that large.
+var ErrTooManyPages = errors.New("jsonapi: exceeded maximum page count following \"next\" links")
+
+// NewClient returns a Client against baseURL, using http.DefaultClient.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: baseURL}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) collectionURL(typ string) string {
+	return strings.TrimRight(c.BaseURL, "/") + "/" + typ
+}
+
+func (c *Client) resourceURL(typ, id string) string {
+	return c.collectionURL(typ) + "/" + id
+}
+
+// Get fetches the resource identified by typ and id and decodes its
+// attributes and relationships into out, a pointer to a `jsonapi`-tagged
+// struct.
+func (c *Client) Get(ctx context.Context, typ, id string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, c.resourceURL(typ, id), nil, nil, out)
+}
+
+// Create marshals in (see Marshal) and POSTs it to in's own resource
+// collection, e.g. "articles" for a struct tagged `jsonapi:"primary,
+// articles"`, with a zero-value primary field omitted so the server assigns
+// the id. The server's response is decoded into out, which may be the same
+// pointer as in to read server-assigned fields back into it.
+func (c *Client) Create(ctx context.Context, in interface{}, out interface{}) error {
+	doc, err := Marshal(in, WithCreateMode())
+	if err != nil {
+		return err
+	}
+	if doc.Data == nil || doc.Data.DataObject == nil {
+		return fmt.Errorf("jsonapi: Create requires a single resource, not a collection")
+	}
+	return c.do(ctx, http.MethodPost, c.collectionURL(doc.Data.DataObject.Type), doc, nil, out)
+}
+
+// Update marshals in (see Marshal) and PATCHes it to its own type and id.
+// The server's response is decoded into out.
+func (c *Client) Update(ctx context.Context, in interface{}, out interface{}) error {
+	doc, err := Marshal(in)
+	if err != nil {
+		return err
+	}
+	if doc.Data == nil || doc.Data.DataObject == nil {
+		return fmt.Errorf("jsonapi: Update requires a single resource, not a collection")
+	}
+	d := doc.Data.DataObject
+	return c.do(ctx, http.MethodPatch, c.resourceURL(d.Type, d.ID), doc, nil, out)
+}
+
+// MarshalPatch builds a minimal update Document for typ/id containing only
+// the attributes named in changed, rather than marshaling a whole struct
+// (see Marshal), which always sends every attribute whether or not it
+// actually changed. A nil value in changed marshals as an explicit JSON
+// null, clearing that attribute server-side -- the same distinction an
+// absent key vs. a present-but-null one makes anywhere else in this
+// package. It pairs with UpdatePatch, the Client counterpart to Update for
+// a caller that only has a set of changed fields on hand, not a whole
+// tagged struct.
+func MarshalPatch(typ, id string, changed map[string]interface{}) (*Document, error) {
+	raw, err := marshalJSON(ObjectAttributes(changed))
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Data: &DataContainer{DataObject: &Data{
+		Type:       typ,
+		ID:         id,
+		Attributes: raw,
+	}}}, nil
+}
+
+// UpdatePatch is Update for a caller that has a set of changed attributes
+// on hand rather than a whole `jsonapi`-tagged struct: it builds a minimal
+// PATCH body via MarshalPatch instead of marshaling every attribute of a
+// full resource. The server's response is decoded into out.
+func (c *Client) UpdatePatch(ctx context.Context, typ, id string, changed map[string]interface{}, out interface{}) error {
+	doc, err := MarshalPatch(typ, id, changed)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPatch, c.resourceURL(typ, id), doc, nil, out)
+}
+
+// UpdateIfMatch is Update plus an optimistic-concurrency check: it sends
+// version (typically read from a prior response via Data.ResourceVersion)
+// as the request's If-Match header, so the server can reject the update
+// with a 412 Precondition Failed if the resource has changed since version
+// was read, instead of silently overwriting a concurrent edit.
+func (c *Client) UpdateIfMatch(ctx context.Context, in interface{}, version string, out interface{}) error {
+	doc, err := Marshal(in)
+	if err != nil {
+		return err
+	}
+	if doc.Data == nil || doc.Data.DataObject == nil {
+		return fmt.Errorf("jsonapi: UpdateIfMatch requires a single resource, not a collection")
+	}
+	d := doc.Data.DataObject
+	return c.do(ctx, http.MethodPatch, c.resourceURL(d.Type, d.ID), doc, map[string]string{"If-Match": version}, out)
+}
+
+// Delete DELETEs the resource identified by typ and id.
+func (c *Client) Delete(ctx context.Context, typ, id string) error {
+	return c.do(ctx, http.MethodDelete, c.resourceURL(typ, id), nil, nil, nil)
+}
+
+// GetAll fetches typ's collection and follows its "next" links, accumulating
+// every page's resources into into, a pointer to a slice of
+// `jsonapi`-tagged structs, until the server stops returning a "next" link.
+// It stops early and returns ErrTooManyPages if that takes more than
+// Client.MaxPages requests, in case a misbehaving server's "next" link
+// cycles back on itself, and it returns ctx.Err() if ctx is canceled
+// between pages.
+func (c *Client) GetAll(ctx context.Context, typ string, into interface{}) error {
+	rv := reflect.ValueOf(into)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("jsonapi: GetAll requires a pointer to a slice")
+	}
+	sliceType := rv.Elem().Type()
+	result := reflect.MakeSlice(sliceType, 0, 0)
+
+	maxPages := c.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+
+	url := c.collectionURL(typ)
+	for page := 0; ; page++ {
+		if page >= maxPages {
+			return ErrTooManyPages
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		doc, err := c.doDoc(ctx, http.MethodGet, url, nil, nil)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return errors.New("jsonapi: GetAll received an empty response body")
+		}
+
+		pagePtr := reflect.New(sliceType)
+		if err := Unmarshal(doc, pagePtr.Interface()); err != nil {
+			return err
+		}
+		result = reflect.AppendSlice(result, pagePtr.Elem())
+
+		next, ok := doc.Links.NextPage()
+		if !ok {
+			break
+		}
+		url = next
+	}
+
+	rv.Elem().Set(result)
+	return nil
+}
+
+// do sends method/url with body marshaled as the request body, if non-nil,
+// and decodes a successful JSON:API response into out, if non-nil. headers
+// is set on the request in addition to Accept/Content-Type, if non-nil. A
+// non-2xx response is returned as an *APIError.
+func (c *Client) do(ctx context.Context, method, url string, body *Document, headers map[string]string, out interface{}) error {
+	doc, err := c.doDoc(ctx, method, url, body, headers)
+	if err != nil {
+		return err
+	}
+	if out == nil || doc == nil {
+		return nil
+	}
+	return Unmarshal(doc, out)
+}
+
+// doDoc sends method/url with body marshaled as the request body, if
+// non-nil, and decodes a successful response into a *Document. headers is
+// set on the request in addition to Accept/Content-Type, if non-nil. A
+// non-2xx response is returned as an *APIError. It returns a nil Document
+// (and a nil error) for a successful response with an empty body.
+func (c *Client) doDoc(ctx context.Context, method, url string, body *Document, headers map[string]string) (*Document, error) {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := marshalJSON(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", MediaType)
+	if body != nil {
+		req.Header.Set("Content-Type", MediaType)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var doc Document
+		if len(respBody) > 0 && unmarshalJSON(respBody, &doc) == nil {
+			apiErr.Errors = doc.Errors
+		}
+		return nil, apiErr
+	}
+
+	if len(respBody) == 0 {
+		return nil, nil
+	}
+
+	var doc Document
+	if err := unmarshalJSON(respBody, &doc); err != nil {
+		return nil, newMalformedError(err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,309 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestClientGetDecodesResource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/people/9" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Accept"); got != MediaType {
+			t.Fatalf("Accept header = %q, want %q", got, MediaType)
+		}
+		doc, _ := Marshal(&testPerson{ID: "9", Name: "Alice"})
+		WriteDocument(w, http.StatusOK, doc)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	var out testPerson
+	if err := client.Get(context.Background(), "people", "9", &out); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if out.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", out)
+	}
+}
+
+func TestClientCreateOmitsZeroIDAndDecodesServerAssignedID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/people" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != MediaType {
+			t.Fatalf("Content-Type header = %q, want %q", got, MediaType)
+		}
+		if doc := readBody(t, r); doc.Data.DataObject.ID != "" {
+			t.Fatalf("expected a create request to omit id, got %+v", doc.Data.DataObject)
+		}
+		resp, _ := Marshal(&testPerson{ID: "99", Name: "Bob"})
+		WriteDocument(w, http.StatusCreated, resp)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	in := &testPerson{Name: "Bob"}
+	if err := client.Create(context.Background(), in, in); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if in.ID != "99" {
+		t.Fatalf("expected Create to decode the server-assigned id, got %+v", in)
+	}
+}
+
+func TestClientUpdateSendsResourceTypeAndID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/people/9" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		resp, _ := Marshal(&testPerson{ID: "9", Name: "Alice Updated"})
+		WriteDocument(w, http.StatusOK, resp)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	out := &testPerson{ID: "9", Name: "Alice"}
+	if err := client.Update(context.Background(), out, out); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if out.Name != "Alice Updated" {
+		t.Fatalf("unexpected person: %+v", out)
+	}
+}
+
+func TestMarshalPatchIncludesOnlyChangedAttributes(t *testing.T) {
+	doc, err := MarshalPatch("people", "9", map[string]interface{}{"name": "Alice Updated", "nickname": nil})
+	if err != nil {
+		t.Fatalf("MarshalPatch returned error: %v", err)
+	}
+
+	d := doc.Data.DataObject
+	if d.Type != "people" || d.ID != "9" {
+		t.Fatalf("unexpected resource identity: %+v", d)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(d.Attributes, &attrs); err != nil {
+		t.Fatalf("unmarshaling attributes: %v", err)
+	}
+	if len(attrs) != 2 {
+		t.Fatalf("expected exactly the 2 changed attributes, got %+v", attrs)
+	}
+	if attrs["name"] != "Alice Updated" {
+		t.Fatalf("unexpected name: %+v", attrs)
+	}
+	if v, ok := attrs["nickname"]; !ok || v != nil {
+		t.Fatalf("expected an explicit null for nickname, got %+v", attrs)
+	}
+	if _, ok := attrs["age"]; ok {
+		t.Fatal("expected an unchanged attribute to be absent")
+	}
+}
+
+func TestClientUpdatePatchSendsOnlyChangedAttributes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/people/9" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		doc := readBody(t, r)
+		var attrs map[string]interface{}
+		if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+			t.Fatalf("unmarshaling attributes: %v", err)
+		}
+		if len(attrs) != 1 || attrs["name"] != "Alice Updated" {
+			t.Fatalf("expected only the changed name attribute, got %+v", attrs)
+		}
+		resp, _ := Marshal(&testPerson{ID: "9", Name: "Alice Updated"})
+		WriteDocument(w, http.StatusOK, resp)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	var out testPerson
+	changed := map[string]interface{}{"name": "Alice Updated"}
+	if err := client.UpdatePatch(context.Background(), "people", "9", changed, &out); err != nil {
+		t.Fatalf("UpdatePatch returned error: %v", err)
+	}
+	if out.Name != "Alice Updated" {
+		t.Fatalf("unexpected person: %+v", out)
+	}
+}
+
+func TestClientUpdateIfMatchSendsIfMatchHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/people/9" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("If-Match"); got != "v1" {
+			t.Fatalf("If-Match header = %q, want %q", got, "v1")
+		}
+		resp, _ := Marshal(&testPerson{ID: "9", Name: "Alice Updated"})
+		WriteDocument(w, http.StatusOK, resp)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	out := &testPerson{ID: "9", Name: "Alice"}
+	if err := client.UpdateIfMatch(context.Background(), out, "v1", out); err != nil {
+		t.Fatalf("UpdateIfMatch returned error: %v", err)
+	}
+	if out.Name != "Alice Updated" {
+		t.Fatalf("unexpected person: %+v", out)
+	}
+}
+
+func TestClientUpdateIfMatchSurfacesPreconditionFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteDocument(w, http.StatusPreconditionFailed, MarshalErrors(ErrorObject{Title: "stale version"}))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	out := &testPerson{ID: "9", Name: "Alice"}
+	err := client.UpdateIfMatch(context.Background(), out, "stale", out)
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected *APIError, got %T (%v)", err, err)
+	}
+	if apiErr.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusPreconditionFailed)
+	}
+}
+
+func TestClientDeleteSendsDeleteRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/people/9" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	if err := client.Delete(context.Background(), "people", "9"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+}
+
+func TestClientSurfacesServerErrorDocumentAsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteDocument(w, http.StatusNotFound, NotFound("no such person"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	var out testPerson
+	err := client.Get(context.Background(), "people", "404", &out)
+	if err == nil {
+		t.Fatal("expected an error for a 404 response")
+	}
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusNotFound)
+	}
+	if len(apiErr.Errors) != 1 || apiErr.Errors[0].Detail != "no such person" {
+		t.Fatalf("unexpected errors: %+v", apiErr.Errors)
+	}
+}
+
+func TestClientGetAllFollowsNextLinksAcrossThreePages(t *testing.T) {
+	pages := [][]testPerson{
+		{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}},
+		{{ID: "3", Name: "Carol"}},
+		{{ID: "4", Name: "Dave"}},
+	}
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+
+		idx := len(requests) - 1
+		people := make([]*testPerson, len(pages[idx]))
+		for i := range pages[idx] {
+			people[i] = &pages[idx][i]
+		}
+		doc, err := Marshal(people)
+		if err != nil {
+			t.Fatalf("Marshal returned error: %v", err)
+		}
+		if idx < len(pages)-1 {
+			doc.Links = &Links{Next: "http://" + r.Host + "/people?page=" + strconv.Itoa(idx+2)}
+		}
+		WriteDocument(w, http.StatusOK, doc)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	var out []testPerson
+	if err := client.GetAll(context.Background(), "people", &out); err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+
+	if len(out) != 4 {
+		t.Fatalf("expected 4 people across 3 pages, got %+v", out)
+	}
+	if out[0].Name != "Alice" || out[3].Name != "Dave" {
+		t.Fatalf("unexpected order: %+v", out)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %v", requests)
+	}
+}
+
+func TestClientGetAllStopsAtMaxPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, _ := Marshal([]*testPerson{{ID: "1", Name: "Alice"}})
+		doc.Links = &Links{Next: "http://" + r.Host + "/people?page=again"}
+		WriteDocument(w, http.StatusOK, doc)
+	}))
+	defer srv.Close()
+
+	client := &Client{BaseURL: srv.URL, MaxPages: 2}
+	var out []testPerson
+	err := client.GetAll(context.Background(), "people", &out)
+	if err != ErrTooManyPages {
+		t.Fatalf("expected ErrTooManyPages, got %v", err)
+	}
+}
+
+func TestClientGetAllRespectsContextCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, _ := Marshal([]*testPerson{{ID: "1", Name: "Alice"}})
+		doc.Links = &Links{Next: "http://" + r.Host + "/people?page=2"}
+		WriteDocument(w, http.StatusOK, doc)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := NewClient(srv.URL)
+	var out []testPerson
+	if err := client.GetAll(ctx, "people", &out); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func readBody(t *testing.T, r *http.Request) *Document {
+	t.Helper()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("failed reading request body: %v", err)
+	}
+	doc, err := ParseDocument(body)
+	if err != nil {
+		t.Fatalf("failed parsing request body: %v", err)
+	}
+	return doc
+}
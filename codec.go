@@ -0,0 +1,36 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// EncodeFunc converts a Go attribute value to the value Marshal puts into
+// the wire attributes object, for a type registered with RegisterFieldCodec.
+type EncodeFunc func(v interface{}) (interface{}, error)
+
+// DecodeFunc parses a raw JSON attribute value into a Go value of the type
+// registered with RegisterFieldCodec, the decode counterpart to EncodeFunc.
+type DecodeFunc func(raw json.RawMessage) (interface{}, error)
+
+type fieldCodec struct {
+	encode EncodeFunc
+	decode DecodeFunc
+}
+
+// fieldCodecs maps a Go type to the codec registered for it via
+// RegisterFieldCodec, the same process-wide scoping as typeRegistry.
+var fieldCodecs = map[reflect.Type]fieldCodec{}
+
+// RegisterFieldCodec associates the Go type t with an encode/decode pair so
+// every attribute field of that type converts through them at the JSON:API
+// boundary instead of through encoding/json's default representation. This
+// bridges a Go type whose wire representation doesn't match its in-memory
+// form, e.g. money stored as cents but sent as a decimal string, or an enum
+// stored as an int but sent as its name. Call it once per Go type before
+// Marshal or Unmarshal touches a struct with a field of that type; a
+// registered codec takes precedence over TimeFormat for time.Time and
+// *time.Time fields.
+func RegisterFieldCodec(t reflect.Type, encode EncodeFunc, decode DecodeFunc) {
+	fieldCodecs[t] = fieldCodec{encode: encode, decode: decode}
+}
@@ -0,0 +1,195 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Money stores an amount in cents, but RegisterFieldCodec below makes it
+// travel over the wire as a decimal string like "19.99".
+type Money int64
+
+func moneyEncode(v interface{}) (interface{}, error) {
+	cents := v.(Money)
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100), nil
+}
+
+func moneyDecode(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("money attribute must be a decimal string: %w", err)
+	}
+	var whole, frac int64
+	if _, err := fmt.Sscanf(s, "%d.%d", &whole, &frac); err != nil {
+		return nil, fmt.Errorf("money attribute %q is not a decimal amount: %w", s, err)
+	}
+	return Money(whole*100 + frac), nil
+}
+
+type testProduct struct {
+	ID    string `jsonapi:"primary,products"`
+	Name  string `jsonapi:"attr,name"`
+	Price Money  `jsonapi:"attr,price"`
+}
+
+func registerMoneyCodec(t *testing.T) {
+	t.Helper()
+	RegisterFieldCodec(reflect.TypeOf(Money(0)), moneyEncode, moneyDecode)
+	t.Cleanup(func() { delete(fieldCodecs, reflect.TypeOf(Money(0))) })
+}
+
+func TestMarshalWithFieldCodecEncodesAttributeThroughCodec(t *testing.T) {
+	registerMoneyCodec(t)
+	product := &testProduct{ID: "1", Name: "Widget", Price: Money(1999)}
+
+	doc, err := Marshal(product)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attrs["price"] != "19.99" {
+		t.Fatalf("price = %v, want %q", attrs["price"], "19.99")
+	}
+}
+
+func TestUnmarshalWithFieldCodecDecodesAttributeThroughCodec(t *testing.T) {
+	registerMoneyCodec(t)
+	doc := &Document{
+		Data: &DataContainer{
+			DataObject: &Data{
+				Type:       "products",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"name":"Widget","price":"19.99"}`),
+			},
+		},
+	}
+
+	var out testProduct
+	if err := Unmarshal(doc, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if out.Price != Money(1999) {
+		t.Fatalf("Price = %d, want %d", out.Price, 1999)
+	}
+}
+
+func TestMarshalUnmarshalRoundTripsFieldCodec(t *testing.T) {
+	registerMoneyCodec(t)
+	product := &testProduct{ID: "1", Name: "Widget", Price: Money(105)}
+
+	doc, err := Marshal(product)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out testProduct
+	if err := Unmarshal(doc, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if out.Price != product.Price {
+		t.Fatalf("Price = %d, want %d", out.Price, product.Price)
+	}
+}
+
+func TestMarshalPropagatesFieldCodecEncodeError(t *testing.T) {
+	failing := func(interface{}) (interface{}, error) { return nil, fmt.Errorf("boom") }
+	RegisterFieldCodec(reflect.TypeOf(Money(0)), failing, moneyDecode)
+	t.Cleanup(func() { delete(fieldCodecs, reflect.TypeOf(Money(0))) })
+
+	_, err := Marshal(&testProduct{ID: "1", Name: "Widget", Price: Money(1999)})
+	if err == nil {
+		t.Fatal("expected Marshal to return an error when the field codec fails to encode")
+	}
+}
+
+func TestUnmarshalPropagatesFieldCodecDecodeError(t *testing.T) {
+	registerMoneyCodec(t)
+	doc := &Document{
+		Data: &DataContainer{
+			DataObject: &Data{
+				Type:       "products",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"name":"Widget","price":"not-money"}`),
+			},
+		},
+	}
+
+	var out testProduct
+	if err := Unmarshal(doc, &out); err == nil {
+		t.Fatal("expected Unmarshal to return an error when the field codec fails to decode")
+	}
+}
+
+// Decimal models the sort of arbitrary-precision type a package like
+// shopspring/decimal provides, stored here as the exact decimal text rather
+// than a float64, so it never round-trips through a lossy binary
+// representation. A real Decimal type would parse/format through its own
+// big.Rat or equivalent; this one keeps the text verbatim since the point of
+// the test is what the codec *avoids* doing, not how such a type works.
+type Decimal string
+
+func decimalEncode(v interface{}) (interface{}, error) {
+	return json.Number(v.(Decimal)), nil
+}
+
+func decimalDecode(raw json.RawMessage) (interface{}, error) {
+	return Decimal(raw), nil
+}
+
+type testInvoice struct {
+	ID     string  `jsonapi:"primary,invoices"`
+	Amount Decimal `jsonapi:"attr,amount"`
+}
+
+func registerDecimalCodec(t *testing.T) {
+	t.Helper()
+	RegisterFieldCodec(reflect.TypeOf(Decimal("")), decimalEncode, decimalDecode)
+	t.Cleanup(func() { delete(fieldCodecs, reflect.TypeOf(Decimal(""))) })
+}
+
+func TestFieldCodecPreservesDecimalPrecisionThatFloat64Loses(t *testing.T) {
+	registerDecimalCodec(t)
+	const amount = "9007199254740993.12345678901"
+
+	// Decoding the same digits into a float64 demonstrates the drift the
+	// codec exists to avoid.
+	var asFloat float64
+	if err := json.Unmarshal([]byte(amount), &asFloat); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if fmt.Sprintf("%.11f", asFloat) == amount {
+		t.Fatal("expected float64 to actually lose precision for this amount, or the test proves nothing")
+	}
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type:       "invoices",
+		ID:         "1",
+		Attributes: json.RawMessage(`{"amount":` + amount + `}`),
+	}}}
+
+	var invoice testInvoice
+	if err := Unmarshal(doc, &invoice); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if invoice.Amount != Decimal(amount) {
+		t.Fatalf("Amount = %q, want %q", invoice.Amount, amount)
+	}
+
+	out, err := Marshal(&invoice)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var attrs map[string]json.Number
+	if err := json.Unmarshal(out.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attrs["amount"].String() != amount {
+		t.Fatalf("re-encoded amount = %s, want %s", attrs["amount"], amount)
+	}
+}
@@ -4,77 +4,1714 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 var objectSuffix = []byte("{")
 var arraySuffix = []byte("[")
+var nullLiteral = []byte("null")
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// stripBOM removes a leading UTF-8 byte order mark from payload, if present.
+// The JSON spec doesn't allow one, but some clients prepend it anyway, and
+// encoding/json treats it as a syntax error rather than skipping it the way
+// it does leading whitespace. Unlike whitespace, a BOM makes the whole
+// payload invalid before any type's UnmarshalJSON runs, so this has to be
+// called on raw request/stream bytes before they ever reach encoding/json,
+// not from within a json.Unmarshaler implementation. Bind and Decoder do so.
+func stripBOM(payload []byte) []byte {
+	return bytes.TrimPrefix(payload, utf8BOM)
+}
 
 // A Document represents a JSON API document as specified here: http://jsonapi.org.
+//
+// "data" and "errors" MUST NOT coexist in the same document; Marshal and
+// Unmarshal both enforce this.
 type Document struct {
-	Links    *Links                 `json:"links,omitempty"`
-	Data     *DataContainer         `json:"data"`
-	Included []Data                 `json:"included,omitempty"`
-	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Links    *Links         `json:"links,omitempty"`
+	Data     *DataContainer `json:"data,omitempty"`
+	Errors   []ErrorObject  `json:"errors,omitempty"`
+	Included []Data         `json:"included,omitempty"`
+	JSONAPI  *JSONAPIObject `json:"jsonapi,omitempty"`
+
+	// Meta carries both whole-document meta (e.g. a request id) and
+	// collection-wide meta about the primary data (e.g. a total count for a
+	// paginated array). The spec gives "data" no member of its own to carry
+	// meta about the collection as a whole — only the top level and each
+	// individual resource object (Data.Meta) have one — so collection-level
+	// meta belongs here, not on DataContainer, regardless of whether Data
+	// is a single resource or an array. See SetMeta/GetMeta and PageMeta for
+	// reading and writing it without touching the map directly.
+	Meta map[string]interface{} `json:"meta,omitempty"`
+
+	// Extensions holds any top-level member not recognized above, keyed by
+	// its raw name (e.g. an extension's namespaced "atomic:operations" or an
+	// "@"-prefixed member), so that round-tripping a document carrying a
+	// JSON:API extension doesn't silently drop it.
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// knownDocumentMembers are the top-level JSON:API members with a dedicated
+// Document field; every other top-level member lands in Extensions.
+var knownDocumentMembers = map[string]bool{
+	"links": true, "data": true, "errors": true,
+	"included": true, "jsonapi": true, "meta": true,
+}
+
+// JSONAPIObject describes the server's implementation, carried in the
+// top-level "jsonapi" member.
+type JSONAPIObject struct {
+	Version string                 `json:"version,omitempty"`
+	Ext     []string               `json:"ext,omitempty"`
+	Profile []string               `json:"profile,omitempty"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Clone returns a deep copy of j. A nil j clones to nil.
+func (j *JSONAPIObject) Clone() *JSONAPIObject {
+	if j == nil {
+		return nil
+	}
+
+	clone := *j
+	clone.Ext = append([]string(nil), j.Ext...)
+	clone.Profile = append([]string(nil), j.Profile...)
+	clone.Meta = cloneMeta(j.Meta)
+	return &clone
+}
+
+// DefaultVersion is the version negotiated when a document carries no
+// "jsonapi" member, per the spec's 1.0 fallback.
+const DefaultVersion = "1.0"
+
+// Version returns the document's negotiated JSON:API version: the
+// "jsonapi.version" member if present, or DefaultVersion otherwise, so
+// downstream code can branch on 1.0 vs 1.1 semantics.
+func (d *Document) Version() string {
+	if d.JSONAPI == nil || d.JSONAPI.Version == "" {
+		return DefaultVersion
+	}
+	return d.JSONAPI.Version
+}
+
+// One returns d's Data as a single resource object, when the document's
+// "data" member is a single object rather than an array. It reports false
+// for an array document or a Document with no Data at all.
+func (d *Document) One() (*Data, bool) {
+	if d.Data == nil || d.Data.DataObject == nil {
+		return nil, false
+	}
+	return d.Data.DataObject, true
+}
+
+// Many returns d's Data as a slice of resource objects, when the document's
+// "data" member is an array rather than a single object. It reports false
+// for a single-object document or a Document with no Data at all.
+func (d *Document) Many() ([]Data, bool) {
+	if d.Data == nil || d.Data.DataArray == nil {
+		return nil, false
+	}
+	return d.Data.DataArray, true
+}
+
+// Each invokes fn once per primary resource, the single object or each
+// element of the array, whichever d.Data holds, stopping and returning the
+// first error fn returns. It performs zero iterations for a nil
+// DataContainer. This lets a caller write one cardinality-agnostic
+// processing loop instead of branching on One/Many itself.
+func (d *Document) Each(fn func(*Data) error) error {
+	if d.Data == nil {
+		return nil
+	}
+	if d.Data.DataObject != nil {
+		return fn(d.Data.DataObject)
+	}
+	for i := range d.Data.DataArray {
+		if err := fn(&d.Data.DataArray[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IncludedOfType returns every resource in d.Included whose Type is typ, in
+// the order they appear. It's a simpler, common-case accessor than building
+// a full index with Link or Document.Link when a caller just wants, say,
+// every included "author" it knows it asked for via an `include` query
+// parameter. It returns an empty slice (never nil) when none match.
+func (d *Document) IncludedOfType(typ string) []Data {
+	out := []Data{}
+	for _, data := range d.Included {
+		if data.Type == typ {
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+// ResourceIdentifiers returns a deduplicated list of type+id (and lid)
+// identifiers for d's primary data, handling both a single resource and an
+// array. When includeRelationships is true, every relationship linkage on
+// every primary resource is included too, which is useful for collecting
+// every resource a document references for an authorization check or a
+// prefetch.
+func (d *Document) ResourceIdentifiers(includeRelationships bool) []RelationshipData {
+	seen := map[string]bool{}
+	var out []RelationshipData
+
+	add := func(rd RelationshipData) {
+		key := rd.Type + ":" + rd.ID + ":" + rd.LID
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		out = append(out, rd)
+	}
+
+	var resources []Data
+	switch {
+	case d.Data == nil:
+	case d.Data.DataObject != nil:
+		resources = []Data{*d.Data.DataObject}
+	case d.Data.DataArray != nil:
+		resources = d.Data.DataArray
+	}
+
+	for _, res := range resources {
+		add(RelationshipData{Type: res.Type, ID: res.ID, LID: res.LID})
+		if !includeRelationships {
+			continue
+		}
+		for _, rel := range res.Relationships {
+			if rel.Data == nil {
+				continue
+			}
+			if rel.Data.DataObject != nil {
+				add(*rel.Data.DataObject)
+			}
+			for _, rd := range rel.Data.DataArray {
+				add(rd)
+			}
+		}
+	}
+
+	return out
+}
+
+// AllAttributes decodes every primary resource's attributes into a generic
+// map, for tooling that doesn't know the schema ahead of time — a generic
+// viewer, an exporter, a proxy. Each map also carries "type" and "id" keys
+// alongside the decoded attributes, so the result is self-describing even
+// once separated from d. A single-object "data" decodes to a one-element
+// slice; a nil Data decodes to an empty slice.
+func (d *Document) AllAttributes() ([]map[string]interface{}, error) {
+	var resources []Data
+	switch {
+	case d.Data == nil:
+	case d.Data.DataObject != nil:
+		resources = []Data{*d.Data.DataObject}
+	case d.Data.DataArray != nil:
+		resources = d.Data.DataArray
+	}
+
+	out := make([]map[string]interface{}, len(resources))
+	for i, res := range resources {
+		m, err := res.AttributesMap()
+		if err != nil {
+			return nil, fmt.Errorf("jsonapi: decoding attributes for %s:%s: %w", res.Type, res.ID, err)
+		}
+		m["type"] = res.Type
+		m["id"] = res.ID
+		out[i] = m
+	}
+
+	return out, nil
+}
+
+// SetSelfLink sets d's top-level "self" link to url, initializing d.Links
+// if it is nil. Any other links already set on d.Links, such as pagination
+// links, are left untouched.
+func (d *Document) SetSelfLink(url string) {
+	if d.Links == nil {
+		d.Links = &Links{}
+	}
+	d.Links.Self = url
+}
+
+// SetMeta sets a single key in d's top-level "meta" map, initializing the
+// map if it is nil. This is the unambiguous place for collection-wide meta
+// about the primary data, such as a total count alongside a paginated
+// array — see the doc comment on Document.Meta.
+func (d *Document) SetMeta(key string, value interface{}) {
+	if d.Meta == nil {
+		d.Meta = map[string]interface{}{}
+	}
+	d.Meta[key] = value
+}
+
+// GetMeta returns d's top-level meta value for key and whether it was
+// present, without panicking on a nil d.Meta.
+func (d *Document) GetMeta(key string) (interface{}, bool) {
+	if d.Meta == nil {
+		return nil, false
+	}
+	value, ok := d.Meta[key]
+	return value, ok
+}
+
+// Clone returns a deep copy of d: Links, Data, Included and their nested
+// maps and slices are all copied, so mutating the clone — including adding
+// or removing an entry from a Meta map — never affects d. A nil d clones to
+// nil.
+func (d *Document) Clone() *Document {
+	if d == nil {
+		return nil
+	}
+
+	clone := &Document{
+		Links:   d.Links.Clone(),
+		Data:    d.Data.Clone(),
+		JSONAPI: d.JSONAPI.Clone(),
+		Meta:    cloneMeta(d.Meta),
+	}
+	if d.Errors != nil {
+		clone.Errors = append([]ErrorObject(nil), d.Errors...)
+	}
+	if d.Included != nil {
+		clone.Included = make([]Data, len(d.Included))
+		for i, inc := range d.Included {
+			clone.Included[i] = inc.Clone()
+		}
+	}
+	if d.Extensions != nil {
+		clone.Extensions = make(map[string]json.RawMessage, len(d.Extensions))
+		for k, v := range d.Extensions {
+			clone.Extensions[k] = append(json.RawMessage(nil), v...)
+		}
+	}
+
+	return clone
+}
+
+// cloneMeta returns a shallow copy of m, so adding or removing a key on the
+// clone doesn't affect m. A nil m clones to nil.
+func cloneMeta(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	clone := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// errDataAndErrors is returned wherever a Document is built or read with both
+// "data" and "errors" populated, which the spec forbids.
+var errDataAndErrors = errors.New("a document must not include both data and errors")
+
+// MarshalJSON returns the JSON encoding of the Document. It returns an error
+// if both Data and Errors are populated, since the spec requires them to be
+// mutually exclusive. Included is omitted whenever it has zero length,
+// whether nil or an empty slice, since the spec discourages reporting an
+// empty "included" member. A Links that is allocated but carries no links
+// (see Links.IsEmpty) is omitted the same way, rather than emitting a bare
+// "links":null. Any members captured in Extensions are merged back into the
+// output.
+func (d *Document) MarshalJSON() ([]byte, error) {
+	if d.Data != nil && d.Errors != nil {
+		return nil, errDataAndErrors
+	}
+
+	type alias Document
+	a := alias(*d)
+	if len(a.Included) == 0 {
+		a.Included = nil
+	}
+	if a.Links.IsEmpty() {
+		a.Links = nil
+	}
+
+	raw, err := marshalJSON(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Extensions) == 0 {
+		return raw, nil
+	}
+
+	var out map[string]json.RawMessage
+	if err := unmarshalJSON(raw, &out); err != nil {
+		return nil, err
+	}
+	for k, v := range d.Extensions {
+		out[k] = v
+	}
+
+	return marshalJSON(out)
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded document into d. It returns an
+// error if both "data" and "errors" are present. Any top-level member not
+// recognized as one of the well-known document members (e.g. an extension's
+// namespaced member) is preserved in Extensions rather than dropped.
+func (d *Document) UnmarshalJSON(payload []byte) error {
+	type alias Document
+	a := (*alias)(d)
+
+	if err := unmarshalJSON(payload, a); err != nil {
+		return err
+	}
+
+	if d.Data != nil && d.Errors != nil {
+		return errDataAndErrors
+	}
+
+	var raw map[string]json.RawMessage
+	if err := unmarshalJSON(payload, &raw); err != nil {
+		return err
+	}
+
+	d.Extensions = nil
+	for k, v := range raw {
+		if knownDocumentMembers[k] {
+			continue
+		}
+		if d.Extensions == nil {
+			d.Extensions = map[string]json.RawMessage{}
+		}
+		d.Extensions[k] = v
+	}
+
+	return nil
+}
+
+// errMissingTopLevelMember is returned by Validate when a document has none
+// of "data", "errors" or "meta", which the spec requires at least one of.
+var errMissingTopLevelMember = errors.New("a document must contain at least one of data, errors or meta")
+
+// errMissingType is returned by Validate when a resource object has no
+// "type" member, which the spec requires.
+var errMissingType = errors.New("a resource object must have a non-empty type")
+
+// errHeterogeneousData is returned by Validate when RequireHomogeneousData
+// is set and the primary "data" array mixes more than one resource type.
+var errHeterogeneousData = errors.New("a document's primary data array must not mix resource types")
+
+// InvalidLinkageError is returned by Validate when a relationship's linkage
+// carries a resource identifier with an empty "type", or an empty "id" and
+// no "lid" -- invalid per spec, and a common symptom of a server bug that
+// turns a null foreign key into {"type":"x","id":""} instead of either a
+// real identifier or "data":null.
+type InvalidLinkageError struct {
+	// Pointer is the JSON pointer to the offending identifier, e.g.
+	// "/data/relationships/author/data" or
+	// "/data/relationships/comments/data/1".
+	Pointer string
+	Detail  string
+}
+
+func (e *InvalidLinkageError) Error() string {
+	return "jsonapi: invalid linkage at " + e.Pointer + ": " + e.Detail
+}
+
+// DuplicateResourceError is returned by Validate when a resource in
+// Included also appears in the document's primary data -- invalid per
+// spec, and a common compound-document bug where a server includes a
+// resource it has already returned as primary data.
+type DuplicateResourceError struct {
+	// Pointer is the JSON pointer to the offending included entry, e.g.
+	// "/included/2".
+	Pointer string
+	Type    string
+	ID      string
+}
+
+func (e *DuplicateResourceError) Error() string {
+	return fmt.Sprintf("jsonapi: included resource at %s (type=%q, id=%q) duplicates primary data", e.Pointer, e.Type, e.ID)
+}
+
+// RelationshipLinkageError is returned by Validate when RequireRelationshipTypes
+// is set and a resource's relationship links to a type its schema doesn't
+// allow.
+type RelationshipLinkageError struct {
+	ResourceType string
+	Relationship string
+	LinkedType   string
+	Allowed      []string
+}
+
+func (e *RelationshipLinkageError) Error() string {
+	return fmt.Sprintf("jsonapi: %s relationship %q links to type %q, want one of %v", e.ResourceType, e.Relationship, e.LinkedType, e.Allowed)
+}
+
+// errVersionRequiresLID is returned by Validate when RequireSpecVersion(1.0
+// strictness) is set and a resource or relationship carries a "lid", which
+// JSON:API 1.0 doesn't define.
+var errVersionRequiresLID = errors.New("jsonapi: \"lid\" requires JSON:API 1.1, document is validated as 1.0")
+
+// errVersionRequiresDescribedBy is returned by Validate when
+// RequireSpecVersion(1.0 strictness) is set and a links object carries a
+// "describedby" member, which JSON:API 1.0 doesn't define.
+var errVersionRequiresDescribedBy = errors.New("jsonapi: \"describedby\" requires JSON:API 1.1, document is validated as 1.0")
+
+// errVersionRequiresExtProfile is returned by Validate when
+// RequireSpecVersion(1.0 strictness) is set and the top-level "jsonapi"
+// member carries "ext" or "profile", which JSON:API 1.0 doesn't define.
+var errVersionRequiresExtProfile = errors.New("jsonapi: \"ext\"/\"profile\" require JSON:API 1.1, document is validated as 1.0")
+
+// ValidateOption configures Document.Validate.
+type ValidateOption func(*validateOptions)
+
+type validateOptions struct {
+	requireHomogeneousData bool
+	relationshipTypes      map[string][]string
+	checkSpecVersion       bool
+	specVersion            string
+}
+
+// RequireSpecVersion makes Validate reject 1.1-only members -- "lid" on any
+// resource or relationship identifier, a "describedby" link, and "ext"/
+// "profile" on the top-level "jsonapi" member -- unless version is "1.1".
+// Pass "" to defer to the document's own declared version (d.Version(),
+// which falls back to "1.0" per the spec when a document carries no
+// "jsonapi" member), so a document that explicitly declares
+// jsonapi.version: "1.1" is validated as 1.1 while a document that's silent
+// about its version is held to 1.0. Pass an explicit "1.0" or "1.1" to
+// override that and check against a specific version regardless of what
+// the document itself declares. Without this option, Validate performs no
+// version-specific checks at all.
+func RequireSpecVersion(version string) ValidateOption {
+	return func(o *validateOptions) {
+		o.checkSpecVersion = true
+		o.specVersion = version
+	}
+}
+
+// RequireHomogeneousData makes Validate reject a primary "data" array whose
+// resources don't all share the same type. JSON:API allows heterogeneous
+// collections, so this is opt-in, for callers that model their primary data
+// as a single resource type and want to catch a surprising mix early.
+func RequireHomogeneousData() ValidateOption {
+	return func(o *validateOptions) { o.requireHomogeneousData = true }
+}
+
+// RequireRelationshipTypes makes Validate check every resource's
+// relationship linkage against schema, which maps a relationship name to
+// the resource type(s) it's allowed to link to, e.g.
+// map[string][]string{"author": {"people"}}. A relationship name absent
+// from schema is left unchecked. This catches a server bug assembling a
+// relationship's linkage from the wrong collection, which a type-agnostic
+// check like the default Validate can't.
+func RequireRelationshipTypes(schema map[string][]string) ValidateOption {
+	return func(o *validateOptions) { o.relationshipTypes = schema }
+}
+
+// Validate checks d against a handful of JSON:API structural rules that
+// json.Unmarshal alone can't enforce: "data" and "errors" must not coexist,
+// the document must carry at least one of "data", "errors" or "meta", and
+// every resource object in Data/Included must have a non-empty "type".
+// RequireHomogeneousData additionally rejects a primary data array mixing
+// resource types. RequireSpecVersion additionally rejects 1.1-only members
+// when validating as JSON:API 1.0.
+func (d *Document) Validate(opts ...ValidateOption) error {
+	var o validateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if d.Data != nil && d.Errors != nil {
+		return errDataAndErrors
+	}
+	if d.Data == nil && d.Errors == nil && d.Meta == nil {
+		return errMissingTopLevelMember
+	}
+
+	strict10 := o.checkSpecVersion && effectiveSpecVersion(d, o.specVersion) == "1.0"
+	if strict10 && d.JSONAPI != nil {
+		if len(d.JSONAPI.Ext) > 0 || len(d.JSONAPI.Profile) > 0 {
+			return errVersionRequiresExtProfile
+		}
+	}
+
+	if d.Data != nil {
+		if d.Data.DataObject != nil {
+			if d.Data.DataObject.Type == "" {
+				return errMissingType
+			}
+			if err := checkRelationshipTypes(d.Data.DataObject, o.relationshipTypes); err != nil {
+				return err
+			}
+			if err := checkLinkageIdentifiers(d.Data.DataObject, "/data"); err != nil {
+				return err
+			}
+			if strict10 {
+				if err := checkSpecVersion10(d.Data.DataObject); err != nil {
+					return err
+				}
+			}
+		}
+		for i := range d.Data.DataArray {
+			data := &d.Data.DataArray[i]
+			if data.Type == "" {
+				return errMissingType
+			}
+			if err := checkRelationshipTypes(data, o.relationshipTypes); err != nil {
+				return err
+			}
+			if err := checkLinkageIdentifiers(data, "/data/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+			if strict10 {
+				if err := checkSpecVersion10(data); err != nil {
+					return err
+				}
+			}
+		}
+
+		if o.requireHomogeneousData && len(d.Data.Types()) > 1 {
+			return errHeterogeneousData
+		}
+	}
+
+	primary := primaryResourceKeys(d.Data)
+
+	for i := range d.Included {
+		data := &d.Included[i]
+		if data.Type == "" {
+			return errMissingType
+		}
+		if err := checkRelationshipTypes(data, o.relationshipTypes); err != nil {
+			return err
+		}
+		if err := checkLinkageIdentifiers(data, "/included/"+strconv.Itoa(i)); err != nil {
+			return err
+		}
+		if primary[data.Type+":"+data.ID] {
+			return &DuplicateResourceError{Pointer: "/included/" + strconv.Itoa(i), Type: data.Type, ID: data.ID}
+		}
+		if strict10 {
+			if err := checkSpecVersion10(data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// primaryResourceKeys returns the "type:id" of every resource in data's
+// primary data, for Validate to check Included against.
+func primaryResourceKeys(data *DataContainer) map[string]bool {
+	keys := map[string]bool{}
+	switch {
+	case data == nil:
+	case data.DataObject != nil:
+		keys[data.DataObject.Type+":"+data.DataObject.ID] = true
+	default:
+		for _, d := range data.DataArray {
+			keys[d.Type+":"+d.ID] = true
+		}
+	}
+	return keys
+}
+
+// checkLinkageIdentifiers validates every relationship linkage identifier on
+// data -- a single to-one identifier or each element of a to-many array --
+// rejecting one with an empty "type", or an empty "id" and no "lid", with an
+// *InvalidLinkageError pointing at the offending identifier relative to
+// resourcePointer (data's own pointer, e.g. "/data" or "/data/2").
+func checkLinkageIdentifiers(data *Data, resourcePointer string) error {
+	for name, rel := range data.Relationships {
+		if rel.Data == nil {
+			continue
+		}
+
+		base := resourcePointer + "/relationships/" + name + "/data"
+		if rel.Data.DataObject != nil {
+			if err := checkLinkageIdentifier(*rel.Data.DataObject, base); err != nil {
+				return err
+			}
+		}
+		for i, rd := range rel.Data.DataArray {
+			if err := checkLinkageIdentifier(rd, base+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkLinkageIdentifier rejects rd if it has an empty "type", or an empty
+// "id" and no "lid".
+func checkLinkageIdentifier(rd RelationshipData, pointer string) error {
+	if rd.Type == "" {
+		return &InvalidLinkageError{Pointer: pointer, Detail: "resource identifier must have a non-empty type"}
+	}
+	if rd.ID == "" && rd.LID == "" {
+		return &InvalidLinkageError{Pointer: pointer, Detail: "resource identifier must have a non-empty id or lid"}
+	}
+	return nil
+}
+
+// effectiveSpecVersion resolves the version RequireSpecVersion should check
+// against: explicit if non-empty, otherwise d's own declared version.
+func effectiveSpecVersion(d *Document, explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	return d.Version()
+}
+
+// checkSpecVersion10 rejects the 1.1-only members RequireSpecVersion checks
+// for on a single resource object: a "lid" on the resource itself or on any
+// of its relationship linkage, and a "describedby" link on the resource or
+// any of its relationships.
+func checkSpecVersion10(data *Data) error {
+	if data.LID != "" {
+		return errVersionRequiresLID
+	}
+	if data.Links.DescribedBy() != "" {
+		return errVersionRequiresDescribedBy
+	}
+
+	for _, rel := range data.Relationships {
+		if rel.Links.DescribedBy() != "" {
+			return errVersionRequiresDescribedBy
+		}
+		if rel.Data == nil {
+			continue
+		}
+		if rel.Data.DataObject != nil && rel.Data.DataObject.LID != "" {
+			return errVersionRequiresLID
+		}
+		for _, rd := range rel.Data.DataArray {
+			if rd.LID != "" {
+				return errVersionRequiresLID
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRelationshipTypes validates data's relationship linkage against
+// schema (see RequireRelationshipTypes), returning a *RelationshipLinkageError
+// for the first relationship that links to a disallowed type.
+func checkRelationshipTypes(data *Data, schema map[string][]string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	for name, rel := range data.Relationships {
+		allowed, ok := schema[name]
+		if !ok || rel.Data == nil {
+			continue
+		}
+
+		linked := rel.Data.DataArray
+		if rel.Data.DataObject != nil {
+			linked = []RelationshipData{*rel.Data.DataObject}
+		}
+
+		for _, id := range linked {
+			if !containsAllowedType(allowed, id.Type) {
+				return &RelationshipLinkageError{
+					ResourceType: data.Type,
+					Relationship: name,
+					LinkedType:   id.Type,
+					Allowed:      allowed,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsAllowedType(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// A DataContainer is used to marshal and unmarshal single objects and arrays
+// of objects.
+type DataContainer struct {
+	DataObject *Data
+	DataArray  []Data
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded data to the DataObject field if
+// the root element is an object or to the DataArray field for arrays. null
+// is accepted too, setting both fields nil, for a to-one relationship or
+// lookup that found nothing. Anything else -- a number, string or boolean --
+// is rejected with an error naming the token actually encountered.
+func (c *DataContainer) UnmarshalJSON(payload []byte) error {
+	trimmed := bytes.TrimLeft(payload, " \t\r\n")
+
+	if bytes.HasPrefix(trimmed, objectSuffix) {
+		return unmarshalJSON(payload, &c.DataObject)
+	}
+
+	if bytes.HasPrefix(trimmed, arraySuffix) {
+		return unmarshalJSON(payload, &c.DataArray)
+	}
+
+	if bytes.Equal(bytes.TrimRight(trimmed, " \t\r\n"), nullLiteral) {
+		c.DataObject = nil
+		c.DataArray = nil
+		return nil
+	}
+
+	return fmt.Errorf(`jsonapi: "data" must be an object, array or null, got %s`, describeJSONToken(trimmed))
+}
+
+// describeJSONToken reports a short, human-readable name for the JSON value
+// trimmed begins with -- "a string", "a number", "a boolean", "null" or
+// "malformed JSON" -- for error messages naming the token actually
+// encountered where a different shape was expected.
+func describeJSONToken(trimmed []byte) string {
+	if len(trimmed) == 0 {
+		return "empty input"
+	}
+	switch trimmed[0] {
+	case '"':
+		return "a string"
+	case 't', 'f':
+		return "a boolean"
+	case 'n':
+		return "null"
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return "a number"
+	default:
+		return "malformed JSON"
+	}
+}
+
+// MarshalJSON returns the JSON encoding of the DataArray field or the
+// DataObject field. The two carry distinct meanings for an empty result: a
+// non-nil, empty DataArray (e.g. []Data{}) marshals to "[]", the correct
+// shape for a collection endpoint that matched zero resources, while a nil
+// DataArray and a nil DataObject marshal to "null", meaning a to-one
+// relationship or lookup that found nothing. Marshal always sets DataArray
+// to a non-nil slice for a slice input, even an empty one, so this
+// distinction is preserved automatically.
+func (c *DataContainer) MarshalJSON() ([]byte, error) {
+	if c.DataArray != nil {
+		return marshalJSON(c.DataArray)
+	}
+
+	return marshalJSON(c.DataObject)
+}
+
+// Types returns the distinct resource types present in c, in first-seen
+// order. It's useful both for Validate's homogeneity check and for routing
+// decode logic that branches on which type(s) a response actually carries.
+func (c *DataContainer) Types() []string {
+	if c == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var types []string
+	add := func(t string) {
+		if t == "" || seen[t] {
+			return
+		}
+		seen[t] = true
+		types = append(types, t)
+	}
+
+	if c.DataObject != nil {
+		add(c.DataObject.Type)
+	}
+	for _, data := range c.DataArray {
+		add(data.Type)
+	}
+
+	return types
+}
+
+// Clone returns a deep copy of c. A nil c clones to nil.
+func (c *DataContainer) Clone() *DataContainer {
+	if c == nil {
+		return nil
+	}
+
+	clone := &DataContainer{}
+	if c.DataObject != nil {
+		obj := c.DataObject.Clone()
+		clone.DataObject = &obj
+	}
+	if c.DataArray != nil {
+		clone.DataArray = make([]Data, len(c.DataArray))
+		for i, data := range c.DataArray {
+			clone.DataArray[i] = data.Clone()
+		}
+	}
+
+	return clone
+}
+
+// Well-known Links keys, used as keys into Links.Objects.
+const (
+	LinkSelf        = "self"
+	LinkRelated     = "related"
+	LinkFirst       = "first"
+	LinkPrev        = "prev"
+	LinkNext        = "next"
+	LinkLast        = "last"
+	LinkDescribedBy = "describedby"
+)
+
+var wellKnownLinkKeys = []string{LinkSelf, LinkRelated, LinkFirst, LinkPrev, LinkNext, LinkLast}
+
+// Links is a general struct for document links and relationship links. Self,
+// Related, First, Previous, Next and Last hold the common bare-href form of
+// each well-known link. Objects holds the richer JSON:API 1.1 link-object
+// form (rel, title, type, hreflang, meta, describedby) for any key that
+// needs it, including keys outside the well-known set; Objects["self"].Href
+// takes precedence over the Self field when both are set.
+type Links struct {
+	Self     string `json:"-"`
+	Related  string `json:"-"`
+	First    string `json:"-"`
+	Previous string `json:"-"`
+	Next     string `json:"-"`
+	Last     string `json:"-"`
+
+	Objects map[string]Link `json:"-"`
+}
+
+// hrefField returns a pointer to the plain-href field backing key, or nil for
+// a key outside the well-known set.
+func (l *Links) hrefField(key string) *string {
+	switch key {
+	case LinkSelf:
+		return &l.Self
+	case LinkRelated:
+		return &l.Related
+	case LinkFirst:
+		return &l.First
+	case LinkPrev:
+		return &l.Previous
+	case LinkNext:
+		return &l.Next
+	case LinkLast:
+		return &l.Last
+	default:
+		return nil
+	}
+}
+
+// LinkTransformer rewrites a single link's href before it's serialized,
+// given the well-known relation it was found under (LinkSelf, LinkRelated,
+// LinkFirst, LinkPrev, LinkNext or LinkLast) or, for a link recorded under
+// Objects, that entry's own key. See WithLinkTransformer for applying one
+// during Marshal, or Transform to apply one to a Links built some other
+// way, e.g. by BuildLinks or BuildRelationshipLinks.
+type LinkTransformer func(rel string, href string) string
+
+// Transform rewrites every non-empty href in l via tf, in place, covering
+// both the well-known fields and every Objects entry. It's a no-op on a nil
+// Links or a nil tf.
+func (l *Links) Transform(tf LinkTransformer) {
+	if l == nil || tf == nil {
+		return
+	}
+
+	for _, key := range wellKnownLinkKeys {
+		field := l.hrefField(key)
+		if *field != "" {
+			*field = tf(key, *field)
+		}
+	}
+	for key, obj := range l.Objects {
+		if obj.Href != "" {
+			obj.Href = tf(key, obj.Href)
+			l.Objects[key] = obj
+		}
+	}
+}
+
+// MarshalJSON returns the JSON encoding of Links as a links object, one
+// member per non-empty well-known field or Objects entry. An Objects entry
+// set to NullLink encodes as JSON null rather than an href or link object,
+// for a link a client distinguishes as explicitly absent rather than just
+// omitted.
+func (l Links) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+
+	for _, key := range wellKnownLinkKeys {
+		href := *l.hrefField(key)
+		if obj, ok := l.Objects[key]; ok {
+			if obj.explicitNull {
+				out[key] = nil
+				continue
+			}
+			if obj.Href == "" {
+				obj.Href = href
+			}
+			out[key] = obj
+		} else if href != "" {
+			out[key] = Link{Href: href}
+		}
+	}
+
+	for key, obj := range l.Objects {
+		if _, ok := out[key]; ok {
+			continue
+		}
+		if obj.explicitNull {
+			out[key] = nil
+			continue
+		}
+		out[key] = obj
+	}
+
+	if len(out) == 0 {
+		return []byte("null"), nil
+	}
+
+	return marshalJSON(out)
+}
+
+// linksKeyAliases maps a tolerated non-conformant key to the well-known key
+// it's treated as on unmarshal, for interop with servers that emit a key
+// spelled slightly differently than the spec. Links is always marshaled
+// back out using the canonical spelling.
+var linksKeyAliases = map[string]string{
+	"previous": LinkPrev,
+}
+
+// UnmarshalJSON unmarshals a links object into l. Each well-known key's href
+// lands on the matching field; any key carrying more than a bare href (or
+// any non-well-known key) is also recorded in Objects. A key listed in
+// linksKeyAliases is treated as its well-known counterpart instead of being
+// recorded under its own name.
+func (l *Links) UnmarshalJSON(payload []byte) error {
+	var raw map[string]Link
+	if err := unmarshalJSON(payload, &raw); err != nil {
+		return err
+	}
+
+	for key, obj := range raw {
+		if alias, ok := linksKeyAliases[key]; ok {
+			key = alias
+		}
+
+		field := l.hrefField(key)
+		if field != nil && !obj.explicitNull {
+			*field = obj.Href
+		}
+
+		if field == nil || obj.explicitNull || obj.Rel != "" || obj.DescribedBy != "" || obj.Title != "" || obj.Type != "" || obj.Hreflang != "" || obj.Meta != nil {
+			if l.Objects == nil {
+				l.Objects = map[string]Link{}
+			}
+			l.Objects[key] = obj
+		}
+	}
+
+	return nil
+}
+
+// Clone returns a deep copy of l. A nil l clones to nil.
+func (l *Links) Clone() *Links {
+	if l == nil {
+		return nil
+	}
+
+	clone := *l
+	if l.Objects != nil {
+		clone.Objects = make(map[string]Link, len(l.Objects))
+		for key, obj := range l.Objects {
+			clone.Objects[key] = obj.clone()
+		}
+	}
+
+	return &clone
+}
+
+// DescribedBy returns the href of l's "describedby" link, the JSON:API 1.1
+// reserved link pointing at a schema describing the linked resource. It
+// returns "" if l is nil or has no describedby link.
+func (l *Links) DescribedBy() string {
+	if l == nil {
+		return ""
+	}
+	return l.Objects[LinkDescribedBy].Href
+}
+
+// pageLink returns the href of l's link-object key, if present and
+// non-empty, preferring the richer JSON:API 1.1 link-object form over the
+// bare-href field when l carries both. It reports false for a nil l or one
+// with no such link.
+func (l *Links) pageLink(key string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	if obj, ok := l.Objects[key]; ok && obj.Href != "" {
+		return obj.Href, true
+	}
+	href := *l.hrefField(key)
+	return href, href != ""
+}
+
+// NextPage returns the href of l's "next" pagination link and whether it's
+// present, for a client following a collection response page by page.
+func (l *Links) NextPage() (string, bool) {
+	return l.pageLink(LinkNext)
+}
+
+// PrevPage returns the href of l's "prev" pagination link and whether it's
+// present.
+func (l *Links) PrevPage() (string, bool) {
+	return l.pageLink(LinkPrev)
+}
+
+// FirstPage returns the href of l's "first" pagination link and whether
+// it's present.
+func (l *Links) FirstPage() (string, bool) {
+	return l.pageLink(LinkFirst)
+}
+
+// LastPage returns the href of l's "last" pagination link and whether it's
+// present.
+func (l *Links) LastPage() (string, bool) {
+	return l.pageLink(LinkLast)
 }
 
-// A DataContainer is used to marshal and unmarshal single objects and arrays
-// of objects.
-type DataContainer struct {
-	DataObject *Data
-	DataArray  []Data
+// IsEmpty reports whether l carries no links at all: a nil l, or a non-nil
+// l with every well-known field empty and no Objects entries. Callers that
+// allocate a Links up front (e.g. to fill in conditionally) can use this to
+// avoid emitting a bare "links":null.
+func (l *Links) IsEmpty() bool {
+	if l == nil {
+		return true
+	}
+	return l.Self == "" && l.Related == "" && l.First == "" && l.Previous == "" &&
+		l.Next == "" && l.Last == "" && len(l.Objects) == 0
 }
 
-// UnmarshalJSON unmarshals the JSON-encoded data to the DataObject field if the
-// root element is an object or to the DataArray field for arrays.
-func (c *DataContainer) UnmarshalJSON(payload []byte) error {
-	if bytes.HasPrefix(payload, objectSuffix) {
-		return json.Unmarshal(payload, &c.DataObject)
+// LinkContext identifies where a Links value appears in a document, since
+// the well-known link keys a given context may carry differ: a resource
+// object's own "links" only ever carries "self", while a relationship's may
+// carry "self", "related" and to-many pagination links, and the document's
+// top-level "links" may carry all of those plus "describedby".
+type LinkContext int
+
+const (
+	// DocumentLinkContext is a document's top-level "links" member.
+	DocumentLinkContext LinkContext = iota
+	// ResourceLinkContext is a resource object's own "links" member.
+	ResourceLinkContext
+	// RelationshipLinkContext is a relationship object's "links" member.
+	RelationshipLinkContext
+)
+
+// linkContextAllowedKeys maps each LinkContext to the well-known keys that
+// make sense there.
+var linkContextAllowedKeys = map[LinkContext]map[string]bool{
+	DocumentLinkContext: {
+		LinkSelf: true, LinkRelated: true, LinkDescribedBy: true,
+		LinkFirst: true, LinkPrev: true, LinkNext: true, LinkLast: true,
+	},
+	ResourceLinkContext: {
+		LinkSelf: true,
+	},
+	RelationshipLinkContext: {
+		LinkSelf: true, LinkRelated: true,
+		LinkFirst: true, LinkPrev: true, LinkNext: true, LinkLast: true,
+	},
+}
+
+// has reports whether l carries key, either as a bare href or as an Objects
+// entry.
+func (l *Links) has(key string) bool {
+	if *l.hrefField(key) != "" {
+		return true
+	}
+	_, ok := l.Objects[key]
+	return ok
+}
+
+// ValidateContext reports the well-known keys l carries that don't belong
+// in ctx, e.g. a document's top-level Links carrying "related" without a
+// "self" makes sense, but a resource object's own Links carrying "first"
+// pagination links does not. It returns nil if every well-known key l
+// carries belongs in ctx.
+func (l *Links) ValidateContext(ctx LinkContext) []string {
+	if l == nil {
+		return nil
 	}
 
-	if bytes.HasPrefix(payload, arraySuffix) {
-		return json.Unmarshal(payload, &c.DataArray)
+	allowed := linkContextAllowedKeys[ctx]
+	var bad []string
+	for _, key := range wellKnownLinkKeys {
+		if l.has(key) && !allowed[key] {
+			bad = append(bad, key)
+		}
 	}
+	return bad
+}
+
+// Link is a single JSON:API link. The spec allows a link to be encoded
+// either as a bare string, or as an object carrying href plus rel,
+// describedby, title, type, hreflang and meta. MarshalJSON/UnmarshalJSON
+// accept and produce both forms.
+type Link struct {
+	Href        string                 `json:"href"`
+	Rel         string                 `json:"rel,omitempty"`
+	DescribedBy string                 `json:"describedby,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Hreflang    string                 `json:"hreflang,omitempty"`
+	Meta        map[string]interface{} `json:"meta,omitempty"`
 
-	return errors.New("expected a JSON encoded object or array")
+	explicitNull bool
 }
 
-// MarshalJSON returns the JSON encoding of the DataArray field or the DataObject
-// field. It will return "null" if neither of them is set.
-func (c *DataContainer) MarshalJSON() ([]byte, error) {
-	if c.DataArray != nil {
-		return json.Marshal(c.DataArray)
+// NullLink is a sentinel Link value meaning a link key is explicitly
+// present but null, distinct from a key that's simply omitted. Assign it to
+// a Links.Objects entry to encode that key's value as JSON null rather than
+// leaving it out, for a client that distinguishes "this link is
+// deliberately absent" from "no opinion either way". Unmarshaling a JSON
+// null link produces NullLink.
+var NullLink = Link{explicitNull: true}
+
+// IsNull reports whether l is NullLink, an explicit JSON null rather than a
+// link carrying an href.
+func (l Link) IsNull() bool {
+	return l.explicitNull
+}
+
+// UnmarshalJSON accepts a bare string, a link object, or JSON null, the
+// last of which unmarshals to NullLink.
+func (l *Link) UnmarshalJSON(payload []byte) error {
+	trimmed := bytes.TrimSpace(payload)
+	if bytes.Equal(trimmed, []byte("null")) {
+		*l = NullLink
+		return nil
+	}
+	if bytes.HasPrefix(trimmed, []byte(`"`)) {
+		return unmarshalJSON(payload, &l.Href)
 	}
 
-	return json.Marshal(c.DataObject)
+	type alias Link
+	return unmarshalJSON(payload, (*alias)(l))
 }
 
-// Links is a general struct for document links and relationship links.
-type Links struct {
-	Self     string `json:"self,omitempty"`
-	Related  string `json:"related,omitempty"`
-	First    string `json:"first,omitempty"`
-	Previous string `json:"prev,omitempty"`
-	Next     string `json:"next,omitempty"`
-	Last     string `json:"last,omitempty"`
+// MarshalJSON encodes the Link as JSON null for NullLink, a bare string when
+// only Href is set, or as a link object when any other member is populated.
+func (l Link) MarshalJSON() ([]byte, error) {
+	if l.explicitNull {
+		return []byte("null"), nil
+	}
+	if l.Rel == "" && l.DescribedBy == "" && l.Title == "" && l.Type == "" && l.Hreflang == "" && l.Meta == nil {
+		return marshalJSON(l.Href)
+	}
+
+	type alias Link
+	return marshalJSON(alias(l))
 }
 
-// Data is a general struct for document data and included data.
+// clone returns a deep copy of l.
+func (l Link) clone() Link {
+	clone := l
+	clone.Meta = cloneMeta(l.Meta)
+	return clone
+}
+
+// Data is a general struct for document data and included data. A resource
+// that has not yet been assigned a server id (e.g. the target of an Atomic
+// Operations "add") may carry a client-generated LID instead of an ID.
 type Data struct {
 	Type          string                  `json:"type"`
-	ID            string                  `json:"id"`
+	ID            string                  `json:"id,omitempty"`
+	LID           string                  `json:"lid,omitempty"`
+	Attributes    json.RawMessage         `json:"attributes"`
+	Relationships map[string]Relationship `json:"relationships,omitempty"`
+	Links         *Links                  `json:"links,omitempty"`
+	Meta          map[string]interface{}  `json:"meta,omitempty"`
+
+	// IDPresent reports whether UnmarshalJSON found an "id" member at all,
+	// even an empty string, so a caller validating a create-vs-update
+	// request can tell `"id":""` -- almost always invalid -- apart from no
+	// "id" key -- valid on a create, where the server assigns the id. It's
+	// left false on a Data built by hand rather than decoded.
+	IDPresent bool `json:"-"`
+}
+
+// lenientIDs opts Data's UnmarshalJSON into coercing a numeric "id" into its
+// string form, for servers that erroneously serialize ids as JSON numbers.
+// It's off by default: a non-conformant "id" is an error unless a caller
+// explicitly asks to tolerate it.
+var lenientIDs bool
+
+// SetLenientIDs enables or disables tolerance for a numeric "id" on an
+// incoming resource object. The marshaled form is unaffected; Data.ID is
+// always written back out as a JSON string.
+func SetLenientIDs(enabled bool) {
+	lenientIDs = enabled
+}
+
+// dataAlias mirrors Data's JSON shape with ID left as a json.RawMessage, so
+// UnmarshalJSON can tell a spec-conformant string id apart from a numeric
+// one before deciding whether to tolerate it.
+type dataAlias struct {
+	Type          string                  `json:"type"`
+	ID            json.RawMessage         `json:"id,omitempty"`
+	LID           string                  `json:"lid,omitempty"`
 	Attributes    json.RawMessage         `json:"attributes"`
 	Relationships map[string]Relationship `json:"relationships,omitempty"`
 	Links         *Links                  `json:"links,omitempty"`
+	Meta          map[string]interface{}  `json:"meta,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of the resource object, omitting
+// "attributes" when d.Attributes is unset. This lets a Data built with no
+// attributes serve as a bare resource identifier object, e.g. relationship
+// linkage built by MarshalRelationship. "relationships" is likewise omitted
+// whenever d.Relationships is empty, whether it's nil or an allocated-but-
+// empty map (omitempty on a map treats both as absent) -- a caller that
+// builds up d.Relationships conditionally doesn't need to nil it back out
+// to avoid emitting a noisy "relationships":{}.
+func (d Data) MarshalJSON() ([]byte, error) {
+	type alias struct {
+		Type          string                  `json:"type"`
+		ID            string                  `json:"id,omitempty"`
+		LID           string                  `json:"lid,omitempty"`
+		Attributes    json.RawMessage         `json:"attributes,omitempty"`
+		Relationships map[string]Relationship `json:"relationships,omitempty"`
+		Links         *Links                  `json:"links,omitempty"`
+		Meta          map[string]interface{}  `json:"meta,omitempty"`
+	}
+
+	return marshalJSON(alias{
+		Type:          d.Type,
+		ID:            d.ID,
+		LID:           d.LID,
+		Attributes:    d.Attributes,
+		Relationships: d.Relationships,
+		Links:         d.Links,
+		Meta:          d.Meta,
+	})
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded resource object into d. A
+// numeric "id" is rejected unless SetLenientIDs(true) has been called, in
+// which case it is coerced into its string form.
+func (d *Data) UnmarshalJSON(payload []byte) error {
+	var a dataAlias
+	if err := unmarshalJSON(payload, &a); err != nil {
+		return err
+	}
+
+	d.Type = a.Type
+	d.LID = a.LID
+	d.Attributes = a.Attributes
+	d.Relationships = a.Relationships
+	d.Links = a.Links
+	d.Meta = a.Meta
+	d.ID = ""
+	d.IDPresent = len(a.ID) > 0
+
+	if len(a.ID) == 0 {
+		return nil
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(a.ID), []byte(`"`)) {
+		return unmarshalJSON(a.ID, &d.ID)
+	}
+
+	if !lenientIDs {
+		return fmt.Errorf("jsonapi: resource id must be a string, got %s", a.ID)
+	}
+
+	var num json.Number
+	if err := unmarshalJSON(a.ID, &num); err != nil {
+		return fmt.Errorf("jsonapi: resource id must be a string or number, got %s", a.ID)
+	}
+	d.ID = num.String()
+
+	return nil
+}
+
+// NewDataRaw builds a resource object from pre-serialized attribute bytes,
+// for gateways and proxies that forward an upstream resource's attributes
+// untouched rather than decoding them into a Go struct and re-encoding
+// them. attrs must be empty or a JSON object; anything else, including a
+// JSON array or scalar, is rejected.
+func NewDataRaw(typ, id string, attrs json.RawMessage) (*Data, error) {
+	if len(attrs) > 0 {
+		var obj map[string]json.RawMessage
+		if err := unmarshalJSON(attrs, &obj); err != nil {
+			return nil, fmt.Errorf("jsonapi: attributes must be a JSON object: %w", err)
+		}
+	}
+
+	return &Data{Type: typ, ID: id, Attributes: attrs}, nil
+}
+
+// DecodeOption configures UnmarshalAttributes, AttributesMap and
+// DecodeMeta.
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	useNumber       bool
+	disallowUnknown bool
+	maxDepth        int
+}
+
+// defaultMaxDecodeDepth bounds the object/array nesting depth decodeJSON
+// accepts when no MaxDepth option is given -- generous enough for any
+// legitimate payload, but enough to stop a maliciously deep document from
+// exhausting the stack or heap before a caller-supplied schema ever gets a
+// chance to reject it.
+const defaultMaxDecodeDepth = 10000
+
+// MaxDepth caps the object/array nesting depth decodeJSON accepts, for a
+// server decoding AttributesMap or DecodeMeta from an untrusted payload
+// whose schema it doesn't control. Decoding a payload nested deeper than n
+// fails with an error instead of recursing further. Without this option,
+// defaultMaxDecodeDepth applies.
+func MaxDepth(n int) DecodeOption {
+	return func(o *decodeOptions) { o.maxDepth = n }
+}
+
+// UseNumber decodes JSON numbers as json.Number instead of float64,
+// preserving integer precision above 2^53 (e.g. a large numeric id or
+// attribute) that would otherwise be mangled by a float64 round trip.
+func UseNumber() DecodeOption {
+	return func(o *decodeOptions) { o.useNumber = true }
+}
+
+// StrictUnknownFields opts into rejecting any JSON member with no matching
+// field on the destination struct, via json.Decoder.DisallowUnknownFields,
+// instead of encoding/json's default of silently ignoring it. This is most
+// useful on a write endpoint's UnmarshalAttributes call, where a typo'd or
+// disallowed attribute name should fail the request rather than be dropped.
+func StrictUnknownFields() DecodeOption {
+	return func(o *decodeOptions) { o.disallowUnknown = true }
+}
+
+// decodeJSON decodes raw into v, applying opts.
+func decodeJSON(raw []byte, v interface{}, opts ...DecodeOption) error {
+	var o decodeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	maxDepth := o.maxDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxDecodeDepth
+	}
+	if maxDepth > 0 {
+		if err := checkJSONDepth(raw, maxDepth); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if o.useNumber {
+		dec.UseNumber()
+	}
+	if o.disallowUnknown {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// checkJSONDepth returns an error if raw contains an object or array nested
+// deeper than max, without otherwise validating or decoding raw -- a cheap
+// pre-flight a caller can run before handing an untrusted payload to
+// encoding/json, whose own recursive decode would rather use the stack than
+// report an error.
+func checkJSONDepth(raw []byte, max int) error {
+	depth := 0
+	inString := false
+	escaped := false
+	for _, b := range raw {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > max {
+				return fmt.Errorf("jsonapi: JSON nested deeper than %d levels", max)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// UnmarshalAttributes decodes d.Attributes into v, sparing callers who
+// already know the concrete attribute shape from having to deal with the
+// raw json.Attributes message themselves.
+func (d Data) UnmarshalAttributes(v interface{}, opts ...DecodeOption) error {
+	return decodeJSON(d.Attributes, v, opts...)
+}
+
+// AttributesMap decodes d.Attributes into a map[string]interface{}, for
+// callers that don't know the resource's schema ahead of time, e.g. generic
+// tooling or a proxy. A nil or empty Attributes decodes to an empty map
+// rather than nil.
+func (d Data) AttributesMap(opts ...DecodeOption) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if len(d.Attributes) == 0 {
+		return m, nil
+	}
+	if err := decodeJSON(d.Attributes, &m, opts...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetAttributesMap encodes m as d.Attributes, the counterpart to
+// AttributesMap for callers building a Data from a generic map.
+func (d *Data) SetAttributesMap(m map[string]interface{}) error {
+	raw, err := marshalJSON(m)
+	if err != nil {
+		return err
+	}
+	d.Attributes = raw
+	return nil
+}
+
+// MergeAttributes decodes d.Attributes into a map, applies patch on top of
+// it -- a key set to nil deletes it, any other value sets or overwrites it
+// -- and re-encodes the result back into d.Attributes. This lets a proxy
+// apply a partial server-side mutation to a resource's attributes without
+// knowing their schema well enough to decode them into a typed struct.
+func (d *Data) MergeAttributes(patch map[string]interface{}) error {
+	m, err := d.AttributesMap()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(m, k)
+			continue
+		}
+		m[k] = v
+	}
+
+	return d.SetAttributesMap(m)
+}
+
+// DecodeMeta decodes m, a Document/Data/Relationship Meta map, into v,
+// sparing callers who already know the concrete meta shape (e.g.
+// `{"total": 42}`) from type-asserting every value by hand. A nil m leaves v
+// untouched and returns nil.
+func DecodeMeta(m map[string]interface{}, v interface{}, opts ...DecodeOption) error {
+	if m == nil {
+		return nil
+	}
+
+	raw, err := marshalJSON(m)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(raw, v, opts...)
+}
+
+// Clone returns a deep copy of d, including its Attributes, Relationships,
+// Links and Meta.
+func (d Data) Clone() Data {
+	clone := d
+	clone.Attributes = append(json.RawMessage(nil), d.Attributes...)
+	clone.Links = d.Links.Clone()
+	clone.Meta = cloneMeta(d.Meta)
+	if d.Relationships != nil {
+		clone.Relationships = make(map[string]Relationship, len(d.Relationships))
+		for key, rel := range d.Relationships {
+			clone.Relationships[key] = rel.Clone()
+		}
+	}
+	return clone
+}
+
+// SelfLink returns the canonical location of d under baseURL:
+// "<baseURL>/<type>/<id>", tolerating a trailing slash on baseURL. It
+// returns "" if d has no Type or no ID, since neither half of the path can
+// be built.
+func (d *Data) SelfLink(baseURL string) string {
+	if d.Type == "" || d.ID == "" {
+		return ""
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + d.Type + "/" + d.ID
+}
+
+// EnsureSelfLink populates d.Links.Self with d.SelfLink(baseURL) if it isn't
+// already set, creating d.Links if necessary. It does nothing if SelfLink
+// can't build a link (d has no Type or ID) or Links.Self is already set.
+func (d *Data) EnsureSelfLink(baseURL string) {
+	if d.Links != nil && d.Links.Self != "" {
+		return
+	}
+
+	link := d.SelfLink(baseURL)
+	if link == "" {
+		return
+	}
+
+	if d.Links == nil {
+		d.Links = &Links{}
+	}
+	d.Links.Self = link
 }
 
-// Relationship contains reference IDs to the related structs
+// Relationship contains reference IDs to the related structs. ExplicitNull
+// is set when the source JSON carried a "data" member whose value was
+// literally null, which the spec uses to mark a to-one relationship known
+// to be empty; that is distinct from "data" being absent entirely, which
+// means the relationship wasn't reported at all.
 type Relationship struct {
-	Links *Links                     `json:"links,omitempty"`
-	Data  *RelationshipDataContainer `json:"data,omitempty"`
-	Meta  map[string]interface{}     `json:"meta,omitempty"`
+	Links        *Links                     `json:"links,omitempty"`
+	Data         *RelationshipDataContainer `json:"data,omitempty"`
+	Meta         map[string]interface{}     `json:"meta,omitempty"`
+	ExplicitNull bool                       `json:"-"`
+}
+
+// relationshipAlias mirrors Relationship's JSON shape with Data left as a
+// json.RawMessage, so MarshalJSON/UnmarshalJSON can tell an explicit
+// "data":null apart from the member being absent.
+type relationshipAlias struct {
+	Links *Links                 `json:"links,omitempty"`
+	Data  json.RawMessage        `json:"data,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of the relationship object. An
+// ExplicitNull relationship is encoded with "data":null rather than
+// omitting the member.
+func (r Relationship) MarshalJSON() ([]byte, error) {
+	a := relationshipAlias{Links: r.Links, Meta: r.Meta}
+
+	switch {
+	case r.ExplicitNull:
+		a.Data = json.RawMessage("null")
+	case r.Data != nil:
+		raw, err := marshalJSON(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		a.Data = raw
+	}
+
+	return marshalJSON(a)
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded relationship object into r,
+// setting ExplicitNull when "data" was present as a literal null rather
+// than an object or array.
+func (r *Relationship) UnmarshalJSON(payload []byte) error {
+	var a relationshipAlias
+	if err := unmarshalJSON(payload, &a); err != nil {
+		return err
+	}
+
+	r.Links = a.Links
+	r.Meta = a.Meta
+	r.Data = nil
+	r.ExplicitNull = false
+
+	switch {
+	case a.Data == nil:
+		// "data" member absent: relationship not reported.
+	case string(bytes.TrimSpace(a.Data)) == "null":
+		r.ExplicitNull = true
+	default:
+		var container RelationshipDataContainer
+		if err := unmarshalJSON(a.Data, &container); err != nil {
+			return err
+		}
+		r.Data = &container
+	}
+
+	return nil
+}
+
+// Clone returns a deep copy of r.
+func (r Relationship) Clone() Relationship {
+	clone := r
+	clone.Links = r.Links.Clone()
+	clone.Meta = cloneMeta(r.Meta)
+	clone.Data = r.Data.Clone()
+	return clone
+}
+
+// Count reads a to-many relationship's size from whichever conventional
+// meta location a server used to report it without sending the full
+// linkage array: r.Meta["count"] first, falling back to the "count" meta
+// on r's related link (see RelatedCount). It reports false if neither is
+// present.
+func (r Relationship) Count() (int, bool) {
+	if count, ok := numericMeta(r.Meta, "count"); ok {
+		return count, true
+	}
+	return r.RelatedCount()
+}
+
+// numericMeta reads key out of meta as an int, accepting either a float64
+// (the shape json.Unmarshal produces into interface{}) or an int (the
+// shape a caller building meta by hand is likely to use).
+func numericMeta(meta map[string]interface{}, key string) (int, bool) {
+	switch count := meta[key].(type) {
+	case float64:
+		return int(count), true
+	case int:
+		return count, true
+	default:
+		return 0, false
+	}
+}
+
+// RelatedCount reads the "count" meta value off r's related link — the
+// common pattern a to-many relationship uses to summarize its size without
+// requiring the client to fetch or count the related data, e.g.
+// `{"related": {"href": "...", "meta": {"count": 10}}}`. It reports false if
+// r has no related link, or the related link's meta has no numeric "count".
+func (r Relationship) RelatedCount() (int, bool) {
+	if r.Links == nil {
+		return 0, false
+	}
+
+	related, ok := r.Links.Objects[LinkRelated]
+	if !ok {
+		return 0, false
+	}
+
+	return numericMeta(related.Meta, "count")
 }
 
 // A RelationshipDataContainer is used to marshal and unmarshal single relationship
@@ -87,14 +1724,22 @@ type RelationshipDataContainer struct {
 // UnmarshalJSON unmarshals the JSON-encoded data to the DataObject field if the
 // root element is an object or to the DataArray field for arrays.
 func (c *RelationshipDataContainer) UnmarshalJSON(payload []byte) error {
-	if bytes.HasPrefix(payload, objectSuffix) {
+	trimmed := bytes.TrimLeft(payload, " \t\r\n")
+
+	if bytes.HasPrefix(trimmed, objectSuffix) {
 		// payload is an object
-		return json.Unmarshal(payload, &c.DataObject)
+		return unmarshalJSON(payload, &c.DataObject)
 	}
 
-	if bytes.HasPrefix(payload, arraySuffix) {
+	if bytes.HasPrefix(trimmed, arraySuffix) {
 		// payload is an array
-		return json.Unmarshal(payload, &c.DataArray)
+		return unmarshalJSON(payload, &c.DataArray)
+	}
+
+	if bytes.Equal(trimmed, nullLiteral) {
+		c.DataObject = nil
+		c.DataArray = nil
+		return nil
 	}
 
 	return errors.New("Invalid json for relationship data array/object")
@@ -104,20 +1749,196 @@ func (c *RelationshipDataContainer) UnmarshalJSON(payload []byte) error {
 // field. It will return "null" if neither of them is set.
 func (c *RelationshipDataContainer) MarshalJSON() ([]byte, error) {
 	if c.DataArray != nil {
-		return json.Marshal(c.DataArray)
+		return marshalJSON(c.DataArray)
+	}
+	return marshalJSON(c.DataObject)
+}
+
+// Clone returns a deep copy of c. A nil c clones to nil.
+func (c *RelationshipDataContainer) Clone() *RelationshipDataContainer {
+	if c == nil {
+		return nil
+	}
+
+	clone := &RelationshipDataContainer{}
+	if c.DataObject != nil {
+		obj := *c.DataObject
+		clone.DataObject = &obj
+	}
+	if c.DataArray != nil {
+		clone.DataArray = append([]RelationshipData(nil), c.DataArray...)
 	}
-	return json.Marshal(c.DataObject)
+
+	return clone
 }
 
-// RelationshipData represents one specific reference ID.
+// RelationshipData represents one specific reference ID. A relationship that
+// points at a resource not yet assigned a server id may carry a
+// client-generated LID instead of an ID.
 type RelationshipData struct {
-	Type string `json:"type"`
-	ID   string `json:"id"`
+	Type string                 `json:"type"`
+	ID   string                 `json:"id,omitempty"`
+	LID  string                 `json:"lid,omitempty"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// NewToManyRelationship builds a to-many Relationship directly from
+// resource identifiers, for handlers that already have type/id pairs on
+// hand and don't need to round-trip through Marshal's tagged-struct walk.
+// Its "data" always serializes as a JSON array, even for exactly one id —
+// cardinality in JSON:API is carried by array-vs-object, not by count, so a
+// single-element to-many must stay "data":[{...}] and never collapse to
+// "data":{...}; use NewToOneRelationship for a relationship that is
+// genuinely to-one. Calling it with no ids serializes as "data":null rather
+// than "data":[], since a variadic call with no arguments passes a nil
+// slice; use NewEmptyToMany to build an empty to-many relationship
+// explicitly.
+func NewToManyRelationship(ids ...RelationshipData) Relationship {
+	return Relationship{Data: &RelationshipDataContainer{DataArray: ids}}
+}
+
+// NewToOneRelationship builds a to-one Relationship directly from a single
+// resource identifier. Its "data" always serializes as a JSON object, never
+// an array — the counterpart distinction to NewToManyRelationship, whose
+// "data" is always an array even with one id.
+func NewToOneRelationship(id RelationshipData) Relationship {
+	return Relationship{Data: &RelationshipDataContainer{DataObject: &id}}
+}
+
+// NewEmptyToMany builds a to-many Relationship with an explicit empty
+// array ("data":[]) instead of null, for a to-many relationship known to
+// have no related resources right now — distinct from ExplicitNull or an
+// absent Data, both of which mean "no linkage at all" rather than "an
+// empty collection of linkage."
+func NewEmptyToMany() Relationship {
+	return Relationship{Data: &RelationshipDataContainer{DataArray: []RelationshipData{}}}
+}
+
+// MarshalRelationship builds a *Document whose data is rel's linkage — the
+// bare resource identifier(s), with no attributes — plus rel's own links
+// and meta. This is the response shape a relationship endpoint (e.g.
+// GET /articles/1/relationships/author) must produce, distinct from the
+// full resource documents Marshal builds.
+func MarshalRelationship(rel Relationship) (*Document, error) {
+	doc := &Document{Links: rel.Links, Meta: rel.Meta}
+
+	if rel.Data == nil {
+		return doc, nil
+	}
+
+	container := &DataContainer{}
+	if rel.Data.DataObject != nil {
+		container.DataObject = &Data{
+			Type: rel.Data.DataObject.Type,
+			ID:   rel.Data.DataObject.ID,
+			LID:  rel.Data.DataObject.LID,
+		}
+	}
+	if rel.Data.DataArray != nil {
+		container.DataArray = make([]Data, len(rel.Data.DataArray))
+		for i, rd := range rel.Data.DataArray {
+			container.DataArray[i] = Data{Type: rd.Type, ID: rd.ID, LID: rd.LID}
+		}
+	}
+	doc.Data = container
+
+	return doc, nil
+}
+
+// NewIdentifierDocument builds a *Document whose "data" is exactly one
+// resource identifier — id, reduced to its type/id/lid — with no
+// attributes or relationships, the shape a to-one relationship endpoint's
+// response requires.
+func NewIdentifierDocument(id RelationshipData) *Document {
+	return &Document{Data: &DataContainer{DataObject: &Data{Type: id.Type, ID: id.ID, LID: id.LID}}}
+}
+
+// NewIdentifierCollectionDocument builds a *Document whose "data" is
+// exactly ids, each reduced to its type/id/lid, the shape a to-many
+// relationship endpoint's response requires. Passing no ids produces an
+// empty "data" array rather than null, matching an empty to-many
+// relationship.
+func NewIdentifierCollectionDocument(ids ...RelationshipData) *Document {
+	arr := make([]Data, len(ids))
+	for i, id := range ids {
+		arr[i] = Data{Type: id.Type, ID: id.ID, LID: id.LID}
+	}
+	return &Document{Data: &DataContainer{DataArray: arr}}
+}
+
+// MarshalIdentifiers builds a *Document whose "data" is an array of
+// resource identifiers, one per id, all sharing typ — the exact body a
+// client POSTs or DELETEs to a to-many relationship endpoint to add or
+// remove members. It's a convenience over NewIdentifierCollectionDocument
+// for the common case where every identifier shares the same type and
+// none of them needs a lid.
+func MarshalIdentifiers(typ string, ids ...string) *Document {
+	rds := make([]RelationshipData, len(ids))
+	for i, id := range ids {
+		rds[i] = RelationshipData{Type: typ, ID: id}
+	}
+	return NewIdentifierCollectionDocument(rds...)
+}
+
+// IsIdentifierDocument reports whether doc's primary data is pure
+// linkage: every resource object in "data" (whether a single object, an
+// array, or null) carries no attributes and no relationships, the shape
+// MarshalRelationship, NewIdentifierDocument and
+// NewIdentifierCollectionDocument all guarantee. This catches a handler
+// that accidentally attaches attributes to a relationship endpoint's
+// response. A document with no data at all (e.g. an errors-only document)
+// counts as an identifier document, since it carries no attributes either.
+func IsIdentifierDocument(doc *Document) bool {
+	if doc == nil || doc.Data == nil {
+		return true
+	}
+
+	if doc.Data.DataObject != nil {
+		return isIdentifierOnly(*doc.Data.DataObject)
+	}
+	for _, d := range doc.Data.DataArray {
+		if !isIdentifierOnly(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentifierOnly reports whether d carries no relationships and no
+// non-empty attributes.
+func isIdentifierOnly(d Data) bool {
+	if len(d.Relationships) > 0 {
+		return false
+	}
+	if len(d.Attributes) == 0 {
+		return true
+	}
+
+	var attrs map[string]json.RawMessage
+	if err := unmarshalJSON(d.Attributes, &attrs); err != nil {
+		return false
+	}
+	return len(attrs) == 0
 }
 
 type CustomObject struct {
 	Fields []string
 	Object interface{}
+
+	// TagName is the struct tag JSONToStruct and MarshalJSON read field
+	// names from. It defaults to "json"; set it to work with models that
+	// key their sparse fields off a non-standard tag, e.g. "jsonapi" or
+	// "db".
+	TagName string
+}
+
+// tagName returns co.TagName, defaulting to "json" for backward
+// compatibility.
+func (co CustomObject) tagName() string {
+	if co.TagName == "" {
+		return "json"
+	}
+	return co.TagName
 }
 
 type FilterFields map[string][]string
@@ -125,46 +1946,186 @@ type FilterFields map[string][]string
 func (f FilterFields) ParseQuery(q url.Values) {
 	rpm := regexp.MustCompile(`(?i)^fields\[([^\]]+)]$`)
 
-	for k, v := range q {
+	for k := range q {
 		matches := rpm.FindStringSubmatch(k)
-		if len(matches) > 0 {
-			f[matches[1]] = strings.Split(strings.Join(v, ","), ",")
+		if len(matches) == 0 {
+			continue
 		}
+
+		f[matches[1]] = splitCSV(q, k)
 	}
 }
 
 type ObjectAttributes map[string]interface{}
 
+// getType returns the reflect.Type backing v, dereferencing a pointer if v
+// is one.
+func getType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// getValue returns the reflect.Value backing v, dereferencing a pointer if v
+// is one.
+func getValue(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// customObjectFieldCacheKey identifies a cached field-name mapping by both
+// the reflected struct type and the tag it was built from, since the same
+// type can be read under different TagName values.
+type customObjectFieldCacheKey struct {
+	t   reflect.Type
+	tag string
+}
+
+// customObjectFieldCache memoizes fieldNamesForTag per type+tag, so
+// marshaling many CustomObjects of the same type doesn't re-walk the
+// struct's fields (and any embedded ones) on every call.
+var customObjectFieldCache sync.Map // customObjectFieldCacheKey -> map[string]string
+
 func (co CustomObject) JSONToStruct() map[string]string {
-	rpm := regexp.MustCompile(`(?i)^([^,]+)(,|$)`)
+	key := customObjectFieldCacheKey{t: getType(co.Object), tag: co.tagName()}
+	if cached, ok := customObjectFieldCache.Load(key); ok {
+		return cached.(map[string]string)
+	}
+
+	fields := fieldNamesForTag(key.t, key.tag)
+	customObjectFieldCache.Store(key, fields)
+	return fields
+}
+
+var tagNameRegexp = regexp.MustCompile(`(?i)^([^,]+)(,|$)`)
+
+// fieldNamesForTag maps each tagName tag value on t to the Go field name
+// that carries it, recursing into anonymous (embedded) struct fields so
+// their promoted fields are included too. A name tagged on t itself wins
+// over one reached through an embedded field.
+func fieldNamesForTag(t reflect.Type, tagName string) map[string]string {
 	res := map[string]string{}
-	ref := getType(co.Object)
 
-	for i := 0; i < ref.NumField(); i++ {
-		f := ref.Field(i)
-		tag, ok := f.Tag.Lookup("json")
-		if ok {
-			matches := rpm.FindStringSubmatch(tag)
-			if len(matches) > 0 && matches[1] != "-" {
-				res[matches[1]] = f.Name
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous {
+			embedded := f.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, fieldName := range fieldNamesForTag(embedded, tagName) {
+					if _, ok := res[name]; !ok {
+						res[name] = fieldName
+					}
+				}
 			}
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		if matches := tagNameRegexp.FindStringSubmatch(tag); len(matches) > 0 && matches[1] != "-" {
+			res[matches[1]] = f.Name
 		}
 	}
+
 	return res
 }
 
+// MarshalJSON returns the JSON encoding of the fields named in co.Fields, in
+// that order. A plain map would sort its keys alphabetically, which loses
+// the caller's intended field order, so the object is assembled by hand
+// instead. A field name may be dotted (e.g. "address.city") to select a
+// field nested inside a struct-valued field, recursing with the remaining
+// path as the nested CustomObject's own Fields.
 func (co CustomObject) MarshalJSON() ([]byte, error) {
-	obj := ObjectAttributes{}
 	dict := co.JSONToStruct()
 	ref := getValue(co.Object)
 
+	var order []string
+	nested := map[string][]string{}
+	isNested := map[string]bool{}
+
 	for _, f := range co.Fields {
-		if dict[f] != "" {
-			obj[f] = ref.FieldByName(dict[f]).Interface()
+		key, rest := f, ""
+		if i := strings.Index(f, "."); i >= 0 {
+			key, rest = f[:i], f[i+1:]
+		}
+		if dict[key] == "" {
+			continue
+		}
+		if _, ok := nested[key]; !ok && !isNested[key] {
+			order = append(order, key)
+		}
+		if rest != "" {
+			nested[key] = append(nested[key], rest)
+			isNested[key] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, key := range order {
+		if i > 0 {
+			buf.WriteByte(',')
 		}
+
+		keyJSON, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+
+		fieldValue := ref.FieldByName(dict[key]).Interface()
+
+		var valJSON []byte
+		if children, ok := nested[key]; ok {
+			valJSON, err = marshalJSON(CustomObject{Fields: children, Object: fieldValue, TagName: co.TagName})
+		} else {
+			valJSON, err = marshalJSON(fieldValue)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(keyJSON)
+		buf.WriteByte(':')
+		buf.Write(valJSON)
 	}
 
-	b, err := json.Marshal(&obj)
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// EffectiveFields returns the subset of co.Fields that MarshalJSON will
+// actually emit, i.e. those whose base name (the part before a dotted
+// nested-field path) resolves to a struct field via co.JSONToStruct.
+// Callers can diff this against co.Fields to report which requested sparse
+// fieldset names, if any, were unknown and therefore ignored.
+func (co CustomObject) EffectiveFields() []string {
+	dict := co.JSONToStruct()
+
+	var effective []string
+	for _, f := range co.Fields {
+		key := f
+		if i := strings.Index(f, "."); i >= 0 {
+			key = f[:i]
+		}
+		if dict[key] == "" {
+			continue
+		}
+		effective = append(effective, f)
+	}
 
-	return b, err
+	return effective
 }
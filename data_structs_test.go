@@ -0,0 +1,2658 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinksMarshalPlainAndRichEntries(t *testing.T) {
+	links := Links{
+		Self: "https://example.com/articles/1",
+		Objects: map[string]Link{
+			LinkRelated: {Href: "https://example.com/articles/1/author", Rel: "author"},
+		},
+	}
+
+	raw, err := json.Marshal(links)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if out["self"] != links.Self {
+		t.Fatalf("expected self to marshal as a bare string, got %#v", out["self"])
+	}
+
+	related, ok := out["related"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected related to marshal as a link object, got %#v", out["related"])
+	}
+	if related["href"] != "https://example.com/articles/1/author" || related["rel"] != "author" {
+		t.Fatalf("unexpected related link object: %#v", related)
+	}
+}
+
+func TestLinksUnmarshalBareStringAndObjectForm(t *testing.T) {
+	raw := []byte(`{
+		"self": "https://example.com/articles/1",
+		"related": {"href": "https://example.com/articles/1/author", "rel": "author"}
+	}`)
+
+	var links Links
+	if err := json.Unmarshal(raw, &links); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if links.Self != "https://example.com/articles/1" {
+		t.Fatalf("unexpected self: %q", links.Self)
+	}
+	if links.Related != "https://example.com/articles/1/author" {
+		t.Fatalf("unexpected related: %q", links.Related)
+	}
+
+	obj, ok := links.Objects[LinkRelated]
+	if !ok {
+		t.Fatal("expected the rich related link to also be recorded in Objects")
+	}
+	if obj.Rel != "author" {
+		t.Fatalf("unexpected related link object: %+v", obj)
+	}
+	if _, ok := links.Objects[LinkSelf]; ok {
+		t.Fatal("expected a bare-string link not to be recorded in Objects")
+	}
+}
+
+func TestLinksUnmarshalAcceptsPrevAndPreviousSpellings(t *testing.T) {
+	for _, key := range []string{"prev", "previous"} {
+		raw := []byte(`{"` + key + `": "https://example.com/articles?page=1"}`)
+
+		var links Links
+		if err := json.Unmarshal(raw, &links); err != nil {
+			t.Fatalf("Unmarshal returned error for key %q: %v", key, err)
+		}
+		if links.Previous != "https://example.com/articles?page=1" {
+			t.Fatalf("unexpected previous for key %q: %q", key, links.Previous)
+		}
+		if _, ok := links.Objects["previous"]; ok {
+			t.Fatalf("expected the aliased key not to be recorded in Objects, got %+v", links.Objects)
+		}
+
+		out, err := json.Marshal(links)
+		if err != nil {
+			t.Fatalf("Marshal returned error: %v", err)
+		}
+		if !strings.Contains(string(out), `"prev":`) || strings.Contains(string(out), `"previous":`) {
+			t.Fatalf("expected marshaling to always use \"prev\", got %s", out)
+		}
+	}
+}
+
+func TestLinksCustomKeyRoundTrip(t *testing.T) {
+	links := Links{
+		Self: "https://example.com/articles/1",
+		Objects: map[string]Link{
+			"describedby": {Href: "https://example.com/schemas/article"},
+		},
+	}
+
+	raw, err := json.Marshal(links)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Links
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if got.Self != links.Self {
+		t.Fatalf("unexpected self: %q", got.Self)
+	}
+	custom, ok := got.Objects["describedby"]
+	if !ok {
+		t.Fatal("expected the custom describedby link to survive the round trip")
+	}
+	if custom.Href != "https://example.com/schemas/article" {
+		t.Fatalf("unexpected describedby link: %+v", custom)
+	}
+	if got := links.DescribedBy(); got != "https://example.com/schemas/article" {
+		t.Fatalf("DescribedBy() = %q, want the schema href", got)
+	}
+}
+
+func TestLinksTransformRewritesWellKnownAndObjectsHrefs(t *testing.T) {
+	links := &Links{
+		Self: "https://api.example.com/articles?page[number]=1",
+		Next: "https://api.example.com/articles?page[number]=2",
+		Objects: map[string]Link{
+			"describedby": {Href: "https://api.example.com/schemas/article"},
+		},
+	}
+
+	links.Transform(func(rel, href string) string {
+		return "https://cdn.example.com" + strings.TrimPrefix(href, "https://api.example.com")
+	})
+
+	if links.Self != "https://cdn.example.com/articles?page[number]=1" {
+		t.Fatalf("unexpected self: %q", links.Self)
+	}
+	if links.Next != "https://cdn.example.com/articles?page[number]=2" {
+		t.Fatalf("unexpected next: %q", links.Next)
+	}
+	if links.Objects["describedby"].Href != "https://cdn.example.com/schemas/article" {
+		t.Fatalf("unexpected describedby: %+v", links.Objects["describedby"])
+	}
+}
+
+func TestLinksTransformLeavesEmptyHrefsAlone(t *testing.T) {
+	links := &Links{Self: "https://api.example.com/articles"}
+
+	calls := 0
+	links.Transform(func(rel, href string) string {
+		calls++
+		return href
+	})
+
+	if calls != 1 {
+		t.Fatalf("expected tf to run only for the non-empty self href, got %d calls", calls)
+	}
+}
+
+func TestLinksTransformOnNilLinksOrNilTransformerIsNoOp(t *testing.T) {
+	var links *Links
+	links.Transform(func(rel, href string) string { return "x" })
+
+	links = &Links{Self: "https://example.com"}
+	links.Transform(nil)
+	if links.Self != "https://example.com" {
+		t.Fatalf("expected a nil transformer to leave links untouched, got %q", links.Self)
+	}
+}
+
+func TestLinksDescribedByOnNilOrEmptyLinks(t *testing.T) {
+	var nilLinks *Links
+	if got := nilLinks.DescribedBy(); got != "" {
+		t.Fatalf("expected empty string for nil Links, got %q", got)
+	}
+
+	empty := Links{}
+	if got := empty.DescribedBy(); got != "" {
+		t.Fatalf("expected empty string for Links with no describedby link, got %q", got)
+	}
+}
+
+func TestLinksPaginationAccessorsWithBareHrefForm(t *testing.T) {
+	links := Links{First: "https://example.com?page=1", Next: "https://example.com?page=3", Last: "https://example.com?page=5"}
+
+	if got, ok := links.FirstPage(); !ok || got != links.First {
+		t.Fatalf("FirstPage() = %q, %v", got, ok)
+	}
+	if got, ok := links.NextPage(); !ok || got != links.Next {
+		t.Fatalf("NextPage() = %q, %v", got, ok)
+	}
+	if got, ok := links.LastPage(); !ok || got != links.Last {
+		t.Fatalf("LastPage() = %q, %v", got, ok)
+	}
+	if got, ok := links.PrevPage(); ok || got != "" {
+		t.Fatalf("expected no prev link, got %q, %v", got, ok)
+	}
+}
+
+func TestLinksPaginationAccessorsWithLinkObjectForm(t *testing.T) {
+	links := Links{Objects: map[string]Link{
+		LinkNext: {Href: "https://example.com?page=3", Rel: "next-page"},
+	}}
+
+	got, ok := links.NextPage()
+	if !ok || got != "https://example.com?page=3" {
+		t.Fatalf("NextPage() = %q, %v", got, ok)
+	}
+}
+
+func TestLinksPaginationAccessorsOnNilLinks(t *testing.T) {
+	var links *Links
+
+	for _, accessor := range []func() (string, bool){links.NextPage, links.PrevPage, links.FirstPage, links.LastPage} {
+		if got, ok := accessor(); ok || got != "" {
+			t.Fatalf("expected (\"\", false) for a nil Links, got (%q, %v)", got, ok)
+		}
+	}
+}
+
+func TestLinksObjectsEntryWithEmptyHrefFallsBackToPlainField(t *testing.T) {
+	links := Links{
+		Self: "https://example.com/stale",
+		Objects: map[string]Link{
+			LinkSelf: {Rel: "canonical"},
+		},
+	}
+
+	raw, err := json.Marshal(links)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if out["self"]["href"] != links.Self {
+		t.Fatalf("expected Objects[self] with an empty href to fall back to the plain field, got %#v", out["self"])
+	}
+	if out["self"]["rel"] != "canonical" {
+		t.Fatalf("expected the rich Objects[self] entry to be preserved, got %#v", out["self"])
+	}
+}
+
+func TestLinksMarshalExplicitNullVsOmittedLink(t *testing.T) {
+	links := Links{
+		Self: "https://example.com/articles/1",
+		Objects: map[string]Link{
+			LinkRelated: NullLink,
+		},
+	}
+
+	raw, err := json.Marshal(links)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	relatedVal, ok := out["related"]
+	if !ok {
+		t.Fatal("expected an explicitly-null link to still be present as a member")
+	}
+	if relatedVal != nil {
+		t.Fatalf("expected related to marshal as JSON null, got %#v", relatedVal)
+	}
+	if _, ok := out["first"]; ok {
+		t.Fatal("expected an omitted link to not appear as a member at all")
+	}
+}
+
+func TestLinksUnmarshalExplicitNullProducesNullLink(t *testing.T) {
+	raw := []byte(`{"self": "https://example.com/articles/1", "related": null}`)
+
+	var links Links
+	if err := json.Unmarshal(raw, &links); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if links.Related != "" {
+		t.Fatalf("expected Related href to remain empty, got %q", links.Related)
+	}
+	obj, ok := links.Objects[LinkRelated]
+	if !ok || !obj.IsNull() {
+		t.Fatalf("expected Objects[related] to be NullLink, got %#v, ok=%v", obj, ok)
+	}
+
+	roundTripped, err := json.Marshal(links)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(roundTripped, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if val, ok := out["related"]; !ok || val != nil {
+		t.Fatalf("expected related to round-trip as JSON null, got %#v, ok=%v", val, ok)
+	}
+}
+
+type customObjectFixture struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestNewToManyRelationship(t *testing.T) {
+	rel := NewToManyRelationship(
+		RelationshipData{Type: "comments", ID: "1"},
+		RelationshipData{Type: "comments", ID: "2"},
+	)
+
+	if rel.Data == nil || len(rel.Data.DataArray) != 2 {
+		t.Fatalf("unexpected relationship: %+v", rel)
+	}
+	if rel.Data.DataArray[0].ID != "1" || rel.Data.DataArray[1].ID != "2" {
+		t.Fatalf("unexpected identifiers: %+v", rel.Data.DataArray)
+	}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"data":[{"type":"comments","id":"1"},{"type":"comments","id":"2"}]}` {
+		t.Fatalf("unexpected JSON: %s", raw)
+	}
+}
+
+func TestNewEmptyToManyMarshalsDataAsEmptyArray(t *testing.T) {
+	rel := NewEmptyToMany()
+
+	if rel.Data == nil || rel.Data.DataArray == nil || len(rel.Data.DataArray) != 0 {
+		t.Fatalf("unexpected relationship: %+v", rel)
+	}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"data":[]}` {
+		t.Fatalf("unexpected JSON: %s", raw)
+	}
+}
+
+func TestNewToManyRelationshipWithNoIDsMarshalsDataAsNull(t *testing.T) {
+	rel := NewToManyRelationship()
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"data":null}` {
+		t.Fatalf("unexpected JSON: %s", raw)
+	}
+}
+
+func TestNewToManyRelationshipWithOneIDStillMarshalsDataAsArray(t *testing.T) {
+	rel := NewToManyRelationship(RelationshipData{Type: "comments", ID: "1"})
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"data":[{"type":"comments","id":"1"}]}` {
+		t.Fatalf("expected a single-element array, got %s", raw)
+	}
+}
+
+func TestNewToOneRelationshipMarshalsDataAsObject(t *testing.T) {
+	rel := NewToOneRelationship(RelationshipData{Type: "people", ID: "9"})
+
+	if rel.Data == nil || rel.Data.DataObject == nil || rel.Data.DataArray != nil {
+		t.Fatalf("unexpected relationship: %+v", rel)
+	}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"data":{"type":"people","id":"9"}}` {
+		t.Fatalf("expected a bare object, got %s", raw)
+	}
+}
+
+func TestNewEmptyToManyRoundTripsThroughUnmarshal(t *testing.T) {
+	raw, err := json.Marshal(NewEmptyToMany())
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var rel Relationship
+	if err := json.Unmarshal(raw, &rel); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if rel.ExplicitNull || rel.Data == nil || rel.Data.DataArray == nil || len(rel.Data.DataArray) != 0 {
+		t.Fatalf("unexpected relationship after round trip: %+v", rel)
+	}
+}
+
+func TestDocumentUnmarshalDataOnlyAcceptsNoErrors(t *testing.T) {
+	raw := []byte(`{"data":{"type":"people","id":"1"}}`)
+
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Data == nil || got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected data: %+v", got.Data)
+	}
+	if got.Errors != nil {
+		t.Fatalf("expected no errors, got %+v", got.Errors)
+	}
+}
+
+func TestDocumentMarshalOmitsEmptyIncludedRegardlessOfNil(t *testing.T) {
+	data := &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}
+
+	nilIncluded := &Document{Data: data}
+	raw, err := json.Marshal(nilIncluded)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(raw), `"included"`) {
+		t.Fatalf("expected no included member for nil Included, got %s", raw)
+	}
+
+	emptySliceIncluded := &Document{Data: data, Included: []Data{}}
+	raw, err = json.Marshal(emptySliceIncluded)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(raw), `"included"`) {
+		t.Fatalf("expected no included member for an empty Included slice, got %s", raw)
+	}
+
+	nonEmptyIncluded := &Document{Data: data, Included: []Data{{Type: "comments", ID: "2"}}}
+	raw, err = json.Marshal(nonEmptyIncluded)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if !strings.Contains(string(raw), `"included"`) {
+		t.Fatalf("expected an included member for a non-empty Included slice, got %s", raw)
+	}
+}
+
+func TestDocumentUnmarshalPreservesExtensionMembers(t *testing.T) {
+	raw := []byte(`{"data":{"type":"people","id":"1"},"atomic:operations":[{"op":"add"}],"@custom":42}`)
+
+	var doc Document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if string(doc.Extensions["atomic:operations"]) != `[{"op":"add"}]` {
+		t.Fatalf("unexpected atomic:operations: %s", doc.Extensions["atomic:operations"])
+	}
+	if string(doc.Extensions["@custom"]) != "42" {
+		t.Fatalf("unexpected @custom: %s", doc.Extensions["@custom"])
+	}
+	if _, ok := doc.Extensions["data"]; ok {
+		t.Fatal("expected the known data member not to leak into Extensions")
+	}
+
+	out, err := json.Marshal(&doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var roundTripped Document
+	if err := json.Unmarshal(out, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+	if string(roundTripped.Extensions["atomic:operations"]) != `[{"op":"add"}]` {
+		t.Fatalf("extension member did not survive round trip: %+v", roundTripped.Extensions)
+	}
+}
+
+func TestDocumentMetaOnlyRoundTrip(t *testing.T) {
+	doc := &Document{Meta: map[string]interface{}{"status": "ok"}}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"meta":{"status":"ok"}}` {
+		t.Fatalf("unexpected JSON: %s", raw)
+	}
+
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Data != nil || got.Errors != nil {
+		t.Fatalf("expected no data/errors, got %+v", got)
+	}
+	if got.Meta["status"] != "ok" {
+		t.Fatalf("unexpected meta: %+v", got.Meta)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatalf("expected a meta-only document to validate, got %v", err)
+	}
+}
+
+func TestDocumentSetMetaAttachesCollectionWideMetaAlongsideDataArray(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{{Type: "articles", ID: "1"}}}}
+	doc.SetMeta("total", 42)
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	meta, ok := out["meta"].(map[string]interface{})
+	if !ok || meta["total"] != float64(42) {
+		t.Fatalf("expected top-level meta to carry total, got %+v", out)
+	}
+	dataArray, ok := out["data"].([]interface{})
+	if !ok || len(dataArray) != 1 {
+		t.Fatalf("unexpected data: %+v", out["data"])
+	}
+	if _, ok := dataArray[0].(map[string]interface{})["meta"]; ok {
+		t.Fatalf("expected collection meta not to land inside a data entry, got %+v", dataArray[0])
+	}
+}
+
+func TestDocumentSetMetaInitializesNilMap(t *testing.T) {
+	var doc Document
+	doc.SetMeta("key", "value")
+
+	value, ok := doc.GetMeta("key")
+	if !ok || value != "value" {
+		t.Fatalf("unexpected meta after SetMeta: %+v", doc.Meta)
+	}
+}
+
+func TestDocumentGetMetaOnNilMetaReturnsFalse(t *testing.T) {
+	var doc Document
+	if _, ok := doc.GetMeta("missing"); ok {
+		t.Fatal("expected GetMeta to report absence on a nil Meta map")
+	}
+}
+
+func TestRelationshipExplicitNullVsAbsent(t *testing.T) {
+	raw := []byte(`{"type":"articles","id":"1","attributes":{},"relationships":{"author":{"data":null}}}`)
+
+	var d Data
+	if err := json.Unmarshal(raw, &d); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	author, ok := d.Relationships["author"]
+	if !ok {
+		t.Fatal("expected the author relationship to be reported")
+	}
+	if !author.ExplicitNull || author.Data != nil {
+		t.Fatalf("expected an explicit null relationship, got %+v", author)
+	}
+	if _, ok := d.Relationships["editor"]; ok {
+		t.Fatal("expected the editor relationship, which was never mentioned, to be absent")
+	}
+
+	out, err := json.Marshal(author)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(out) != `{"data":null}` {
+		t.Fatalf("expected explicit null to round trip as data:null, got %s", out)
+	}
+}
+
+func TestCustomObjectMarshalPreservesFieldOrder(t *testing.T) {
+	co := CustomObject{
+		Fields: []string{"age", "name"},
+		Object: customObjectFixture{Name: "Michele", Age: 30},
+	}
+
+	raw, err := json.Marshal(co)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := `{"age":30,"name":"Michele"}`
+	if string(raw) != want {
+		t.Fatalf("got %s, want %s", raw, want)
+	}
+}
+
+type customObjectAddress struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type customObjectNestedFixture struct {
+	Name    string              `json:"name"`
+	Address customObjectAddress `json:"address"`
+}
+
+type customObjectBase struct {
+	ID string `json:"id"`
+}
+
+type customObjectEmbeddedFixture struct {
+	customObjectBase
+	Name string `json:"name"`
+}
+
+type customObjectDBTaggedFixture struct {
+	Name string `db:"name" json:"full_name"`
+	Age  int    `db:"age" json:"years"`
+}
+
+func TestCustomObjectMarshalCustomTagName(t *testing.T) {
+	co := CustomObject{
+		Fields:  []string{"age", "name"},
+		Object:  customObjectDBTaggedFixture{Name: "Michele", Age: 30},
+		TagName: "db",
+	}
+
+	raw, err := json.Marshal(co)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := `{"age":30,"name":"Michele"}`
+	if string(raw) != want {
+		t.Fatalf("got %s, want %s", raw, want)
+	}
+}
+
+func TestCustomObjectMarshalEmbeddedFields(t *testing.T) {
+	co := CustomObject{
+		Fields: []string{"id", "name"},
+		Object: customObjectEmbeddedFixture{
+			customObjectBase: customObjectBase{ID: "1"},
+			Name:             "Michele",
+		},
+	}
+
+	raw, err := json.Marshal(co)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := `{"id":"1","name":"Michele"}`
+	if string(raw) != want {
+		t.Fatalf("got %s, want %s", raw, want)
+	}
+}
+
+func TestCustomObjectMarshalDottedNestedField(t *testing.T) {
+	co := CustomObject{
+		Fields: []string{"name", "address.city"},
+		Object: customObjectNestedFixture{
+			Name:    "Michele",
+			Address: customObjectAddress{City: "Turin", Country: "Italy"},
+		},
+	}
+
+	raw, err := json.Marshal(co)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := `{"name":"Michele","address":{"city":"Turin"}}`
+	if string(raw) != want {
+		t.Fatalf("got %s, want %s", raw, want)
+	}
+}
+
+func TestCustomObjectJSONToStructCacheIsConsistentAcrossTagNames(t *testing.T) {
+	plain := CustomObject{Object: customObjectDBTaggedFixture{Name: "Michele", Age: 30}}
+	dict := plain.JSONToStruct()
+	if dict["full_name"] != "Name" || dict["years"] != "Age" {
+		t.Fatalf("unexpected json-tag mapping: %+v", dict)
+	}
+
+	tagged := CustomObject{Object: customObjectDBTaggedFixture{Name: "Michele", Age: 30}, TagName: "db"}
+	dbDict := tagged.JSONToStruct()
+	if dbDict["name"] != "Name" || dbDict["age"] != "Age" {
+		t.Fatalf("unexpected db-tag mapping: %+v", dbDict)
+	}
+}
+
+func TestCustomObjectEffectiveFieldsFiltersUnknownNames(t *testing.T) {
+	co := CustomObject{
+		Fields: []string{"name", "nickname", "age", "bogus"},
+		Object: customObjectFixture{Name: "Michele", Age: 30},
+	}
+
+	got := co.EffectiveFields()
+	want := []string{"name", "age"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCustomObjectEffectiveFieldsKeepsDottedNestedFieldByBaseName(t *testing.T) {
+	co := CustomObject{
+		Fields: []string{"name", "address.city", "address.bogus", "bogus.city"},
+		Object: customObjectNestedFixture{Name: "Michele", Address: customObjectAddress{City: "Rome"}},
+	}
+
+	got := co.EffectiveFields()
+	want := []string{"name", "address.city", "address.bogus"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func BenchmarkCustomObjectMarshalJSON(b *testing.B) {
+	co := CustomObject{
+		Fields: []string{"age", "name"},
+		Object: customObjectFixture{Name: "Michele", Age: 30},
+	}
+
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(co); err != nil {
+			b.Fatalf("Marshal returned error: %v", err)
+		}
+	}
+}
+
+func TestDataMetaRoundTrip(t *testing.T) {
+	d := Data{Type: "people", ID: "1", Meta: map[string]interface{}{"version": "2"}}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Data
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Meta["version"] != "2" {
+		t.Fatalf("unexpected meta: %+v", got.Meta)
+	}
+}
+
+func TestDataUnmarshalAttributes(t *testing.T) {
+	d := Data{Type: "people", ID: "1", Attributes: json.RawMessage(`{"name":"Michele","age":30}`)}
+
+	var attrs struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	if err := d.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if attrs.Name != "Michele" || attrs.Age != 30 {
+		t.Fatalf("unexpected attrs: %+v", attrs)
+	}
+}
+
+func TestDataLIDRoundTrip(t *testing.T) {
+	raw := []byte(`{"type":"people","lid":"temp-1","attributes":{}}`)
+
+	var d Data
+	if err := json.Unmarshal(raw, &d); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if d.ID != "" || d.LID != "temp-1" {
+		t.Fatalf("unexpected data: %+v", d)
+	}
+
+	out, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := got["id"]; ok {
+		t.Fatalf("expected no id member for a lid-only resource, got %#v", got["id"])
+	}
+	if got["lid"] != "temp-1" {
+		t.Fatalf("expected lid to round trip, got %#v", got["lid"])
+	}
+}
+
+func TestDocumentValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		doc     Document
+		wantErr error
+	}{
+		{
+			name:    "data and errors",
+			doc:     Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}, Errors: []ErrorObject{{Title: "boom"}}},
+			wantErr: errDataAndErrors,
+		},
+		{
+			name:    "nothing",
+			doc:     Document{},
+			wantErr: errMissingTopLevelMember,
+		},
+		{
+			name:    "meta only is valid",
+			doc:     Document{Meta: map[string]interface{}{"count": 1}},
+			wantErr: nil,
+		},
+		{
+			name:    "data object missing type",
+			doc:     Document{Data: &DataContainer{DataObject: &Data{ID: "1"}}},
+			wantErr: errMissingType,
+		},
+		{
+			name:    "included resource missing type",
+			doc:     Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}, Included: []Data{{ID: "2"}}},
+			wantErr: errMissingType,
+		},
+		{
+			name:    "valid document",
+			doc:     Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.doc.Validate(); err != c.wantErr {
+				t.Fatalf("got %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestDataSelfLink(t *testing.T) {
+	d := Data{Type: "articles", ID: "1"}
+
+	if got := d.SelfLink("https://example.com"); got != "https://example.com/articles/1" {
+		t.Fatalf("unexpected self link: %q", got)
+	}
+	if got := d.SelfLink("https://example.com/"); got != "https://example.com/articles/1" {
+		t.Fatalf("expected a trailing slash in baseURL to be tolerated, got %q", got)
+	}
+
+	noID := Data{Type: "articles"}
+	if got := noID.SelfLink("https://example.com"); got != "" {
+		t.Fatalf("expected an empty self link for a resource with no id, got %q", got)
+	}
+}
+
+func TestDataEnsureSelfLink(t *testing.T) {
+	d := Data{Type: "articles", ID: "1"}
+	d.EnsureSelfLink("https://example.com")
+
+	if d.Links == nil || d.Links.Self != "https://example.com/articles/1" {
+		t.Fatalf("expected self link to be populated, got %+v", d.Links)
+	}
+
+	d.Links.Self = "https://example.com/custom"
+	d.EnsureSelfLink("https://example.com")
+	if d.Links.Self != "https://example.com/custom" {
+		t.Fatalf("expected an existing self link not to be overwritten, got %q", d.Links.Self)
+	}
+
+	noID := Data{Type: "articles"}
+	noID.EnsureSelfLink("https://example.com")
+	if noID.Links != nil {
+		t.Fatalf("expected no Links to be created when SelfLink can't build one, got %+v", noID.Links)
+	}
+}
+
+func TestDataMarshalOmitsAttributesWhenUnset(t *testing.T) {
+	d := Data{Type: "people", ID: "1"}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(raw), `"attributes"`) {
+		t.Fatalf("expected no attributes member, got %s", raw)
+	}
+}
+
+func TestDataMarshalOmitsAllocatedButEmptyRelationshipsMap(t *testing.T) {
+	d := Data{Type: "people", ID: "1", Relationships: map[string]Relationship{}}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(raw), `"relationships"`) {
+		t.Fatalf("expected no relationships member for an allocated-but-empty map, got %s", raw)
+	}
+}
+
+func TestDataMarshalRelationshipOnlyResourceOmitsAttributes(t *testing.T) {
+	d := Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+		},
+	}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["attributes"]; ok {
+		t.Fatalf("expected no attributes key, got %#v", out["attributes"])
+	}
+	if _, ok := out["relationships"]; !ok {
+		t.Fatalf("expected relationships to still be present, got %#v", out)
+	}
+}
+
+func TestNewDataRawAcceptsObjectAttributes(t *testing.T) {
+	d, err := NewDataRaw("people", "1", json.RawMessage(`{"name":"Michele"}`))
+	if err != nil {
+		t.Fatalf("NewDataRaw returned error: %v", err)
+	}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	want := `{"type":"people","id":"1","attributes":{"name":"Michele"}}`
+	if string(raw) != want {
+		t.Fatalf("got %s, want %s", raw, want)
+	}
+}
+
+func TestNewDataRawAcceptsEmptyAttributes(t *testing.T) {
+	d, err := NewDataRaw("people", "1", nil)
+	if err != nil {
+		t.Fatalf("NewDataRaw returned error: %v", err)
+	}
+	if d.Attributes != nil {
+		t.Fatalf("expected nil attributes, got %s", d.Attributes)
+	}
+}
+
+func TestNewDataRawRejectsArrayAttributes(t *testing.T) {
+	if _, err := NewDataRaw("people", "1", json.RawMessage(`[1,2,3]`)); err == nil {
+		t.Fatal("expected an error for array attributes")
+	}
+}
+
+func TestNewDataRawRejectsScalarAttributes(t *testing.T) {
+	if _, err := NewDataRaw("people", "1", json.RawMessage(`"oops"`)); err == nil {
+		t.Fatal("expected an error for scalar attributes")
+	}
+}
+
+func TestNewDataRawRejectsMalformedAttributes(t *testing.T) {
+	if _, err := NewDataRaw("people", "1", json.RawMessage(`{not json`)); err == nil {
+		t.Fatal("expected an error for malformed attributes")
+	}
+}
+
+func TestMarshalRelationshipToOneLinkageOnly(t *testing.T) {
+	rel := Relationship{
+		Links: &Links{Self: "https://api.example.com/articles/1/relationships/author"},
+		Data:  &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}},
+	}
+
+	doc, err := MarshalRelationship(rel)
+	if err != nil {
+		t.Fatalf("MarshalRelationship returned error: %v", err)
+	}
+
+	if doc.Data.DataObject == nil || doc.Data.DataObject.Type != "people" || doc.Data.DataObject.ID != "9" {
+		t.Fatalf("unexpected linkage: %+v", doc.Data)
+	}
+	if doc.Data.DataObject.Attributes != nil {
+		t.Fatalf("expected no attributes in linkage, got %s", doc.Data.DataObject.Attributes)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(raw), `"attributes"`) {
+		t.Fatalf("expected no attributes member in the marshaled linkage document, got %s", raw)
+	}
+}
+
+func TestMarshalRelationshipToManyLinkage(t *testing.T) {
+	rel := NewToManyRelationship(
+		RelationshipData{Type: "comments", ID: "1"},
+		RelationshipData{Type: "comments", ID: "2"},
+	)
+
+	doc, err := MarshalRelationship(rel)
+	if err != nil {
+		t.Fatalf("MarshalRelationship returned error: %v", err)
+	}
+	if len(doc.Data.DataArray) != 2 {
+		t.Fatalf("expected 2 linkage entries, got %+v", doc.Data.DataArray)
+	}
+}
+
+func TestMarshalRelationshipExplicitNullLinkage(t *testing.T) {
+	rel := Relationship{ExplicitNull: true}
+
+	doc, err := MarshalRelationship(rel)
+	if err != nil {
+		t.Fatalf("MarshalRelationship returned error: %v", err)
+	}
+	if doc.Data != nil {
+		t.Fatalf("expected no data for an explicit-null relationship, got %+v", doc.Data)
+	}
+}
+
+func TestNewIdentifierDocumentBuildsToOneLinkageOnly(t *testing.T) {
+	doc := NewIdentifierDocument(RelationshipData{Type: "people", ID: "9", Meta: map[string]interface{}{"ignored": true}})
+
+	if doc.Data.DataObject == nil || doc.Data.DataObject.Type != "people" || doc.Data.DataObject.ID != "9" {
+		t.Fatalf("unexpected linkage: %+v", doc.Data)
+	}
+	if !IsIdentifierDocument(doc) {
+		t.Fatalf("expected an identifier document, got %+v", doc)
+	}
+}
+
+func TestNewIdentifierCollectionDocumentBuildsToManyLinkageOnly(t *testing.T) {
+	doc := NewIdentifierCollectionDocument(
+		RelationshipData{Type: "comments", ID: "1"},
+		RelationshipData{Type: "comments", ID: "2"},
+	)
+
+	if len(doc.Data.DataArray) != 2 {
+		t.Fatalf("expected 2 linkage entries, got %+v", doc.Data.DataArray)
+	}
+	if !IsIdentifierDocument(doc) {
+		t.Fatalf("expected an identifier document, got %+v", doc)
+	}
+}
+
+func TestNewIdentifierCollectionDocumentWithNoIDsProducesEmptyArray(t *testing.T) {
+	doc := NewIdentifierCollectionDocument()
+
+	if doc.Data.DataArray == nil || len(doc.Data.DataArray) != 0 {
+		t.Fatalf("expected an empty array, got %+v", doc.Data.DataArray)
+	}
+}
+
+func TestMarshalIdentifiersBuildsSharedTypeLinkageArray(t *testing.T) {
+	doc := MarshalIdentifiers("comments", "1", "2")
+
+	want := []Data{
+		{Type: "comments", ID: "1"},
+		{Type: "comments", ID: "2"},
+	}
+	if !reflect.DeepEqual(doc.Data.DataArray, want) {
+		t.Fatalf("got %+v, want %+v", doc.Data.DataArray, want)
+	}
+	if !IsIdentifierDocument(doc) {
+		t.Fatalf("expected an identifier document, got %+v", doc)
+	}
+}
+
+func TestMarshalIdentifiersWithNoIDsProducesEmptyArray(t *testing.T) {
+	doc := MarshalIdentifiers("comments")
+
+	if doc.Data.DataArray == nil || len(doc.Data.DataArray) != 0 {
+		t.Fatalf("expected an empty array, got %+v", doc.Data.DataArray)
+	}
+}
+
+func TestIsIdentifierDocumentRejectsAttributes(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type:       "people",
+		ID:         "9",
+		Attributes: json.RawMessage(`{"name":"Alice"}`),
+	}}}
+
+	if IsIdentifierDocument(doc) {
+		t.Fatal("expected IsIdentifierDocument to reject a resource object carrying attributes")
+	}
+}
+
+func TestIsIdentifierDocumentRejectsRelationships(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type:          "people",
+		ID:            "9",
+		Relationships: map[string]Relationship{"articles": {}},
+	}}}
+
+	if IsIdentifierDocument(doc) {
+		t.Fatal("expected IsIdentifierDocument to reject a resource object carrying relationships")
+	}
+}
+
+func TestIsIdentifierDocumentAcceptsArrayOfBareIdentifiers(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "comments", ID: "1"},
+		{Type: "comments", ID: "2"},
+	}}}
+
+	if !IsIdentifierDocument(doc) {
+		t.Fatal("expected IsIdentifierDocument to accept an array of bare identifiers")
+	}
+}
+
+func TestIsIdentifierDocumentRejectsOneBadItemInArray(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "comments", ID: "1"},
+		{Type: "comments", ID: "2", Attributes: json.RawMessage(`{"body":"hi"}`)},
+	}}}
+
+	if IsIdentifierDocument(doc) {
+		t.Fatal("expected IsIdentifierDocument to reject an array with one non-identifier item")
+	}
+}
+
+func TestIsIdentifierDocumentAcceptsNilData(t *testing.T) {
+	if !IsIdentifierDocument(&Document{}) {
+		t.Fatal("expected IsIdentifierDocument to accept a document with no data")
+	}
+	if !IsIdentifierDocument(nil) {
+		t.Fatal("expected IsIdentifierDocument to accept a nil document")
+	}
+}
+
+func TestDataAttributesMapDecodesGenericMap(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"name":"Alice","age":30}`)}
+
+	got, err := d.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+	if got["name"] != "Alice" || got["age"] != float64(30) {
+		t.Fatalf("unexpected attributes map: %+v", got)
+	}
+}
+
+func TestDataAttributesMapOnNilAttributesReturnsEmptyMap(t *testing.T) {
+	var d Data
+
+	got, err := d.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("expected an empty map for nil attributes, got %+v", got)
+	}
+}
+
+func TestDataSetAttributesMapRoundTrip(t *testing.T) {
+	var d Data
+	if err := d.SetAttributesMap(map[string]interface{}{"name": "Bob"}); err != nil {
+		t.Fatalf("SetAttributesMap returned error: %v", err)
+	}
+
+	got, err := d.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+	if got["name"] != "Bob" {
+		t.Fatalf("unexpected attributes map after round trip: %+v", got)
+	}
+}
+
+func TestUnmarshalAttributesUseNumberPreservesLargeIntegerPrecision(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"big":9007199254740993}`)}
+
+	var got map[string]interface{}
+	if err := d.UnmarshalAttributes(&got, UseNumber()); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+
+	num, ok := got["big"].(json.Number)
+	if !ok {
+		t.Fatalf("expected a json.Number, got %T", got["big"])
+	}
+	if num.String() != "9007199254740993" {
+		t.Fatalf("expected exact precision, got %s", num.String())
+	}
+}
+
+func TestUnmarshalAttributesWithoutUseNumberLosesPrecision(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"big":9007199254740993}`)}
+
+	var got map[string]interface{}
+	if err := d.UnmarshalAttributes(&got); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+
+	if _, ok := got["big"].(float64); !ok {
+		t.Fatalf("expected a float64 by default, got %T", got["big"])
+	}
+}
+
+func TestAttributesMapUseNumber(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"big":9007199254740993}`)}
+
+	got, err := d.AttributesMap(UseNumber())
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+	if got["big"].(json.Number).String() != "9007199254740993" {
+		t.Fatalf("unexpected value: %v", got["big"])
+	}
+}
+
+func TestAttributesMapMaxDepthRejectsPayloadNestedBeyondLimit(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"a":{"b":{"c":{"d":1}}}}`)}
+
+	if _, err := d.AttributesMap(MaxDepth(3)); err == nil {
+		t.Fatal("expected an error for a payload nested beyond the limit")
+	}
+}
+
+func TestAttributesMapMaxDepthAllowsPayloadWithinLimit(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"a":{"b":{"c":1}}}`)}
+
+	got, err := d.AttributesMap(MaxDepth(3))
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+	if _, ok := got["a"]; !ok {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestMergeAttributesAddsOverwritesAndDeletesKeys(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"name":"Alice","age":30,"nickname":"Al"}`)}
+
+	err := d.MergeAttributes(map[string]interface{}{
+		"age":      31,        // overwrite
+		"email":    "a@b.com", // add
+		"nickname": nil,       // delete
+	})
+	if err != nil {
+		t.Fatalf("MergeAttributes returned error: %v", err)
+	}
+
+	got, err := d.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+
+	if got["name"] != "Alice" {
+		t.Fatalf("expected untouched key to survive, got %+v", got)
+	}
+	if got["age"] != float64(31) {
+		t.Fatalf("expected age to be overwritten to 31, got %v", got["age"])
+	}
+	if got["email"] != "a@b.com" {
+		t.Fatalf("expected email to be added, got %v", got["email"])
+	}
+	if _, ok := got["nickname"]; ok {
+		t.Fatalf("expected nickname to be deleted, got %+v", got)
+	}
+}
+
+func TestMergeAttributesOnEmptyAttributesStartsFromEmptyMap(t *testing.T) {
+	var d Data
+
+	if err := d.MergeAttributes(map[string]interface{}{"name": "Bob"}); err != nil {
+		t.Fatalf("MergeAttributes returned error: %v", err)
+	}
+
+	got, err := d.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap returned error: %v", err)
+	}
+	if got["name"] != "Bob" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestDecodeMetaMaxDepthRejectsPayloadNestedBeyondLimit(t *testing.T) {
+	meta := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}}}
+
+	var got map[string]interface{}
+	if err := DecodeMeta(meta, &got, MaxDepth(2)); err == nil {
+		t.Fatal("expected an error for meta nested beyond the limit")
+	}
+}
+
+func TestUnmarshalAttributesStrictUnknownFieldsRejectsExtraKey(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"name":"Alice","typo":"oops"}`)}
+
+	var got struct {
+		Name string `json:"name"`
+	}
+	if err := d.UnmarshalAttributes(&got, StrictUnknownFields()); err == nil {
+		t.Fatal("expected an error for an unknown attribute field")
+	}
+}
+
+func TestUnmarshalAttributesWithoutStrictIgnoresExtraKey(t *testing.T) {
+	d := Data{Attributes: json.RawMessage(`{"name":"Alice","typo":"oops"}`)}
+
+	var got struct {
+		Name string `json:"name"`
+	}
+	if err := d.UnmarshalAttributes(&got); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if got.Name != "Alice" {
+		t.Fatalf("unexpected name: %q", got.Name)
+	}
+}
+
+func TestDecodeMetaIntoTypedStruct(t *testing.T) {
+	meta := map[string]interface{}{"total": float64(42), "page": float64(3)}
+
+	var got struct {
+		Total int `json:"total"`
+		Page  int `json:"page"`
+	}
+	if err := DecodeMeta(meta, &got); err != nil {
+		t.Fatalf("DecodeMeta returned error: %v", err)
+	}
+	if got.Total != 42 || got.Page != 3 {
+		t.Fatalf("unexpected decoded meta: %+v", got)
+	}
+}
+
+func TestDecodeMetaOnNilLeavesVUntouched(t *testing.T) {
+	got := struct{ Total int }{Total: 7}
+	if err := DecodeMeta(nil, &got); err != nil {
+		t.Fatalf("DecodeMeta returned error: %v", err)
+	}
+	if got.Total != 7 {
+		t.Fatalf("expected v to be left untouched, got %+v", got)
+	}
+}
+
+func TestDataContainerUnmarshalSkipsLeadingWhitespace(t *testing.T) {
+	indentedObject := []byte("\n  {\n    \"type\": \"people\",\n    \"id\": \"1\"\n  }\n")
+	var objContainer DataContainer
+	if err := json.Unmarshal(indentedObject, &objContainer); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if objContainer.DataObject == nil || objContainer.DataObject.ID != "1" {
+		t.Fatalf("unexpected container: %+v", objContainer)
+	}
+
+	indentedArray := []byte("\r\n\t[\n    {\"type\": \"people\", \"id\": \"1\"}\n  ]")
+	var arrContainer DataContainer
+	if err := json.Unmarshal(indentedArray, &arrContainer); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if len(arrContainer.DataArray) != 1 {
+		t.Fatalf("unexpected container: %+v", arrContainer)
+	}
+}
+
+func TestDataContainerUnmarshalAcceptsNull(t *testing.T) {
+	var container DataContainer
+	if err := json.Unmarshal([]byte("null"), &container); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if container.DataObject != nil || container.DataArray != nil {
+		t.Fatalf("expected both fields nil for null data, got %+v", container)
+	}
+}
+
+func TestDataContainerUnmarshalRejectsScalarsWithDistinctErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"number", "5", "a number"},
+		{"string", `"x"`, "a string"},
+		{"boolean", "true", "a boolean"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var container DataContainer
+			err := json.Unmarshal([]byte(c.payload), &container)
+			if err == nil {
+				t.Fatalf("expected an error for payload %q", c.payload)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("expected error to mention %q, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestDataContainerUnmarshalRejectsMalformedInput(t *testing.T) {
+	// encoding/json rejects a syntactically invalid top-level document
+	// before ever reaching a field's UnmarshalJSON, so this calls the
+	// method directly to exercise describeJSONToken's fallback branch.
+	var container DataContainer
+	err := container.UnmarshalJSON([]byte("garbage"))
+	if err == nil {
+		t.Fatal("expected an error for malformed data")
+	}
+	if !strings.Contains(err.Error(), "malformed JSON") {
+		t.Fatalf("expected error to mention malformed JSON, got %v", err)
+	}
+}
+
+func TestRelationshipDataContainerUnmarshalSkipsLeadingWhitespace(t *testing.T) {
+	indented := []byte("\n  {\n    \"type\": \"people\",\n    \"id\": \"1\"\n  }\n")
+	var container RelationshipDataContainer
+	if err := json.Unmarshal(indented, &container); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if container.DataObject == nil || container.DataObject.ID != "1" {
+		t.Fatalf("unexpected container: %+v", container)
+	}
+}
+
+func TestStripBOMRemovesLeadingBOM(t *testing.T) {
+	got := stripBOM([]byte("\xEF\xBB\xBF{\"a\":1}"))
+	if string(got) != `{"a":1}` {
+		t.Fatalf("unexpected result: %s", got)
+	}
+}
+
+func TestStripBOMLeavesPayloadWithoutBOMUnchanged(t *testing.T) {
+	got := stripBOM([]byte(`{"a":1}`))
+	if string(got) != `{"a":1}` {
+		t.Fatalf("unexpected result: %s", got)
+	}
+}
+
+func TestRelationshipDataContainerUnmarshalNullLeavesFieldsNil(t *testing.T) {
+	var container RelationshipDataContainer
+	if err := json.Unmarshal([]byte("  null  "), &container); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if container.DataObject != nil || container.DataArray != nil {
+		t.Fatalf("expected a nil object and array, got %+v", container)
+	}
+}
+
+func TestRelationshipDataContainerRoundTripsPerItemMetaInArray(t *testing.T) {
+	raw := []byte(`[{"type":"tags","id":"1","meta":{"primary":true}},{"type":"tags","id":"2"},{"type":"tags","id":"3","meta":{"primary":false}}]`)
+
+	var container RelationshipDataContainer
+	if err := json.Unmarshal(raw, &container); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if len(container.DataArray) != 3 {
+		t.Fatalf("expected 3 identifiers, got %+v", container.DataArray)
+	}
+	if container.DataArray[0].Meta["primary"] != true {
+		t.Fatalf("expected the first identifier's meta to survive, got %+v", container.DataArray[0])
+	}
+	if container.DataArray[1].Meta != nil {
+		t.Fatalf("expected the second identifier to have no meta, got %+v", container.DataArray[1])
+	}
+	if container.DataArray[2].Meta["primary"] != false {
+		t.Fatalf("expected the third identifier's meta to survive, got %+v", container.DataArray[2])
+	}
+
+	out, err := json.Marshal(&container)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var roundTripped RelationshipDataContainer
+	if err := json.Unmarshal(out, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal of re-marshaled payload returned error: %v", err)
+	}
+	if roundTripped.DataArray[0].Meta["primary"] != true || roundTripped.DataArray[1].Meta != nil || roundTripped.DataArray[2].Meta["primary"] != false {
+		t.Fatalf("meta did not survive a full round trip: %+v", roundTripped.DataArray)
+	}
+}
+
+func TestRelationshipUnmarshalMarshalNullDataRoundTrip(t *testing.T) {
+	payload := []byte(`{"data": null}`)
+
+	var rel Relationship
+	if err := json.Unmarshal(payload, &rel); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if !rel.ExplicitNull || rel.Data != nil {
+		t.Fatalf("expected an explicit null relationship, got %+v", rel)
+	}
+
+	out, err := json.Marshal(&rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var roundTripped map[string]interface{}
+	if err := json.Unmarshal(out, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+	if data, ok := roundTripped["data"]; !ok || data != nil {
+		t.Fatalf("expected data to marshal back to null, got %v", roundTripped["data"])
+	}
+}
+
+func TestRelationshipLinksDataMetaRoundTrip(t *testing.T) {
+	rel := Relationship{
+		Links: &Links{Self: "https://api.example.com/articles/1/relationships/author"},
+		Data:  &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}},
+		Meta:  map[string]interface{}{"lastFetched": "2024-01-01"},
+	}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Relationship
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if got.Links == nil || got.Links.Self != rel.Links.Self {
+		t.Fatalf("unexpected links: %+v", got.Links)
+	}
+	if got.Data == nil || got.Data.DataObject == nil || got.Data.DataObject.ID != "9" {
+		t.Fatalf("unexpected data: %+v", got.Data)
+	}
+	if got.Meta["lastFetched"] != "2024-01-01" {
+		t.Fatalf("unexpected meta: %+v", got.Meta)
+	}
+}
+
+func TestRelationshipMetaOnlyRoundTrip(t *testing.T) {
+	rel := Relationship{Meta: map[string]interface{}{"count": 3.0}}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != `{"meta":{"count":3}}` {
+		t.Fatalf(`expected {"meta":{"count":3}}, got %s`, raw)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+	if _, ok := out["data"]; ok {
+		t.Fatalf("expected no data key for a meta-only relationship, got %#v", out["data"])
+	}
+	if _, ok := out["links"]; ok {
+		t.Fatalf("expected no links key for a meta-only relationship, got %#v", out["links"])
+	}
+
+	var got Relationship
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Data != nil || got.ExplicitNull || got.Links != nil {
+		t.Fatalf("expected no data, no links and no explicit null, got %+v", got)
+	}
+	if got.Meta["count"] != 3.0 {
+		t.Fatalf("unexpected meta: %+v", got.Meta)
+	}
+}
+
+func TestRelationshipLinksOnlyRoundTrip(t *testing.T) {
+	rel := Relationship{Links: &Links{Related: "https://example.com/articles/1/comments"}}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+	if _, ok := out["data"]; ok {
+		t.Fatalf("expected no data key for a links-only relationship, got %#v", out["data"])
+	}
+
+	var got Relationship
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Data != nil || got.ExplicitNull {
+		t.Fatalf("expected no data and no explicit null, got %+v", got)
+	}
+	if got.IsLoaded() {
+		t.Fatal("expected a links-only relationship to report not loaded")
+	}
+	if got.Links == nil || got.Links.Related != rel.Links.Related {
+		t.Fatalf("unexpected links: %+v", got.Links)
+	}
+}
+
+func TestRelationshipToOneLIDOnlyRoundTrip(t *testing.T) {
+	rel := Relationship{Data: &RelationshipDataContainer{
+		DataObject: &RelationshipData{Type: "people", LID: "temp-1"},
+	}}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+	data, ok := out["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected an object data member, got %#v", out["data"])
+	}
+	if _, ok := data["id"]; ok {
+		t.Fatalf("expected no id member for a lid-only identifier, got %#v", data["id"])
+	}
+	if data["lid"] != "temp-1" {
+		t.Fatalf("unexpected lid: %#v", data["lid"])
+	}
+
+	var got Relationship
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Data == nil || got.Data.DataObject == nil {
+		t.Fatalf("expected a to-one identifier, got %+v", got.Data)
+	}
+	if got.Data.DataObject.ID != "" || got.Data.DataObject.LID != "temp-1" {
+		t.Fatalf("unexpected identifier: %+v", got.Data.DataObject)
+	}
+	if !got.IsLoaded() {
+		t.Fatal("expected a lid-only to-one relationship to report loaded")
+	}
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type:          "articles",
+		ID:            "1",
+		Relationships: map[string]Relationship{"author": got},
+	}}}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("expected a lid-only relationship to pass Validate, got %v", err)
+	}
+}
+
+func TestRelationshipRelatedCount(t *testing.T) {
+	rel := Relationship{Links: &Links{Objects: map[string]Link{
+		LinkRelated: {Href: "https://example.com/articles/1/comments", Meta: map[string]interface{}{"count": float64(10)}},
+	}}}
+
+	count, ok := rel.RelatedCount()
+	if !ok || count != 10 {
+		t.Fatalf("expected count 10, got %d, %v", count, ok)
+	}
+
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var roundTripped Relationship
+	if err := json.Unmarshal(raw, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if count, ok := roundTripped.RelatedCount(); !ok || count != 10 {
+		t.Fatalf("expected count to survive a round trip, got %d, %v", count, ok)
+	}
+
+	var empty Relationship
+	if _, ok := empty.RelatedCount(); ok {
+		t.Fatal("expected RelatedCount to report false for a relationship with no related link")
+	}
+}
+
+func TestRelationshipCountReadsOwnMeta(t *testing.T) {
+	rel := Relationship{Meta: map[string]interface{}{"count": float64(7)}}
+
+	count, ok := rel.Count()
+	if !ok || count != 7 {
+		t.Fatalf("expected count 7, got %d, %v", count, ok)
+	}
+}
+
+func TestRelationshipCountFallsBackToRelatedLinkMeta(t *testing.T) {
+	rel := Relationship{Links: &Links{Objects: map[string]Link{
+		LinkRelated: {Href: "https://example.com/articles/1/comments", Meta: map[string]interface{}{"count": float64(10)}},
+	}}}
+
+	count, ok := rel.Count()
+	if !ok || count != 10 {
+		t.Fatalf("expected count 10, got %d, %v", count, ok)
+	}
+}
+
+func TestRelationshipCountPrefersOwnMetaOverRelatedLinkMeta(t *testing.T) {
+	rel := Relationship{
+		Meta: map[string]interface{}{"count": float64(3)},
+		Links: &Links{Objects: map[string]Link{
+			LinkRelated: {Href: "https://example.com/articles/1/comments", Meta: map[string]interface{}{"count": float64(10)}},
+		}},
+	}
+
+	count, ok := rel.Count()
+	if !ok || count != 3 {
+		t.Fatalf("expected the relationship's own meta to win, got %d, %v", count, ok)
+	}
+}
+
+func TestRelationshipCountReportsFalseWithoutEitherPlacement(t *testing.T) {
+	var rel Relationship
+	if _, ok := rel.Count(); ok {
+		t.Fatal("expected Count to report false with no count anywhere")
+	}
+}
+
+func TestDataContainerMarshalEmptyDataArrayProducesBrackets(t *testing.T) {
+	c := &DataContainer{DataArray: []Data{}}
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != "[]" {
+		t.Fatalf("got %s, want []", raw)
+	}
+}
+
+func TestDataContainerMarshalNilDataProducesNull(t *testing.T) {
+	c := &DataContainer{}
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw) != "null" {
+		t.Fatalf("got %s, want null", raw)
+	}
+}
+
+func TestMarshalEmptySliceProducesDataBrackets(t *testing.T) {
+	var people []testPerson
+
+	doc, err := Marshal(people)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal of document returned error: %v", err)
+	}
+	if string(raw) != `{"data":[]}` {
+		t.Fatalf("got %s, want {\"data\":[]}", raw)
+	}
+}
+
+func TestDataContainerTypes(t *testing.T) {
+	single := &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}
+	if got := single.Types(); len(got) != 1 || got[0] != "people" {
+		t.Fatalf("unexpected types for a single object: %v", got)
+	}
+
+	homogeneous := &DataContainer{DataArray: []Data{{Type: "articles", ID: "1"}, {Type: "articles", ID: "2"}}}
+	if got := homogeneous.Types(); len(got) != 1 || got[0] != "articles" {
+		t.Fatalf("unexpected types for a homogeneous array: %v", got)
+	}
+
+	mixed := &DataContainer{DataArray: []Data{{Type: "articles", ID: "1"}, {Type: "people", ID: "9"}}}
+	if got := mixed.Types(); len(got) != 2 || got[0] != "articles" || got[1] != "people" {
+		t.Fatalf("unexpected types for a heterogeneous array: %v", got)
+	}
+
+	var nilContainer *DataContainer
+	if got := nilContainer.Types(); got != nil {
+		t.Fatalf("expected nil Types for a nil container, got %v", got)
+	}
+}
+
+func TestDocumentValidateRequireHomogeneousData(t *testing.T) {
+	doc := Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "articles", ID: "1"},
+		{Type: "people", ID: "9"},
+	}}}
+
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("expected heterogeneous data to be allowed by default, got %v", err)
+	}
+	if err := doc.Validate(RequireHomogeneousData()); err != errHeterogeneousData {
+		t.Fatalf("expected errHeterogeneousData with RequireHomogeneousData, got %v", err)
+	}
+
+	homogeneous := Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "articles", ID: "1"},
+		{Type: "articles", ID: "2"},
+	}}}
+	if err := homogeneous.Validate(RequireHomogeneousData()); err != nil {
+		t.Fatalf("expected no error for a homogeneous array, got %v", err)
+	}
+}
+
+func TestDocumentValidateRequireRelationshipTypesFlagsMismatchedLinkage(t *testing.T) {
+	schema := map[string][]string{"author": {"people"}}
+
+	mismatched := Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "comments", ID: "5"}}},
+		},
+	}}}
+
+	err := mismatched.Validate(RequireRelationshipTypes(schema))
+	linkageErr, ok := err.(*RelationshipLinkageError)
+	if !ok {
+		t.Fatalf("expected *RelationshipLinkageError, got %v", err)
+	}
+	if linkageErr.Relationship != "author" || linkageErr.LinkedType != "comments" {
+		t.Fatalf("unexpected error: %+v", linkageErr)
+	}
+
+	if err := mismatched.Validate(); err != nil {
+		t.Fatalf("expected no error without RequireRelationshipTypes, got %v", err)
+	}
+}
+
+func TestDocumentValidateRequireRelationshipTypesAllowsMatchingLinkage(t *testing.T) {
+	schema := map[string][]string{"author": {"people", "organizations"}}
+
+	doc := Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author":     {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			"unreviewed": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "anything", ID: "1"}}},
+		},
+	}}}
+
+	if err := doc.Validate(RequireRelationshipTypes(schema)); err != nil {
+		t.Fatalf("expected matching linkage (and an unlisted relationship) to pass, got %v", err)
+	}
+}
+
+func TestDocumentValidateRequireRelationshipTypesChecksToManyLinkage(t *testing.T) {
+	schema := map[string][]string{"comments": {"comments"}}
+
+	doc := Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"comments": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{
+				{Type: "comments", ID: "1"},
+				{Type: "people", ID: "9"},
+			}}},
+		},
+	}}}
+
+	err := doc.Validate(RequireRelationshipTypes(schema))
+	linkageErr, ok := err.(*RelationshipLinkageError)
+	if !ok {
+		t.Fatalf("expected *RelationshipLinkageError, got %v", err)
+	}
+	if linkageErr.LinkedType != "people" {
+		t.Fatalf("unexpected error: %+v", linkageErr)
+	}
+}
+
+func TestDocumentValidateRequireSpecVersionRejectsLIDUnder10(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", LID: "temp-1"}}}
+
+	if err := doc.Validate(RequireSpecVersion("1.0")); err != errVersionRequiresLID {
+		t.Fatalf("expected errVersionRequiresLID, got %v", err)
+	}
+	if err := doc.Validate(RequireSpecVersion("1.1")); err != nil {
+		t.Fatalf("expected lid to be allowed under 1.1, got %v", err)
+	}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("expected no version check without RequireSpecVersion, got %v", err)
+	}
+}
+
+func TestDocumentValidateRequireSpecVersionDefersToDocumentVersion(t *testing.T) {
+	lidDoc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", LID: "temp-1"}}}
+
+	if err := lidDoc.Validate(RequireSpecVersion("")); err != errVersionRequiresLID {
+		t.Fatalf("expected a document with no jsonapi.version to default to 1.0 strictness, got %v", err)
+	}
+
+	lidDoc.JSONAPI = &JSONAPIObject{Version: "1.1"}
+	if err := lidDoc.Validate(RequireSpecVersion("")); err != nil {
+		t.Fatalf("expected a document declaring jsonapi.version 1.1 to allow lid, got %v", err)
+	}
+}
+
+func TestDocumentValidateRequireSpecVersionRejectsDescribedByAndExtProfileUnder10(t *testing.T) {
+	describedBy := &Document{Data: &DataContainer{DataObject: &Data{
+		Type:  "people",
+		Links: &Links{Objects: map[string]Link{LinkDescribedBy: {Href: "https://example.com/schemas/people"}}},
+	}}}
+	if err := describedBy.Validate(RequireSpecVersion("1.0")); err != errVersionRequiresDescribedBy {
+		t.Fatalf("expected errVersionRequiresDescribedBy, got %v", err)
+	}
+
+	extProfile := &Document{
+		Data:    &DataContainer{DataObject: &Data{Type: "people"}},
+		JSONAPI: &JSONAPIObject{Ext: []string{"https://example.com/ext/atomic"}},
+	}
+	if err := extProfile.Validate(RequireSpecVersion("1.0")); err != errVersionRequiresExtProfile {
+		t.Fatalf("expected errVersionRequiresExtProfile, got %v", err)
+	}
+}
+
+func TestDocumentValidateRequireSpecVersionRejectsRelationshipLID(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", LID: "temp-1"}}},
+		},
+	}}}
+
+	if err := doc.Validate(RequireSpecVersion("1.0")); err != errVersionRequiresLID {
+		t.Fatalf("expected errVersionRequiresLID for relationship linkage, got %v", err)
+	}
+}
+
+func TestDocumentValidateRejectsEmptyIDLinkageWithNoLID(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: ""}}},
+		},
+	}}}
+
+	err := doc.Validate()
+	linkageErr, ok := err.(*InvalidLinkageError)
+	if !ok {
+		t.Fatalf("expected *InvalidLinkageError, got %v", err)
+	}
+	if linkageErr.Pointer != "/data/relationships/author/data" {
+		t.Fatalf("unexpected pointer: %q", linkageErr.Pointer)
+	}
+}
+
+func TestDocumentValidateAllowsEmptyIDLinkageWithLID(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", LID: "temp-1"}}},
+		},
+	}}}
+
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("expected a lid-only identifier to pass, got %v", err)
+	}
+}
+
+func TestDocumentValidateRejectsEmptyIDInToManyLinkageWithIndexedPointer(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"comments": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{
+				{Type: "comments", ID: "1"},
+				{Type: "comments", ID: ""},
+			}}},
+		},
+	}}}
+
+	err := doc.Validate()
+	linkageErr, ok := err.(*InvalidLinkageError)
+	if !ok {
+		t.Fatalf("expected *InvalidLinkageError, got %v", err)
+	}
+	if linkageErr.Pointer != "/data/relationships/comments/data/1" {
+		t.Fatalf("unexpected pointer: %q", linkageErr.Pointer)
+	}
+}
+
+func TestDocumentValidateRejectsEmptyTypeLinkage(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{{
+		Type: "articles",
+		ID:   "2",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "", ID: "9"}}},
+		},
+	}}}}
+
+	err := doc.Validate()
+	linkageErr, ok := err.(*InvalidLinkageError)
+	if !ok {
+		t.Fatalf("expected *InvalidLinkageError, got %v", err)
+	}
+	if linkageErr.Pointer != "/data/0/relationships/author/data" {
+		t.Fatalf("unexpected pointer: %q", linkageErr.Pointer)
+	}
+}
+
+func TestDocumentValidateRejectsIncludedDuplicatingPrimaryResource(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Included: []Data{
+			{Type: "people", ID: "9"},
+			{Type: "articles", ID: "1"},
+		},
+	}
+
+	err := doc.Validate()
+	dupErr, ok := err.(*DuplicateResourceError)
+	if !ok {
+		t.Fatalf("expected *DuplicateResourceError, got %v", err)
+	}
+	if dupErr.Pointer != "/included/1" || dupErr.Type != "articles" || dupErr.ID != "1" {
+		t.Fatalf("unexpected error: %+v", dupErr)
+	}
+}
+
+func TestDocumentValidateRejectsIncludedDuplicatingPrimaryResourceInArray(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "articles", ID: "1"},
+			{Type: "articles", ID: "2"},
+		}},
+		Included: []Data{
+			{Type: "articles", ID: "2"},
+		},
+	}
+
+	err := doc.Validate()
+	dupErr, ok := err.(*DuplicateResourceError)
+	if !ok {
+		t.Fatalf("expected *DuplicateResourceError, got %v", err)
+	}
+	if dupErr.Pointer != "/included/0" {
+		t.Fatalf("unexpected pointer: %q", dupErr.Pointer)
+	}
+}
+
+func TestDocumentValidateAllowsIncludedResourcesNotInPrimaryData(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Included: []Data{
+			{Type: "people", ID: "9"},
+		},
+	}
+
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLinkMetaRoundTrip(t *testing.T) {
+	link := Link{Href: "https://example.com/articles/1/author", Meta: map[string]interface{}{"count": float64(1)}}
+
+	raw, err := json.Marshal(link)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Link
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Href != link.Href || got.Meta["count"] != float64(1) {
+		t.Fatalf("unexpected link: %+v", got)
+	}
+}
+
+func TestDocumentVersion(t *testing.T) {
+	var noJSONAPI Document
+	if v := noJSONAPI.Version(); v != DefaultVersion {
+		t.Fatalf("expected %q for a document with no jsonapi member, got %q", DefaultVersion, v)
+	}
+
+	doc := Document{JSONAPI: &JSONAPIObject{Version: "1.1"}}
+	raw, err := json.Marshal(&doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if v := got.Version(); v != "1.1" {
+		t.Fatalf("expected version 1.1 round trip, got %q", v)
+	}
+}
+
+func TestDataUnmarshalRejectsNumericIDByDefault(t *testing.T) {
+	var d Data
+	if err := json.Unmarshal([]byte(`{"type":"people","id":123}`), &d); err == nil {
+		t.Fatal("expected a numeric id to be rejected by default")
+	}
+}
+
+func TestDataUnmarshalLenientIDsCoercesNumber(t *testing.T) {
+	SetLenientIDs(true)
+	defer SetLenientIDs(false)
+
+	var d Data
+	if err := json.Unmarshal([]byte(`{"type":"people","id":123}`), &d); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if d.ID != "123" {
+		t.Fatalf("expected id %q, got %q", "123", d.ID)
+	}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["id"].(string); !ok {
+		t.Fatalf("expected the marshaled id to be a JSON string, got %#v", out["id"])
+	}
+}
+
+func TestDataUnmarshalStringIDStillWorksWithLenientIDs(t *testing.T) {
+	SetLenientIDs(true)
+	defer SetLenientIDs(false)
+
+	var d Data
+	if err := json.Unmarshal([]byte(`{"type":"people","id":"9"}`), &d); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if d.ID != "9" {
+		t.Fatalf("expected id %q, got %q", "9", d.ID)
+	}
+}
+
+func TestDataUnmarshalIDPresentDistinguishesAbsentEmptyAndPopulatedID(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		wantID    string
+		wantFound bool
+	}{
+		{"absent", `{"type":"people"}`, "", false},
+		{"empty", `{"type":"people","id":""}`, "", true},
+		{"populated", `{"type":"people","id":"9"}`, "9", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Data
+			if err := json.Unmarshal([]byte(tt.payload), &d); err != nil {
+				t.Fatalf("Unmarshal returned error: %v", err)
+			}
+			if d.ID != tt.wantID {
+				t.Fatalf("ID = %q, want %q", d.ID, tt.wantID)
+			}
+			if d.IDPresent != tt.wantFound {
+				t.Fatalf("IDPresent = %v, want %v", d.IDPresent, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestDocumentCloneIsIndependentOfSource(t *testing.T) {
+	doc := &Document{
+		Links: &Links{Self: "https://example.com/articles", Objects: map[string]Link{
+			"describedby": {Href: "https://example.com/schema"},
+		}},
+		Data: &DataContainer{DataArray: []Data{
+			{
+				Type:       "articles",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"title":"Original"}`),
+				Relationships: map[string]Relationship{
+					"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+				},
+				Meta: map[string]interface{}{"views": 1},
+			},
+		}},
+		Included: []Data{{Type: "people", ID: "9"}},
+		Meta:     map[string]interface{}{"total": 1},
+	}
+
+	clone := doc.Clone()
+
+	clone.Data.DataArray[0].Attributes = json.RawMessage(`{"title":"Changed"}`)
+	clone.Data.DataArray[0].Relationships["author"].Data.DataObject.ID = "changed"
+	clone.Data.DataArray[0].Meta["views"] = 2
+	clone.Included[0].ID = "changed"
+	clone.Meta["total"] = 2
+	clone.Links.Self = "https://example.com/changed"
+	clone.Links.Objects["describedby"] = Link{Href: "https://example.com/changed-schema"}
+
+	if string(doc.Data.DataArray[0].Attributes) != `{"title":"Original"}` {
+		t.Fatalf("mutating the clone's attributes affected the source: %s", doc.Data.DataArray[0].Attributes)
+	}
+	if doc.Data.DataArray[0].Relationships["author"].Data.DataObject.ID != "9" {
+		t.Fatal("mutating the clone's relationship data affected the source")
+	}
+	if doc.Data.DataArray[0].Meta["views"] != 1 {
+		t.Fatal("mutating the clone's resource meta affected the source")
+	}
+	if doc.Included[0].ID != "9" {
+		t.Fatal("mutating the clone's included data affected the source")
+	}
+	if doc.Meta["total"] != 1 {
+		t.Fatal("mutating the clone's top-level meta affected the source")
+	}
+	if doc.Links.Self != "https://example.com/articles" {
+		t.Fatal("mutating the clone's links affected the source")
+	}
+	if doc.Links.Objects["describedby"].Href != "https://example.com/schema" {
+		t.Fatal("mutating the clone's link objects affected the source")
+	}
+}
+
+func TestDocumentCloneOfNilIsNil(t *testing.T) {
+	var doc *Document
+	if doc.Clone() != nil {
+		t.Fatal("expected cloning a nil Document to return nil")
+	}
+}
+
+func TestDocumentOneAndMany(t *testing.T) {
+	var empty Document
+	if _, ok := empty.One(); ok {
+		t.Fatal("expected One to report false for a Document with no Data")
+	}
+	if _, ok := empty.Many(); ok {
+		t.Fatal("expected Many to report false for a Document with no Data")
+	}
+
+	single := Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	data, ok := single.One()
+	if !ok || data.ID != "1" {
+		t.Fatalf("expected One to return the single resource, got %+v, %v", data, ok)
+	}
+	if _, ok := single.Many(); ok {
+		t.Fatal("expected Many to report false for a single-object document")
+	}
+
+	many := Document{Data: &DataContainer{DataArray: []Data{{Type: "people", ID: "1"}, {Type: "people", ID: "2"}}}}
+	list, ok := many.Many()
+	if !ok || len(list) != 2 {
+		t.Fatalf("expected Many to return both resources, got %+v, %v", list, ok)
+	}
+	if _, ok := many.One(); ok {
+		t.Fatal("expected One to report false for an array document")
+	}
+}
+
+func TestDocumentEachOnSingleResource(t *testing.T) {
+	doc := Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+
+	var visited []string
+	err := doc.Each(func(d *Data) error {
+		visited = append(visited, d.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each returned error: %v", err)
+	}
+	if len(visited) != 1 || visited[0] != "1" {
+		t.Fatalf("expected one visit to id 1, got %v", visited)
+	}
+}
+
+func TestDocumentEachOnArray(t *testing.T) {
+	doc := Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "people", ID: "1"},
+		{Type: "people", ID: "2"},
+		{Type: "people", ID: "3"},
+	}}}
+
+	var visited []string
+	err := doc.Each(func(d *Data) error {
+		visited = append(visited, d.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each returned error: %v", err)
+	}
+	if len(visited) != 3 || visited[0] != "1" || visited[2] != "3" {
+		t.Fatalf("unexpected visits: %v", visited)
+	}
+}
+
+func TestDocumentEachStopsOnFirstError(t *testing.T) {
+	doc := Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "people", ID: "1"},
+		{Type: "people", ID: "2"},
+		{Type: "people", ID: "3"},
+	}}}
+
+	boom := errors.New("boom")
+	var visited []string
+	err := doc.Each(func(d *Data) error {
+		visited = append(visited, d.ID)
+		if d.ID == "2" {
+			return boom
+		}
+		return nil
+	})
+	if err != boom {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if len(visited) != 2 {
+		t.Fatalf("expected iteration to stop after the second resource, got %v", visited)
+	}
+}
+
+func TestDocumentEachOnNilDataPerformsZeroIterations(t *testing.T) {
+	var doc Document
+	calls := 0
+	if err := doc.Each(func(d *Data) error { calls++; return nil }); err != nil {
+		t.Fatalf("Each returned error: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected zero iterations for a nil DataContainer, got %d", calls)
+	}
+}
+
+func TestDocumentIncludedOfTypeFiltersMixedIncludedArray(t *testing.T) {
+	doc := &Document{
+		Included: []Data{
+			{Type: "authors", ID: "1"},
+			{Type: "comments", ID: "10"},
+			{Type: "authors", ID: "2"},
+		},
+	}
+
+	authors := doc.IncludedOfType("authors")
+	if len(authors) != 2 || authors[0].ID != "1" || authors[1].ID != "2" {
+		t.Fatalf("unexpected authors: %+v", authors)
+	}
+}
+
+func TestDocumentIncludedOfTypeReturnsEmptySliceWhenNoneMatch(t *testing.T) {
+	doc := &Document{Included: []Data{{Type: "comments", ID: "10"}}}
+
+	authors := doc.IncludedOfType("authors")
+	if authors == nil || len(authors) != 0 {
+		t.Fatalf("expected an empty, non-nil slice, got %#v", authors)
+	}
+}
+
+func TestDocumentJSONAPIObjectOmittedWhenNil(t *testing.T) {
+	raw, err := json.Marshal(&Document{})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["jsonapi"]; ok {
+		t.Fatalf("expected no jsonapi member, got %#v", out["jsonapi"])
+	}
+}
+
+func TestJSONAPIObjectMetaRoundTrip(t *testing.T) {
+	doc := Document{JSONAPI: &JSONAPIObject{Meta: map[string]interface{}{"build": "42"}}}
+
+	raw, err := json.Marshal(&doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.JSONAPI == nil || got.JSONAPI.Version != "" || got.JSONAPI.Meta["build"] != "42" {
+		t.Fatalf("unexpected jsonapi object: %+v", got.JSONAPI)
+	}
+}
+
+func TestDocumentResourceIdentifiersSingle(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+
+	ids := doc.ResourceIdentifiers(false)
+	if len(ids) != 1 || ids[0].Type != "articles" || ids[0].ID != "1" {
+		t.Fatalf("unexpected identifiers: %+v", ids)
+	}
+}
+
+func TestDocumentResourceIdentifiersArray(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "articles", ID: "1"},
+		{Type: "articles", ID: "2"},
+	}}}
+
+	ids := doc.ResourceIdentifiers(false)
+	if len(ids) != 2 {
+		t.Fatalf("unexpected identifiers: %+v", ids)
+	}
+}
+
+func TestDocumentResourceIdentifiersIncludesRelationshipsAndDedupes(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author":   {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+				"comments": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "comments", ID: "1"}, {Type: "comments", ID: "2"}}}},
+			},
+		},
+		{
+			Type: "articles", ID: "2",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+		},
+	}}}
+
+	ids := doc.ResourceIdentifiers(true)
+	if len(ids) != 5 {
+		t.Fatalf("expected 5 deduplicated identifiers, got %d: %+v", len(ids), ids)
+	}
+}
+
+func TestDocumentResourceIdentifiersOnNilData(t *testing.T) {
+	doc := &Document{}
+
+	if ids := doc.ResourceIdentifiers(true); ids != nil {
+		t.Fatalf("expected nil identifiers, got %+v", ids)
+	}
+}
+
+func TestDocumentAllAttributesOnHeterogeneousCollection(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"Hello"}`)},
+		{Type: "people", ID: "9", Attributes: json.RawMessage(`{"name":"Alice","age":30}`)},
+	}}}
+
+	all, err := doc.AllAttributes()
+	if err != nil {
+		t.Fatalf("AllAttributes returned error: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 maps, got %+v", all)
+	}
+
+	if all[0]["type"] != "articles" || all[0]["id"] != "1" || all[0]["title"] != "Hello" {
+		t.Fatalf("unexpected first map: %+v", all[0])
+	}
+	if all[1]["type"] != "people" || all[1]["id"] != "9" || all[1]["name"] != "Alice" || all[1]["age"] != float64(30) {
+		t.Fatalf("unexpected second map: %+v", all[1])
+	}
+}
+
+func TestDocumentAllAttributesOnSingleObjectReturnsOneElementSlice(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"Hello"}`),
+	}}}
+
+	all, err := doc.AllAttributes()
+	if err != nil {
+		t.Fatalf("AllAttributes returned error: %v", err)
+	}
+	if len(all) != 1 || all[0]["title"] != "Hello" {
+		t.Fatalf("unexpected result: %+v", all)
+	}
+}
+
+func TestDocumentAllAttributesOnNilDataReturnsEmptySlice(t *testing.T) {
+	doc := &Document{}
+
+	all, err := doc.AllAttributes()
+	if err != nil {
+		t.Fatalf("AllAttributes returned error: %v", err)
+	}
+	if len(all) != 0 {
+		t.Fatalf("expected an empty slice, got %+v", all)
+	}
+}
+
+func TestDocumentSetSelfLinkInitializesLinks(t *testing.T) {
+	doc := &Document{}
+	doc.SetSelfLink("/articles/1")
+
+	if doc.Links == nil || doc.Links.Self != "/articles/1" {
+		t.Fatalf("unexpected links: %+v", doc.Links)
+	}
+}
+
+func TestDocumentSetSelfLinkPreservesExistingLinks(t *testing.T) {
+	doc := &Document{Links: &Links{Next: "/articles?page=2"}}
+	doc.SetSelfLink("/articles?page=1")
+
+	if doc.Links.Self != "/articles?page=1" || doc.Links.Next != "/articles?page=2" {
+		t.Fatalf("unexpected links: %+v", doc.Links)
+	}
+}
+
+func TestDocumentMarshalOmitsAllocatedEmptyLinks(t *testing.T) {
+	doc := &Document{Links: &Links{}}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["links"]; ok {
+		t.Fatalf("expected no links key, got %#v", out["links"])
+	}
+}
+
+func TestLinksIsEmpty(t *testing.T) {
+	cases := []struct {
+		name string
+		l    *Links
+		want bool
+	}{
+		{"nil", nil, true},
+		{"zero value", &Links{}, true},
+		{"bare href", &Links{Self: "/articles/1"}, false},
+		{"objects only", &Links{Objects: map[string]Link{"describedby": {Href: "/schema"}}}, false},
+	}
+
+	for _, c := range cases {
+		if got := c.l.IsEmpty(); got != c.want {
+			t.Errorf("%s: IsEmpty() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestLinksValidateContext(t *testing.T) {
+	cases := []struct {
+		name string
+		l    *Links
+		ctx  LinkContext
+		want []string
+	}{
+		{"nil links", nil, ResourceLinkContext, nil},
+		{"resource with only self", &Links{Self: "/articles/1"}, ResourceLinkContext, nil},
+		{"resource with pagination", &Links{Self: "/articles/1", First: "/articles?page=1"}, ResourceLinkContext, []string{LinkFirst}},
+		{"relationship with self and related", &Links{Self: "/articles/1/relationships/author", Related: "/articles/1/author"}, RelationshipLinkContext, nil},
+		{"relationship with pagination for to-many", &Links{Related: "/articles/1/comments", Last: "/articles/1/comments?page=5"}, RelationshipLinkContext, nil},
+		{"document with describedby", &Links{Self: "/articles", Objects: map[string]Link{LinkDescribedBy: {Href: "/schema"}}}, DocumentLinkContext, nil},
+		{"resource with describedby", &Links{Self: "/articles/1", Objects: map[string]Link{LinkDescribedBy: {Href: "/schema"}}}, ResourceLinkContext, nil},
+	}
+
+	for _, c := range cases {
+		got := c.l.ValidateContext(c.ctx)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: ValidateContext() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDocumentLinksPaginationMetaRoundTrip(t *testing.T) {
+	raw := []byte(`{"data":null,"links":{` +
+		`"self":"https://api.example.com/articles?page=5",` +
+		`"first":{"href":"https://api.example.com/articles?page=1","meta":{"page":1}},` +
+		`"last":{"href":"https://api.example.com/articles?page=10","meta":{"page":10}}` +
+		`}}`)
+
+	var doc Document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if doc.Links.Self != "https://api.example.com/articles?page=5" {
+		t.Fatalf("unexpected self link: %q", doc.Links.Self)
+	}
+	if doc.Links.Last != "https://api.example.com/articles?page=10" {
+		t.Fatalf("unexpected last href: %q", doc.Links.Last)
+	}
+	if got := doc.Links.Objects["last"].Meta["page"]; got != float64(10) {
+		t.Fatalf("unexpected last meta: %v", got)
+	}
+	if got := doc.Links.Objects["first"].Meta["page"]; got != float64(1) {
+		t.Fatalf("unexpected first meta: %v", got)
+	}
+
+	out, err := json.Marshal(&doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var roundtrip Document
+	if err := json.Unmarshal(out, &roundtrip); err != nil {
+		t.Fatalf("Unmarshal of remarshaled document returned error: %v", err)
+	}
+	if roundtrip.Links.First != doc.Links.First || roundtrip.Links.Last != doc.Links.Last {
+		t.Fatalf("hrefs lost across round trip: %+v", roundtrip.Links)
+	}
+	if roundtrip.Links.Objects["last"].Meta["page"] != float64(10) {
+		t.Fatalf("last meta lost across round trip: %+v", roundtrip.Links.Objects["last"])
+	}
+	if roundtrip.Links.Objects["first"].Meta["page"] != float64(1) {
+		t.Fatalf("first meta lost across round trip: %+v", roundtrip.Links.Objects["first"])
+	}
+}
+
+func TestRelationshipDataMetaRoundTripSingle(t *testing.T) {
+	container := RelationshipDataContainer{
+		DataObject: &RelationshipData{Type: "people", ID: "9", Meta: map[string]interface{}{"role": "editor"}},
+	}
+
+	raw, err := json.Marshal(&container)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got RelationshipDataContainer
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.DataObject == nil || got.DataObject.Meta["role"] != "editor" {
+		t.Fatalf("unexpected data object: %+v", got.DataObject)
+	}
+}
+
+func TestRelationshipDataMetaRoundTripArray(t *testing.T) {
+	container := RelationshipDataContainer{
+		DataArray: []RelationshipData{
+			{Type: "tags", ID: "1", Meta: map[string]interface{}{"weight": "0.5"}},
+			{Type: "tags", ID: "2"},
+		},
+	}
+
+	raw, err := json.Marshal(&container)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got RelationshipDataContainer
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if len(got.DataArray) != 2 || got.DataArray[0].Meta["weight"] != "0.5" || got.DataArray[1].Meta != nil {
+		t.Fatalf("unexpected data array: %+v", got.DataArray)
+	}
+}
@@ -0,0 +1,101 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DedupeIncluded collapses duplicate type+id entries in d.Included into one
+// entry each, in their first-seen order, merging attributes and
+// relationships that the spec says must not be duplicated but some servers
+// emit anyway. Where two entries for the same resource disagree on an
+// attribute or relationship value, the first entry's value is kept and the
+// conflict is reported in the returned warnings, one string per duplicate
+// entry that conflicted with what was already merged for its resource.
+func (d *Document) DedupeIncluded() []string {
+	if len(d.Included) == 0 {
+		return nil
+	}
+
+	order := make([]string, 0, len(d.Included))
+	merged := map[string]Data{}
+	var warnings []string
+
+	for _, inc := range d.Included {
+		key := inc.Type + ":" + inc.ID
+
+		existing, ok := merged[key]
+		if !ok {
+			merged[key] = inc
+			order = append(order, key)
+			continue
+		}
+
+		combined, warning := mergeIncluded(existing, inc)
+		merged[key] = combined
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	out := make([]Data, 0, len(order))
+	for _, key := range order {
+		out = append(out, merged[key])
+	}
+	d.Included = out
+
+	return warnings
+}
+
+// mergeIncluded merges b's attributes and relationships into a, keeping a's
+// value wherever both sides set a key to a different value. It returns the
+// merged Data and a warning describing any such conflict, or an empty
+// warning if none was found.
+func mergeIncluded(a, b Data) (Data, string) {
+	var attrsA, attrsB map[string]json.RawMessage
+	unmarshalJSON(a.Attributes, &attrsA)
+	unmarshalJSON(b.Attributes, &attrsB)
+	if attrsA == nil {
+		attrsA = map[string]json.RawMessage{}
+	}
+
+	var conflicts []string
+
+	for key, value := range attrsB {
+		existing, ok := attrsA[key]
+		if !ok {
+			attrsA[key] = value
+			continue
+		}
+		if !bytes.Equal(bytes.TrimSpace(existing), bytes.TrimSpace(value)) {
+			conflicts = append(conflicts, fmt.Sprintf("attribute %q", key))
+		}
+	}
+
+	if raw, err := marshalJSON(attrsA); err == nil {
+		a.Attributes = raw
+	}
+
+	if a.Relationships == nil && b.Relationships != nil {
+		a.Relationships = map[string]Relationship{}
+	}
+	for key, value := range b.Relationships {
+		existing, ok := a.Relationships[key]
+		if !ok {
+			a.Relationships[key] = value
+			continue
+		}
+		if !reflect.DeepEqual(existing, value) {
+			conflicts = append(conflicts, fmt.Sprintf("relationship %q", key))
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return a, ""
+	}
+
+	return a, fmt.Sprintf("jsonapi: conflicting duplicate included resource %s:%s: %s", a.Type, a.ID, strings.Join(conflicts, ", "))
+}
@@ -0,0 +1,67 @@
+package jsonapi
+
+import "testing"
+
+func TestDedupeIncludedCollapsesIdenticalDupes(t *testing.T) {
+	doc := &Document{Included: []Data{
+		{Type: "people", ID: "9", Attributes: []byte(`{"name":"Alice"}`)},
+		{Type: "people", ID: "9", Attributes: []byte(`{"name":"Alice"}`)},
+		{Type: "articles", ID: "1", Attributes: []byte(`{"title":"Hello"}`)},
+	}}
+
+	warnings := doc.DedupeIncluded()
+
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings for identical duplicates, got %v", warnings)
+	}
+	if len(doc.Included) != 2 {
+		t.Fatalf("expected 2 distinct resources, got %d: %+v", len(doc.Included), doc.Included)
+	}
+}
+
+func TestDedupeIncludedReportsConflictingDupes(t *testing.T) {
+	doc := &Document{Included: []Data{
+		{Type: "people", ID: "9", Attributes: []byte(`{"name":"Alice"}`)},
+		{Type: "people", ID: "9", Attributes: []byte(`{"name":"Alicia"}`)},
+	}}
+
+	warnings := doc.DedupeIncluded()
+
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning for the conflicting name, got %v", warnings)
+	}
+	if len(doc.Included) != 1 {
+		t.Fatalf("expected the conflicting dupes to still collapse to 1 resource, got %d", len(doc.Included))
+	}
+	if string(doc.Included[0].Attributes) != `{"name":"Alice"}` {
+		t.Fatalf("expected the first entry's value to win, got %s", doc.Included[0].Attributes)
+	}
+}
+
+func TestDedupeIncludedMergesNonConflictingAttributes(t *testing.T) {
+	doc := &Document{Included: []Data{
+		{Type: "people", ID: "9", Attributes: []byte(`{"name":"Alice"}`)},
+		{Type: "people", ID: "9", Attributes: []byte(`{"email":"alice@example.com"}`)},
+	}}
+
+	warnings := doc.DedupeIncluded()
+
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+
+	var attrs map[string]string
+	if err := doc.Included[0].UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if attrs["name"] != "Alice" || attrs["email"] != "alice@example.com" {
+		t.Fatalf("expected merged attributes, got %+v", attrs)
+	}
+}
+
+func TestDedupeIncludedOnEmptyDocument(t *testing.T) {
+	var doc Document
+	if warnings := doc.DedupeIncluded(); warnings != nil {
+		t.Fatalf("expected no warnings for an empty document, got %v", warnings)
+	}
+}
@@ -0,0 +1,57 @@
+package jsonapi
+
+import "reflect"
+
+// AttributeSchema describes one `jsonapi:"attr,..."` field.
+type AttributeSchema struct {
+	Name      string
+	Kind      reflect.Kind
+	OmitEmpty bool
+	ReadOnly  bool
+}
+
+// RelationshipSchema describes one `jsonapi:"relation,..."` field.
+type RelationshipSchema struct {
+	Name   string
+	ToMany bool
+}
+
+// ResourceSchema is a reflection-based description of a struct's JSON:API
+// shape, for tooling that auto-documents endpoints (e.g. generating
+// OpenAPI-ish field listings). It is not a full OpenAPI generator — just the
+// resource type, attribute names with their Go kinds, and relationship
+// names and cardinality, all derived from the same `jsonapi` tags Marshal
+// reads.
+type ResourceSchema struct {
+	Type          string
+	Attributes    []AttributeSchema
+	Relationships []RelationshipSchema
+}
+
+// Describe returns v's ResourceSchema, reusing the same tag-parsing and
+// caching (typeMetaFor) Marshal uses to walk a struct's fields. v may be a
+// struct or a pointer to one.
+func Describe(v interface{}) ResourceSchema {
+	rt := getType(v)
+	meta := typeMetaFor(rt)
+
+	schema := ResourceSchema{Type: meta.primaryTag.Name}
+
+	for _, f := range meta.attrs {
+		schema.Attributes = append(schema.Attributes, AttributeSchema{
+			Name:      f.Tag.Name,
+			Kind:      rt.Field(f.Index).Type.Kind(),
+			OmitEmpty: f.Tag.OmitEmpty,
+			ReadOnly:  f.Tag.ReadOnly,
+		})
+	}
+
+	for _, f := range meta.relations {
+		schema.Relationships = append(schema.Relationships, RelationshipSchema{
+			Name:   f.Tag.Name,
+			ToMany: rt.Field(f.Index).Type.Kind() == reflect.Slice,
+		})
+	}
+
+	return schema
+}
@@ -0,0 +1,41 @@
+package jsonapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescribeReportsAttributesAndRelationships(t *testing.T) {
+	schema := Describe(&testArticleWithComments{})
+
+	if schema.Type != "articles" {
+		t.Fatalf("unexpected type: %q", schema.Type)
+	}
+
+	wantAttrs := []AttributeSchema{
+		{Name: "title", Kind: reflect.String},
+	}
+	if !reflect.DeepEqual(schema.Attributes, wantAttrs) {
+		t.Fatalf("got attributes %+v, want %+v", schema.Attributes, wantAttrs)
+	}
+
+	wantRels := []RelationshipSchema{
+		{Name: "author", ToMany: false},
+		{Name: "comments", ToMany: true},
+	}
+	if !reflect.DeepEqual(schema.Relationships, wantRels) {
+		t.Fatalf("got relationships %+v, want %+v", schema.Relationships, wantRels)
+	}
+}
+
+func TestDescribeReportsOmitEmptyAndReadOnly(t *testing.T) {
+	schema := Describe(&testProfile{})
+
+	wantAttrs := []AttributeSchema{
+		{Name: "bio", Kind: reflect.String, OmitEmpty: true},
+		{Name: "nickname", Kind: reflect.String},
+	}
+	if !reflect.DeepEqual(schema.Attributes, wantAttrs) {
+		t.Fatalf("got attributes %+v, want %+v", schema.Attributes, wantAttrs)
+	}
+}
@@ -0,0 +1,183 @@
+package jsonapi
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Diff compares old and new, two revisions of the same resource object, and
+// reports which attribute and relationship names differ between them — an
+// added, removed or changed member counts as changed. A nil old or new is
+// treated as a resource with no attributes or relationships, so diffing
+// against one reports every member present on the other side as changed;
+// this covers comparing a just-created or just-deleted resource against its
+// absent prior or current state. Servers that just applied a PATCH can use
+// it to describe what the request actually modified in a webhook or audit
+// log entry. Both return values are sorted for a deterministic order.
+func Diff(old, new *Data) (changedAttrs []string, changedRels []string) {
+	oldAttrs := attributesMapOrNil(old)
+	newAttrs := attributesMapOrNil(new)
+
+	attrNames := map[string]bool{}
+	for name := range oldAttrs {
+		attrNames[name] = true
+	}
+	for name := range newAttrs {
+		attrNames[name] = true
+	}
+	for name := range attrNames {
+		if !reflect.DeepEqual(oldAttrs[name], newAttrs[name]) {
+			changedAttrs = append(changedAttrs, name)
+		}
+	}
+	sort.Strings(changedAttrs)
+
+	oldRels := relationshipsOrNil(old)
+	newRels := relationshipsOrNil(new)
+
+	relNames := map[string]bool{}
+	for name := range oldRels {
+		relNames[name] = true
+	}
+	for name := range newRels {
+		relNames[name] = true
+	}
+	for name := range relNames {
+		if !relationshipLinkageEqual(oldRels[name], newRels[name]) {
+			changedRels = append(changedRels, name)
+		}
+	}
+	sort.Strings(changedRels)
+
+	return changedAttrs, changedRels
+}
+
+// ResourceKey identifies a resource by type and id, the key DiffDocuments
+// reports added and removed resources under.
+type ResourceKey struct {
+	Type string
+	ID   string
+}
+
+// ChangedResource is one resource DiffDocuments found on both sides of the
+// comparison but with different attributes or relationships, carrying the
+// same changed-member names Diff reports for a single resource.
+type ChangedResource struct {
+	ResourceKey
+	ChangedAttrs []string
+	ChangedRels  []string
+}
+
+// DocumentDiff is the result of DiffDocuments.
+type DocumentDiff struct {
+	Added   []ResourceKey
+	Removed []ResourceKey
+	Changed []ChangedResource
+}
+
+// DiffDocuments compares old and new, two revisions of the same compound
+// document, and reports which resources -- primary data or included, it
+// makes no distinction between the two -- were added, removed or changed
+// between them, each identified by type+id. It's the document-wide
+// counterpart to Diff: a change-feed or sync endpoint that only has two
+// full snapshots of a compound response can use it to describe what moved
+// between them, including included resources that weren't part of the
+// primary data on either side. It builds on IndexIncluded for the type+id
+// lookup and Diff for the per-resource comparison, so it runs in time
+// proportional to the number of resources on both sides rather than
+// comparing every pair. A nil old or new is treated as an empty document,
+// so every resource on the other side reports as added or removed.
+func DiffDocuments(old, new *Document) DocumentDiff {
+	oldIndex := indexDocumentResources(old)
+	newIndex := indexDocumentResources(new)
+
+	keys := make([]string, 0, len(oldIndex)+len(newIndex))
+	seen := map[string]bool{}
+	for key := range oldIndex {
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	for key := range newIndex {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var diff DocumentDiff
+	for _, key := range keys {
+		oldData, hasOld := oldIndex[key]
+		newData, hasNew := newIndex[key]
+
+		switch {
+		case hasOld && !hasNew:
+			diff.Removed = append(diff.Removed, ResourceKey{Type: oldData.Type, ID: oldData.ID})
+		case !hasOld && hasNew:
+			diff.Added = append(diff.Added, ResourceKey{Type: newData.Type, ID: newData.ID})
+		default:
+			changedAttrs, changedRels := Diff(&oldData, &newData)
+			if len(changedAttrs) > 0 || len(changedRels) > 0 {
+				diff.Changed = append(diff.Changed, ChangedResource{
+					ResourceKey:  ResourceKey{Type: newData.Type, ID: newData.ID},
+					ChangedAttrs: changedAttrs,
+					ChangedRels:  changedRels,
+				})
+			}
+		}
+	}
+
+	return diff
+}
+
+// indexDocumentResources builds a "type:id" lookup over every resource in
+// doc, primary data and included alike, reusing IndexIncluded for both.
+func indexDocumentResources(doc *Document) map[string]Data {
+	if doc == nil {
+		return nil
+	}
+
+	var primary []Data
+	switch {
+	case doc.Data == nil:
+	case doc.Data.DataObject != nil:
+		primary = []Data{*doc.Data.DataObject}
+	case doc.Data.DataArray != nil:
+		primary = doc.Data.DataArray
+	}
+
+	index := IndexIncluded(primary)
+	for key, d := range IndexIncluded(doc.Included) {
+		index[key] = d
+	}
+	return index
+}
+
+// attributesMapOrNil decodes d.Attributes into a map, or returns nil for a
+// nil d or one whose attributes fail to decode (treated the same as no
+// attributes, so a malformed side of a diff doesn't panic callers).
+func attributesMapOrNil(d *Data) map[string]interface{} {
+	if d == nil {
+		return nil
+	}
+	m, err := d.AttributesMap()
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// relationshipsOrNil returns d.Relationships, or nil for a nil d.
+func relationshipsOrNil(d *Data) map[string]Relationship {
+	if d == nil {
+		return nil
+	}
+	return d.Relationships
+}
+
+// relationshipLinkageEqual reports whether a and b reference the same
+// related resource(s), ignoring their links and meta, which describe the
+// relationship rather than what it points to.
+func relationshipLinkageEqual(a, b Relationship) bool {
+	return a.ExplicitNull == b.ExplicitNull && reflect.DeepEqual(a.Data, b.Data)
+}
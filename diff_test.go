@@ -0,0 +1,189 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDiffDetectsChangedAddedAndRemovedAttributes(t *testing.T) {
+	old := &Data{
+		Type:       "articles",
+		ID:         "1",
+		Attributes: json.RawMessage(`{"title":"Old","views":1,"draft":true}`),
+	}
+	new := &Data{
+		Type:       "articles",
+		ID:         "1",
+		Attributes: json.RawMessage(`{"title":"New","views":1,"subtitle":"added"}`),
+	}
+
+	changedAttrs, changedRels := Diff(old, new)
+
+	for _, want := range []string{"title", "draft", "subtitle"} {
+		if !containsString(changedAttrs, want) {
+			t.Fatalf("expected %q in changedAttrs, got %v", want, changedAttrs)
+		}
+	}
+	if containsString(changedAttrs, "views") {
+		t.Fatalf("did not expect unchanged %q in changedAttrs, got %v", "views", changedAttrs)
+	}
+	if len(changedRels) != 0 {
+		t.Fatalf("expected no changed relationships, got %v", changedRels)
+	}
+}
+
+func TestDiffDetectsNestedAttributeChanges(t *testing.T) {
+	old := &Data{
+		Attributes: json.RawMessage(`{"address":{"city":"Rome","zip":"00100"}}`),
+	}
+	new := &Data{
+		Attributes: json.RawMessage(`{"address":{"city":"Milan","zip":"00100"}}`),
+	}
+
+	changedAttrs, _ := Diff(old, new)
+	if !containsString(changedAttrs, "address") {
+		t.Fatalf("expected %q in changedAttrs, got %v", "address", changedAttrs)
+	}
+}
+
+func TestDiffDetectsChangedRelationship(t *testing.T) {
+	old := &Data{
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}},
+		},
+	}
+	new := &Data{
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "2"}}},
+		},
+	}
+
+	changedAttrs, changedRels := Diff(old, new)
+	if len(changedAttrs) != 0 {
+		t.Fatalf("expected no changed attributes, got %v", changedAttrs)
+	}
+	if !containsString(changedRels, "author") {
+		t.Fatalf("expected %q in changedRels, got %v", "author", changedRels)
+	}
+}
+
+func TestDiffIgnoresUnchangedRelationship(t *testing.T) {
+	linkage := Relationship{Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}}
+	old := &Data{Relationships: map[string]Relationship{"author": linkage}}
+	new := &Data{Relationships: map[string]Relationship{"author": linkage}}
+
+	_, changedRels := Diff(old, new)
+	if len(changedRels) != 0 {
+		t.Fatalf("expected no changed relationships, got %v", changedRels)
+	}
+}
+
+func TestDiffAgainstNilTreatsAllMembersAsChanged(t *testing.T) {
+	created := &Data{
+		Attributes:    json.RawMessage(`{"title":"New"}`),
+		Relationships: map[string]Relationship{"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}}},
+	}
+
+	changedAttrs, changedRels := Diff(nil, created)
+	if !containsString(changedAttrs, "title") {
+		t.Fatalf("expected %q in changedAttrs, got %v", "title", changedAttrs)
+	}
+	if !containsString(changedRels, "author") {
+		t.Fatalf("expected %q in changedRels, got %v", "author", changedRels)
+	}
+
+	changedAttrs, changedRels = Diff(created, nil)
+	if !containsString(changedAttrs, "title") {
+		t.Fatalf("expected %q in changedAttrs, got %v", "title", changedAttrs)
+	}
+	if !containsString(changedRels, "author") {
+		t.Fatalf("expected %q in changedRels, got %v", "author", changedRels)
+	}
+}
+
+func TestDiffBothNilReturnsNoChanges(t *testing.T) {
+	changedAttrs, changedRels := Diff(nil, nil)
+	if len(changedAttrs) != 0 || len(changedRels) != 0 {
+		t.Fatalf("expected no changes, got attrs=%v rels=%v", changedAttrs, changedRels)
+	}
+}
+
+func TestDiffDocumentsDetectsAddedRemovedAndChangedAcrossIncluded(t *testing.T) {
+	old := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"Old"}`),
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+			{Type: "comments", ID: "5", Attributes: json.RawMessage(`{"body":"gone"}`)},
+		},
+	}
+	new := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"New"}`),
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+			{Type: "people", ID: "10", Attributes: json.RawMessage(`{"name":"Bob"}`)},
+		},
+	}
+
+	diff := DiffDocuments(old, new)
+
+	if len(diff.Added) != 1 || diff.Added[0] != (ResourceKey{Type: "people", ID: "10"}) {
+		t.Fatalf("unexpected added: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != (ResourceKey{Type: "comments", ID: "5"}) {
+		t.Fatalf("unexpected removed: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].ResourceKey != (ResourceKey{Type: "articles", ID: "1"}) {
+		t.Fatalf("unexpected changed: %+v", diff.Changed)
+	}
+	if !containsString(diff.Changed[0].ChangedAttrs, "title") {
+		t.Fatalf("expected %q in changed attrs, got %v", "title", diff.Changed[0].ChangedAttrs)
+	}
+}
+
+func TestDiffDocumentsAgainstNilTreatsEveryResourceAsAdded(t *testing.T) {
+	new := &Document{
+		Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Included: []Data{
+			{Type: "people", ID: "9"},
+		},
+	}
+
+	diff := DiffDocuments(nil, new)
+
+	if len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Fatalf("expected only additions, got %+v", diff)
+	}
+	if len(diff.Added) != 2 {
+		t.Fatalf("expected 2 added resources, got %+v", diff.Added)
+	}
+}
+
+func TestDiffDocumentsOnIdenticalDocumentsReportsNoChanges(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"Same"}`),
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+		},
+	}
+
+	diff := DiffDocuments(doc, doc)
+
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+}
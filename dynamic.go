@@ -0,0 +1,41 @@
+package jsonapi
+
+import "fmt"
+
+// MarshalDynamic builds a single-resource *Document straight from attrs, a
+// generic map, for admin tools and other schema-less callers with no Go
+// struct to put `jsonapi` tags on. typ becomes the resource's "type";
+// idKey names the attrs key to lift into Data.ID, left out of the
+// resulting "attributes" once moved. Passing "" for idKey leaves Data.ID
+// empty and attrs untouched, for a create request where the server assigns
+// the id. It returns an error if idKey is non-empty but attrs has no such
+// key, or the key's value isn't a string.
+func MarshalDynamic(typ, idKey string, attrs map[string]interface{}) (*Document, error) {
+	id := ""
+	rest := attrs
+
+	if idKey != "" {
+		v, ok := attrs[idKey]
+		if !ok {
+			return nil, fmt.Errorf("jsonapi: attrs has no %q key to use as id", idKey)
+		}
+		id, ok = v.(string)
+		if !ok {
+			return nil, fmt.Errorf("jsonapi: attrs[%q] is %T, not a string", idKey, v)
+		}
+
+		rest = make(map[string]interface{}, len(attrs))
+		for k, v := range attrs {
+			if k != idKey {
+				rest[k] = v
+			}
+		}
+	}
+
+	d := &Data{Type: typ, ID: id}
+	if err := d.SetAttributesMap(rest); err != nil {
+		return nil, err
+	}
+
+	return &Document{Data: &DataContainer{DataObject: d}}, nil
+}
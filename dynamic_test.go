@@ -0,0 +1,66 @@
+package jsonapi
+
+import "testing"
+
+func TestMarshalDynamicBuildsDocumentFromPlainMap(t *testing.T) {
+	doc, err := MarshalDynamic("articles", "id", map[string]interface{}{
+		"id":    "1",
+		"title": "Hello",
+		"views": float64(42),
+	})
+	if err != nil {
+		t.Fatalf("MarshalDynamic() error = %v", err)
+	}
+
+	data := doc.Data.DataObject
+	if data == nil {
+		t.Fatal("expected doc.Data.DataObject to be set")
+	}
+	if data.Type != "articles" || data.ID != "1" {
+		t.Fatalf("got type=%q id=%q, want type=articles id=1", data.Type, data.ID)
+	}
+
+	attrs, err := data.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap() error = %v", err)
+	}
+	if _, ok := attrs["id"]; ok {
+		t.Fatal("expected idKey to be lifted out of attributes")
+	}
+	if attrs["title"] != "Hello" || attrs["views"] != float64(42) {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+}
+
+func TestMarshalDynamicWithEmptyIDKeyLeavesAttrsAndIDUntouched(t *testing.T) {
+	doc, err := MarshalDynamic("articles", "", map[string]interface{}{"title": "Hello"})
+	if err != nil {
+		t.Fatalf("MarshalDynamic() error = %v", err)
+	}
+
+	data := doc.Data.DataObject
+	if data.ID != "" {
+		t.Fatalf("expected empty id, got %q", data.ID)
+	}
+	attrs, err := data.AttributesMap()
+	if err != nil {
+		t.Fatalf("AttributesMap() error = %v", err)
+	}
+	if attrs["title"] != "Hello" {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+}
+
+func TestMarshalDynamicMissingIDKeyErrors(t *testing.T) {
+	_, err := MarshalDynamic("articles", "id", map[string]interface{}{"title": "Hello"})
+	if err == nil {
+		t.Fatal("expected an error for a missing idKey")
+	}
+}
+
+func TestMarshalDynamicNonStringIDKeyErrors(t *testing.T) {
+	_, err := MarshalDynamic("articles", "id", map[string]interface{}{"id": 1})
+	if err == nil {
+		t.Fatal("expected an error for a non-string idKey value")
+	}
+}
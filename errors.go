@@ -0,0 +1,408 @@
+package jsonapi
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorObject represents an error object as specified here:
+// http://jsonapi.org/format/#errors.
+type ErrorObject struct {
+	ID     string                 `json:"id,omitempty"`
+	Links  *ErrorLinks            `json:"links,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Source *ErrorSource           `json:"source,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+
+	// Extra holds any top-level members not covered by the fields above, so
+	// that nonstandard additions (e.g. a gateway's own "trace_id") survive a
+	// round trip when proxying an upstream error instead of being dropped.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// errorObjectFields lists ErrorObject's own JSON member names, used by
+// MarshalJSON/UnmarshalJSON to tell them apart from Extra's members.
+var errorObjectFields = map[string]bool{
+	"id": true, "links": true, "status": true, "code": true,
+	"title": true, "detail": true, "source": true, "meta": true,
+}
+
+type errorObjectAlias struct {
+	ID     string                 `json:"id,omitempty"`
+	Links  *ErrorLinks            `json:"links,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Source *ErrorSource           `json:"source,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of the error object, merging in
+// e.Extra's members alongside the spec-defined ones.
+func (e ErrorObject) MarshalJSON() ([]byte, error) {
+	b, err := marshalJSON(errorObjectAlias{
+		ID:     e.ID,
+		Links:  e.Links,
+		Status: e.Status,
+		Code:   e.Code,
+		Title:  e.Title,
+		Detail: e.Detail,
+		Source: e.Source,
+		Meta:   e.Meta,
+	})
+	if err != nil || len(e.Extra) == 0 {
+		return b, err
+	}
+
+	var m map[string]json.RawMessage
+	if err := unmarshalJSON(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range e.Extra {
+		if errorObjectFields[k] {
+			continue
+		}
+		raw, err := marshalJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = raw
+	}
+
+	return marshalJSON(m)
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded error object into e, collecting
+// any members it doesn't recognize into e.Extra.
+func (e *ErrorObject) UnmarshalJSON(payload []byte) error {
+	var a errorObjectAlias
+	if err := unmarshalJSON(payload, &a); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := unmarshalJSON(payload, &raw); err != nil {
+		return err
+	}
+
+	extra := map[string]interface{}{}
+	for k, v := range raw {
+		if errorObjectFields[k] {
+			continue
+		}
+		var val interface{}
+		if err := unmarshalJSON(v, &val); err != nil {
+			return err
+		}
+		extra[k] = val
+	}
+
+	*e = ErrorObject{
+		ID:     a.ID,
+		Links:  a.Links,
+		Status: a.Status,
+		Code:   a.Code,
+		Title:  a.Title,
+		Detail: a.Detail,
+		Source: a.Source,
+		Meta:   a.Meta,
+	}
+	if len(extra) > 0 {
+		e.Extra = extra
+	}
+
+	return nil
+}
+
+// ErrorLinks holds the links that may appear on an ErrorObject.
+type ErrorLinks struct {
+	About string `json:"about,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// ErrorSource points to the part of the request document that caused the
+// error, as either a JSON pointer, a query parameter, or a header name.
+type ErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Header    string `json:"header,omitempty"`
+}
+
+// NewValidationError builds an ErrorObject for a request document that
+// failed validation at the given JSON pointer (e.g. "/data/attributes/title").
+func NewValidationError(pointer, detail string) ErrorObject {
+	return ErrorObject{
+		Status: "422",
+		Title:  "Invalid Attribute",
+		Detail: detail,
+		Source: &ErrorSource{Pointer: pointer},
+	}
+}
+
+// ErrorForAttribute builds a validation ErrorObject whose source pointer
+// targets the named attribute (e.g. ErrorForAttribute("title", "is required")
+// points at "/data/attributes/title").
+func ErrorForAttribute(attr, detail string) ErrorObject {
+	return NewValidationError("/data/attributes/"+attr, detail)
+}
+
+// ErrorForRelationship builds a validation ErrorObject whose source pointer
+// targets the named relationship (e.g. ErrorForRelationship("author", "must
+// be set") points at "/data/relationships/author").
+func ErrorForRelationship(rel, detail string) ErrorObject {
+	return NewValidationError("/data/relationships/"+rel, detail)
+}
+
+// FieldError is the minimal shape a struct validation library's per-field
+// error needs to satisfy for ErrorsFromFieldErrors to turn it into an
+// ErrorObject — the same shape go-playground/validator's validator.FieldError
+// already has, so adapting that library means only converting its
+// validator.ValidationErrors into a []FieldError, not reimplementing
+// anything.
+type FieldError interface {
+	error
+	// Field returns the invalid field's name as it appears on the Go
+	// struct, e.g. "Title" for a field tagged `json:"title"`.
+	Field() string
+}
+
+// ErrorsFromFieldErrors converts fieldErrs into one ErrorObject per error,
+// each sourced to a JSON pointer built from the matching field's `jsonapi`
+// attr/relation tag on structType (struct or pointer to struct); a field
+// absent from structType, or with no jsonapi tag, falls back to its own name
+// lowercased, treated as an attribute.
+func ErrorsFromFieldErrors(structType reflect.Type, fieldErrs []FieldError) []ErrorObject {
+	errs := make([]ErrorObject, len(fieldErrs))
+	for i, fe := range fieldErrs {
+		errs[i] = NewValidationError(jsonPointerForField(structType, fe.Field()), fe.Error())
+	}
+	return errs
+}
+
+// jsonPointerForField builds a "/data/attributes/..." or
+// "/data/relationships/..." source pointer for fieldName, preferring
+// structType's jsonapi tag for that field.
+func jsonPointerForField(structType reflect.Type, fieldName string) string {
+	for structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	name := strings.ToLower(fieldName)
+	section := "attributes"
+	if structType != nil && structType.Kind() == reflect.Struct {
+		if f, ok := structType.FieldByName(fieldName); ok {
+			if tag, ok := parseJSONAPITag(f.Tag.Get("jsonapi")); ok {
+				name = tag.Name
+				if tag.Kind == "relation" {
+					section = "relationships"
+				}
+			}
+		}
+	}
+
+	return "/data/" + section + "/" + name
+}
+
+// NewErrorFromHTTP builds an ErrorObject from an HTTP status code, title and
+// detail, so handlers can turn a failed request straight into a spec
+// compliant error body.
+func NewErrorFromHTTP(status int, title, detail string) ErrorObject {
+	return ErrorObject{
+		Status: strconv.Itoa(status),
+		Title:  title,
+		Detail: detail,
+	}
+}
+
+// NewError builds a bare ErrorObject from an HTTP status code, title and
+// detail, for callers that want to attach a source via WithPointer,
+// WithParameter or WithHeader before returning it.
+func NewError(status int, title, detail string) ErrorObject {
+	return ErrorObject{
+		Status: strconv.Itoa(status),
+		Title:  title,
+		Detail: detail,
+	}
+}
+
+// WithPointer returns a copy of e with its source set to the given JSON
+// pointer (e.g. "/data/attributes/title").
+func (e ErrorObject) WithPointer(pointer string) ErrorObject {
+	e.Source = &ErrorSource{Pointer: pointer}
+	return e
+}
+
+// WithParameter returns a copy of e with its source set to the given query
+// parameter name.
+func (e ErrorObject) WithParameter(parameter string) ErrorObject {
+	e.Source = &ErrorSource{Parameter: parameter}
+	return e
+}
+
+// WithHeader returns a copy of e with its source set to the given header
+// name.
+func (e ErrorObject) WithHeader(header string) ErrorObject {
+	e.Source = &ErrorSource{Header: header}
+	return e
+}
+
+// errorIDGenerator, when set via SetErrorIDGenerator, is called by
+// MarshalErrors to fill in ErrorObject.ID on any error that doesn't already
+// have one. It's nil -- disabled -- by default, since minting an id per
+// occurrence is useful for operational tracing but not something every
+// caller wants forced on them.
+var errorIDGenerator func() string
+
+// SetErrorIDGenerator installs generate as the function MarshalErrors uses
+// to auto-fill ErrorObject.ID on any error passed to it with no id already
+// set, so a client can reference that id in a support ticket. Pass nil to
+// disable auto-generation, the default. Like SetTypeNormalizer, this is a
+// process-wide setting. NewRandomErrorID is a ready-to-use generator.
+func SetErrorIDGenerator(generate func() string) {
+	errorIDGenerator = generate
+}
+
+// NewRandomErrorID returns a random 128-bit id rendered as a UUID-like
+// hyphenated hex string (version and variant bits are not set, since
+// ErrorObject.ID only needs to be unique, not a conformant UUID), suitable
+// for use with SetErrorIDGenerator.
+func NewRandomErrorID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// MarshalErrors builds a *Document carrying errs as its top-level "errors"
+// array, for handlers that want to write a spec-compliant error response in
+// one call. If SetErrorIDGenerator has installed a generator, it's used to
+// fill in ID on any error in errs that doesn't already have one.
+func MarshalErrors(errs ...ErrorObject) *Document {
+	if errorIDGenerator != nil {
+		for i := range errs {
+			if errs[i].ID == "" {
+				errs[i].ID = errorIDGenerator()
+			}
+		}
+	}
+	return &Document{Errors: errs}
+}
+
+// httpErrorDocument builds a single-error Document for the given status
+// code, using http.StatusText for the error's title.
+func httpErrorDocument(status int, detail string) *Document {
+	return MarshalErrors(NewErrorFromHTTP(status, http.StatusText(status), detail))
+}
+
+// BadRequest builds a single-error Document for an HTTP 400, for a request
+// that's malformed or fails validation before any resource lookup.
+func BadRequest(detail string) *Document {
+	return httpErrorDocument(http.StatusBadRequest, detail)
+}
+
+// Unauthorized builds a single-error Document for an HTTP 401, for a
+// request missing or carrying invalid authentication credentials.
+func Unauthorized(detail string) *Document {
+	return httpErrorDocument(http.StatusUnauthorized, detail)
+}
+
+// Forbidden builds a single-error Document for an HTTP 403, for an
+// authenticated request the caller isn't allowed to make.
+func Forbidden(detail string) *Document {
+	return httpErrorDocument(http.StatusForbidden, detail)
+}
+
+// NotFound builds a single-error Document for an HTTP 404, for a request
+// targeting a resource that doesn't exist.
+func NotFound(detail string) *Document {
+	return httpErrorDocument(http.StatusNotFound, detail)
+}
+
+// Conflict builds a single-error Document for an HTTP 409, for a request
+// that can't be applied as-is because it conflicts with the resource's
+// current state, e.g. a stale update or a uniqueness violation.
+func Conflict(detail string) *Document {
+	return httpErrorDocument(http.StatusConflict, detail)
+}
+
+// UnprocessableEntity builds a single-error Document for an HTTP 422, for a
+// request that is well-formed but semantically invalid, e.g. one that fails
+// field validation.
+func UnprocessableEntity(detail string) *Document {
+	return httpErrorDocument(http.StatusUnprocessableEntity, detail)
+}
+
+// InternalServerError builds a single-error Document for an HTTP 500, for a
+// request that failed for reasons the caller can't act on.
+func InternalServerError(detail string) *Document {
+	return httpErrorDocument(http.StatusInternalServerError, detail)
+}
+
+// StatusCoder is implemented by an error that knows the HTTP status it
+// should be reported under, so ErrorsFromError can use it instead of
+// falling back to 500.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// JSONAPIError is implemented by an error that knows how to render itself
+// as an ErrorObject, for full control over its status, code and source
+// pointer.
+type JSONAPIError interface {
+	JSONAPIError() ErrorObject
+}
+
+// ErrorsFromError builds a *Document from a Go error, so a handler can
+// return an error and get a spec-compliant error body without building one
+// by hand. It walks err's chain with errors.Unwrap, recursing into both the
+// single-error (Unwrap() error) and multi-error (Unwrap() []error) shapes:
+// an error implementing JSONAPIError is rendered with its own ErrorObject;
+// failing that, one implementing StatusCoder is rendered with that status
+// and its Error() text; any other error stops the walk at the first error
+// with no further Unwrap and becomes a generic 500 carrying its Error()
+// text.
+func ErrorsFromError(err error) *Document {
+	return MarshalErrors(errorObjectsFromError(err)...)
+}
+
+func errorObjectsFromError(err error) []ErrorObject {
+	if err == nil {
+		return nil
+	}
+
+	if je, ok := err.(JSONAPIError); ok {
+		return []ErrorObject{je.JSONAPIError()}
+	}
+
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []ErrorObject
+		for _, e := range u.Unwrap() {
+			errs = append(errs, errorObjectsFromError(e)...)
+		}
+		return errs
+	}
+
+	if sc, ok := err.(StatusCoder); ok {
+		status := sc.StatusCode()
+		return []ErrorObject{NewErrorFromHTTP(status, http.StatusText(status), err.Error())}
+	}
+
+	if u, ok := err.(interface{ Unwrap() error }); ok {
+		if next := u.Unwrap(); next != nil {
+			return errorObjectsFromError(next)
+		}
+	}
+
+	return []ErrorObject{NewErrorFromHTTP(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), err.Error())}
+}
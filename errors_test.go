@@ -0,0 +1,421 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentMarshalRejectsDataAndErrors(t *testing.T) {
+	doc := &Document{
+		Data:   &DataContainer{DataObject: &Data{Type: "people", ID: "1"}},
+		Errors: []ErrorObject{{Title: "boom"}},
+	}
+
+	if _, err := json.Marshal(doc); !errors.Is(err, errDataAndErrors) {
+		t.Fatalf("expected errDataAndErrors, got %v", err)
+	}
+}
+
+func TestDocumentUnmarshalRejectsDataAndErrors(t *testing.T) {
+	raw := []byte(`{"data":{"type":"people","id":"1"},"errors":[{"title":"boom"}]}`)
+
+	var doc Document
+	if err := json.Unmarshal(raw, &doc); !errors.Is(err, errDataAndErrors) {
+		t.Fatalf("expected errDataAndErrors, got %v", err)
+	}
+}
+
+func TestDocumentErrorsOnlyRoundTrip(t *testing.T) {
+	doc := &Document{
+		Errors: []ErrorObject{NewValidationError("/data/attributes/title", "can't be blank")},
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if got.Data != nil {
+		t.Fatalf("expected no data, got %+v", got.Data)
+	}
+	if len(got.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(got.Errors))
+	}
+	if got.Errors[0].Status != "422" || got.Errors[0].Source.Pointer != "/data/attributes/title" {
+		t.Fatalf("unexpected error object: %+v", got.Errors[0])
+	}
+}
+
+func TestDocumentErrorsOnlyOmitsDataKey(t *testing.T) {
+	doc := &Document{Errors: []ErrorObject{{Title: "boom"}}}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["data"]; ok {
+		t.Fatalf("expected no data key for an error document, got %#v", out["data"])
+	}
+}
+
+func TestNewValidationError(t *testing.T) {
+	e := NewValidationError("/data/attributes/name", "is required")
+
+	if e.Status != "422" || e.Title != "Invalid Attribute" || e.Detail != "is required" {
+		t.Fatalf("unexpected error object: %+v", e)
+	}
+	if e.Source == nil || e.Source.Pointer != "/data/attributes/name" {
+		t.Fatalf("unexpected error source: %+v", e.Source)
+	}
+}
+
+func TestMarshalErrorsBuildsErrorDocument(t *testing.T) {
+	doc := MarshalErrors(
+		NewError(422, "Invalid Attribute", "can't be blank").WithPointer("/data/attributes/title"),
+		NewError(409, "Conflict", "already exists").WithParameter("filter[slug]"),
+	)
+
+	if len(doc.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(doc.Errors))
+	}
+	if doc.Errors[0].Status != "422" || doc.Errors[0].Source.Pointer != "/data/attributes/title" {
+		t.Fatalf("unexpected first error: %+v", doc.Errors[0])
+	}
+	if doc.Errors[1].Status != "409" || doc.Errors[1].Source.Parameter != "filter[slug]" {
+		t.Fatalf("unexpected second error: %+v", doc.Errors[1])
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+}
+
+func TestMarshalErrorsLeavesIDEmptyByDefault(t *testing.T) {
+	doc := MarshalErrors(NewError(500, "Internal Server Error", "boom"))
+	if doc.Errors[0].ID != "" {
+		t.Fatalf("expected no auto-generated id by default, got %q", doc.Errors[0].ID)
+	}
+}
+
+func TestMarshalErrorsFillsIDFromGeneratorWhenConfigured(t *testing.T) {
+	defer SetErrorIDGenerator(nil)
+
+	n := 0
+	SetErrorIDGenerator(func() string {
+		n++
+		return fmt.Sprintf("trace-%d", n)
+	})
+
+	doc := MarshalErrors(
+		NewError(500, "Internal Server Error", "boom"),
+		NewError(500, "Internal Server Error", "boom again").WithPointer("/data"),
+	)
+
+	if doc.Errors[0].ID != "trace-1" || doc.Errors[1].ID != "trace-2" {
+		t.Fatalf("expected distinct generated ids, got %+v", doc.Errors)
+	}
+
+	raw1, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	raw2, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(raw1) != string(raw2) {
+		t.Fatalf("expected a stable id within this MarshalErrors call, got %s then %s", raw1, raw2)
+	}
+}
+
+func TestMarshalErrorsPreservesExplicitID(t *testing.T) {
+	defer SetErrorIDGenerator(nil)
+	SetErrorIDGenerator(func() string { return "should-not-be-used" })
+
+	doc := MarshalErrors(ErrorObject{ID: "explicit-id", Title: "boom"})
+	if doc.Errors[0].ID != "explicit-id" {
+		t.Fatalf("expected an explicit id to be preserved, got %q", doc.Errors[0].ID)
+	}
+}
+
+func TestNewRandomErrorIDIsStableWithinACallAndUniqueAcrossCalls(t *testing.T) {
+	a := NewRandomErrorID()
+	b := NewRandomErrorID()
+	if a == "" || b == "" {
+		t.Fatal("expected a non-empty generated id")
+	}
+	if a == b {
+		t.Fatalf("expected two generated ids to differ, got %q twice", a)
+	}
+}
+
+func TestErrorObjectWithHeader(t *testing.T) {
+	e := NewError(400, "Bad Request", "missing header").WithHeader("Authorization")
+
+	if e.Source == nil || e.Source.Header != "Authorization" {
+		t.Fatalf("unexpected error source: %+v", e.Source)
+	}
+}
+
+func TestErrorForAttribute(t *testing.T) {
+	e := ErrorForAttribute("title", "can't be blank")
+
+	if e.Source == nil || e.Source.Pointer != "/data/attributes/title" {
+		t.Fatalf("unexpected error source: %+v", e.Source)
+	}
+	if e.Detail != "can't be blank" {
+		t.Fatalf("unexpected detail: %q", e.Detail)
+	}
+}
+
+func TestErrorForRelationship(t *testing.T) {
+	e := ErrorForRelationship("author", "must be set")
+
+	if e.Source == nil || e.Source.Pointer != "/data/relationships/author" {
+		t.Fatalf("unexpected error source: %+v", e.Source)
+	}
+}
+
+func TestNewErrorFromHTTP(t *testing.T) {
+	e := NewErrorFromHTTP(404, "Not Found", "no such resource")
+
+	if e.Status != "404" || e.Title != "Not Found" || e.Detail != "no such resource" {
+		t.Fatalf("unexpected error object: %+v", e)
+	}
+	if e.Source != nil {
+		t.Fatalf("expected no source, got %+v", e.Source)
+	}
+}
+
+func TestTypedHTTPErrorConstructorsSetStatusTitleAndDetail(t *testing.T) {
+	cases := []struct {
+		name   string
+		build  func(string) *Document
+		status string
+		title  string
+	}{
+		{"BadRequest", BadRequest, "400", "Bad Request"},
+		{"Unauthorized", Unauthorized, "401", "Unauthorized"},
+		{"Forbidden", Forbidden, "403", "Forbidden"},
+		{"NotFound", NotFound, "404", "Not Found"},
+		{"Conflict", Conflict, "409", "Conflict"},
+		{"UnprocessableEntity", UnprocessableEntity, "422", "Unprocessable Entity"},
+		{"InternalServerError", InternalServerError, "500", "Internal Server Error"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc := c.build("something went wrong")
+
+			if len(doc.Errors) != 1 {
+				t.Fatalf("expected exactly 1 error, got %+v", doc.Errors)
+			}
+			e := doc.Errors[0]
+			if e.Status != c.status {
+				t.Fatalf("unexpected status: got %q, want %q", e.Status, c.status)
+			}
+			if e.Title != c.title {
+				t.Fatalf("unexpected title: got %q, want %q", e.Title, c.title)
+			}
+			if e.Detail != "something went wrong" {
+				t.Fatalf("unexpected detail: %q", e.Detail)
+			}
+			if doc.Data != nil {
+				t.Fatalf("expected no data alongside errors, got %+v", doc.Data)
+			}
+		})
+	}
+}
+
+func TestErrorObjectPreservesUnknownMembersOnRoundTrip(t *testing.T) {
+	raw := []byte(`{"status":"502","title":"Upstream Error","trace_id":"abc123"}`)
+
+	var e ErrorObject
+	if err := json.Unmarshal(raw, &e); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if e.Extra["trace_id"] != "abc123" {
+		t.Fatalf("expected trace_id to be preserved, got %+v", e.Extra)
+	}
+
+	out, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("failed to unmarshal output: %v", err)
+	}
+	if got["trace_id"] != "abc123" {
+		t.Fatalf("expected trace_id %q in output, got %+v", "abc123", got)
+	}
+	if got["status"] != "502" || got["title"] != "Upstream Error" {
+		t.Fatalf("expected standard fields preserved, got %+v", got)
+	}
+}
+
+func TestErrorObjectWithoutUnknownMembersOmitsExtra(t *testing.T) {
+	raw := []byte(`{"status":"404","title":"Not Found"}`)
+
+	var e ErrorObject
+	if err := json.Unmarshal(raw, &e); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if e.Extra != nil {
+		t.Fatalf("expected nil Extra, got %+v", e.Extra)
+	}
+}
+
+type mockFieldError struct {
+	field, msg string
+}
+
+func (e mockFieldError) Error() string { return e.msg }
+func (e mockFieldError) Field() string { return e.field }
+
+func TestErrorsFromFieldErrorsMapsRequiredFieldToAttributePointer(t *testing.T) {
+	fieldErrs := []FieldError{
+		mockFieldError{field: "Name", msg: "is required"},
+	}
+
+	errs := ErrorsFromFieldErrors(reflect.TypeOf(testPerson{}), fieldErrs)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errs))
+	}
+	if errs[0].Source == nil || errs[0].Source.Pointer != "/data/attributes/name" {
+		t.Fatalf("unexpected source: %+v", errs[0].Source)
+	}
+	if errs[0].Detail != "is required" {
+		t.Fatalf("unexpected detail: %q", errs[0].Detail)
+	}
+}
+
+func TestErrorsFromFieldErrorsMapsRelationField(t *testing.T) {
+	fieldErrs := []FieldError{
+		mockFieldError{field: "Articles", msg: "must not be empty"},
+	}
+
+	errs := ErrorsFromFieldErrors(reflect.TypeOf(testPerson{}), fieldErrs)
+
+	if errs[0].Source == nil || errs[0].Source.Pointer != "/data/relationships/articles" {
+		t.Fatalf("unexpected source: %+v", errs[0].Source)
+	}
+}
+
+func TestErrorsFromFieldErrorsFallsBackToLowercasedFieldName(t *testing.T) {
+	fieldErrs := []FieldError{
+		mockFieldError{field: "Missing", msg: "unknown field"},
+	}
+
+	errs := ErrorsFromFieldErrors(reflect.TypeOf(testPerson{}), fieldErrs)
+
+	if errs[0].Source == nil || errs[0].Source.Pointer != "/data/attributes/missing" {
+		t.Fatalf("unexpected source: %+v", errs[0].Source)
+	}
+}
+
+// mockJSONAPIError wraps an ErrorObject to use as a Go error whose
+// JSONAPIError method is in full control of its rendering.
+type mockJSONAPIError struct {
+	ErrorObject
+}
+
+func (e mockJSONAPIError) Error() string             { return e.Detail }
+func (e mockJSONAPIError) JSONAPIError() ErrorObject { return e.ErrorObject }
+
+// mockStatusError is a plain error that only knows its HTTP status code.
+type mockStatusError struct {
+	status int
+	msg    string
+}
+
+func (e mockStatusError) Error() string   { return e.msg }
+func (e mockStatusError) StatusCode() int { return e.status }
+
+func TestErrorsFromErrorUnwrapsToJSONAPIError(t *testing.T) {
+	inner := mockJSONAPIError{ErrorObject: ErrorForAttribute("title", "is required")}
+	err := fmt.Errorf("creating person: %w", inner)
+
+	doc := ErrorsFromError(err)
+
+	if len(doc.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(doc.Errors))
+	}
+	if doc.Errors[0].Status != "422" {
+		t.Fatalf("expected status 422, got %q", doc.Errors[0].Status)
+	}
+	if doc.Errors[0].Source == nil || doc.Errors[0].Source.Pointer != "/data/attributes/title" {
+		t.Fatalf("unexpected source: %+v", doc.Errors[0].Source)
+	}
+}
+
+func TestErrorsFromErrorUnwrapsToStatusCoder(t *testing.T) {
+	err := fmt.Errorf("fetching person: %w", mockStatusError{status: 404, msg: "person not found"})
+
+	doc := ErrorsFromError(err)
+
+	if len(doc.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(doc.Errors))
+	}
+	if doc.Errors[0].Status != "404" {
+		t.Fatalf("expected status 404, got %q", doc.Errors[0].Status)
+	}
+	if doc.Errors[0].Detail != "person not found" {
+		t.Fatalf("unexpected detail: %q", doc.Errors[0].Detail)
+	}
+}
+
+func TestErrorsFromErrorFallsBackToGeneric500(t *testing.T) {
+	err := errors.New("boom")
+
+	doc := ErrorsFromError(err)
+
+	if len(doc.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(doc.Errors))
+	}
+	if doc.Errors[0].Status != "500" {
+		t.Fatalf("expected status 500, got %q", doc.Errors[0].Status)
+	}
+	if doc.Errors[0].Detail != "boom" {
+		t.Fatalf("unexpected detail: %q", doc.Errors[0].Detail)
+	}
+}
+
+func TestErrorsFromErrorFlattensMultiError(t *testing.T) {
+	err := errors.Join(
+		mockJSONAPIError{ErrorObject: ErrorForAttribute("title", "is required")},
+		mockStatusError{status: 409, msg: "conflict"},
+	)
+
+	doc := ErrorsFromError(err)
+
+	if len(doc.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(doc.Errors))
+	}
+	if doc.Errors[0].Status != "422" || doc.Errors[1].Status != "409" {
+		t.Fatalf("unexpected statuses: %q, %q", doc.Errors[0].Status, doc.Errors[1].Status)
+	}
+}
+
+func TestErrorsFromErrorOnNilReturnsEmptyDocument(t *testing.T) {
+	doc := ErrorsFromError(nil)
+
+	if len(doc.Errors) != 0 {
+		t.Fatalf("expected no errors, got %d", len(doc.Errors))
+	}
+}
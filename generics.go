@@ -0,0 +1,140 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// TypedDocument wraps a *Document with compile-time type safety for T, the
+// expected resource type, deferring to Unmarshal to decode attributes and
+// relationships the same way the non-generic API does.
+type TypedDocument[T any] struct {
+	Doc *Document
+}
+
+// NewTypedDocument wraps doc for typed access via Resource/Resources.
+func NewTypedDocument[T any](doc *Document) TypedDocument[T] {
+	return TypedDocument[T]{Doc: doc}
+}
+
+// Resource decodes the document's single primary resource into a T. It
+// returns an error if the document's data is not a single object.
+func (t TypedDocument[T]) Resource() (T, error) {
+	var v T
+	if t.Doc == nil || t.Doc.Data == nil || t.Doc.Data.DataObject == nil {
+		return v, errors.New("jsonapi: document data is not a single resource")
+	}
+	err := Unmarshal(t.Doc, &v)
+	return v, err
+}
+
+// Resources decodes the document's primary data array into a []T. It
+// returns an error if the document's data is not an array.
+func (t TypedDocument[T]) Resources() ([]T, error) {
+	if t.Doc == nil || t.Doc.Data == nil || t.Doc.Data.DataArray == nil {
+		return nil, errors.New("jsonapi: document data is not an array")
+	}
+	var v []T
+	err := Unmarshal(t.Doc, &v)
+	return v, err
+}
+
+// MarshalTyped builds a *Document from items via Marshal, then checks that
+// every resulting resource came out tagged with typ, catching a T whose
+// `jsonapi:"primary,..."` tag doesn't match the type the caller expects.
+func MarshalTyped[T any](typ string, items ...T) (*Document, error) {
+	doc, err := Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range doc.Data.DataArray {
+		if d.Type != typ {
+			return nil, fmt.Errorf("jsonapi: expected resource type %q, got %q", typ, d.Type)
+		}
+	}
+
+	return doc, nil
+}
+
+// DecodeResource reads a JSON:API document from r and decodes its single
+// primary resource into a T. It returns an error if the document's data is
+// an array rather than a single resource.
+func DecodeResource[T any](r io.Reader) (T, error) {
+	var v T
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return v, err
+	}
+	return NewTypedDocument[T](&doc).Resource()
+}
+
+// DecodeCollection reads a JSON:API document from r and decodes its primary
+// data array into a []T. It returns an error if the document's data is a
+// single resource rather than an array.
+func DecodeCollection[T any](r io.Reader) ([]T, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return NewTypedDocument[T](&doc).Resources()
+}
+
+// BatchError reports the elements of a DataArray that failed to decode,
+// each tagged with the index of the element that failed, for a bulk import
+// endpoint that needs to report exactly which items were rejected.
+type BatchError struct {
+	Errors []ErrorObject
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("jsonapi: %d element(s) failed to decode", len(e.Errors))
+}
+
+// UnmarshalCollectionPartial decodes doc's primary data array into []T like
+// Unmarshal, except a single bad element doesn't abort the whole batch: it's
+// recorded in the returned *BatchError, pointer-indexed to its position
+// (e.g. "/data/2"), and decoding continues with the rest. It returns the
+// successfully decoded elements, in document order, skipping failures,
+// alongside a *BatchError naming each failure, or a nil error if every
+// element decoded. It returns an error if doc.Data is not an array.
+func UnmarshalCollectionPartial[T any](doc *Document, opts ...UnmarshalOption) ([]T, error) {
+	if doc.Data == nil || doc.Data.DataArray == nil {
+		return nil, errors.New("jsonapi: document data is not an array")
+	}
+
+	o := unmarshalOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	index := IndexIncluded(doc.Included)
+
+	var out []T
+	var batchErr BatchError
+	for i, d := range doc.Data.DataArray {
+		if o.expectedType != "" && d.Type == "" {
+			d.Type = o.expectedType
+		}
+
+		var v T
+		if err := dataToStruct(d, reflect.ValueOf(&v).Elem(), index, o.timeFormat, o.timeLocation, o.keyTransform, o.readOnly, o.caseInsensitive, o.coerceScalars); err != nil {
+			batchErr.Errors = append(batchErr.Errors, ErrorObject{
+				Status: "422",
+				Title:  "Decode Error",
+				Detail: err.Error(),
+				Source: &ErrorSource{Pointer: fmt.Sprintf("/data/%d", i)},
+			})
+			continue
+		}
+		out = append(out, v)
+	}
+
+	if len(batchErr.Errors) > 0 {
+		return out, &batchErr
+	}
+	return out, nil
+}
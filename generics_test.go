@@ -0,0 +1,193 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMarshalTypedBuildsDocumentFromSlice(t *testing.T) {
+	people := []testPerson{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}}
+
+	doc, err := MarshalTyped("people", people...)
+	if err != nil {
+		t.Fatalf("MarshalTyped returned error: %v", err)
+	}
+
+	list, ok := doc.Many()
+	if !ok || len(list) != 2 {
+		t.Fatalf("expected 2 resources, got %+v, %v", list, ok)
+	}
+}
+
+func TestMarshalTypedRejectsMismatchedType(t *testing.T) {
+	people := []testPerson{{ID: "1", Name: "Alice"}}
+
+	if _, err := MarshalTyped("articles", people...); err == nil {
+		t.Fatal("expected an error for a mismatched resource type")
+	}
+}
+
+func TestTypedDocumentResource(t *testing.T) {
+	doc, err := Marshal(testPerson{ID: "1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	typed := NewTypedDocument[testPerson](doc)
+	person, err := typed.Resource()
+	if err != nil {
+		t.Fatalf("Resource returned error: %v", err)
+	}
+	if person.ID != "1" || person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+
+	if _, err := typed.Resources(); err == nil {
+		t.Fatal("expected Resources to error on a single-object document")
+	}
+}
+
+func TestTypedDocumentResources(t *testing.T) {
+	doc, err := Marshal([]testPerson{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	typed := NewTypedDocument[testPerson](doc)
+	people, err := typed.Resources()
+	if err != nil {
+		t.Fatalf("Resources returned error: %v", err)
+	}
+	if len(people) != 2 {
+		t.Fatalf("expected 2 people, got %+v", people)
+	}
+
+	if _, err := typed.Resource(); err == nil {
+		t.Fatal("expected Resource to error on an array document")
+	}
+}
+
+func TestDecodeResourceDecodesSingleResource(t *testing.T) {
+	doc, err := Marshal(testPerson{ID: "1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+
+	person, err := DecodeResource[testPerson](bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("DecodeResource returned error: %v", err)
+	}
+	if person.ID != "1" || person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+}
+
+func TestDecodeResourceRejectsArrayDocument(t *testing.T) {
+	doc, err := Marshal([]testPerson{{ID: "1", Name: "Alice"}})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+
+	if _, err := DecodeResource[testPerson](bytes.NewReader(raw)); err == nil {
+		t.Fatal("expected an error decoding an array document as a single resource")
+	}
+}
+
+func TestDecodeCollectionDecodesArray(t *testing.T) {
+	doc, err := Marshal([]testPerson{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+
+	people, err := DecodeCollection[testPerson](bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("DecodeCollection returned error: %v", err)
+	}
+	if len(people) != 2 {
+		t.Fatalf("expected 2 people, got %+v", people)
+	}
+}
+
+func TestDecodeCollectionRejectsSingleResourceDocument(t *testing.T) {
+	doc, err := Marshal(testPerson{ID: "1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+
+	if _, err := DecodeCollection[testPerson](bytes.NewReader(raw)); err == nil {
+		t.Fatal("expected an error decoding a single-resource document as a collection")
+	}
+}
+
+type testBulkWidget struct {
+	ID  string `jsonapi:"primary,widgets"`
+	Age int    `jsonapi:"attr,age"`
+}
+
+func TestUnmarshalCollectionPartialReturnsGoodElementsAndIndexedErrors(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "widgets", ID: "1", Attributes: json.RawMessage(`{"age":30}`)},
+		{Type: "widgets", ID: "2", Attributes: json.RawMessage(`{"age":"not-a-number"}`)},
+		{Type: "widgets", ID: "3", Attributes: json.RawMessage(`{"age":40}`)},
+	}}}
+
+	widgets, err := UnmarshalCollectionPartial[testBulkWidget](doc)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a *BatchError, got %v", err)
+	}
+	if len(batchErr.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %d: %+v", len(batchErr.Errors), batchErr.Errors)
+	}
+	if got := batchErr.Errors[0].Source.Pointer; got != "/data/1" {
+		t.Fatalf("expected error pointer /data/1, got %q", got)
+	}
+
+	if len(widgets) != 2 {
+		t.Fatalf("expected 2 successfully decoded widgets, got %+v", widgets)
+	}
+	if widgets[0].ID != "1" || widgets[1].ID != "3" {
+		t.Fatalf("unexpected widgets: %+v", widgets)
+	}
+}
+
+func TestUnmarshalCollectionPartialWithAllValidElementsReturnsNilError(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "widgets", ID: "1", Attributes: json.RawMessage(`{"age":30}`)},
+	}}}
+
+	widgets, err := UnmarshalCollectionPartial[testBulkWidget](doc)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(widgets) != 1 {
+		t.Fatalf("expected 1 widget, got %+v", widgets)
+	}
+}
+
+func TestUnmarshalCollectionPartialRejectsSingleResourceDocument(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "widgets", ID: "1"}}}
+
+	if _, err := UnmarshalCollectionPartial[testBulkWidget](doc); err == nil {
+		t.Fatal("expected an error for a document whose data is not an array")
+	}
+}
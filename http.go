@@ -0,0 +1,497 @@
+package jsonapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// MediaType is the JSON:API media type used for requests and responses, per
+// http://jsonapi.org/format/#content-negotiation.
+const MediaType = "application/vnd.api+json"
+
+// MaxBindBodyBytes caps the request body Bind will read, guarding handlers
+// against oversized or unbounded request bodies.
+const MaxBindBodyBytes = 5 << 20 // 5 MiB
+
+// BindValidationError is returned by Bind when a ValidateFunc installed via
+// WithValidation rejects the decoded value; Errors holds one ErrorObject per
+// rejected field, suitable for passing straight to MarshalErrors.
+type BindValidationError struct {
+	Errors []ErrorObject
+}
+
+func (e *BindValidationError) Error() string {
+	return fmt.Sprintf("jsonapi: validation failed on %d field(s)", len(e.Errors))
+}
+
+// ValidateFunc validates v and reports one FieldError per invalid field, or
+// nil if v is valid. A go-playground/validator Validate.Struct call can be
+// adapted into a ValidateFunc by converting its validator.ValidationErrors
+// into a []FieldError, since validator.FieldError already satisfies FieldError.
+type ValidateFunc func(v interface{}) []FieldError
+
+type bindOptions struct {
+	validate     ValidateFunc
+	expectedType string
+}
+
+// BindOption configures Bind.
+type BindOption func(*bindOptions)
+
+// WithValidation installs validate as a post-decode check: Bind calls it on
+// the decoded value and, if it reports any field errors, returns them as a
+// *BindValidationError instead of a nil error.
+func WithValidation(validate ValidateFunc) BindOption {
+	return func(o *bindOptions) {
+		o.validate = validate
+	}
+}
+
+// WithExpectedResourceType tells Bind to reject a request whose primary
+// resource type isn't typ with a *TypeConflictError, per the spec's
+// requirement that a server performing a create or update respond 409
+// Conflict if a client posts the wrong type for the endpoint (e.g. posting
+// a "comments" resource to an "articles" endpoint).
+func WithExpectedResourceType(typ string) BindOption {
+	return func(o *bindOptions) {
+		o.expectedType = typ
+	}
+}
+
+// TypeConflictError is returned by Bind when WithExpectedResourceType is set
+// and the request's primary resource type doesn't match it. It implements
+// StatusCoder so ErrorsFromError renders it as an HTTP 409.
+type TypeConflictError struct {
+	Got, Want string
+}
+
+func (e *TypeConflictError) Error() string {
+	return fmt.Sprintf("jsonapi: resource type %q does not match expected type %q", e.Got, e.Want)
+}
+
+func (e *TypeConflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+// MalformedError is returned by Bind when the request body isn't a valid
+// JSON:API document at all — a JSON syntax error, or valid JSON of the
+// wrong shape, such as a bare array or a string — as opposed to a
+// structurally sound document that merely fails validation. Offset holds
+// the input byte offset json reported for the failure, when available, so
+// a handler can report a more useful 400 than an opaque decode error.
+type MalformedError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *MalformedError) Error() string {
+	if e.Offset > 0 {
+		return fmt.Sprintf("jsonapi: request body is not a valid JSON:API document (offset %d): %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("jsonapi: request body is not a valid JSON:API document: %v", e.Err)
+}
+
+func (e *MalformedError) Unwrap() error {
+	return e.Err
+}
+
+// newMalformedError wraps err, a json.Unmarshal failure, in a
+// *MalformedError, pulling the byte offset out of it when err is a
+// *json.SyntaxError or *json.UnmarshalTypeError.
+func newMalformedError(err error) *MalformedError {
+	me := &MalformedError{Err: err}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		me.Offset = syntaxErr.Offset
+		return me
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		me.Offset = typeErr.Offset
+	}
+
+	return me
+}
+
+// Bind reads r's body, validates its Content-Type, unmarshals it into a
+// Document, and decodes the primary resource's attributes and relationships
+// into v using the jsonapi tags. It is the inbound counterpart to
+// WriteDocument, giving handlers a one-liner for request decoding. The body
+// is capped at MaxBindBodyBytes to prevent abuse. A leading UTF-8 byte order
+// mark, which some clients send despite the JSON spec disallowing one, is
+// tolerated. If the body isn't valid JSON, or is JSON of the wrong shape
+// (e.g. a bare array), Bind returns a *MalformedError carrying the byte
+// offset of the failure when available. If WithExpectedResourceType is set
+// and the primary resource's type doesn't match, Bind returns a
+// *TypeConflictError before attempting to decode it. If a ValidateFunc is
+// installed via WithValidation, Bind runs it on the decoded value and
+// returns a *BindValidationError if it reports any field errors.
+func Bind(r *http.Request, v interface{}, opts ...BindOption) error {
+	var o bindOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != MediaType {
+		return errors.New("jsonapi: request Content-Type is not " + MediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxBindBodyBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > MaxBindBodyBytes {
+		return errors.New("jsonapi: request body exceeds MaxBindBodyBytes")
+	}
+
+	var doc Document
+	if err := unmarshalJSON(stripBOM(body), &doc); err != nil {
+		return newMalformedError(err)
+	}
+
+	if o.expectedType != "" {
+		got := ""
+		if doc.Data != nil && doc.Data.DataObject != nil {
+			got = doc.Data.DataObject.Type
+		}
+		if got != o.expectedType {
+			return &TypeConflictError{Got: got, Want: o.expectedType}
+		}
+	}
+
+	if err := Unmarshal(&doc, v); err != nil {
+		return err
+	}
+
+	if o.validate != nil {
+		if fieldErrs := o.validate(v); len(fieldErrs) > 0 {
+			return &BindValidationError{Errors: ErrorsFromFieldErrors(reflect.TypeOf(v), fieldErrs)}
+		}
+	}
+
+	return nil
+}
+
+// WriteOption configures WriteDocument.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	pretty bool
+}
+
+// WithPrettyPrint tells WriteDocument to indent its JSON output, for manual
+// exploration of an API response in a browser or terminal without piping it
+// through an external formatter. Production traffic should leave this off;
+// PrettyPrintRequested helps a handler apply it only when a client asked for
+// it.
+func WithPrettyPrint() WriteOption {
+	return func(o *writeOptions) { o.pretty = true }
+}
+
+// PrettyPrintRequested reports whether r's query carries param set to a
+// truthy value ("1", "true" or "yes", case-insensitively), for a handler
+// that wants to opt into WithPrettyPrint based on a debug query parameter
+// (e.g. "?pretty=1") rather than unconditionally.
+func PrettyPrintRequested(r *http.Request, param string) bool {
+	switch strings.ToLower(r.URL.Query().Get(param)) {
+	case "1", "true", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// WriteDocument marshals doc and writes it to w with the JSON:API
+// Content-Type header and the given status code, so handlers don't have to
+// remember to set the header on every response. By default the body is
+// compact; WithPrettyPrint indents it.
+func WriteDocument(w http.ResponseWriter, status int, doc *Document, opts ...WriteOption) error {
+	var o writeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var b []byte
+	var err error
+	if o.pretty {
+		b, err = json.MarshalIndent(doc, "", "  ")
+	} else {
+		b, err = marshalJSON(doc)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteDocumentGzip writes doc like WriteDocument, but compresses the body
+// and sets Content-Encoding: gzip when r's Accept-Encoding header indicates
+// the client supports it, which is worth doing for a document with a large
+// included array. It falls back to plain output when the client doesn't
+// advertise gzip support.
+func WriteDocumentGzip(w http.ResponseWriter, r *http.Request, status int, doc *Document) error {
+	b, err := marshalJSON(doc)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", MediaType)
+
+	if !acceptsGzip(r) {
+		w.WriteHeader(status)
+		_, err = w.Write(b)
+		return err
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(status)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// ValidatingWriter wraps an http.ResponseWriter, running Document.Validate
+// on every document passed to WriteValidated before committing the status
+// code, so a server that accidentally produces an invalid payload (e.g. a
+// resource object with no type) gets caught in development instead of
+// shipped to a client. Validation is on by default; set SkipValidation to
+// skip the cost in production once the server's output is trusted.
+type ValidatingWriter struct {
+	http.ResponseWriter
+	// SkipValidation disables the Validate call, making WriteValidated
+	// behave exactly like WriteDocument. Off by default.
+	SkipValidation bool
+	// ValidateOptions are passed through to every Document.Validate call.
+	ValidateOptions []ValidateOption
+}
+
+// NewValidatingWriter wraps w in a ValidatingWriter with validation enabled.
+func NewValidatingWriter(w http.ResponseWriter) *ValidatingWriter {
+	return &ValidatingWriter{ResponseWriter: w}
+}
+
+// WriteValidated validates doc and, if it fails, writes a 500 response
+// carrying an internal error document instead of doc itself — the point
+// being to catch the server's own bug rather than hand the client a
+// malformed payload. SkipValidation bypasses the check and writes doc
+// unconditionally, the same as calling WriteDocument directly.
+func (vw *ValidatingWriter) WriteValidated(status int, doc *Document) error {
+	if vw.SkipValidation {
+		return WriteDocument(vw.ResponseWriter, status, doc)
+	}
+
+	if err := doc.Validate(vw.ValidateOptions...); err != nil {
+		errDoc := MarshalErrors(NewErrorFromHTTP(http.StatusInternalServerError, "Internal Server Error", "the server produced an invalid JSON:API document: "+err.Error()))
+		return WriteDocument(vw.ResponseWriter, http.StatusInternalServerError, errDoc)
+	}
+
+	return WriteDocument(vw.ResponseWriter, status, doc)
+}
+
+// acceptsGzip reports whether r's Accept-Encoding header lists gzip among
+// the encodings it accepts.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.EqualFold(strings.TrimSpace(enc), "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMediaType parses a Content-Type or Accept header value as the
+// JSON:API media type, extracting the "ext" and "profile" parameters
+// introduced in 1.1. Each is a space-separated list of URIs; either comes
+// back nil if the header carries no such parameter. It returns an error if
+// the header doesn't parse or its base type isn't MediaType.
+func ParseMediaType(header string) (ext []string, profile []string, err error) {
+	mt, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mt != MediaType {
+		return nil, nil, fmt.Errorf("jsonapi: media type %q is not %s", mt, MediaType)
+	}
+
+	if v := params["ext"]; v != "" {
+		ext = strings.Fields(v)
+	}
+	if v := params["profile"]; v != "" {
+		profile = strings.Fields(v)
+	}
+	return ext, profile, nil
+}
+
+// RequireExtensions wraps next with a check that any "ext" URI requested in
+// a request's Content-Type header is one of supported. A request naming an
+// unsupported extension gets a 406 Not Acceptable response instead of
+// reaching next, per the spec's guidance for a server that can't honor a
+// requested extension; a request with no "ext" parameter always passes
+// through.
+func RequireExtensions(supported []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(supported))
+	for _, uri := range supported {
+		allowed[uri] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ext, _, err := ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, uri := range ext {
+			if !allowed[uri] {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMediaType wraps next with a check that any request carrying a body
+// declares the JSON:API media type in its Content-Type header, per the
+// spec's content negotiation rules. A request whose Content-Type doesn't
+// match gets a 415 Unsupported Media Type response instead of reaching
+// next; media type parameters (e.g. the atomic operations ext) are ignored
+// for this check.
+func RequireMediaType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mt != MediaType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ContentTypeOption configures RequireMediaTypeStrict's tolerance for
+// Content-Type parameters beyond the bare JSON:API media type.
+type ContentTypeOption func(*contentTypeOptions)
+
+type contentTypeOptions struct {
+	allowCharsetUTF8 bool
+	allowedExt       map[string]bool
+	allowedProfile   map[string]bool
+}
+
+// AllowCharsetUTF8 tells RequireMediaTypeStrict to accept a "charset=utf-8"
+// parameter alongside the JSON:API media type even though the spec forbids
+// media type parameters other than "ext"/"profile" -- a common addition by
+// proxies and load balancers that RequireMediaType's looser check already
+// tolerates by ignoring parameters altogether.
+func AllowCharsetUTF8() ContentTypeOption {
+	return func(o *contentTypeOptions) { o.allowCharsetUTF8 = true }
+}
+
+// AllowExtensions tells RequireMediaTypeStrict to accept these "ext" URIs
+// in a request's Content-Type, the same set RequireExtensions checks.
+func AllowExtensions(uris []string) ContentTypeOption {
+	return func(o *contentTypeOptions) {
+		o.allowedExt = make(map[string]bool, len(uris))
+		for _, uri := range uris {
+			o.allowedExt[uri] = true
+		}
+	}
+}
+
+// AllowProfiles tells RequireMediaTypeStrict to accept these "profile"
+// URIs in a request's Content-Type.
+func AllowProfiles(uris []string) ContentTypeOption {
+	return func(o *contentTypeOptions) {
+		o.allowedProfile = make(map[string]bool, len(uris))
+		for _, uri := range uris {
+			o.allowedProfile[uri] = true
+		}
+	}
+}
+
+// RequireMediaTypeStrict wraps next with a stricter Content-Type check than
+// RequireMediaType: rather than ignoring media type parameters altogether,
+// it rejects every one by default, per the spec's "no media type
+// parameters" rule, except "ext"/"profile" URIs named via AllowExtensions/
+// AllowProfiles and, if AllowCharsetUTF8 is passed, a "charset=utf-8"
+// parameter -- the one real-world addition (from a proxy or load balancer)
+// common enough to be worth a pragmatic exception. A request failing the
+// check gets 415 Unsupported Media Type instead of reaching next.
+func RequireMediaTypeStrict(next http.Handler, opts ...ContentTypeOption) http.Handler {
+	var o contentTypeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mt != MediaType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		for key, value := range params {
+			switch key {
+			case "charset":
+				if !o.allowCharsetUTF8 || !strings.EqualFold(value, "utf-8") {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+			case "ext":
+				for _, uri := range strings.Fields(value) {
+					if !o.allowedExt[uri] {
+						w.WriteHeader(http.StatusUnsupportedMediaType)
+						return
+					}
+				}
+			case "profile":
+				for _, uri := range strings.Fields(value) {
+					if !o.allowedProfile[uri] {
+						w.WriteHeader(http.StatusUnsupportedMediaType)
+						return
+					}
+				}
+			default:
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,632 @@
+package jsonapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteDocumentSetsContentTypeAndStatus(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	rec := httptest.NewRecorder()
+
+	if err := WriteDocument(rec, http.StatusCreated, doc); err != nil {
+		t.Fatalf("WriteDocument returned error: %v", err)
+	}
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != MediaType {
+		t.Fatalf("expected Content-Type %q, got %q", MediaType, got)
+	}
+
+	var got Document
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+}
+
+func TestWriteDocumentIsCompactByDefault(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	rec := httptest.NewRecorder()
+
+	if err := WriteDocument(rec, http.StatusOK, doc); err != nil {
+		t.Fatalf("WriteDocument returned error: %v", err)
+	}
+	if strings.Contains(rec.Body.String(), "\n") {
+		t.Fatalf("expected compact output by default, got %q", rec.Body.String())
+	}
+}
+
+func TestWriteDocumentWithPrettyPrintIndentsOutput(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	rec := httptest.NewRecorder()
+
+	if err := WriteDocument(rec, http.StatusOK, doc, WithPrettyPrint()); err != nil {
+		t.Fatalf("WriteDocument returned error: %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), "\n") {
+		t.Fatalf("expected indented output, got %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != MediaType {
+		t.Fatalf("expected Content-Type %q, got %q", MediaType, got)
+	}
+
+	var got Document
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+}
+
+func TestPrettyPrintRequestedReadsQueryParameter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"1", true},
+		{"true", true},
+		{"YES", true},
+		{"0", false},
+		{"", false},
+		{"false", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/people?pretty="+tt.value, nil)
+		if got := PrettyPrintRequested(req, "pretty"); got != tt.want {
+			t.Fatalf("PrettyPrintRequested(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBindDecodesAttributesFromRequestBody(t *testing.T) {
+	body := strings.NewReader(`{"data":{"type":"people","id":"1","attributes":{"name":"Alice"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var person testPerson
+	if err := Bind(req, &person); err != nil {
+		t.Fatalf("Bind returned error: %v", err)
+	}
+	if person.ID != "1" || person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+}
+
+func TestBindTreatsLeadingUTF8BOMAsWhitespace(t *testing.T) {
+	body := strings.NewReader("\xEF\xBB\xBF" + `{"data":{"type":"people","id":"1","attributes":{"name":"Alice"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var person testPerson
+	if err := Bind(req, &person); err != nil {
+		t.Fatalf("Bind returned error: %v", err)
+	}
+	if person.ID != "1" || person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+}
+
+func TestBindReturnsMalformedErrorForInvalidJSON(t *testing.T) {
+	body := strings.NewReader(`not json at all`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var person testPerson
+	err := Bind(req, &person)
+
+	var malformed *MalformedError
+	if !errors.As(err, &malformed) {
+		t.Fatalf("expected a *MalformedError, got %v (%T)", err, err)
+	}
+	if malformed.Offset == 0 {
+		t.Fatalf("expected a nonzero offset, got %+v", malformed)
+	}
+}
+
+func TestBindReturnsMalformedErrorForBareArray(t *testing.T) {
+	body := strings.NewReader(`[1,2,3]`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var person testPerson
+	err := Bind(req, &person)
+
+	var malformed *MalformedError
+	if !errors.As(err, &malformed) {
+		t.Fatalf("expected a *MalformedError, got %v (%T)", err, err)
+	}
+}
+
+func TestBindRejectsWrongContentType(t *testing.T) {
+	body := strings.NewReader(`{"data":{"type":"people","id":"1"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", "application/json")
+
+	var person testPerson
+	if err := Bind(req, &person); err == nil {
+		t.Fatal("expected an error for a non-JSON:API Content-Type")
+	}
+}
+
+func TestBindRejectsOversizedBody(t *testing.T) {
+	huge := strings.Repeat("a", MaxBindBodyBytes+1)
+	body := strings.NewReader(`{"data":{"type":"people","id":"1","attributes":{"name":"` + huge + `"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var person testPerson
+	if err := Bind(req, &person); err == nil {
+		t.Fatal("expected an error for an oversized body")
+	}
+}
+
+func TestBindWithValidationPassesValidValueThrough(t *testing.T) {
+	body := strings.NewReader(`{"data":{"type":"people","id":"1","attributes":{"name":"Alice"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	validate := func(v interface{}) []FieldError {
+		return nil
+	}
+
+	var person testPerson
+	if err := Bind(req, &person, WithValidation(validate)); err != nil {
+		t.Fatalf("Bind returned error: %v", err)
+	}
+}
+
+func TestBindWithValidationReturnsBindValidationErrorForRequiredField(t *testing.T) {
+	body := strings.NewReader(`{"data":{"type":"people","id":"1","attributes":{"name":""}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/people", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	validate := func(v interface{}) []FieldError {
+		p := v.(*testPerson)
+		if p.Name == "" {
+			return []FieldError{mockFieldError{field: "Name", msg: "is required"}}
+		}
+		return nil
+	}
+
+	var person testPerson
+	err := Bind(req, &person, WithValidation(validate))
+
+	var verr *BindValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *BindValidationError, got %v", err)
+	}
+	if len(verr.Errors) != 1 {
+		t.Fatalf("expected 1 field error, got %d", len(verr.Errors))
+	}
+	if got := verr.Errors[0].Source.Pointer; got != "/data/attributes/name" {
+		t.Fatalf("expected pointer /data/attributes/name, got %q", got)
+	}
+}
+
+func TestBindWithExpectedResourceTypeRejectsWrongTypeWith409(t *testing.T) {
+	body := strings.NewReader(`{"data":{"type":"comments","id":"1","attributes":{"title":"Hello"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/articles", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var article testArticle
+	err := Bind(req, &article, WithExpectedResourceType("articles"))
+
+	var conflict *TypeConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a *TypeConflictError, got %v (%T)", err, err)
+	}
+	if conflict.Got != "comments" || conflict.Want != "articles" {
+		t.Fatalf("unexpected conflict: %+v", conflict)
+	}
+
+	doc := ErrorsFromError(err)
+	if len(doc.Errors) != 1 || doc.Errors[0].Status != "409" {
+		t.Fatalf("expected a single 409 error document, got %+v", doc.Errors)
+	}
+}
+
+func TestBindWithExpectedResourceTypeAllowsMatchingType(t *testing.T) {
+	body := strings.NewReader(`{"data":{"type":"articles","id":"1","attributes":{"title":"Hello"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/articles", body)
+	req.Header.Set("Content-Type", MediaType)
+
+	var article testArticle
+	if err := Bind(req, &article, WithExpectedResourceType("articles")); err != nil {
+		t.Fatalf("Bind returned error: %v", err)
+	}
+	if article.ID != "1" || article.Title != "Hello" {
+		t.Fatalf("unexpected article: %+v", article)
+	}
+}
+
+func TestWriteDocumentGzipCompressesWhenAccepted(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	req := httptest.NewRequest(http.MethodGet, "/people/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+
+	if err := WriteDocumentGzip(rec, req, http.StatusOK, doc); err != nil {
+		t.Fatalf("WriteDocumentGzip returned error: %v", err)
+	}
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("expected Content-Encoding gzip, got %q", got)
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("reading gzip body: %v", err)
+	}
+
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+}
+
+func TestWriteDocumentGzipFallsBackWithoutAcceptEncoding(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	req := httptest.NewRequest(http.MethodGet, "/people/1", nil)
+	rec := httptest.NewRecorder()
+
+	if err := WriteDocumentGzip(rec, req, http.StatusOK, doc); err != nil {
+		t.Fatalf("WriteDocumentGzip returned error: %v", err)
+	}
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("expected no Content-Encoding, got %q", got)
+	}
+
+	var got Document
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+}
+
+func TestRequireMediaTypeRejectsWrongContentType(t *testing.T) {
+	called := false
+	h := RequireMediaType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("expected next not to be called")
+	}
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestRequireMediaTypeAcceptsMatchingContentType(t *testing.T) {
+	called := false
+	h := RequireMediaType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", MediaType)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called")
+	}
+}
+
+func TestRequireMediaTypeIgnoresBodylessRequests(t *testing.T) {
+	called := false
+	h := RequireMediaType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/articles", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called for a bodyless GET")
+	}
+}
+
+func TestParseMediaTypeExtractsExtAndProfile(t *testing.T) {
+	header := `application/vnd.api+json; ext="https://jsonapi.org/ext/atomic https://example.com/ext/custom"; profile="https://example.com/profiles/flexible-pagination"`
+
+	ext, profile, err := ParseMediaType(header)
+	if err != nil {
+		t.Fatalf("ParseMediaType returned error: %v", err)
+	}
+	if len(ext) != 2 || ext[0] != "https://jsonapi.org/ext/atomic" || ext[1] != "https://example.com/ext/custom" {
+		t.Fatalf("unexpected ext: %+v", ext)
+	}
+	if len(profile) != 1 || profile[0] != "https://example.com/profiles/flexible-pagination" {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+}
+
+func TestParseMediaTypeWithNoParametersReturnsNilSlices(t *testing.T) {
+	ext, profile, err := ParseMediaType(MediaType)
+	if err != nil {
+		t.Fatalf("ParseMediaType returned error: %v", err)
+	}
+	if ext != nil || profile != nil {
+		t.Fatalf("expected nil ext and profile, got %+v, %+v", ext, profile)
+	}
+}
+
+func TestParseMediaTypeRejectsWrongBaseType(t *testing.T) {
+	if _, _, err := ParseMediaType("application/json"); err == nil {
+		t.Fatal("expected an error for a non-JSON:API media type")
+	}
+}
+
+func TestRequireExtensionsRejectsUnsupportedExtension(t *testing.T) {
+	called := false
+	h := RequireExtensions([]string{"https://jsonapi.org/ext/atomic"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", `application/vnd.api+json; ext="https://example.com/ext/unsupported"`)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("expected next not to be called")
+	}
+	if rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", rec.Code)
+	}
+}
+
+func TestRequireExtensionsAllowsSupportedExtension(t *testing.T) {
+	called := false
+	h := RequireExtensions([]string{"https://jsonapi.org/ext/atomic"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", `application/vnd.api+json; ext="https://jsonapi.org/ext/atomic"`)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called")
+	}
+}
+
+func TestRequireExtensionsIgnoresBodylessRequests(t *testing.T) {
+	called := false
+	h := RequireExtensions([]string{"https://jsonapi.org/ext/atomic"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/operations", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called for a bodyless GET")
+	}
+}
+
+func TestRequireMediaTypeStrictRejectsAnyParameterByDefault(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", MediaType+"; charset=utf-8")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("expected next not to be called")
+	}
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestRequireMediaTypeStrictAcceptsCharsetUTF8WithAllowCharsetUTF8(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), AllowCharsetUTF8())
+
+	req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", MediaType+"; charset=utf-8")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called")
+	}
+}
+
+func TestRequireMediaTypeStrictRejectsOtherCharsets(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), AllowCharsetUTF8())
+
+	req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", MediaType+"; charset=iso-8859-1")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("expected next not to be called")
+	}
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestRequireMediaTypeStrictRejectsUnknownParameterEvenWithCharsetAllowed(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), AllowCharsetUTF8())
+
+	req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", MediaType+"; boundary=xyz")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("expected next not to be called")
+	}
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestRequireMediaTypeStrictChecksExtAndProfileAgainstAllowlist(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), AllowExtensions([]string{"https://jsonapi.org/ext/atomic"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", `application/vnd.api+json; ext="https://example.com/ext/unsupported"`)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("expected next not to be called")
+	}
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestRequireMediaTypeStrictAllowsConfiguredExtension(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), AllowExtensions([]string{"https://jsonapi.org/ext/atomic"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/operations", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", `application/vnd.api+json; ext="https://jsonapi.org/ext/atomic"`)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called")
+	}
+}
+
+func TestRequireMediaTypeStrictIgnoresBodylessRequests(t *testing.T) {
+	called := false
+	h := RequireMediaTypeStrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/articles", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next to be called for a bodyless GET")
+	}
+}
+
+func TestValidatingWriterWritesValidDocumentAsIs(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+	rec := httptest.NewRecorder()
+	vw := NewValidatingWriter(rec)
+
+	if err := vw.WriteValidated(http.StatusCreated, doc); err != nil {
+		t.Fatalf("WriteValidated returned error: %v", err)
+	}
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+
+	var got Document
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+}
+
+func TestValidatingWriterRewritesInvalidDocumentAs500(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{ID: "1"}}}
+	rec := httptest.NewRecorder()
+	vw := NewValidatingWriter(rec)
+
+	if err := vw.WriteValidated(http.StatusOK, doc); err != nil {
+		t.Fatalf("WriteValidated returned error: %v", err)
+	}
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+
+	var got Document
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if len(got.Errors) != 1 {
+		t.Fatalf("expected one error object, got %+v", got.Errors)
+	}
+}
+
+func TestValidatingWriterSkipValidationWritesInvalidDocumentUnchecked(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{ID: "1"}}}
+	rec := httptest.NewRecorder()
+	vw := NewValidatingWriter(rec)
+	vw.SkipValidation = true
+
+	if err := vw.WriteValidated(http.StatusOK, doc); err != nil {
+		t.Fatalf("WriteValidated returned error: %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
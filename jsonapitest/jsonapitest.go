@@ -0,0 +1,62 @@
+// Package jsonapitest provides testing helpers for code that builds
+// jsonapi.Document values, kept separate from the main jsonapi package so
+// that importing it doesn't pull "testing" into production binaries.
+package jsonapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	jsonapi "_/root/module"
+)
+
+// AssertRoundTrip marshals doc, unmarshals the result back into a fresh
+// Document, and marshals that copy again, failing t if the two marshaled
+// forms differ. This pins that doc survives a marshal/unmarshal cycle
+// byte-for-byte (after both sides are canonicalized the same way, so key
+// order and insignificant whitespace don't cause false failures), the
+// property a resource's own round-trip test should assert before relying
+// on this package to decode it.
+func AssertRoundTrip(t testing.TB, doc *jsonapi.Document) {
+	t.Helper()
+
+	first, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("jsonapitest: first Marshal returned error: %v", err)
+	}
+
+	var decoded jsonapi.Document
+	if err := json.Unmarshal(first, &decoded); err != nil {
+		t.Fatalf("jsonapitest: Unmarshal returned error: %v", err)
+	}
+
+	second, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("jsonapitest: second Marshal returned error: %v", err)
+	}
+
+	canonicalFirst, err := canonicalize(first)
+	if err != nil {
+		t.Fatalf("jsonapitest: canonicalizing first marshal: %v", err)
+	}
+	canonicalSecond, err := canonicalize(second)
+	if err != nil {
+		t.Fatalf("jsonapitest: canonicalizing second marshal: %v", err)
+	}
+
+	if !bytes.Equal(canonicalFirst, canonicalSecond) {
+		t.Fatalf("jsonapitest: document did not round-trip byte-stably:\nfirst:  %s\nsecond: %s", canonicalFirst, canonicalSecond)
+	}
+}
+
+// canonicalize re-encodes raw through a generic interface{}, which sorts
+// object keys and drops insignificant whitespace, so two semantically
+// equal documents compare equal regardless of member order.
+func canonicalize(raw []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,43 @@
+package jsonapitest
+
+import (
+	"testing"
+
+	jsonapi "_/root/module"
+)
+
+func TestAssertRoundTripPassesForSingleResourceDocument(t *testing.T) {
+	doc := &jsonapi.Document{
+		Data: &jsonapi.DataContainer{DataObject: &jsonapi.Data{
+			Type:       "people",
+			ID:         "1",
+			Attributes: []byte(`{"name":"Alice"}`),
+		}},
+	}
+
+	if !t.Run("round trip", func(st *testing.T) { AssertRoundTrip(st, doc) }) {
+		t.Fatal("expected AssertRoundTrip to pass for a well-formed single-resource document")
+	}
+}
+
+func TestAssertRoundTripPassesForCollectionDocument(t *testing.T) {
+	doc := &jsonapi.Document{
+		Data: &jsonapi.DataContainer{DataArray: []jsonapi.Data{
+			{Type: "articles", ID: "1"},
+			{Type: "articles", ID: "2"},
+		}},
+		Meta: map[string]interface{}{"total": float64(2)},
+	}
+
+	if !t.Run("round trip", func(st *testing.T) { AssertRoundTrip(st, doc) }) {
+		t.Fatal("expected AssertRoundTrip to pass for a well-formed collection document")
+	}
+}
+
+func TestAssertRoundTripPassesForErrorsOnlyDocument(t *testing.T) {
+	doc := jsonapi.MarshalErrors(jsonapi.NewValidationError("/data/attributes/title", "can't be blank"))
+
+	if !t.Run("round trip", func(st *testing.T) { AssertRoundTrip(st, doc) }) {
+		t.Fatal("expected AssertRoundTrip to pass for a well-formed errors document")
+	}
+}
@@ -0,0 +1,43 @@
+package jsonapi
+
+import "encoding/json"
+
+// JSONMarshalFunc matches the signature of json.Marshal.
+type JSONMarshalFunc func(v interface{}) ([]byte, error)
+
+// JSONUnmarshalFunc matches the signature of json.Unmarshal.
+type JSONUnmarshalFunc func(data []byte, v interface{}) error
+
+// marshalJSON and unmarshalJSON are the codec most JSON encoding and
+// decoding inside this package routes through, including the custom
+// MarshalJSON/UnmarshalJSON methods on DataContainer,
+// RelationshipDataContainer and the rest of the document types. A few call
+// sites, such as indented output via json.MarshalIndent and Normalize's
+// attribute encoding, call encoding/json directly and are not affected by
+// SetJSONCodec. They default to the standard library and are only ever
+// reassigned by SetJSONCodec.
+var (
+	marshalJSON   JSONMarshalFunc   = json.Marshal
+	unmarshalJSON JSONUnmarshalFunc = json.Unmarshal
+)
+
+// SetJSONCodec overrides the JSON encoder and decoder this package uses
+// internally, e.g. to drop in a faster implementation (jsoniter,
+// goccy/go-json) or one configured with different escaping, without
+// forking the library. Passing nil for either argument leaves that half of
+// the codec unchanged. Like SetTypeNormalizer, this is a process-wide
+// setting — it takes effect for every call into the package made after it
+// returns, not just ones made with some per-call option — so it should
+// normally be set once at startup, before Marshal/Unmarshal/
+// MarshalDocumentTo are used concurrently. The replacement must be
+// semantically compatible with encoding/json: in particular it must still
+// honor json.Marshaler/json.Unmarshaler and `json:"..."` struct tags, since
+// this package's own types rely on both.
+func SetJSONCodec(marshal JSONMarshalFunc, unmarshal JSONUnmarshalFunc) {
+	if marshal != nil {
+		marshalJSON = marshal
+	}
+	if unmarshal != nil {
+		unmarshalJSON = unmarshal
+	}
+}
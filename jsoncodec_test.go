@@ -0,0 +1,61 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSetJSONCodecOverridesDataContainerMarshalers(t *testing.T) {
+	var marshalCalls, unmarshalCalls int
+	SetJSONCodec(
+		func(v interface{}) ([]byte, error) {
+			marshalCalls++
+			return json.Marshal(v)
+		},
+		func(data []byte, v interface{}) error {
+			unmarshalCalls++
+			return json.Unmarshal(data, v)
+		},
+	)
+	defer SetJSONCodec(json.Marshal, json.Unmarshal)
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	if marshalCalls == 0 {
+		t.Fatal("expected the overridden marshal func to be used by DataContainer.MarshalJSON")
+	}
+
+	var out Document
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if unmarshalCalls == 0 {
+		t.Fatal("expected the overridden unmarshal func to be used by DataContainer.UnmarshalJSON")
+	}
+	if out.Data.DataObject == nil || out.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected round trip result: %+v", out.Data)
+	}
+}
+
+func TestSetJSONCodecWithNilArgumentLeavesThatHalfUnchanged(t *testing.T) {
+	var used bool
+	SetJSONCodec(func(v interface{}) ([]byte, error) {
+		used = true
+		return json.Marshal(v)
+	}, nil)
+	defer SetJSONCodec(json.Marshal, json.Unmarshal)
+
+	if _, err := marshalJSON(Data{Type: "articles", ID: "1"}); err != nil {
+		t.Fatalf("marshalJSON returned error: %v", err)
+	}
+	if !used {
+		t.Fatal("expected the overridden marshal func to be installed")
+	}
+	if unmarshalJSON == nil {
+		t.Fatal("expected unmarshalJSON to remain set")
+	}
+}
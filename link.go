@@ -0,0 +1,557 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// TypeRegistry maps JSON:API resource type names to Go struct types, scoped
+// to a single caller (e.g. a request handler) rather than shared process-wide
+// state like RegisterType/typeRegistry. Document.Link uses it to resolve an
+// interface-typed relationship field to a concrete type while walking a
+// compound document's relationships.
+type TypeRegistry struct {
+	types          map[string]reflect.Type
+	discriminators map[string]discriminatedConfig
+}
+
+// NewTypeRegistry returns an empty TypeRegistry.
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: map[string]reflect.Type{}}
+}
+
+// Register associates name with the Go type behind v (a struct, or a
+// pointer to one).
+func (r *TypeRegistry) Register(name string, v interface{}) {
+	r.types[name] = getType(v)
+}
+
+// discriminatedConfig records how to pick a candidate Go type for a
+// resource type registered via RegisterDiscriminated: field names the
+// attribute to read, mapping keys its string value against a candidate.
+type discriminatedConfig struct {
+	field   string
+	mapping map[string]reflect.Type
+}
+
+// RegisterDiscriminated registers typeName as polymorphic on one of its own
+// attributes: decoding a resource of that JSON:API type with
+// DecodeDiscriminated reads the attribute named field and, keyed by its
+// string value, decodes into the Go type behind the matching entry of
+// mapping (a struct, or a pointer to one) instead of a single fixed type.
+// This covers models where the JSON:API type is shared across shapes that
+// only differ by an attribute, e.g. an "events" resource whose fields
+// depend on `"kind": "login"` vs `"kind": "logout"`.
+func (r *TypeRegistry) RegisterDiscriminated(typeName, field string, mapping map[string]interface{}) {
+	types := make(map[string]reflect.Type, len(mapping))
+	for k, v := range mapping {
+		types[k] = getType(v)
+	}
+
+	if r.discriminators == nil {
+		r.discriminators = map[string]discriminatedConfig{}
+	}
+	r.discriminators[typeName] = discriminatedConfig{field: field, mapping: types}
+}
+
+// DecodeDiscriminated decodes d into the Go type selected by the
+// discriminator registered for d.Type via RegisterDiscriminated, returning
+// the decoded pointer as interface{} for the caller to type-switch on.
+func (r *TypeRegistry) DecodeDiscriminated(d Data) (interface{}, error) {
+	cfg, ok := r.discriminators[d.Type]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: no discriminator registered for %q; call (*TypeRegistry).RegisterDiscriminated", d.Type)
+	}
+
+	var attrs map[string]json.RawMessage
+	if err := unmarshalJSON(d.Attributes, &attrs); err != nil {
+		return nil, fmt.Errorf("jsonapi: decoding discriminator attributes for %q: %w", d.Type, err)
+	}
+
+	raw, ok := attrs[cfg.field]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: resource %s:%s has no %q attribute to discriminate on", d.Type, d.ID, cfg.field)
+	}
+
+	var disc string
+	if err := unmarshalJSON(raw, &disc); err != nil {
+		return nil, fmt.Errorf("jsonapi: discriminator attribute %q must be a string, got %s", cfg.field, raw)
+	}
+
+	rt, ok := cfg.mapping[disc]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: no candidate registered for %s %q on %q", cfg.field, disc, d.Type)
+	}
+
+	v := reflect.New(rt)
+	if err := dataToStruct(d, v.Elem(), nil, TimeFormatRFC3339, nil, nil, readOnlyAllow, false, false); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// resolve returns the concrete struct type to allocate for a related
+// resource of the given JSON:API type, the same way relatedStructType does
+// for the global typeRegistry: a concrete fieldType is used as-is, an
+// interface fieldType is resolved by typeName through r.
+func (r *TypeRegistry) resolve(fieldType reflect.Type, typeName string) (reflect.Type, error) {
+	t := fieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Interface {
+		return t, nil
+	}
+	if r == nil {
+		return nil, fmt.Errorf("jsonapi: Link: no TypeRegistry provided to resolve interface relationship of type %q", typeName)
+	}
+	rt, ok := r.types[typeName]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: Link: no type registered for %q; call (*TypeRegistry).Register", typeName)
+	}
+	return rt, nil
+}
+
+// Link decodes d's primary resource into target (a pointer to a
+// `jsonapi`-tagged struct) and then walks its relationships, resolving each
+// against d.Included and populating the corresponding relation-tagged
+// fields, recursively, the way sideloading on Marshal is the inverse of.
+// reg resolves any interface-typed relationship field to a concrete type;
+// it may be nil if target has none. A relationship cycle (direct or through
+// several hops) reuses the struct already allocated for that type+id
+// instead of decoding it again.
+func (d *Document) Link(reg *TypeRegistry, target interface{}) error {
+	if d.Data == nil || d.Data.DataObject == nil {
+		return errors.New("jsonapi: Link requires a document with a single primary resource")
+	}
+
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr {
+		return errors.New("jsonapi: Link requires a pointer")
+	}
+
+	index := IndexIncluded(d.Included)
+	return linkStruct(*d.Data.DataObject, rv.Elem(), index, reg, map[string]reflect.Value{})
+}
+
+// linkStruct decodes data's attributes into rv and then resolves its
+// relationships against index, recording rv's own address in visited before
+// recursing so a cycle back to it is linked rather than walked again.
+func linkStruct(data Data, rv reflect.Value, index map[string]Data, reg *TypeRegistry, visited map[string]reflect.Value) error {
+	if data.ID != "" {
+		visited[data.Type+":"+data.ID] = rv.Addr()
+	}
+
+	var attrs map[string]json.RawMessage
+	if len(data.Attributes) > 0 {
+		if err := unmarshalJSON(data.Attributes, &attrs); err != nil {
+			return err
+		}
+	}
+
+	meta := typeMetaFor(rv.Type())
+
+	if meta.primaryIndex >= 0 {
+		if fv := rv.Field(meta.primaryIndex); fv.Kind() == reflect.String {
+			fv.SetString(data.ID)
+		}
+	}
+
+	for _, f := range meta.attrs {
+		raw, ok := attrs[f.Tag.Name]
+		if !ok {
+			continue
+		}
+		if err := setAttrValue(rv.Field(f.Index), raw, TimeFormatRFC3339, nil, false); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range meta.relations {
+		rel, ok := data.Relationships[f.Tag.Name]
+		if !ok || rel.Data == nil {
+			continue
+		}
+		if err := linkRelation(rv.Field(f.Index), rel.Data, index, reg, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkRelation populates fv (a relation-tagged field, either a slice or a
+// single related struct) from container, the same shapes setRelation
+// handles for Unmarshal.
+func linkRelation(fv reflect.Value, container *RelationshipDataContainer, index map[string]Data, reg *TypeRegistry, visited map[string]reflect.Value) error {
+	switch {
+	case container.DataArray != nil:
+		elemType := fv.Type().Elem()
+		out := reflect.MakeSlice(fv.Type(), 0, len(container.DataArray))
+		for _, rd := range container.DataArray {
+			item, err := linkTarget(rd, elemType, index, reg, visited)
+			if err != nil {
+				return err
+			}
+			if item == nil {
+				continue
+			}
+			if elemType.Kind() == reflect.Ptr || elemType.Kind() == reflect.Interface {
+				out = reflect.Append(out, *item)
+			} else {
+				out = reflect.Append(out, item.Elem())
+			}
+		}
+		fv.Set(out)
+	case container.DataObject != nil:
+		item, err := linkTarget(*container.DataObject, fv.Type(), index, reg, visited)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return nil
+		}
+		if fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+			fv.Set(*item)
+		} else {
+			fv.Set(item.Elem())
+		}
+	}
+
+	return nil
+}
+
+// Denormalize inlines d's included resources into their referencing
+// relationships, producing a nested object graph (map[string]interface{}
+// for a single resource, []interface{} for a collection) instead of
+// JSON:API's normalized compound form -- the shape a consumer migrating to
+// a nested-JSON or GraphQL-ish API typically wants. Each resource's map
+// carries "type", "id" and its decoded attributes as sibling keys, plus one
+// key per relationship holding its inlined resource(s); a relationship with
+// no matching included resource is left as a bare {"type", "id"} reference.
+// A cycle (a resource that, through its own relationships, ends up
+// referencing an ancestor of itself) is broken the same way, by reference
+// rather than infinite recursion. reg is accepted for symmetry with Link
+// but isn't used: unlike decoding into a typed struct, building a generic
+// object graph needs no interface-to-concrete-type resolution.
+func (d *Document) Denormalize(reg *TypeRegistry) (interface{}, error) {
+	index := IndexIncluded(d.Included)
+
+	switch {
+	case d.Data == nil:
+		return nil, nil
+	case d.Data.DataObject != nil:
+		return denormalizeData(*d.Data.DataObject, index, map[string]bool{})
+	default:
+		out := make([]interface{}, 0, len(d.Data.DataArray))
+		for _, data := range d.Data.DataArray {
+			v, err := denormalizeData(data, index, map[string]bool{})
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+}
+
+// denormalizeData builds data's nested map, recursing into its
+// relationships via denormalizeLinkage. path records the type:id of every
+// resource on the current recursion path (data's ancestors, and data
+// itself), so a relationship looping back to one of them is caught.
+func denormalizeData(data Data, index map[string]Data, path map[string]bool) (map[string]interface{}, error) {
+	childPath := make(map[string]bool, len(path)+1)
+	for k := range path {
+		childPath[k] = true
+	}
+	childPath[data.Type+":"+data.ID] = true
+
+	attrs, err := data.AttributesMap()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{}, len(attrs)+2)
+	for k, v := range attrs {
+		out[k] = v
+	}
+	out["type"] = data.Type
+	out["id"] = data.ID
+
+	for name, rel := range data.Relationships {
+		if rel.Data == nil {
+			continue
+		}
+		switch {
+		case rel.Data.DataObject != nil:
+			v, err := denormalizeLinkage(*rel.Data.DataObject, index, childPath)
+			if err != nil {
+				return nil, err
+			}
+			out[name] = v
+		case rel.Data.DataArray != nil:
+			list := make([]interface{}, 0, len(rel.Data.DataArray))
+			for _, rd := range rel.Data.DataArray {
+				v, err := denormalizeLinkage(rd, index, childPath)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			out[name] = list
+		}
+	}
+
+	return out, nil
+}
+
+// denormalizeLinkage resolves rd against index and denormalizes it, or
+// returns a bare {"type", "id"} reference if rd isn't in index (a
+// relationship that wasn't sideloaded) or would close a cycle back onto
+// path.
+func denormalizeLinkage(rd RelationshipData, index map[string]Data, path map[string]bool) (interface{}, error) {
+	key := rd.Type + ":" + rd.ID
+	if path[key] {
+		return map[string]interface{}{"type": rd.Type, "id": rd.ID}, nil
+	}
+
+	included, ok := index[key]
+	if !ok {
+		return map[string]interface{}{"type": rd.Type, "id": rd.ID}, nil
+	}
+
+	return denormalizeData(included, index, path)
+}
+
+// Normalize is the inverse of Denormalize: it walks nested, a tree of
+// map[string]interface{} (or a []interface{} of such maps for a
+// collection) shaped like Denormalize's output -- "type" and "id" keys plus
+// attributes and inlined relationships as sibling keys -- and rebuilds a
+// compound Document, extracting every related resource it finds into
+// Included and replacing it with linkage. A resource referenced more than
+// once (by matching type and id, including one that refers back to an
+// ancestor of itself) is normalized only the first time it's encountered;
+// later references reuse the same linkage, which also breaks cycles. reg is
+// accepted for symmetry with Denormalize but isn't used: walking nested has
+// no need to resolve a Go type for an interface-typed field. A nested value
+// is treated as a relationship when it's a map carrying "type" and "id"
+// keys (or a non-empty slice of such maps); anything else is kept as an
+// attribute.
+func Normalize(nested interface{}, reg *TypeRegistry) (*Document, error) {
+	switch v := nested.(type) {
+	case map[string]interface{}:
+		primary := map[string]bool{}
+		if typ, id, ok := resourceKey(v); ok {
+			primary[typ+":"+id] = true
+		}
+
+		included := map[string]Data{}
+		data, err := normalizeNode(v, primary, included)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := &Document{Data: &DataContainer{DataObject: &data}}
+		if len(included) > 0 {
+			doc.Included = includedValues(included)
+		}
+		return doc, nil
+
+	case []interface{}:
+		maps := make([]map[string]interface{}, len(v))
+		primary := map[string]bool{}
+		for i, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("jsonapi: Normalize requires every element of a collection to be a map, got %T", item)
+			}
+			maps[i] = m
+			if typ, id, ok := resourceKey(m); ok {
+				primary[typ+":"+id] = true
+			}
+		}
+
+		included := map[string]Data{}
+		arr := make([]Data, 0, len(maps))
+		for _, m := range maps {
+			data, err := normalizeNode(m, primary, included)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, data)
+		}
+
+		doc := &Document{Data: &DataContainer{DataArray: arr}}
+		if len(included) > 0 {
+			doc.Included = includedValues(included)
+		}
+		return doc, nil
+
+	default:
+		return nil, fmt.Errorf("jsonapi: Normalize requires a map or a slice of maps, got %T", nested)
+	}
+}
+
+// normalizeNode splits m into attributes and relationships and builds the
+// Data for it, recursing into each relationship via normalizeLinkage.
+func normalizeNode(m map[string]interface{}, primary map[string]bool, included map[string]Data) (Data, error) {
+	typ, ok := m["type"].(string)
+	if !ok || typ == "" {
+		return Data{}, fmt.Errorf(`jsonapi: Normalize requires a "type" key on every resource, got %#v`, m)
+	}
+	id, _ := m["id"].(string)
+
+	var relationships map[string]Relationship
+	attrs := make(map[string]interface{}, len(m))
+
+	for k, val := range m {
+		if k == "type" || k == "id" {
+			continue
+		}
+
+		switch rv := val.(type) {
+		case map[string]interface{}:
+			if _, _, ok := resourceKey(rv); ok {
+				rd, err := normalizeLinkage(rv, primary, included)
+				if err != nil {
+					return Data{}, err
+				}
+				if relationships == nil {
+					relationships = map[string]Relationship{}
+				}
+				relationships[k] = Relationship{Data: &RelationshipDataContainer{DataObject: &rd}}
+				continue
+			}
+		case []interface{}:
+			if isResourceList(rv) {
+				list := make([]RelationshipData, 0, len(rv))
+				for _, item := range rv {
+					rd, err := normalizeLinkage(item.(map[string]interface{}), primary, included)
+					if err != nil {
+						return Data{}, err
+					}
+					list = append(list, rd)
+				}
+				if relationships == nil {
+					relationships = map[string]Relationship{}
+				}
+				relationships[k] = Relationship{Data: &RelationshipDataContainer{DataArray: list}}
+				continue
+			}
+		}
+
+		attrs[k] = val
+	}
+
+	data := Data{Type: typ, ID: id, Relationships: relationships}
+	if len(attrs) > 0 {
+		raw, err := json.Marshal(attrs)
+		if err != nil {
+			return Data{}, err
+		}
+		data.Attributes = raw
+	}
+	return data, nil
+}
+
+// normalizeLinkage extracts m into included (unless it's one of the
+// document's primary resources, or was already extracted) and returns the
+// linkage that refers to it.
+func normalizeLinkage(m map[string]interface{}, primary map[string]bool, included map[string]Data) (RelationshipData, error) {
+	typ, id, ok := resourceKey(m)
+	if !ok {
+		return RelationshipData{}, fmt.Errorf(`jsonapi: Normalize requires a relationship resource to have "type" and "id", got %#v`, m)
+	}
+	key := typ + ":" + id
+
+	if _, alreadyIncluded := included[key]; !primary[key] && !alreadyIncluded {
+		included[key] = Data{Type: typ, ID: id}
+		data, err := normalizeNode(m, primary, included)
+		if err != nil {
+			return RelationshipData{}, err
+		}
+		included[key] = data
+	}
+
+	return RelationshipData{Type: typ, ID: id}, nil
+}
+
+// resourceKey reports whether m looks like a resource map (non-empty
+// "type" and "id" string keys) and, if so, returns them.
+func resourceKey(m map[string]interface{}) (string, string, bool) {
+	typ, ok := m["type"].(string)
+	if !ok || typ == "" {
+		return "", "", false
+	}
+	id, ok := m["id"].(string)
+	if !ok {
+		return "", "", false
+	}
+	return typ, id, true
+}
+
+// isResourceList reports whether every element of list is a resource map.
+func isResourceList(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, _, ok := resourceKey(m); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// includedValues flattens included into a slice ordered by type:id so
+// Normalize's output is deterministic.
+func includedValues(included map[string]Data) []Data {
+	keys := make([]string, 0, len(included))
+	for k := range included {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]Data, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, included[k])
+	}
+	return out
+}
+
+// linkTarget resolves rd against index and returns a pointer reflect.Value
+// to its decoded struct, reusing the value already recorded in visited for
+// rd's type+id instead of decoding it again if this is a cycle. It returns
+// a nil *reflect.Value, not an error, when rd isn't present in index, the
+// same "skip, don't fail" behavior setRelation has.
+func linkTarget(rd RelationshipData, fieldType reflect.Type, index map[string]Data, reg *TypeRegistry, visited map[string]reflect.Value) (*reflect.Value, error) {
+	key := rd.Type + ":" + rd.ID
+	if v, ok := visited[key]; ok {
+		return &v, nil
+	}
+
+	included, ok := index[key]
+	if !ok {
+		return nil, nil
+	}
+
+	itemType, err := reg.resolve(fieldType, included.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	item := reflect.New(itemType)
+	visited[key] = item
+	if err := linkStruct(included, item.Elem(), index, reg, visited); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,563 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type linkArticle struct {
+	ID     string      `jsonapi:"primary,articles"`
+	Title  string      `jsonapi:"attr,title"`
+	Author *linkPerson `jsonapi:"relation,author"`
+	Tags   []*linkTag  `jsonapi:"relation,tags"`
+}
+
+type linkPerson struct {
+	ID       string         `jsonapi:"primary,people"`
+	Name     string         `jsonapi:"attr,name"`
+	Articles []*linkArticle `jsonapi:"relation,articles"`
+}
+
+type linkTag struct {
+	ID   string `jsonapi:"primary,tags"`
+	Name string `jsonapi:"attr,name"`
+}
+
+func TestDocumentLinkPopulatesRelationshipsFromIncluded(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Attributes: mustRawMessage(t, `{"title":"Hello"}`),
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+				"tags":   {Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "tags", ID: "1"}, {Type: "tags", ID: "2"}}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: mustRawMessage(t, `{"name":"Alice"}`)},
+			{Type: "tags", ID: "1", Attributes: mustRawMessage(t, `{"name":"go"}`)},
+			{Type: "tags", ID: "2", Attributes: mustRawMessage(t, `{"name":"jsonapi"}`)},
+		},
+	}
+
+	var article linkArticle
+	if err := doc.Link(nil, &article); err != nil {
+		t.Fatalf("Link returned error: %v", err)
+	}
+
+	if article.Title != "Hello" {
+		t.Fatalf("unexpected title: %q", article.Title)
+	}
+	if article.Author == nil || article.Author.Name != "Alice" {
+		t.Fatalf("unexpected author: %+v", article.Author)
+	}
+	if len(article.Tags) != 2 || article.Tags[0].Name != "go" || article.Tags[1].Name != "jsonapi" {
+		t.Fatalf("unexpected tags: %+v", article.Tags)
+	}
+}
+
+func TestDocumentLinkHandlesRelationshipCycles(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Attributes: mustRawMessage(t, `{"title":"Hello"}`),
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+		}},
+		Included: []Data{
+			{
+				Type: "people", ID: "9",
+				Attributes: mustRawMessage(t, `{"name":"Alice"}`),
+				Relationships: map[string]Relationship{
+					"articles": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "articles", ID: "1"}}}},
+				},
+			},
+		},
+	}
+
+	var article linkArticle
+	if err := doc.Link(nil, &article); err != nil {
+		t.Fatalf("Link returned error: %v", err)
+	}
+
+	if article.Author == nil || len(article.Author.Articles) != 1 {
+		t.Fatalf("unexpected author: %+v", article.Author)
+	}
+	if article.Author.Articles[0] != &article {
+		t.Fatal("expected the cyclic reference to point back to the same article value")
+	}
+}
+
+func TestDocumentLinkSkipsRelationshipNotInIncluded(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "missing"}}},
+			},
+		}},
+	}
+
+	var article linkArticle
+	if err := doc.Link(nil, &article); err != nil {
+		t.Fatalf("Link returned error: %v", err)
+	}
+	if article.Author != nil {
+		t.Fatalf("expected no author, got %+v", article.Author)
+	}
+}
+
+func TestDocumentLinkRequiresPointerTarget(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+
+	if err := doc.Link(nil, linkArticle{}); err == nil {
+		t.Fatal("expected an error for a non-pointer target")
+	}
+}
+
+func TestDocumentLinkRequiresPrimaryData(t *testing.T) {
+	doc := &Document{}
+
+	var article linkArticle
+	if err := doc.Link(nil, &article); err == nil {
+		t.Fatal("expected an error for a document with no primary data")
+	}
+}
+
+type linkEvent struct {
+	ID     string          `jsonapi:"primary,events"`
+	Target linkCommentable `jsonapi:"relation,target"`
+}
+
+type linkCommentable interface {
+	isLinkCommentable()
+}
+
+type linkPhoto struct {
+	ID  string `jsonapi:"primary,photos"`
+	URL string `jsonapi:"attr,url"`
+}
+
+func (linkPhoto) isLinkCommentable() {}
+
+func TestDocumentLinkResolvesInterfaceFieldViaTypeRegistry(t *testing.T) {
+	reg := NewTypeRegistry()
+	reg.Register("photos", &linkPhoto{})
+
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "events", ID: "1",
+			Relationships: map[string]Relationship{
+				"target": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "photos", ID: "5"}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "photos", ID: "5", Attributes: mustRawMessage(t, `{"url":"https://example.com/5.jpg"}`)},
+		},
+	}
+
+	var event linkEvent
+	if err := doc.Link(reg, &event); err != nil {
+		t.Fatalf("Link returned error: %v", err)
+	}
+
+	photo, ok := event.Target.(*linkPhoto)
+	if !ok || photo.URL != "https://example.com/5.jpg" {
+		t.Fatalf("unexpected target: %+v", event.Target)
+	}
+}
+
+func TestDocumentLinkErrorsOnInterfaceFieldWithoutTypeRegistry(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "events", ID: "1",
+			Relationships: map[string]Relationship{
+				"target": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "photos", ID: "5"}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "photos", ID: "5"},
+		},
+	}
+
+	var event linkEvent
+	if err := doc.Link(nil, &event); err == nil {
+		t.Fatal("expected an error for an unresolved interface relationship")
+	}
+}
+
+func mustRawMessage(t *testing.T, s string) []byte {
+	t.Helper()
+	return []byte(s)
+}
+
+type discriminatedLogin struct {
+	ID   string `jsonapi:"primary,events"`
+	Kind string `jsonapi:"attr,kind"`
+	User string `jsonapi:"attr,user"`
+}
+
+type discriminatedLogout struct {
+	ID       string `jsonapi:"primary,events"`
+	Kind     string `jsonapi:"attr,kind"`
+	Duration int    `jsonapi:"attr,duration"`
+}
+
+func TestTypeRegistryDecodeDiscriminatedSelectsCandidateByAttribute(t *testing.T) {
+	reg := NewTypeRegistry()
+	reg.RegisterDiscriminated("events", "kind", map[string]interface{}{
+		"login":  &discriminatedLogin{},
+		"logout": &discriminatedLogout{},
+	})
+
+	login, err := reg.DecodeDiscriminated(Data{
+		Type: "events", ID: "1",
+		Attributes: mustRawMessage(t, `{"kind":"login","user":"alice"}`),
+	})
+	if err != nil {
+		t.Fatalf("DecodeDiscriminated returned error: %v", err)
+	}
+	loginEvent, ok := login.(*discriminatedLogin)
+	if !ok || loginEvent.User != "alice" {
+		t.Fatalf("unexpected login decode: %+v", login)
+	}
+
+	logout, err := reg.DecodeDiscriminated(Data{
+		Type: "events", ID: "2",
+		Attributes: mustRawMessage(t, `{"kind":"logout","duration":30}`),
+	})
+	if err != nil {
+		t.Fatalf("DecodeDiscriminated returned error: %v", err)
+	}
+	logoutEvent, ok := logout.(*discriminatedLogout)
+	if !ok || logoutEvent.Duration != 30 {
+		t.Fatalf("unexpected logout decode: %+v", logout)
+	}
+}
+
+func TestTypeRegistryDecodeDiscriminatedErrorsOnUnknownValue(t *testing.T) {
+	reg := NewTypeRegistry()
+	reg.RegisterDiscriminated("events", "kind", map[string]interface{}{
+		"login": &discriminatedLogin{},
+	})
+
+	_, err := reg.DecodeDiscriminated(Data{
+		Type: "events", ID: "1",
+		Attributes: mustRawMessage(t, `{"kind":"banned"}`),
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unregistered discriminator value")
+	}
+}
+
+func TestTypeRegistryDecodeDiscriminatedErrorsWithoutRegistration(t *testing.T) {
+	reg := NewTypeRegistry()
+
+	_, err := reg.DecodeDiscriminated(Data{Type: "events", ID: "1", Attributes: mustRawMessage(t, `{"kind":"login"}`)})
+	if err == nil {
+		t.Fatal("expected an error for a type with no discriminator registered")
+	}
+}
+
+func TestDocumentDenormalizeInlinesSingleRelationship(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Attributes: mustRawMessage(t, `{"title":"Hello"}`),
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: mustRawMessage(t, `{"name":"Alice"}`)},
+		},
+	}
+
+	out, err := doc.Denormalize(nil)
+	if err != nil {
+		t.Fatalf("Denormalize returned error: %v", err)
+	}
+
+	article, ok := out.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected a map, got %T", out)
+	}
+	if article["type"] != "articles" || article["id"] != "1" || article["title"] != "Hello" {
+		t.Fatalf("unexpected article: %+v", article)
+	}
+
+	author, ok := article["author"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected author to be inlined as a map, got %T", article["author"])
+	}
+	if author["type"] != "people" || author["id"] != "9" || author["name"] != "Alice" {
+		t.Fatalf("unexpected author: %+v", author)
+	}
+}
+
+func TestDocumentDenormalizeInlinesToManyRelationship(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"tags": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "tags", ID: "1"}, {Type: "tags", ID: "2"}}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "tags", ID: "1", Attributes: mustRawMessage(t, `{"name":"go"}`)},
+			{Type: "tags", ID: "2", Attributes: mustRawMessage(t, `{"name":"jsonapi"}`)},
+		},
+	}
+
+	out, err := doc.Denormalize(nil)
+	if err != nil {
+		t.Fatalf("Denormalize returned error: %v", err)
+	}
+
+	article := out.(map[string]interface{})
+	tags, ok := article["tags"].([]interface{})
+	if !ok || len(tags) != 2 {
+		t.Fatalf("unexpected tags: %+v", article["tags"])
+	}
+	if tags[0].(map[string]interface{})["name"] != "go" || tags[1].(map[string]interface{})["name"] != "jsonapi" {
+		t.Fatalf("unexpected tags: %+v", tags)
+	}
+}
+
+func TestDocumentDenormalizeLeavesMissingIncludedAsReference(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "missing"}}},
+			},
+		}},
+	}
+
+	out, err := doc.Denormalize(nil)
+	if err != nil {
+		t.Fatalf("Denormalize returned error: %v", err)
+	}
+
+	author := out.(map[string]interface{})["author"].(map[string]interface{})
+	if len(author) != 2 || author["type"] != "people" || author["id"] != "missing" {
+		t.Fatalf("expected a bare reference, got %+v", author)
+	}
+}
+
+func TestDocumentDenormalizeBreaksCyclesByReference(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+		}},
+		Included: []Data{
+			{
+				Type: "people", ID: "9",
+				Attributes: mustRawMessage(t, `{"name":"Alice"}`),
+				Relationships: map[string]Relationship{
+					"articles": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "articles", ID: "1"}}}},
+				},
+			},
+		},
+	}
+
+	out, err := doc.Denormalize(nil)
+	if err != nil {
+		t.Fatalf("Denormalize returned error: %v", err)
+	}
+
+	article := out.(map[string]interface{})
+	author := article["author"].(map[string]interface{})
+	articles := author["articles"].([]interface{})
+	if len(articles) != 1 {
+		t.Fatalf("unexpected articles: %+v", articles)
+	}
+
+	backref := articles[0].(map[string]interface{})
+	if len(backref) != 2 || backref["type"] != "articles" || backref["id"] != "1" {
+		t.Fatalf("expected the cycle to close with a bare reference, got %+v", backref)
+	}
+}
+
+func TestDocumentDenormalizeOnCollection(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "tags", ID: "1", Attributes: mustRawMessage(t, `{"name":"go"}`)},
+			{Type: "tags", ID: "2", Attributes: mustRawMessage(t, `{"name":"jsonapi"}`)},
+		}},
+	}
+
+	out, err := doc.Denormalize(nil)
+	if err != nil {
+		t.Fatalf("Denormalize returned error: %v", err)
+	}
+
+	list, ok := out.([]interface{})
+	if !ok || len(list) != 2 {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+	if list[0].(map[string]interface{})["name"] != "go" || list[1].(map[string]interface{})["name"] != "jsonapi" {
+		t.Fatalf("unexpected result: %+v", list)
+	}
+}
+
+func TestNormalizeExtractsTwoLevelNestedRelationshipsIntoIncluded(t *testing.T) {
+	nested := map[string]interface{}{
+		"type":  "articles",
+		"id":    "1",
+		"title": "Hello",
+		"author": map[string]interface{}{
+			"type": "people",
+			"id":   "9",
+			"name": "Alice",
+			"employer": map[string]interface{}{
+				"type": "companies",
+				"id":   "5",
+				"name": "Acme",
+			},
+		},
+	}
+
+	doc, err := Normalize(nested, nil)
+	if err != nil {
+		t.Fatalf("Normalize returned error: %v", err)
+	}
+
+	if doc.Data == nil || doc.Data.DataObject == nil {
+		t.Fatalf("expected a single primary resource, got %+v", doc.Data)
+	}
+	article := doc.Data.DataObject
+	if article.Type != "articles" || article.ID != "1" {
+		t.Fatalf("unexpected primary resource: %+v", article)
+	}
+
+	author, ok := article.Relationships["author"]
+	if !ok || author.Data == nil || author.Data.DataObject == nil {
+		t.Fatalf("expected an author relationship, got %+v", article.Relationships)
+	}
+	if author.Data.DataObject.Type != "people" || author.Data.DataObject.ID != "9" {
+		t.Fatalf("unexpected author linkage: %+v", author.Data.DataObject)
+	}
+
+	if len(doc.Included) != 2 {
+		t.Fatalf("expected 2 included resources, got %d: %+v", len(doc.Included), doc.Included)
+	}
+
+	index := IndexIncluded(doc.Included)
+	person, ok := index["people:9"]
+	if !ok {
+		t.Fatalf("expected people:9 in included, got %+v", doc.Included)
+	}
+	var personAttrs map[string]interface{}
+	if err := json.Unmarshal(person.Attributes, &personAttrs); err != nil {
+		t.Fatalf("failed to unmarshal person attributes: %v", err)
+	}
+	if personAttrs["name"] != "Alice" {
+		t.Fatalf("unexpected person attributes: %+v", personAttrs)
+	}
+	employer, ok := person.Relationships["employer"]
+	if !ok || employer.Data == nil || employer.Data.DataObject == nil || employer.Data.DataObject.ID != "5" {
+		t.Fatalf("unexpected employer linkage: %+v", person.Relationships)
+	}
+
+	company, ok := index["companies:5"]
+	if !ok {
+		t.Fatalf("expected companies:5 in included, got %+v", doc.Included)
+	}
+	var companyAttrs map[string]interface{}
+	if err := json.Unmarshal(company.Attributes, &companyAttrs); err != nil {
+		t.Fatalf("failed to unmarshal company attributes: %v", err)
+	}
+	if companyAttrs["name"] != "Acme" {
+		t.Fatalf("unexpected company attributes: %+v", companyAttrs)
+	}
+}
+
+func TestNormalizeDedupsRepeatedReferenceByID(t *testing.T) {
+	nested := map[string]interface{}{
+		"type": "articles",
+		"id":   "1",
+		"tags": []interface{}{
+			map[string]interface{}{"type": "tags", "id": "1", "name": "go"},
+			map[string]interface{}{"type": "tags", "id": "1", "name": "go"},
+		},
+	}
+
+	doc, err := Normalize(nested, nil)
+	if err != nil {
+		t.Fatalf("Normalize returned error: %v", err)
+	}
+	if len(doc.Included) != 1 {
+		t.Fatalf("expected a single deduplicated included resource, got %d: %+v", len(doc.Included), doc.Included)
+	}
+}
+
+func TestNormalizeOmitsPrimaryResourceFromIncludedOnCycle(t *testing.T) {
+	nested := map[string]interface{}{
+		"type": "articles",
+		"id":   "1",
+		"author": map[string]interface{}{
+			"type": "people",
+			"id":   "9",
+			"articles": []interface{}{
+				map[string]interface{}{"type": "articles", "id": "1"},
+			},
+		},
+	}
+
+	doc, err := Normalize(nested, nil)
+	if err != nil {
+		t.Fatalf("Normalize returned error: %v", err)
+	}
+
+	for _, d := range doc.Included {
+		if d.Type == "articles" && d.ID == "1" {
+			t.Fatalf("expected the primary resource not to appear in included, got %+v", doc.Included)
+		}
+	}
+	index := IndexIncluded(doc.Included)
+	person, ok := index["people:9"]
+	if !ok {
+		t.Fatalf("expected people:9 in included, got %+v", doc.Included)
+	}
+	articles, ok := person.Relationships["articles"]
+	if !ok || articles.Data == nil || len(articles.Data.DataArray) != 1 || articles.Data.DataArray[0].ID != "1" {
+		t.Fatalf("unexpected back-reference: %+v", person.Relationships["articles"])
+	}
+}
+
+func TestNormalizeOnCollectionTreatsEachElementAsPrimary(t *testing.T) {
+	nested := []interface{}{
+		map[string]interface{}{"type": "tags", "id": "1", "name": "go"},
+		map[string]interface{}{"type": "tags", "id": "2", "name": "jsonapi"},
+	}
+
+	doc, err := Normalize(nested, nil)
+	if err != nil {
+		t.Fatalf("Normalize returned error: %v", err)
+	}
+	if doc.Data == nil || len(doc.Data.DataArray) != 2 {
+		t.Fatalf("expected a 2-element primary array, got %+v", doc.Data)
+	}
+	if len(doc.Included) != 0 {
+		t.Fatalf("expected no included resources, got %+v", doc.Included)
+	}
+}
+
+func TestNormalizeRejectsMissingType(t *testing.T) {
+	_, err := Normalize(map[string]interface{}{"id": "1"}, nil)
+	if err == nil {
+		t.Fatal("expected an error for a resource with no type")
+	}
+}
+
+func TestNormalizeRejectsUnsupportedRoot(t *testing.T) {
+	_, err := Normalize("not a resource", nil)
+	if err == nil {
+		t.Fatal("expected an error for a non-map, non-slice root value")
+	}
+}
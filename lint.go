@@ -0,0 +1,338 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// LintSeverity categorizes a LintIssue.
+type LintSeverity int
+
+const (
+	// LintError marks a definite spec violation.
+	LintError LintSeverity = iota
+	// LintWarning marks something the spec allows but discourages, or that
+	// is commonly a mistake.
+	LintWarning
+)
+
+// String returns "error" or "warning".
+func (s LintSeverity) String() string {
+	if s == LintWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// LintIssue is a single conformance issue Lint found in a raw payload.
+// Pointer is a JSON pointer (RFC 6901) to the offending location, or "" for
+// a document-level issue.
+type LintIssue struct {
+	Severity LintSeverity
+	Pointer  string
+	Message  string
+}
+
+// Lint parses payload as a raw JSON:API document and reports spec
+// conformance issues by inspecting the untyped JSON directly, so it can
+// flag problems the Document struct wouldn't even decode, such as a
+// numeric "id" or "data" and "errors" both present. It returns nil for a
+// fully conformant document.
+func Lint(payload []byte) []LintIssue {
+	var top map[string]json.RawMessage
+	if err := unmarshalJSON(payload, &top); err != nil {
+		return []LintIssue{{Severity: LintError, Message: "payload is not a JSON object: " + err.Error()}}
+	}
+
+	var issues []LintIssue
+
+	_, hasData := top["data"]
+	_, hasErrors := top["errors"]
+	_, hasMeta := top["meta"]
+
+	if hasData && hasErrors {
+		issues = append(issues, LintIssue{Severity: LintError, Message: `a document must not include both "data" and "errors"`})
+	}
+	if !hasData && !hasErrors && !hasMeta {
+		issues = append(issues, LintIssue{Severity: LintError, Message: `a document must contain at least one of "data", "errors" or "meta"`})
+	}
+
+	if hasData {
+		issues = append(issues, lintData(top["data"], "/data")...)
+	}
+	if hasMeta {
+		issues = append(issues, lintMeta(top["meta"], "/meta")...)
+	}
+
+	return issues
+}
+
+// lintMeta reports an error if raw, the raw JSON of a "meta" member, isn't
+// a JSON object, per the spec's requirement that meta always be an object
+// — e.g. "meta": [] or "meta": "x" are both violations the Document struct
+// itself can't catch, since map[string]interface{} simply fails to decode
+// rather than reporting a lintable issue.
+func lintMeta(raw json.RawMessage, pointer string) []LintIssue {
+	var v interface{}
+	if err := unmarshalJSON(raw, &v); err != nil {
+		return []LintIssue{{Severity: LintError, Pointer: pointer, Message: `"meta" is not valid JSON: ` + err.Error()}}
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return []LintIssue{{Severity: LintError, Pointer: pointer, Message: `"meta" must be an object`}}
+	}
+	return nil
+}
+
+// lintData lints the raw "data" member at pointer, which may be a single
+// resource object, an array of them, or null.
+func lintData(raw json.RawMessage, pointer string) []LintIssue {
+	trimmed := bytes.TrimSpace(raw)
+	if bytes.Equal(trimmed, nullLiteral) {
+		return nil
+	}
+
+	if bytes.HasPrefix(trimmed, arraySuffix) {
+		var arr []json.RawMessage
+		if err := unmarshalJSON(raw, &arr); err != nil {
+			return []LintIssue{{Severity: LintError, Pointer: pointer, Message: "expected an array of resource objects: " + err.Error()}}
+		}
+
+		var issues []LintIssue
+		for i, item := range arr {
+			issues = append(issues, lintResource(item, fmt.Sprintf("%s/%d", pointer, i))...)
+		}
+		return issues
+	}
+
+	return lintResource(raw, pointer)
+}
+
+// lintResource lints a single resource object at pointer.
+func lintResource(raw json.RawMessage, pointer string) []LintIssue {
+	var res map[string]json.RawMessage
+	if err := unmarshalJSON(raw, &res); err != nil {
+		return []LintIssue{{Severity: LintError, Pointer: pointer, Message: "expected a resource object: " + err.Error()}}
+	}
+
+	var issues []LintIssue
+
+	typeRaw, hasType := res["type"]
+	switch {
+	case !hasType:
+		issues = append(issues, LintIssue{Severity: LintError, Pointer: pointer, Message: `resource object must have a "type" member`})
+	default:
+		var typ string
+		if err := unmarshalJSON(typeRaw, &typ); err != nil || typ == "" {
+			issues = append(issues, LintIssue{Severity: LintError, Pointer: pointer + "/type", Message: `"type" must be a non-empty string`})
+		}
+	}
+
+	idRaw, hasID := res["id"]
+	_, hasLID := res["lid"]
+	switch {
+	case hasID:
+		var id string
+		if err := unmarshalJSON(idRaw, &id); err != nil {
+			issues = append(issues, LintIssue{Severity: LintError, Pointer: pointer + "/id", Message: `"id" must be a string`})
+		}
+	case !hasLID:
+		issues = append(issues, LintIssue{Severity: LintWarning, Pointer: pointer, Message: `resource object has neither "id" nor "lid"`})
+	}
+
+	if metaRaw, ok := res["meta"]; ok {
+		issues = append(issues, lintMeta(metaRaw, pointer+"/meta")...)
+	}
+
+	if relRaw, ok := res["relationships"]; ok {
+		issues = append(issues, lintRelationships(relRaw, pointer+"/relationships")...)
+	}
+
+	return issues
+}
+
+// lintRelationships lints the raw "relationships" member of a resource
+// object at pointer, checking each relationship's "meta", if present.
+func lintRelationships(raw json.RawMessage, pointer string) []LintIssue {
+	var rels map[string]json.RawMessage
+	if err := unmarshalJSON(raw, &rels); err != nil {
+		return []LintIssue{{Severity: LintError, Pointer: pointer, Message: `"relationships" must be an object: ` + err.Error()}}
+	}
+
+	names := make([]string, 0, len(rels))
+	for name := range rels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var issues []LintIssue
+	for _, name := range names {
+		var rel map[string]json.RawMessage
+		if err := unmarshalJSON(rels[name], &rel); err != nil {
+			issues = append(issues, LintIssue{Severity: LintError, Pointer: pointer + "/" + name, Message: "relationship must be an object: " + err.Error()})
+			continue
+		}
+		if metaRaw, ok := rel["meta"]; ok {
+			issues = append(issues, lintMeta(metaRaw, pointer+"/"+name+"/meta")...)
+		}
+	}
+	return issues
+}
+
+// ValidateStream checks r for the same structural rules Lint enforces on an
+// in-memory payload — "data" and "errors" must not coexist, the document
+// must carry at least one of "data", "errors" or "meta", and every resource
+// object in "data" must have a non-empty "type" — but reads it with
+// json.Decoder tokens instead of buffering it into a map or a Document. This
+// makes it cheap to reject a malformed upload before committing to reading
+// all of it. Unlike Lint, which collects every issue it finds, ValidateStream
+// returns as soon as it hits the first violation, annotated with the
+// decoder's byte offset at that point as an approximate location, since a
+// token stream has no JSON pointer to offer until it's fully buffered.
+func ValidateStream(r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("jsonapi: offset %d: expected a JSON object", dec.InputOffset())
+	}
+
+	var hasData, hasErrors, hasMeta bool
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("jsonapi: offset %d: expected an object key", dec.InputOffset())
+		}
+
+		switch key {
+		case "data":
+			hasData = true
+			if err := validateStreamData(dec); err != nil {
+				return err
+			}
+		case "errors":
+			hasErrors = true
+			var discard json.RawMessage
+			if err := dec.Decode(&discard); err != nil {
+				return err
+			}
+		case "meta":
+			hasMeta = true
+			var discard json.RawMessage
+			if err := dec.Decode(&discard); err != nil {
+				return err
+			}
+		default:
+			var discard json.RawMessage
+			if err := dec.Decode(&discard); err != nil {
+				return err
+			}
+		}
+
+		if hasData && hasErrors {
+			return fmt.Errorf(`jsonapi: offset %d: a document must not include both "data" and "errors"`, dec.InputOffset())
+		}
+	}
+
+	if !hasData && !hasErrors && !hasMeta {
+		return fmt.Errorf(`jsonapi: offset %d: a document must contain at least one of "data", "errors" or "meta"`, dec.InputOffset())
+	}
+
+	return nil
+}
+
+// validateStreamData validates the value of "data", having already consumed
+// the "data" key token, checking every resource object it contains (whether
+// "data" is a single object, an array, or null) for a non-empty "type".
+func validateStreamData(dec *json.Decoder) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch d := t.(type) {
+	case json.Delim:
+		if d == '{' {
+			return validateStreamResource(dec, "/data")
+		}
+		if d == '[' {
+			for i := 0; dec.More(); i++ {
+				t, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				od, ok := t.(json.Delim)
+				if !ok || od != '{' {
+					return fmt.Errorf("jsonapi: offset %d: expected a resource object at /data/%d", dec.InputOffset(), i)
+				}
+				if err := validateStreamResource(dec, fmt.Sprintf("/data/%d", i)); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil { // closing ']'
+				return err
+			}
+			return nil
+		}
+		return fmt.Errorf(`jsonapi: offset %d: "data" must be an object, array, or null`, dec.InputOffset())
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf(`jsonapi: offset %d: "data" must be an object, array, or null`, dec.InputOffset())
+	}
+}
+
+// validateStreamResource validates a single resource object at pointer,
+// having already consumed its opening '{', and consumes through its
+// closing '}' before returning.
+func validateStreamResource(dec *json.Decoder, pointer string) error {
+	var hasType bool
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("jsonapi: offset %d: expected an object key", dec.InputOffset())
+		}
+
+		if key == "type" {
+			var typ string
+			if err := dec.Decode(&typ); err != nil {
+				return fmt.Errorf(`jsonapi: offset %d: %s/type must be a string`, dec.InputOffset(), pointer)
+			}
+			if typ == "" {
+				return fmt.Errorf(`jsonapi: offset %d: %s must have a non-empty "type"`, dec.InputOffset(), pointer)
+			}
+			hasType = true
+			continue
+		}
+
+		var discard json.RawMessage
+		if err := dec.Decode(&discard); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil { // closing '}'
+		return err
+	}
+
+	if !hasType {
+		return fmt.Errorf(`jsonapi: offset %d: %s must have a "type" member`, dec.InputOffset(), pointer)
+	}
+
+	return nil
+}
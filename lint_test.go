@@ -0,0 +1,209 @@
+package jsonapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintCleanDocumentReportsNoIssues(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people","id":"1"}}`))
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestLintFlagsDataAndErrorsCoexisting(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people","id":"1"},"errors":[{"title":"boom"}]}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Severity == LintError && issue.Message == `a document must not include both "data" and "errors"` {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a data+errors conflict issue, got %+v", issues)
+	}
+}
+
+func TestLintFlagsMissingType(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"id":"1"}}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Severity == LintError && issue.Pointer == "/data" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a missing-type issue at /data, got %+v", issues)
+	}
+}
+
+func TestLintFlagsNonStringID(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people","id":1}}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Pointer == "/data/id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a non-string id issue at /data/id, got %+v", issues)
+	}
+}
+
+func TestLintWarnsOnMissingIDAndLID(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people"}}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Severity == LintWarning && issue.Pointer == "/data" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a missing id/lid warning, got %+v", issues)
+	}
+}
+
+func TestLintCoversResourceArray(t *testing.T) {
+	issues := Lint([]byte(`{"data":[{"type":"people","id":"1"},{"id":"2"}]}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Pointer == "/data/1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected an issue at /data/1 for the second element, got %+v", issues)
+	}
+}
+
+func TestLintFlagsEmptyTopLevel(t *testing.T) {
+	issues := Lint([]byte(`{}`))
+
+	if len(issues) != 1 || issues[0].Severity != LintError {
+		t.Fatalf("expected a single error for an empty document, got %+v", issues)
+	}
+}
+
+func TestLintRejectsNonObjectPayload(t *testing.T) {
+	issues := Lint([]byte(`[1,2,3]`))
+
+	if len(issues) != 1 || issues[0].Severity != LintError {
+		t.Fatalf("expected a single error for a non-object payload, got %+v", issues)
+	}
+}
+
+func TestLintFlagsArrayTopLevelMeta(t *testing.T) {
+	issues := Lint([]byte(`{"meta":[]}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Pointer == "/meta" && issue.Message == `"meta" must be an object` {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf(`expected a "meta" must be an object issue at /meta, got %+v`, issues)
+	}
+}
+
+func TestLintFlagsArrayResourceMeta(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people","id":"1","meta":[]}}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Pointer == "/data/meta" && issue.Message == `"meta" must be an object` {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf(`expected a "meta" must be an object issue at /data/meta, got %+v`, issues)
+	}
+}
+
+func TestLintFlagsArrayRelationshipMeta(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people","id":"1","relationships":{"author":{"meta":[]}}}}`))
+
+	found := false
+	for _, issue := range issues {
+		if issue.Pointer == "/data/relationships/author/meta" && issue.Message == `"meta" must be an object` {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf(`expected a "meta" must be an object issue at /data/relationships/author/meta, got %+v`, issues)
+	}
+}
+
+func TestLintAcceptsObjectMetaAtAllLevels(t *testing.T) {
+	issues := Lint([]byte(`{"data":{"type":"people","id":"1","meta":{"a":1},"relationships":{"author":{"meta":{"b":2}}}},"meta":{"c":3}}`))
+
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues for object meta at every level, got %+v", issues)
+	}
+}
+
+func TestValidateStreamAcceptsCleanDocument(t *testing.T) {
+	r := strings.NewReader(`{"data":{"type":"people","id":"1"}}`)
+	if err := ValidateStream(r); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateStreamAcceptsResourceArray(t *testing.T) {
+	r := strings.NewReader(`{"data":[{"type":"people","id":"1"},{"type":"people","id":"2"}]}`)
+	if err := ValidateStream(r); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateStreamRejectsDataAndErrorsCoexisting(t *testing.T) {
+	r := strings.NewReader(`{"data":{"type":"people","id":"1"},"errors":[{"title":"boom"}]}`)
+	err := ValidateStream(r)
+	if err == nil || !strings.Contains(err.Error(), `must not include both "data" and "errors"`) {
+		t.Fatalf("expected a data/errors error, got %v", err)
+	}
+}
+
+func TestValidateStreamRejectsMissingType(t *testing.T) {
+	r := strings.NewReader(`{"data":{"id":"1"}}`)
+	err := ValidateStream(r)
+	if err == nil || !strings.Contains(err.Error(), `must have a "type" member`) {
+		t.Fatalf("expected a missing-type error, got %v", err)
+	}
+}
+
+func TestValidateStreamRejectsMissingTypeWithinArray(t *testing.T) {
+	r := strings.NewReader(`{"data":[{"type":"people","id":"1"},{"id":"2"}]}`)
+	err := ValidateStream(r)
+	if err == nil || !strings.Contains(err.Error(), "/data/1") {
+		t.Fatalf("expected an error pointing at /data/1, got %v", err)
+	}
+}
+
+func TestValidateStreamRejectsEmptyTopLevel(t *testing.T) {
+	r := strings.NewReader(`{"links":{"self":"https://example.com"}}`)
+	err := ValidateStream(r)
+	if err == nil || !strings.Contains(err.Error(), `must contain at least one of`) {
+		t.Fatalf("expected a missing-top-level-member error, got %v", err)
+	}
+}
+
+func TestValidateStreamAcceptsNullData(t *testing.T) {
+	r := strings.NewReader(`{"data":null}`)
+	if err := ValidateStream(r); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateStreamAcceptsMetaOnlyDocument(t *testing.T) {
+	r := strings.NewReader(`{"meta":{"count":0}}`)
+	if err := ValidateStream(r); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package jsonapi
+
+import "fmt"
+
+// BatchLoader fetches the full resource objects for a batch of relationship
+// identifiers in one call instead of one at a time, so assembling a compound
+// document from an `include` path doesn't issue one query per related
+// resource. It may return the fetched resources in any order; a requested id
+// that isn't found is simply omitted from the result, not an error.
+type BatchLoader func(ids []RelationshipData) ([]Data, error)
+
+// Loaders maps a relationship's JSON:API member name to the BatchLoader
+// responsible for fetching it. SideloadIncluded looks up a loader by name at
+// each level of the include tree it walks.
+type Loaders map[string]BatchLoader
+
+// SideloadIncluded walks tree -- as produced by ParseQuery's Include, or
+// parseInclude directly -- starting from doc.Data, and at each level
+// collects every identifier a relationship in tree references across all
+// resources at that level, then calls the matching Loaders entry once with
+// the full set instead of once per resource. The resources it fetches are
+// appended to doc.Included, deduplicated against doc.Data and what's already
+// in doc.Included, and become the level SideloadIncluded descends into for
+// the next path segment. A relationship cycle -- a fetched resource
+// referencing doc.Data or an earlier fetch -- stops there instead of being
+// requested again.
+//
+// It returns an error naming the relationship if tree references a
+// relationship with no entry in loaders; a relationship present in tree but
+// absent or empty on every resource at that level is simply skipped.
+func SideloadIncluded(doc *Document, tree IncludeTree, loaders Loaders) error {
+	if doc == nil || doc.Data == nil || len(tree) == 0 {
+		return nil
+	}
+
+	primary := primaryResources(doc.Data)
+	seen := map[string]bool{}
+	for _, d := range primary {
+		seen[d.Type+":"+d.ID] = true
+	}
+	for _, d := range doc.Included {
+		seen[d.Type+":"+d.ID] = true
+	}
+
+	return sideloadLevel(doc, primary, tree, loaders, seen)
+}
+
+// primaryResources returns c's resource objects, whether it holds a single
+// resource or a collection, as the uniform slice sideloadLevel walks.
+func primaryResources(c *DataContainer) []Data {
+	if c.DataObject != nil {
+		return []Data{*c.DataObject}
+	}
+	return c.DataArray
+}
+
+// sideloadLevel fetches, via loaders, every relationship tree names that's
+// reachable from resources, then recurses into each relationship's subtree
+// against the resources its loader returned.
+func sideloadLevel(doc *Document, resources []Data, tree IncludeTree, loaders Loaders, seen map[string]bool) error {
+	for name, subtree := range tree {
+		loader, ok := loaders[name]
+		if !ok {
+			return fmt.Errorf("jsonapi: no loader registered for relationship %q", name)
+		}
+
+		var ids []RelationshipData
+		requested := map[string]bool{}
+		for _, res := range resources {
+			rel, ok := res.Relationships[name]
+			if !ok {
+				continue
+			}
+			for _, rd := range relationshipLinkage(rel) {
+				key := rd.Type + ":" + rd.ID
+				if seen[key] || requested[key] {
+					continue
+				}
+				requested[key] = true
+				ids = append(ids, rd)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		fetched, err := loader(ids)
+		if err != nil {
+			return fmt.Errorf("jsonapi: loading relationship %q: %w", name, err)
+		}
+
+		for _, d := range fetched {
+			key := d.Type + ":" + d.ID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			doc.Included = append(doc.Included, d)
+		}
+
+		if len(subtree) > 0 {
+			if err := sideloadLevel(doc, fetched, subtree, loaders, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
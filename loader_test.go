@@ -0,0 +1,196 @@
+package jsonapi
+
+import "testing"
+
+func TestSideloadIncludedCallsLoaderOnceWithFullBatch(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "articles", ID: "1", Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			}},
+			{Type: "articles", ID: "2", Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "10"}}},
+			}},
+			{Type: "articles", ID: "3", Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			}},
+		}},
+	}
+
+	calls := 0
+	loaders := Loaders{
+		"author": func(ids []RelationshipData) ([]Data, error) {
+			calls++
+			if len(ids) != 2 {
+				t.Fatalf("expected one batch call with 2 distinct ids, got %d: %+v", len(ids), ids)
+			}
+			var out []Data
+			for _, id := range ids {
+				out = append(out, Data{Type: id.Type, ID: id.ID})
+			}
+			return out, nil
+		},
+	}
+
+	if err := SideloadIncluded(doc, IncludeTree{"author": {}}, loaders); err != nil {
+		t.Fatalf("SideloadIncluded returned error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the author loader to be called exactly once, got %d calls", calls)
+	}
+	if len(doc.Included) != 2 {
+		t.Fatalf("expected 2 deduplicated people in Included, got %+v", doc.Included)
+	}
+}
+
+func TestSideloadIncludedDescendsIntoNestedPath(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"comments": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{
+					{Type: "comments", ID: "1"},
+					{Type: "comments", ID: "2"},
+				}}},
+			},
+		}},
+	}
+
+	var userLoaderIDs []RelationshipData
+	loaders := Loaders{
+		"comments": func(ids []RelationshipData) ([]Data, error) {
+			var out []Data
+			for _, id := range ids {
+				out = append(out, Data{
+					Type: id.Type, ID: id.ID,
+					Relationships: map[string]Relationship{
+						"user": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "shared-author"}}},
+					},
+				})
+			}
+			return out, nil
+		},
+		"user": func(ids []RelationshipData) ([]Data, error) {
+			userLoaderIDs = ids
+			var out []Data
+			for _, id := range ids {
+				out = append(out, Data{Type: id.Type, ID: id.ID})
+			}
+			return out, nil
+		},
+	}
+
+	tree := IncludeTree{"comments": {"user": {}}}
+	if err := SideloadIncluded(doc, tree, loaders); err != nil {
+		t.Fatalf("SideloadIncluded returned error: %v", err)
+	}
+
+	if len(userLoaderIDs) != 1 {
+		t.Fatalf("expected both comments' shared author to be requested once, got %+v", userLoaderIDs)
+	}
+	if len(doc.Included) != 3 {
+		t.Fatalf("expected 2 comments and 1 person in Included, got %+v", doc.Included)
+	}
+}
+
+func TestSideloadIncludedSkipsIDsAlreadyInIncluded(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+		}},
+		Included: []Data{{Type: "people", ID: "9"}},
+	}
+
+	called := false
+	loaders := Loaders{"author": func(ids []RelationshipData) ([]Data, error) {
+		called = true
+		return nil, nil
+	}}
+
+	if err := SideloadIncluded(doc, IncludeTree{"author": {}}, loaders); err != nil {
+		t.Fatalf("SideloadIncluded returned error: %v", err)
+	}
+	if called {
+		t.Fatal("expected the loader not to be called for an id already present in Included")
+	}
+}
+
+func TestSideloadIncludedTerminatesOnTwoNodeCycle(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "a", ID: "1",
+			Relationships: map[string]Relationship{
+				"b": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "b", ID: "2"}}},
+			},
+		}},
+	}
+
+	calls := map[string]int{}
+	loaders := Loaders{
+		"b": func(ids []RelationshipData) ([]Data, error) {
+			calls["b"]++
+			return []Data{{
+				Type: "b", ID: "2",
+				Relationships: map[string]Relationship{
+					"a": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "a", ID: "1"}}},
+				},
+			}}, nil
+		},
+		"a": func(ids []RelationshipData) ([]Data, error) {
+			calls["a"]++
+			return []Data{{Type: "a", ID: "1"}}, nil
+		},
+	}
+
+	tree := IncludeTree{"b": {"a": {"b": {}}}}
+	if err := SideloadIncluded(doc, tree, loaders); err != nil {
+		t.Fatalf("SideloadIncluded returned error: %v", err)
+	}
+	if calls["b"] != 1 {
+		t.Fatalf("expected the b loader to run exactly once despite the cycle, got %+v", calls)
+	}
+	if calls["a"] != 0 {
+		t.Fatalf("expected the a loader not to run, since a's only id is the primary resource already seen, got %+v", calls)
+	}
+	if len(doc.Included) != 1 || doc.Included[0].Type != "b" {
+		t.Fatalf("expected only b to be fetched, since a was already the primary resource, got %+v", doc.Included)
+	}
+}
+
+func TestSideloadIncludedErrorsOnMissingLoader(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+		}},
+	}
+
+	err := SideloadIncluded(doc, IncludeTree{"author": {}}, Loaders{})
+	if err == nil {
+		t.Fatal("expected an error naming the relationship with no registered loader")
+	}
+}
+
+func TestSideloadIncludedSkipsRelationshipAbsentFromEveryResource(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+	}
+
+	called := false
+	loaders := Loaders{"author": func(ids []RelationshipData) ([]Data, error) {
+		called = true
+		return nil, nil
+	}}
+
+	if err := SideloadIncluded(doc, IncludeTree{"author": {}}, loaders); err != nil {
+		t.Fatalf("SideloadIncluded returned error: %v", err)
+	}
+	if called {
+		t.Fatal("expected the loader not to be called when no resource has the relationship")
+	}
+}
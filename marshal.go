@@ -0,0 +1,1512 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// jsonapiTag is the parsed form of a struct field's `jsonapi` tag, e.g.
+// `jsonapi:"attr,title"`, `jsonapi:"attr,title,omitempty"`,
+// `jsonapi:"attr,createdAt,readonly"` or `jsonapi:"relation,author"`.
+//
+// Declaring an attribute as a pointer type (e.g. `Bio *string`) gives full
+// three-state control over how it's marshaled, which matters for PATCH
+// responses/requests where "omitted" and "explicitly cleared" are different
+// things:
+//   - a non-nil pointer marshals its pointed-to value, same as a plain field
+//   - a nil pointer without omitempty marshals as an explicit "field":null
+//   - a nil pointer with omitempty is left out of "attributes" entirely
+//
+// A non-pointer field has no nil state, so it can only ever be present
+// (with omitempty skipping it on its zero value) — it can't express an
+// explicit null.
+//
+// Whether a struct-typed field is a value object or a relationship is
+// decided entirely by the tag's Kind, never by reflect.Kind: a
+// `jsonapi:"attr,..."` struct field (e.g. an Address embedded by value, not
+// a pointer to another resource) is encoded as a nested JSON object inside
+// "attributes", the same as encoding/json would encode it on its own,
+// while `jsonapi:"relation,..."` is the only path that produces a resource
+// identifier and a sideloaded "included" entry.
+type jsonapiTag struct {
+	Kind      string // "primary", "attr", "relation" or "meta"
+	Name      string // resource type (primary) or attribute/relationship/meta name
+	OmitEmpty bool   // "attr" and "meta": skip the field when it's a zero value
+	ReadOnly  bool   // "attr" only: server-controlled, see WithReadOnlyPolicy
+}
+
+// parseJSONAPITag splits a `jsonapi` struct tag the same way
+// CustomObject.JSONToStruct splits `json` tags.
+func parseJSONAPITag(tag string) (jsonapiTag, bool) {
+	parts := strings.Split(tag, ",")
+	if len(parts) < 2 || parts[0] == "-" {
+		return jsonapiTag{}, false
+	}
+
+	t := jsonapiTag{Kind: parts[0], Name: parts[1]}
+	for _, opt := range parts[2:] {
+		switch opt {
+		case "omitempty":
+			t.OmitEmpty = true
+		case "readonly":
+			t.ReadOnly = true
+		}
+	}
+	return t, true
+}
+
+// TypeOverrider lets a model derive its resource type at runtime (e.g. from
+// a subtype discriminator field) instead of reading it statically from its
+// `jsonapi:"primary,..."` tag, for single-table-inheritance models where one
+// Go struct can represent several resource types. It is checked the same
+// way as Marshaler and LinkableResource, and takes priority over WithTypeNamer
+// when both are present. A resource that doesn't implement it, or whose
+// JSONAPIType returns "", keeps the tag's (or namer's) type.
+type TypeOverrider interface {
+	JSONAPIType() string
+}
+
+// IDStringer lets a model encode its own "id" member during Marshal,
+// checked via an interface assertion the same way TypeOverrider is. It's
+// meant for primary fields that aren't naturally a string — a UUID stored
+// as [16]byte, a composite key, an encrypted id — which the default
+// rendering can't express. A model that doesn't implement it falls back to
+// formatPrimaryID, which handles a string, an integer or a fmt.Stringer
+// field and returns an error for any other kind.
+type IDStringer interface {
+	JSONAPIID() string
+}
+
+// IDParser is IDStringer's Unmarshal-side counterpart: it lets a model
+// parse its own "id" member instead of relying on the default behavior of
+// setting a string-typed primary field directly. A model that doesn't
+// implement it is decoded as today: a string-typed primary field is set
+// from "id" verbatim, and a non-string one is left untouched.
+type IDParser interface {
+	JSONAPISetID(id string) error
+}
+
+// ResourceMarshaler lets a model take total control of its own Data during
+// Marshal, bypassing the reflective walk of its `jsonapi`-tagged fields
+// entirely -- for a resource shaped too irregularly for tags to express, or
+// one that already builds its own Data for other reasons. It's checked the
+// same way as TypeOverrider, but earlier: structToData defers to it before
+// looking at tags at all, so MarshalJSONAPI is responsible for the whole
+// resource object, relationships included. A model that doesn't implement
+// it is marshaled as today.
+type ResourceMarshaler interface {
+	MarshalJSONAPI() (*Data, error)
+}
+
+// ResourceUnmarshaler is ResourceMarshaler's Unmarshal-side counterpart: a
+// model implementing it decodes d into itself however it likes, bypassing
+// dataToStruct's reflective walk entirely. A model that doesn't implement
+// it is decoded as today.
+type ResourceUnmarshaler interface {
+	UnmarshalJSONAPI(d *Data) error
+}
+
+// addressablePtr returns a pointer to rv's value, so an interface assertion
+// against a pointer-receiver method finds it even when rv itself isn't
+// addressable (e.g. it came from a slice of values rather than pointers).
+func addressablePtr(rv reflect.Value) reflect.Value {
+	if rv.CanAddr() {
+		return rv.Addr()
+	}
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	return ptr
+}
+
+// primaryTypeID peeks a struct's `jsonapi:"primary,..."` field without
+// walking its attributes or relationships, so callers can recognize a
+// resource they've already visited before recursing into it. namer, if
+// non-nil, overrides the tag's resource type name (see TypeNamer); a
+// TypeOverrider implementation, if present, overrides both. A non-nil
+// error means the primary field's value couldn't be rendered as an id (see
+// formatPrimaryID); ok is still true in that case, since the struct does
+// have a primary field, it's just unformattable.
+func primaryTypeID(rv reflect.Value, namer TypeNamer) (string, string, bool, error) {
+	rt := rv.Type()
+	meta := typeMetaFor(rt)
+	if meta.primaryIndex < 0 {
+		return "", "", false, nil
+	}
+
+	typ := resourceTypeName(rt, meta.primaryTag.Name, namer)
+	ptr := addressablePtr(rv)
+	if o, ok := ptr.Interface().(TypeOverrider); ok {
+		if override := o.JSONAPIType(); override != "" {
+			typ = override
+		}
+	}
+
+	if s, ok := ptr.Interface().(IDStringer); ok {
+		return typ, s.JSONAPIID(), true, nil
+	}
+
+	id, err := formatPrimaryID(rv.Field(meta.primaryIndex))
+	if err != nil {
+		return typ, "", true, err
+	}
+
+	return typ, id, true, nil
+}
+
+// formatPrimaryID renders a struct's primary field as the string Data.ID
+// expects: directly for a string, the signed/unsigned integer kinds, or a
+// fmt.Stringer -- the shapes `ID int64`/`ID uint`/a UUID type with a
+// String() method naturally come in. Anything else is rejected rather than
+// falling back to fmt.Sprintf's default formatting, which would silently
+// dump a struct or map's Go representation into the id instead of failing
+// loudly. A model with an id of some other shape should implement
+// IDStringer, which primaryTypeID checks before ever calling this.
+func formatPrimaryID(fv reflect.Value) (string, error) {
+	if s, ok := fv.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("jsonapi: unsupported primary field kind %s for id encoding; implement IDStringer to customize it", fv.Kind())
+	}
+}
+
+// taggedField is a struct field's index paired with its parsed `jsonapi` tag.
+type taggedField struct {
+	Index int
+	Tag   jsonapiTag
+}
+
+// structTypeMeta is the parsed `jsonapi` tag layout of a struct type:
+// which field (if any) is the primary field, and which fields are
+// attributes, relationships or meta entries, in declaration order.
+// typeMetaFor caches one of these per reflect.Type so structToData and
+// dataToStruct only walk a type's fields and parse its tags once, no
+// matter how many times that type is marshaled or unmarshaled.
+type structTypeMeta struct {
+	primaryIndex int
+	primaryTag   jsonapiTag
+	attrs        []taggedField
+	relations    []taggedField
+	metas        []taggedField
+}
+
+var typeMetaCache sync.Map // reflect.Type -> *structTypeMeta
+
+// typeMetaFor returns rt's cached structTypeMeta, computing and storing it
+// on first use. It is safe for concurrent use.
+func typeMetaFor(rt reflect.Type) *structTypeMeta {
+	if cached, ok := typeMetaCache.Load(rt); ok {
+		return cached.(*structTypeMeta)
+	}
+
+	meta := &structTypeMeta{primaryIndex: -1}
+	for i := 0; i < rt.NumField(); i++ {
+		tagStr, ok := rt.Field(i).Tag.Lookup("jsonapi")
+		if !ok {
+			continue
+		}
+		tag, ok := parseJSONAPITag(tagStr)
+		if !ok {
+			continue
+		}
+
+		switch tag.Kind {
+		case "primary":
+			meta.primaryIndex = i
+			meta.primaryTag = tag
+		case "attr":
+			meta.attrs = append(meta.attrs, taggedField{Index: i, Tag: tag})
+		case "relation":
+			meta.relations = append(meta.relations, taggedField{Index: i, Tag: tag})
+		case "meta":
+			meta.metas = append(meta.metas, taggedField{Index: i, Tag: tag})
+		}
+	}
+
+	actual, _ := typeMetaCache.LoadOrStore(rt, meta)
+	return actual.(*structTypeMeta)
+}
+
+// TypeNamer derives a resource type name from a Go struct type, letting
+// Marshal's output follow a naming convention (e.g. pluralization) without
+// having to write it into every `jsonapi:"primary,..."` tag.
+type TypeNamer func(reflect.Type) string
+
+// resourceTypeName returns tagName, or namer(rt) when namer is non-nil.
+func resourceTypeName(rt reflect.Type, tagName string, namer TypeNamer) string {
+	if namer == nil {
+		return tagName
+	}
+	return namer(rt)
+}
+
+// MarshalOption configures Marshal's behavior.
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	sideloadIncluded     bool
+	fields               FilterFields
+	countKey             string
+	typeNamer            TypeNamer
+	timeFormat           TimeFormat
+	skipNilElements      bool
+	resourceMeta         ResourceMetaFunc
+	keyTransform         KeyTransform
+	createMode           bool
+	attrPredicate        AttributePredicate
+	relationshipLinkBase string
+	linkTransformer      LinkTransformer
+	include              IncludeTree
+}
+
+// AttributePredicate decides whether an attribute should be included in
+// Marshal's output. fieldName is the attribute's `jsonapi:"attr,..."` name
+// (after any WithKeyTransform) and value is the Go field's value, exactly as
+// read via reflection, before formatting. It's called once per attribute per
+// resource, including resources reached through a relationship, except for
+// an omitempty attribute holding its zero value, which is dropped before the
+// predicate would see it.
+type AttributePredicate func(fieldName string, value interface{}) bool
+
+// WithAttributePredicate tells Marshal to call predicate for every
+// attribute on every resource it encodes and drop the ones it returns false
+// for, enabling dynamic, per-value visibility — e.g. hiding a nil optional
+// field, or a field gated by the current user's role captured in predicate's
+// closure — that a static omitempty tag or SparseFields allowlist can't
+// express since neither can inspect the value or outside state. Dropping an
+// attribute this way is indistinguishable from the struct never having had
+// that field; it applies before SparseFields and the attribute allowlist
+// installed by SetAttributeAllowlist narrow the result further.
+func WithAttributePredicate(predicate AttributePredicate) MarshalOption {
+	return func(o *marshalOptions) { o.attrPredicate = predicate }
+}
+
+// ResourceMetaFunc computes per-resource meta for resource (the struct
+// value Marshal is currently encoding, never a pointer), e.g. access
+// permissions for the current user. A nil return omits meta for that
+// resource.
+type ResourceMetaFunc func(resource interface{}) map[string]interface{}
+
+// WithResourceMeta tells Marshal to call fn for every resource it encodes,
+// including ones reached through a relationship, and attach the map it
+// returns to that resource's Data.Meta. Unlike the Marshaler interface,
+// this lets meta be computed from request-scoped state (the current user,
+// say) rather than only from the resource's own fields; fn takes
+// precedence over Marshaler when both are present.
+func WithResourceMeta(fn ResourceMetaFunc) MarshalOption {
+	return func(o *marshalOptions) { o.resourceMeta = fn }
+}
+
+// SkipNilElements tells Marshal to silently drop nil pointers found in a
+// slice of pointers (e.g. []*Article) instead of returning an error. This is
+// convenient for ORMs that leave gaps in a result slice rather than
+// compacting it.
+func SkipNilElements() MarshalOption {
+	return func(o *marshalOptions) { o.skipNilElements = true }
+}
+
+// WithTimeFormat tells Marshal to render time.Time (and *time.Time)
+// attributes using format instead of the default RFC 3339 string, e.g. as a
+// Unix timestamp for a frontend that expects one. It applies to every
+// resource Marshal encodes, including ones reached through a relationship.
+func WithTimeFormat(format TimeFormat) MarshalOption {
+	return func(o *marshalOptions) { o.timeFormat = format }
+}
+
+// WithTypeNamer tells Marshal to derive each resource's "type" by calling
+// namer with the Go struct type, instead of reading it verbatim from the
+// `jsonapi:"primary,..."` tag. This lets the same tagged structs drive
+// different type-naming conventions (e.g. singular tags, pluralized output)
+// across backends without changing the tags themselves.
+func WithTypeNamer(namer TypeNamer) MarshalOption {
+	return func(o *marshalOptions) { o.typeNamer = namer }
+}
+
+// SkipIncluded tells Marshal to still build relationship references (type+id
+// only) but not append the related resources to the Document's Included
+// array. Related resources are sideloaded into Included by default.
+func SkipIncluded() MarshalOption {
+	return func(o *marshalOptions) { o.sideloadIncluded = false }
+}
+
+// SparseFields restricts Marshal's output to the attributes and
+// relationships named in fields, per resource type, implementing sparse
+// fieldsets (the `fields[TYPE]` query parameter) for the reflection-based
+// marshaler. A type absent from fields is marshaled with all of its fields;
+// a type present with a (possibly empty) list is restricted to exactly
+// those attribute and relationship names. It applies to every resource
+// Marshal encodes, including ones reached through a relationship.
+func SparseFields(fields FilterFields) MarshalOption {
+	return func(o *marshalOptions) { o.fields = fields }
+}
+
+// WithRelationshipLinkTemplates tells Marshal to generate "self"/"related"
+// links (via BuildRelationshipSelfLinks) for any relationship that's left
+// with neither loaded data nor explicit links -- typically a nil pointer
+// field, or an empty Relationship a Marshaler chose not to populate --
+// instead of serializing it as an empty, effectively unusable relationship
+// object. base is the API's root URL, passed to BuildRelationshipSelfLinks
+// along with the owning resource's type, id and the relationship's name. It
+// applies to every resource Marshal encodes, including ones reached through
+// a relationship.
+func WithRelationshipLinkTemplates(base string) MarshalOption {
+	return func(o *marshalOptions) { o.relationshipLinkBase = base }
+}
+
+// WithLoadedRelationships restricts Marshal to emitting linkage data and
+// sideloading an included resource only for a relationship named in
+// include, typically the same IncludeTree a request's `include` query
+// parameter parsed into, via ParseQuery or ParseAndValidateQuery. A
+// relationship whose struct field is populated but whose name is absent
+// from include still gets a "relationships" entry — just without "data",
+// so the document stays spec-conformant — and gets generated links if
+// WithRelationshipLinkTemplates is also in effect. This is the correct
+// compound-document behavior per the JSON:API spec: a server includes the
+// full resource only for what the client actually asked for, and nothing
+// without it means every relationship is emitted as today (loaded,
+// regardless of request).
+func WithLoadedRelationships(include IncludeTree) MarshalOption {
+	return func(o *marshalOptions) { o.include = include }
+}
+
+// loadedRelationship reports whether name should be walked and sideloaded,
+// and the include subtree to carry into that walk for its own nested
+// relationships. A nil include (WithLoadedRelationships never called) means
+// no restriction at all — every relationship loads, the same as before the
+// option existed — so it always reports true with a nil subtree.
+func loadedRelationship(include IncludeTree, name string) (IncludeTree, bool) {
+	if include == nil {
+		return nil, true
+	}
+	subtree, ok := include[name]
+	return subtree, ok
+}
+
+// WithLinkTransformer applies tf to every link href Marshal emits -- each
+// resource's own Links, from a LinkableResource hook or otherwise, and
+// every relationship's Links, including those WithRelationshipLinkTemplates
+// generates -- right before returning the Document. Use it to rewrite
+// hostnames or append auth tokens uniformly, e.g. when responses are served
+// through a CDN or reverse proxy that differs from the origin the app
+// builds links against. A caller that adds document-level or pagination
+// links afterward with SetSelfLink/BuildLinks can run the same tf through
+// those Links' own Transform method for full coverage.
+func WithLinkTransformer(tf LinkTransformer) MarshalOption {
+	return func(o *marshalOptions) { o.linkTransformer = tf }
+}
+
+// WithCollectionCount tells Marshal to set the document's top-level
+// meta[key] to the number of resources in the primary data, a common
+// pattern for list endpoints. key defaults to "count" when empty. It has no
+// effect when v is a single struct rather than a slice.
+func WithCollectionCount(key string) MarshalOption {
+	if key == "" {
+		key = "count"
+	}
+	return func(o *marshalOptions) { o.countKey = key }
+}
+
+// WithKeyTransform tells Marshal to rewrite every attribute name with
+// transform before emitting it, so a struct tagged the usual snake_case way
+// can still produce, e.g., camelCase output for a frontend that expects it.
+// It applies to every resource Marshal encodes, including ones reached
+// through a relationship. Pair it with the matching WithUnmarshalKeyTransform
+// option to round-trip a document back into the same struct.
+func WithKeyTransform(transform KeyTransform) MarshalOption {
+	return func(o *marshalOptions) { o.keyTransform = transform }
+}
+
+// WithCreateMode tells Marshal this is a client create request: each
+// primary resource whose id field holds its zero value gets no "id" member
+// at all, rather than one holding that zero value stringified (e.g. "0"
+// for an int id, which omitempty alone doesn't catch since it isn't the
+// empty string). The spec allows a create request's resource object to
+// omit "id" entirely for a server-assigned id; it has no effect on a
+// resource whose id field is already set.
+func WithCreateMode() MarshalOption {
+	return func(o *marshalOptions) { o.createMode = true }
+}
+
+// isPrimaryIDZero reports whether rv's primary id field holds its zero
+// value, the condition WithCreateMode uses to decide whether to omit "id"
+// from the marshaled resource object.
+func isPrimaryIDZero(rv reflect.Value) bool {
+	meta := typeMetaFor(rv.Type())
+	if meta.primaryIndex < 0 {
+		return false
+	}
+	return rv.Field(meta.primaryIndex).IsZero()
+}
+
+// includedSet collects the resources Marshal sideloads into Document.Included,
+// keyed by "type:id" for the cycle/dedup checks addIncluded already needs,
+// while preserving the order each was first encountered in — the order a
+// plain map can't guarantee once ranged over. This is what gives Marshal's
+// output (and so Document.Included's linkage-reference order) a
+// deterministic, repeatable shape across runs, which plain map iteration
+// order cannot: run the same Marshal call twice and Included comes out
+// identical, which callers that cache or diff marshaled output rely on.
+type includedSet struct {
+	byKey map[string]Data
+	order []string
+}
+
+func newIncludedSet() *includedSet {
+	return &includedSet{byKey: map[string]Data{}}
+}
+
+// add stores d under key, appending key to the encounter order the first
+// time it's seen; a repeated key (Marshal re-visiting the same resource)
+// overwrites the stored Data without moving its position.
+func (s *includedSet) add(key string, d Data) {
+	if _, ok := s.byKey[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.byKey[key] = d
+}
+
+// slice returns the collected resources in encounter order.
+func (s *includedSet) slice() []Data {
+	if len(s.order) == 0 {
+		return nil
+	}
+	out := make([]Data, len(s.order))
+	for i, key := range s.order {
+		out[i] = s.byKey[key]
+	}
+	return out
+}
+
+// Marshal builds a *Document from v, which must be a struct (or pointer to
+// one) tagged with `jsonapi` struct tags, or a slice of such structs.
+// Relationship fields are walked recursively and collected into the
+// Document's Included array unless SkipIncluded is passed; a resource
+// already visited (including cycles, such as an article's author also
+// listing that article) is referenced by type+id rather than walked again.
+func Marshal(v interface{}, opts ...MarshalOption) (*Document, error) {
+	o := marshalOptions{sideloadIncluded: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rv := getValue(v)
+
+	included := newIncludedSet()
+	seen := map[string]bool{}
+	container := &DataContainer{}
+	isCollection := rv.Kind() == reflect.Slice
+
+	switch rv.Kind() {
+	case reflect.Slice:
+		container.DataArray = []Data{}
+		for i := 0; i < rv.Len(); i++ {
+			ev := rv.Index(i)
+			if ev.Kind() == reflect.Ptr && ev.IsNil() {
+				if o.skipNilElements {
+					continue
+				}
+				return nil, fmt.Errorf("jsonapi: Marshal: nil element at index %d", i)
+			}
+
+			elem := getValue(ev.Interface())
+			markSeen(elem, seen, o.typeNamer)
+			d, err := structToData(elem, included, seen, o.fields, o.typeNamer, o.timeFormat, o.resourceMeta, o.keyTransform, o.attrPredicate, o.relationshipLinkBase, o.include)
+			if err != nil {
+				return nil, err
+			}
+			if o.createMode && isPrimaryIDZero(elem) {
+				d.ID = ""
+			}
+			container.DataArray = append(container.DataArray, d)
+		}
+	case reflect.Struct:
+		markSeen(rv, seen, o.typeNamer)
+		d, err := structToData(rv, included, seen, o.fields, o.typeNamer, o.timeFormat, o.resourceMeta, o.keyTransform, o.attrPredicate, o.relationshipLinkBase, o.include)
+		if err != nil {
+			return nil, err
+		}
+		if o.createMode && isPrimaryIDZero(rv) {
+			d.ID = ""
+		}
+		container.DataObject = &d
+	default:
+		return nil, errors.New("jsonapi: Marshal requires a struct or a slice of structs")
+	}
+
+	doc := &Document{Data: container}
+	if o.sideloadIncluded {
+		doc.Included = included.slice()
+	}
+	if o.countKey != "" && isCollection {
+		doc.Meta = map[string]interface{}{o.countKey: len(container.DataArray)}
+	}
+
+	if o.linkTransformer != nil {
+		applyLinkTransformer(doc, o.linkTransformer)
+	}
+
+	return doc, nil
+}
+
+// applyLinkTransformer runs tf over every Links Marshal itself produced:
+// doc's own (set by a hook that ran before this point), each primary
+// resource's and each included resource's, and every relationship's.
+func applyLinkTransformer(doc *Document, tf LinkTransformer) {
+	doc.Links.Transform(tf)
+
+	transform := func(data *Data) error {
+		data.Links.Transform(tf)
+		for name, rel := range data.Relationships {
+			rel.Links.Transform(tf)
+			data.Relationships[name] = rel
+		}
+		return nil
+	}
+
+	doc.Each(transform)
+	for i := range doc.Included {
+		transform(&doc.Included[i])
+	}
+}
+
+// markSeen records rv's type+id in seen, if it has a primary field, before
+// Marshal starts walking it as a root resource.
+func markSeen(rv reflect.Value, seen map[string]bool, namer TypeNamer) {
+	if t, id, ok, err := primaryTypeID(rv, namer); ok && err == nil {
+		seen[t+":"+id] = true
+	}
+}
+
+// structToData converts a single struct value to a Data object, recording
+// any relationships it finds in included (keyed by "type:id") so callers can
+// flatten them into Document.Included. seen tracks every type+id visited so
+// far in this Marshal call, so a relationship cycle stops instead of
+// recursing forever.
+func structToData(rv reflect.Value, included *includedSet, seen map[string]bool, fields FilterFields, namer TypeNamer, timeFormat TimeFormat, resourceMeta ResourceMetaFunc, keyTransform KeyTransform, attrPredicate AttributePredicate, linkBase string, include IncludeTree) (Data, error) {
+	if rv.Kind() != reflect.Struct {
+		return Data{}, fmt.Errorf("jsonapi: expected struct, got %s", rv.Kind())
+	}
+
+	if rm, ok := addressablePtr(rv).Interface().(ResourceMarshaler); ok {
+		d, err := rm.MarshalJSONAPI()
+		if err != nil {
+			return Data{}, err
+		}
+		return *d, nil
+	}
+
+	d := Data{}
+	attrs := ObjectAttributes{}
+	rt := rv.Type()
+	meta := typeMetaFor(rt)
+
+	if meta.primaryIndex >= 0 {
+		typ, id, _, err := primaryTypeID(rv, namer)
+		if err != nil {
+			return Data{}, err
+		}
+		d.Type, d.ID = typ, id
+	}
+
+	effectiveLinkBase := linkBase
+	if templates, ok := linkRegistry[d.Type]; ok {
+		if effectiveLinkBase == "" {
+			effectiveLinkBase = templates.Base
+		}
+		if d.Links == nil {
+			d.Links = &Links{Self: BuildResourceSelfLink(templates.Base, d.Type, d.ID)}
+		}
+	}
+
+	for _, f := range meta.attrs {
+		fv := rv.Field(f.Index)
+		if f.Tag.OmitEmpty && fv.IsZero() {
+			continue
+		}
+		name := applyKeyTransform(keyTransform, f.Tag.Name)
+		if attrPredicate != nil && !attrPredicate(name, fv.Interface()) {
+			continue
+		}
+		val, err := formatAttrValue(fv, timeFormat)
+		if err != nil {
+			return Data{}, fmt.Errorf("jsonapi: encoding attribute %q: %w", f.Tag.Name, err)
+		}
+		attrs[name] = val
+	}
+
+	for _, f := range meta.relations {
+		var rel Relationship
+		var err error
+
+		if subtree, loaded := loadedRelationship(include, f.Tag.Name); loaded {
+			rel, err = relationToRelationship(rv.Field(f.Index), included, seen, fields, namer, timeFormat, resourceMeta, keyTransform, attrPredicate, linkBase, subtree)
+		}
+
+		if err == nil && rel.Data == nil && rel.Links == nil && effectiveLinkBase != "" {
+			rel.Links = BuildRelationshipSelfLinks(effectiveLinkBase, d.Type, d.ID, f.Tag.Name)
+		}
+		if err != nil {
+			return Data{}, err
+		}
+		if d.Relationships == nil {
+			d.Relationships = map[string]Relationship{}
+		}
+		d.Relationships[f.Tag.Name] = rel
+	}
+
+	applySparseFields(d.Type, attrs, d.Relationships, fields)
+	applyAttributeAllowlist(d.Type, attrs)
+
+	raw, err := marshalJSON(attrs)
+	if err != nil {
+		return Data{}, err
+	}
+	d.Attributes = raw
+
+	for _, f := range meta.metas {
+		fv := rv.Field(f.Index)
+		if f.Tag.OmitEmpty && fv.IsZero() {
+			continue
+		}
+		if d.Meta == nil {
+			d.Meta = map[string]interface{}{}
+		}
+		d.Meta[f.Tag.Name] = fv.Interface()
+	}
+
+	applyMarshalHooks(rv, &d)
+
+	if resourceMeta != nil {
+		if m := resourceMeta(rv.Interface()); m != nil {
+			d.Meta = mergeMeta(d.Meta, m)
+		}
+	}
+
+	return d, nil
+}
+
+// applySparseFields removes any attribute or relationship not named in
+// fields[typ], if typ has an entry in fields at all; a type absent from
+// fields is left untouched.
+func applySparseFields(typ string, attrs ObjectAttributes, relationships map[string]Relationship, fields FilterFields) {
+	want, ok := fields[typ]
+	if !ok {
+		return
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range want {
+		allowed[name] = true
+	}
+
+	for name := range attrs {
+		if !allowed[name] {
+			delete(attrs, name)
+		}
+	}
+	for name := range relationships {
+		if !allowed[name] {
+			delete(relationships, name)
+		}
+	}
+}
+
+// ApplyFieldsets narrows d's primary and included resources to f's sparse
+// fieldsets after the fact, removing any attribute or relationship not
+// named for that resource's type -- the same rule SparseFields applies
+// during Marshal, but reusable on a Document assembled some other way
+// (hand-built, decoded from a request, merged from several sources), e.g.
+// by middleware that enforces a client's requested fieldsets uniformly
+// regardless of how the response was produced.
+func (d *Document) ApplyFieldsets(f FilterFields) error {
+	apply := func(data *Data) error {
+		if len(data.Attributes) == 0 {
+			applySparseFields(data.Type, ObjectAttributes{}, data.Relationships, f)
+			return nil
+		}
+
+		attrs, err := data.AttributesMap()
+		if err != nil {
+			return err
+		}
+		applySparseFields(data.Type, ObjectAttributes(attrs), data.Relationships, f)
+		return data.SetAttributesMap(attrs)
+	}
+
+	if err := d.Each(apply); err != nil {
+		return err
+	}
+	for i := range d.Included {
+		if err := apply(&d.Included[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attributeAllowlist is the active server-side policy, or nil. See
+// SetAttributeAllowlist.
+var attributeAllowlist FilterFields
+
+// SetAttributeAllowlist installs a package-level safety net restricting
+// which attributes Marshal will ever serialize for a given resource type,
+// regardless of what the struct contains or what SparseFields requests.
+// A type absent from allowlist is left unrestricted. Passing nil disables
+// the check again. Unlike SparseFields, which lets a client narrow an
+// already-safe response further, this is a server-controlled setting —
+// the same package-level pattern as SetTypeNormalizer — meant to catch a
+// struct that accidentally grew a field it should never expose.
+func SetAttributeAllowlist(allowlist FilterFields) {
+	attributeAllowlist = allowlist
+}
+
+// applyAttributeAllowlist removes any attribute not named in
+// attributeAllowlist[typ], if attributeAllowlist is set and has an entry
+// for typ; it's a no-op otherwise.
+func applyAttributeAllowlist(typ string, attrs ObjectAttributes) {
+	if attributeAllowlist == nil {
+		return
+	}
+	want, ok := attributeAllowlist[typ]
+	if !ok {
+		return
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range want {
+		allowed[name] = true
+	}
+
+	for name := range attrs {
+		if !allowed[name] {
+			delete(attrs, name)
+		}
+	}
+}
+
+// Marshaler lets a model contribute its own resource-level meta during
+// Marshal, checked via an interface assertion on every resource Marshal
+// encodes. A resource that doesn't implement it is marshaled as today.
+type Marshaler interface {
+	JSONAPIMeta() map[string]interface{}
+}
+
+// LinkableResource lets a model contribute its own resource-level links
+// during Marshal, checked the same way as Marshaler.
+type LinkableResource interface {
+	JSONAPILinks() *Links
+}
+
+// applyMarshalHooks checks rv against Marshaler and LinkableResource. A
+// Marshaler's meta is merged over any meta already on d, such as from a
+// `jsonapi:"meta,..."` tagged field, winning on a key collision; a
+// LinkableResource's links replace d.Links outright. It works whether the
+// hook is defined on the value or the pointer receiver.
+func applyMarshalHooks(rv reflect.Value, d *Data) {
+	ptr := addressablePtr(rv)
+
+	if m, ok := ptr.Interface().(Marshaler); ok {
+		d.Meta = mergeMeta(d.Meta, m.JSONAPIMeta())
+	}
+	if l, ok := ptr.Interface().(LinkableResource); ok {
+		d.Links = l.JSONAPILinks()
+	}
+}
+
+// mergeMeta returns a map holding every entry of base overlaid with every
+// entry of overlay, with overlay's value winning on a key collision. A nil
+// overlay returns base unchanged; a nil or empty base returns overlay
+// itself rather than allocating a copy.
+func mergeMeta(base, overlay map[string]interface{}) map[string]interface{} {
+	if overlay == nil {
+		return base
+	}
+	if len(base) == 0 {
+		return overlay
+	}
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+// relationToRelationship builds a Relationship for a single related struct
+// (pointer or value) or a slice of related structs, recording the related
+// resources in included.
+func relationToRelationship(fv reflect.Value, included *includedSet, seen map[string]bool, fields FilterFields, namer TypeNamer, timeFormat TimeFormat, resourceMeta ResourceMetaFunc, keyTransform KeyTransform, attrPredicate AttributePredicate, linkBase string, include IncludeTree) (Relationship, error) {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return Relationship{}, nil
+		}
+		fv = fv.Elem()
+	}
+
+	container := &RelationshipDataContainer{}
+
+	switch fv.Kind() {
+	case reflect.Slice:
+		for i := 0; i < fv.Len(); i++ {
+			rd, err := addIncluded(fv.Index(i), included, seen, fields, namer, timeFormat, resourceMeta, keyTransform, attrPredicate, linkBase, include)
+			if err != nil {
+				return Relationship{}, err
+			}
+			container.DataArray = append(container.DataArray, rd)
+		}
+	case reflect.Struct:
+		rd, err := addIncluded(fv, included, seen, fields, namer, timeFormat, resourceMeta, keyTransform, attrPredicate, linkBase, include)
+		if err != nil {
+			return Relationship{}, err
+		}
+		container.DataObject = &rd
+	default:
+		return Relationship{}, fmt.Errorf("jsonapi: unsupported relation field kind %s", fv.Kind())
+	}
+
+	return Relationship{Data: container}, nil
+}
+
+// addIncluded converts a related struct to Data, stores it in included and
+// returns the RelationshipData reference to it. If its type+id is already in
+// seen — because it was visited earlier in this Marshal call, or because
+// following this relationship would cycle back to it — it is referenced
+// without being walked again.
+func addIncluded(rv reflect.Value, included *includedSet, seen map[string]bool, fields FilterFields, namer TypeNamer, timeFormat TimeFormat, resourceMeta ResourceMetaFunc, keyTransform KeyTransform, attrPredicate AttributePredicate, linkBase string, include IncludeTree) (RelationshipData, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+
+	if t, id, ok, err := primaryTypeID(rv, namer); ok && err == nil {
+		key := t + ":" + id
+		if seen[key] {
+			return RelationshipData{Type: t, ID: id}, nil
+		}
+		seen[key] = true
+	}
+
+	d, err := structToData(rv, included, seen, fields, namer, timeFormat, resourceMeta, keyTransform, attrPredicate, linkBase, include)
+	if err != nil {
+		return RelationshipData{}, err
+	}
+
+	included.add(d.Type+":"+d.ID, d)
+
+	return RelationshipData{Type: d.Type, ID: d.ID}, nil
+}
+
+// IndexIncluded builds a "type:id" lookup index over included, the resources
+// carried in a Document's Included array. Unmarshal uses it to resolve
+// relationships; callers that need to look up included resources without
+// running a full Unmarshal (e.g. a handler resolving a relationship by hand)
+// can call it directly.
+func IndexIncluded(included []Data) map[string]Data {
+	index := map[string]Data{}
+	for _, d := range included {
+		index[d.Type+":"+d.ID] = d
+	}
+	return index
+}
+
+// DecodeIncludedByType partitions doc.Included by resource type and decodes
+// each group into a slice of its registered Go type (see RegisterType),
+// returning a map keyed by JSON:API type name whose values are typed slices
+// (e.g. "people" decodes to a []Person, returned as interface{}). Relationship
+// fields within each group are resolved against doc.Included the same way
+// Unmarshal resolves them. It returns an error if any type present in
+// Included has no Go type registered for it.
+func DecodeIncludedByType(doc *Document) (map[string]interface{}, error) {
+	groups := map[string][]Data{}
+	for _, d := range doc.Included {
+		groups[d.Type] = append(groups[d.Type], d)
+	}
+
+	index := IndexIncluded(doc.Included)
+
+	out := make(map[string]interface{}, len(groups))
+	for typ, items := range groups {
+		rt, ok := typeRegistry[typ]
+		if !ok {
+			return nil, fmt.Errorf("jsonapi: no type registered for %q; call RegisterType", typ)
+		}
+
+		slice := reflect.MakeSlice(reflect.SliceOf(rt), len(items), len(items))
+		for i, d := range items {
+			if err := dataToStruct(d, slice.Index(i), index, TimeFormatRFC3339, nil, nil, readOnlyAllow, false, false); err != nil {
+				return nil, err
+			}
+		}
+		out[typ] = slice.Interface()
+	}
+
+	return out, nil
+}
+
+// DecodeIncludedPartial decodes doc.Included one resource at a time,
+// returning each successfully decoded resource (as a pointer to its
+// registered Go type) in decoded, and the raw Data for any resource whose
+// type has no RegisterType entry in unknown, instead of failing the whole
+// call. This supports a client talking to a server that may have started
+// sending resource types the client doesn't know about yet.
+func DecodeIncludedPartial(doc *Document) (decoded []interface{}, unknown []Data, err error) {
+	index := IndexIncluded(doc.Included)
+
+	for _, d := range doc.Included {
+		rt, ok := typeRegistry[d.Type]
+		if !ok {
+			unknown = append(unknown, d)
+			continue
+		}
+
+		v := reflect.New(rt)
+		if err := dataToStruct(d, v.Elem(), index, TimeFormatRFC3339, nil, nil, readOnlyAllow, false, false); err != nil {
+			return nil, nil, err
+		}
+		decoded = append(decoded, v.Interface())
+	}
+
+	return decoded, unknown, nil
+}
+
+// UnmarshalOption configures Unmarshal's behavior.
+type UnmarshalOption func(*unmarshalOptions)
+
+type unmarshalOptions struct {
+	timeFormat      TimeFormat
+	timeLocation    *time.Location
+	keyTransform    KeyTransform
+	expectedType    string
+	readOnly        readOnlyMode
+	coerceContainer bool
+	caseInsensitive bool
+	coerceScalars   bool
+}
+
+// readOnlyMode selects how Unmarshal treats a `jsonapi:"attr,...,readonly"`
+// field the input document sets. The zero value, readOnlyAllow, leaves
+// Unmarshal's behavior exactly as it was before readonly existed, so
+// decoding a server's own response (which legitimately sets e.g. createdAt)
+// is unaffected unless a caller opts in via WithReadOnlyPolicy.
+type readOnlyMode int
+
+const (
+	readOnlyAllow readOnlyMode = iota
+	readOnlyIgnore
+	readOnlyReject
+)
+
+// ReadOnlyPolicy selects WithReadOnlyPolicy's behavior for a
+// `jsonapi:"attr,...,readonly"` field set in the input document.
+type ReadOnlyPolicy int
+
+const (
+	// IgnoreReadOnly silently drops a readonly attribute present in the
+	// input, leaving the destination field at its existing value.
+	IgnoreReadOnly ReadOnlyPolicy = iota
+	// RejectReadOnly fails the whole Unmarshal with a *ReadOnlyFieldError
+	// the first time a readonly attribute is present in the input.
+	RejectReadOnly
+)
+
+// WithReadOnlyPolicy tells Unmarshal how to handle a `jsonapi:"attr,...,readonly"`
+// field the input document attempts to set, for a server enforcing that
+// certain attributes (e.g. createdAt) are server-controlled and can't be
+// written by a client. Without this option, a readonly field is decoded like
+// any other attribute, the same as before the tag existed — this is what
+// keeps decoding a server's own response, which legitimately sets those
+// fields, unaffected.
+func WithReadOnlyPolicy(policy ReadOnlyPolicy) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		if policy == RejectReadOnly {
+			o.readOnly = readOnlyReject
+		} else {
+			o.readOnly = readOnlyIgnore
+		}
+	}
+}
+
+// ReadOnlyFieldError is returned by Unmarshal when WithReadOnlyPolicy(RejectReadOnly)
+// is in effect and the input document sets a readonly attribute, e.g. a
+// client trying to set createdAt on a create request. Errors holds one
+// ErrorObject per rejected field, sourced to its JSON pointer, suitable for
+// passing straight to MarshalErrors.
+type ReadOnlyFieldError struct {
+	Errors []ErrorObject
+}
+
+func (e *ReadOnlyFieldError) Error() string {
+	return fmt.Sprintf("jsonapi: %d read-only field(s) set in request", len(e.Errors))
+}
+
+// newReadOnlyFieldError builds a *ReadOnlyFieldError rejecting the attribute
+// named attr.
+func newReadOnlyFieldError(attr string) *ReadOnlyFieldError {
+	pointer := "/data/attributes/" + attr
+	return &ReadOnlyFieldError{Errors: []ErrorObject{{
+		Status: "403",
+		Title:  "Read-Only Field",
+		Detail: fmt.Sprintf("attribute %q is read-only and cannot be set by the client", attr),
+		Source: &ErrorSource{Pointer: pointer},
+	}}}
+}
+
+// WithUnmarshalTimeFormat tells Unmarshal to parse time.Time (and
+// *time.Time) attributes using format instead of assuming the default RFC
+// 3339 string, matching whatever format the document was marshaled with via
+// WithTimeFormat.
+func WithUnmarshalTimeFormat(format TimeFormat) UnmarshalOption {
+	return func(o *unmarshalOptions) { o.timeFormat = format }
+}
+
+// WithUnmarshalTimeLocation tells Unmarshal to interpret a format-ambiguous
+// time attribute -- currently, one using TimeFormatDateOnly -- in loc
+// instead of UTC, the default. A server whose "local time" API returns bare
+// dates meant for a specific time zone needs this to decode them into the
+// instant the server intended, rather than midnight UTC on that date. It has
+// no effect on TimeFormatRFC3339 (whose string carries its own offset, or
+// defaults to UTC via encoding/json when it has none) or TimeFormatUnixSeconds
+// (already an absolute instant).
+func WithUnmarshalTimeLocation(loc *time.Location) UnmarshalOption {
+	return func(o *unmarshalOptions) { o.timeLocation = loc }
+}
+
+// WithUnmarshalKeyTransform tells Unmarshal to look up each attribute under
+// transform(tag name) instead of the tag name verbatim, the counterpart to
+// Marshal's WithKeyTransform for decoding a document that used it.
+func WithUnmarshalKeyTransform(transform KeyTransform) UnmarshalOption {
+	return func(o *unmarshalOptions) { o.keyTransform = transform }
+}
+
+// WithContainerCoercion tells Unmarshal to tolerate a single primary
+// resource object where an array was expected, and a one-element primary
+// data array where a single object was expected, wrapping or unwrapping as
+// needed rather than failing with "document data is not an array"/"...is
+// not an object". This is strictly opt-in: the spec ties a bare object vs.
+// an array to to-one vs. to-many semantics, so accepting either
+// unconditionally would paper over a real server bug. It exists for
+// interop with non-conformant servers that, e.g., return a bare object from
+// what should be a collection endpoint.
+func WithContainerCoercion() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.coerceContainer = true }
+}
+
+// WithExpectedType tells Unmarshal to treat a primary resource with no
+// "type" member as though the server had sent typ, for a backend that omits
+// type on nested or abbreviated responses where the caller already knows it
+// from the endpoint it called. It has no effect on a resource that already
+// carries a non-empty type. This is the only leniency Unmarshal has for a
+// missing type: without it, an empty type is compared against the
+// destination's `jsonapi:"primary,..."` tag the same as any other value,
+// and is rejected as a mismatch whenever SetTypeNormalizer has installed a
+// TypeNormalizer to check it.
+func WithExpectedType(typ string) UnmarshalOption {
+	return func(o *unmarshalOptions) { o.expectedType = typ }
+}
+
+// WithCaseInsensitiveAttributes tells Unmarshal to match each attribute
+// key against a struct field's tag name (after any WithUnmarshalKeyTransform)
+// case-insensitively rather than verbatim, for a backend that sends keys in
+// inconsistent case. It's opt-in because it can mask a genuine key mismatch
+// that would otherwise surface as a silently-ignored field. If the
+// document's attributes contain two keys that differ only by case, Unmarshal
+// returns an error rather than guessing which one the field should take,
+// since both can't be matched unambiguously.
+func WithCaseInsensitiveAttributes() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.caseInsensitive = true }
+}
+
+// WithLenientScalarCoercion tells Unmarshal to accept a bool, int, uint or
+// float attribute sent as its string-encoded form, e.g. "active":"true" or
+// "count":"5", coercing it to the destination field's type instead of
+// failing with a type-mismatch error. This is strictly opt-in: without it,
+// a string where a scalar is expected is a genuine error, which is the
+// right default for a conformant server — this exists for integrating with
+// a loosely-typed backend that encodes some scalars as strings. A string
+// that doesn't parse as the field's type (e.g. "abc" for an int) is still
+// an error even with this option set.
+func WithLenientScalarCoercion() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.coerceScalars = true }
+}
+
+// Unmarshal populates v, a pointer to a struct or a pointer to a slice of
+// structs, from doc. Relationship fields are populated by matching `type`
+// and `id` against doc.Included.
+func Unmarshal(doc *Document, v interface{}, opts ...UnmarshalOption) error {
+	if doc.Data == nil {
+		return errors.New("jsonapi: document has no data to unmarshal")
+	}
+
+	o := unmarshalOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	index := IndexIncluded(doc.Included)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return errors.New("jsonapi: Unmarshal requires a pointer")
+	}
+	rv = rv.Elem()
+
+	if rv.Kind() == reflect.Slice {
+		dataArray := doc.Data.DataArray
+		if dataArray == nil {
+			if !o.coerceContainer || doc.Data.DataObject == nil {
+				return errors.New("jsonapi: document data is not an array")
+			}
+			dataArray = []Data{*doc.Data.DataObject}
+		}
+		elemType := rv.Type().Elem()
+		structType := elemType
+		for structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+
+		out := reflect.MakeSlice(rv.Type(), 0, len(dataArray))
+		for _, d := range dataArray {
+			if o.expectedType != "" && d.Type == "" {
+				d.Type = o.expectedType
+			}
+			elem := reflect.New(structType)
+			if err := dataToStruct(d, elem.Elem(), index, o.timeFormat, o.timeLocation, o.keyTransform, o.readOnly, o.caseInsensitive, o.coerceScalars); err != nil {
+				return err
+			}
+			if elemType.Kind() == reflect.Ptr {
+				out = reflect.Append(out, elem)
+			} else {
+				out = reflect.Append(out, elem.Elem())
+			}
+		}
+		rv.Set(out)
+		return nil
+	}
+
+	dataObject := doc.Data.DataObject
+	if dataObject == nil {
+		if !o.coerceContainer || len(doc.Data.DataArray) != 1 {
+			return errors.New("jsonapi: document data is not an object")
+		}
+		dataObject = &doc.Data.DataArray[0]
+	}
+	d := *dataObject
+	if o.expectedType != "" && d.Type == "" {
+		d.Type = o.expectedType
+	}
+	return dataToStruct(d, rv, index, o.timeFormat, o.timeLocation, o.keyTransform, o.readOnly, o.caseInsensitive, o.coerceScalars)
+}
+
+// TypeNormalizer normalizes a JSON:API resource type name before Unmarshal
+// compares it against a struct's `jsonapi:"primary,..."` tag, so a backend
+// that's inconsistent about casing or pluralization (e.g. "Articles" vs
+// "article") can still be matched. Both d.Type and the tag's name are passed
+// through it before comparison.
+type TypeNormalizer func(string) string
+
+// LowercaseTypeNormalizer is a TypeNormalizer that folds a resource type to
+// lower case, for backends that vary only in casing.
+func LowercaseTypeNormalizer(s string) string {
+	return strings.ToLower(s)
+}
+
+// KeyTransform rewrites an attribute name at the document boundary, so a Go
+// struct's `jsonapi` tag names (conventionally snake_case) can differ from
+// the attribute keys Marshal emits and Unmarshal accepts over the wire
+// (e.g. a frontend that expects camelCase) without duplicating every tag
+// for each convention. It's applied to the tag name, not to the key as
+// written in an incoming document, so Marshal and Unmarshal should be
+// configured with the same KeyTransform for a round trip to work.
+type KeyTransform func(string) string
+
+// CamelCaseKeys is a KeyTransform that converts a snake_case attribute name
+// to camelCase, e.g. "created_at" to "createdAt".
+func CamelCaseKeys(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue
+		}
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// SnakeCaseKeys is a KeyTransform that converts a camelCase (or
+// PascalCase) attribute name to snake_case, e.g. "createdAt" to
+// "created_at".
+func SnakeCaseKeys(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// applyKeyTransform runs transform on key if transform is non-nil,
+// returning key unchanged otherwise.
+func applyKeyTransform(transform KeyTransform, key string) string {
+	if transform == nil {
+		return key
+	}
+	return transform(key)
+}
+
+// normalizeType is the active TypeNormalizer, or nil. Unmarshal only checks
+// Data.Type against the destination struct's primary tag when this is set;
+// by default it trusts the caller to have picked the right struct, the same
+// as before type checking existed.
+var normalizeType TypeNormalizer
+
+// SetTypeNormalizer opts Unmarshal into checking that each Data.Type matches
+// the destination struct's primary tag, comparing both through normalize
+// first. Passing nil disables the check again. This is a package-level
+// setting, the same as typeRegistry/RegisterType, since a program normally
+// has one backend with one casing convention to account for.
+func SetTypeNormalizer(normalize TypeNormalizer) {
+	normalizeType = normalize
+}
+
+// dataToStruct populates the struct value rv from d, resolving relationship
+// fields against index, a "type:id" lookup into doc.Included. loc selects
+// the location a format-ambiguous time attribute (see WithUnmarshalTimeLocation)
+// is interpreted in.
+func dataToStruct(d Data, rv reflect.Value, index map[string]Data, timeFormat TimeFormat, loc *time.Location, keyTransform KeyTransform, readOnly readOnlyMode, caseInsensitive bool, coerceScalars bool) error {
+	if ru, ok := addressablePtr(rv).Interface().(ResourceUnmarshaler); ok {
+		return ru.UnmarshalJSONAPI(&d)
+	}
+
+	var attrs map[string]json.RawMessage
+	if len(d.Attributes) > 0 {
+		if err := unmarshalJSON(d.Attributes, &attrs); err != nil {
+			return err
+		}
+	}
+
+	rt := rv.Type()
+	meta := typeMetaFor(rt)
+
+	if meta.primaryIndex >= 0 {
+		if normalizeType != nil && normalizeType(d.Type) != normalizeType(meta.primaryTag.Name) {
+			return fmt.Errorf("jsonapi: resource type %q does not match destination type %q", d.Type, meta.primaryTag.Name)
+		}
+		if p, ok := addressablePtr(rv).Interface().(IDParser); ok {
+			if err := p.JSONAPISetID(d.ID); err != nil {
+				return err
+			}
+		} else if fv := rv.Field(meta.primaryIndex); fv.Kind() == reflect.String {
+			fv.SetString(d.ID)
+		}
+	}
+
+	var foldedAttrs map[string][]string
+	if caseInsensitive {
+		foldedAttrs = make(map[string][]string, len(attrs))
+		for k := range attrs {
+			lk := strings.ToLower(k)
+			foldedAttrs[lk] = append(foldedAttrs[lk], k)
+		}
+	}
+
+	for _, f := range meta.attrs {
+		name := applyKeyTransform(keyTransform, f.Tag.Name)
+		raw, ok := attrs[name]
+		if caseInsensitive {
+			if matches := foldedAttrs[strings.ToLower(name)]; len(matches) > 1 {
+				return fmt.Errorf("jsonapi: attribute %q matches multiple keys differing only by case: %v", name, matches)
+			} else if !ok && len(matches) == 1 {
+				raw, ok = attrs[matches[0]], true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if f.Tag.ReadOnly {
+			switch readOnly {
+			case readOnlyReject:
+				return newReadOnlyFieldError(f.Tag.Name)
+			case readOnlyIgnore:
+				continue
+			}
+		}
+		if err := setAttrValue(rv.Field(f.Index), raw, timeFormat, loc, coerceScalars); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range meta.relations {
+		rel, ok := d.Relationships[f.Tag.Name]
+		if !ok || rel.Data == nil {
+			continue
+		}
+		if err := setRelation(rv.Field(f.Index), rel.Data, index, timeFormat, loc, keyTransform); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// typeRegistry maps a JSON:API resource type name to the Go struct type
+// registered for it via RegisterType, so Unmarshal can decode a polymorphic
+// relationship field (one declared as an interface) into the right
+// concrete type.
+var typeRegistry = map[string]reflect.Type{}
+
+// RegisterType associates the JSON:API resource type name with the Go type
+// behind v (a struct, or a pointer to one). Call it once per polymorphic
+// resource type before Unmarshal is used on a struct with an interface-typed
+// relationship field; concrete (non-interface) relationship fields don't
+// need registration.
+func RegisterType(name string, v interface{}) {
+	typeRegistry[name] = getType(v)
+}
+
+// ToOne returns the single resource identifier a to-one relationship's
+// linkage points to, and whether one was present — false for a relationship
+// with no "data", an explicit "data":null, or a to-many array. It's the
+// cleanest way to branch on a polymorphic to-one relationship's type, e.g. a
+// comment's "commentable" that can be an article or a photo, without
+// reaching into r.Data.DataObject by hand.
+func (r Relationship) ToOne() (RelationshipData, bool) {
+	if r.Data == nil || r.Data.DataObject == nil {
+		return RelationshipData{}, false
+	}
+	return *r.Data.DataObject, true
+}
+
+// IsLoaded reports whether r's linkage is known, whether that's an
+// explicit "data":null, an empty to-many array, or a populated one. It's
+// false only when "data" is absent entirely, the shape of a links-only
+// relationship that tells the client to fetch the related resource(s) via
+// r.Links instead — that case must not be mistaken for "an empty
+// relationship," since nothing has actually been determined about it yet.
+func (r Relationship) IsLoaded() bool {
+	return r.Data != nil || r.ExplicitNull
+}
+
+// DecodePolymorphic resolves a to-one relationship's target against index
+// (see IndexIncluded) and decodes it into the Go type registered for its
+// resource type via RegisterType, returning it as interface{} for the
+// caller to type-switch on. It's the manual counterpart to declaring the
+// relationship field as an interface{} on a struct decoded via Unmarshal,
+// for callers that want to inspect or branch on a polymorphic linkage
+// without modeling the whole resource as a tagged struct. It returns nil,
+// nil for a relationship with no linkage.
+func (r Relationship) DecodePolymorphic(index map[string]Data) (interface{}, error) {
+	rd, ok := r.ToOne()
+	if !ok {
+		return nil, nil
+	}
+
+	included, ok := index[rd.Type+":"+rd.ID]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: relationship target %s:%s not found in included", rd.Type, rd.ID)
+	}
+
+	rt, ok := typeRegistry[included.Type]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: no type registered for %q; call RegisterType", included.Type)
+	}
+
+	v := reflect.New(rt)
+	if err := dataToStruct(included, v.Elem(), index, TimeFormatRFC3339, nil, nil, readOnlyAllow, false, false); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// relatedStructType resolves the concrete struct type to allocate for a
+// related resource of JSON:API type typeName into a field declared as
+// fieldType. A concrete fieldType (struct or pointer to one) is used as-is;
+// an interface fieldType is resolved through typeRegistry by typeName,
+// supporting polymorphic relationships.
+func relatedStructType(fieldType reflect.Type, typeName string) (reflect.Type, error) {
+	t := fieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Interface {
+		return t, nil
+	}
+
+	registered, ok := typeRegistry[typeName]
+	if !ok {
+		return nil, fmt.Errorf("jsonapi: no type registered for %q; call RegisterType", typeName)
+	}
+	return registered, nil
+}
+
+// setRelation populates a relationship field (pointer-to-struct,
+// interface, or slice of either) from container, looking up each related
+// resource in index.
+func setRelation(fv reflect.Value, container *RelationshipDataContainer, index map[string]Data, timeFormat TimeFormat, loc *time.Location, keyTransform KeyTransform) error {
+	switch {
+	case container.DataArray != nil:
+		elemType := fv.Type().Elem()
+		out := reflect.MakeSlice(fv.Type(), 0, len(container.DataArray))
+		for _, rd := range container.DataArray {
+			included, ok := index[rd.Type+":"+rd.ID]
+			if !ok {
+				continue
+			}
+			itemType, err := relatedStructType(elemType, included.Type)
+			if err != nil {
+				return err
+			}
+			item := reflect.New(itemType)
+			if err := dataToStruct(included, item.Elem(), index, timeFormat, loc, keyTransform, readOnlyAllow, false, false); err != nil {
+				return err
+			}
+			if elemType.Kind() == reflect.Ptr || elemType.Kind() == reflect.Interface {
+				out = reflect.Append(out, item)
+			} else {
+				out = reflect.Append(out, item.Elem())
+			}
+		}
+		fv.Set(out)
+	case container.DataObject != nil:
+		included, ok := index[container.DataObject.Type+":"+container.DataObject.ID]
+		if !ok {
+			return nil
+		}
+		itemType, err := relatedStructType(fv.Type(), included.Type)
+		if err != nil {
+			return err
+		}
+		item := reflect.New(itemType)
+		if err := dataToStruct(included, item.Elem(), index, timeFormat, loc, keyTransform, readOnlyAllow, false, false); err != nil {
+			return err
+		}
+		if fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+			fv.Set(item)
+		} else {
+			fv.Set(item.Elem())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,2026 @@
+package jsonapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testPerson struct {
+	ID       string         `jsonapi:"primary,people"`
+	Name     string         `jsonapi:"attr,name"`
+	Articles []*testArticle `jsonapi:"relation,articles"`
+}
+
+type testArticle struct {
+	ID     string      `jsonapi:"primary,articles"`
+	Title  string      `jsonapi:"attr,title"`
+	Author *testPerson `jsonapi:"relation,author"`
+}
+
+func TestMarshalSliceOfPointersDereferencesElements(t *testing.T) {
+	articles := []*testArticle{
+		{ID: "1", Title: "First"},
+		{ID: "2", Title: "Second"},
+	}
+
+	doc, err := Marshal(articles)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if len(doc.Data.DataArray) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(doc.Data.DataArray))
+	}
+	if doc.Data.DataArray[0].ID != "1" || doc.Data.DataArray[1].ID != "2" {
+		t.Fatalf("unexpected resources: %+v", doc.Data.DataArray)
+	}
+}
+
+func TestMarshalSliceOfPointersWithNilElementErrorsByDefault(t *testing.T) {
+	articles := []*testArticle{
+		{ID: "1", Title: "First"},
+		nil,
+	}
+
+	if _, err := Marshal(articles); err == nil {
+		t.Fatal("expected an error for a nil element")
+	}
+}
+
+func TestMarshalSliceOfPointersWithNilElementSkipsWithOption(t *testing.T) {
+	articles := []*testArticle{
+		{ID: "1", Title: "First"},
+		nil,
+		{ID: "2", Title: "Second"},
+	}
+
+	doc, err := Marshal(articles, SkipNilElements())
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if len(doc.Data.DataArray) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(doc.Data.DataArray))
+	}
+	if doc.Data.DataArray[0].ID != "1" || doc.Data.DataArray[1].ID != "2" {
+		t.Fatalf("unexpected resources: %+v", doc.Data.DataArray)
+	}
+}
+
+func TestMarshalCyclicRelationshipsDoNotRecurseForever(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+	person.Articles = []*testArticle{article}
+
+	doc, err := Marshal(person)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject == nil {
+		t.Fatal("expected a single data object")
+	}
+	if doc.Data.DataObject.ID != "1" || doc.Data.DataObject.Type != "people" {
+		t.Fatalf("unexpected primary data: %+v", doc.Data.DataObject)
+	}
+
+	if len(doc.Included) != 1 {
+		t.Fatalf("expected exactly one included resource, got %d", len(doc.Included))
+	}
+	if doc.Included[0].Type != "articles" || doc.Included[0].ID != "10" {
+		t.Fatalf("unexpected included resource: %+v", doc.Included[0])
+	}
+
+	rel, ok := doc.Included[0].Relationships["author"]
+	if !ok || rel.Data == nil || rel.Data.DataObject == nil {
+		t.Fatal("expected the included article to reference its author")
+	}
+	if rel.Data.DataObject.Type != "people" || rel.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected author reference: %+v", rel.Data.DataObject)
+	}
+}
+
+type testProfile struct {
+	ID   string `jsonapi:"primary,profiles"`
+	Bio  string `jsonapi:"attr,bio,omitempty"`
+	Nick string `jsonapi:"attr,nickname"`
+}
+
+func TestMarshalOmitEmptyAttribute(t *testing.T) {
+	doc, err := Marshal(&testProfile{ID: "1"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if _, ok := attrs["bio"]; ok {
+		t.Fatalf("expected bio to be omitted, got %#v", attrs["bio"])
+	}
+	if _, ok := attrs["nickname"]; !ok {
+		t.Fatal("expected nickname to be present even when empty")
+	}
+}
+
+type testPatch struct {
+	ID    string  `jsonapi:"primary,patches"`
+	Title *string `jsonapi:"attr,title"`
+	Bio   *string `jsonapi:"attr,bio,omitempty"`
+}
+
+func TestMarshalPointerAttributeThreeStateControl(t *testing.T) {
+	title := "Hello"
+	doc, err := Marshal(&testPatch{ID: "1", Title: &title, Bio: nil})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if attrs["title"] != "Hello" {
+		t.Fatalf("expected title to be present with its value, got %#v", attrs["title"])
+	}
+
+	if got, ok := attrs["bio"]; ok {
+		t.Fatalf("expected bio (nil, omitempty) to be omitted entirely, got %#v", got)
+	}
+}
+
+func TestMarshalNilPointerAttributeWithoutOmitEmptyIsExplicitNull(t *testing.T) {
+	doc, err := Marshal(&testPatch{ID: "1", Title: nil})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &raw); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	titleRaw, ok := raw["title"]
+	if !ok {
+		t.Fatal("expected title to be present as an explicit null, but it was omitted")
+	}
+	if string(titleRaw) != "null" {
+		t.Fatalf("expected title to marshal as null, got %s", titleRaw)
+	}
+}
+
+func TestMarshalAttributePredicateHidesNilValuedFields(t *testing.T) {
+	bio := "Hello"
+	predicate := func(fieldName string, value interface{}) bool {
+		return !reflect.ValueOf(value).IsNil()
+	}
+
+	// title has no omitempty tag, so without the predicate a nil title
+	// would marshal as an explicit null.
+	doc, err := Marshal(&testPatch{ID: "1", Title: nil, Bio: &bio}, WithAttributePredicate(predicate))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := doc.Data.DataObject.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if attrs["bio"] != "Hello" {
+		t.Fatalf("expected bio to survive the predicate, got %#v", attrs["bio"])
+	}
+	if _, ok := attrs["title"]; ok {
+		t.Fatalf("expected title (nil) to be hidden by the predicate, got %#v", attrs["title"])
+	}
+}
+
+func TestMarshalAttributePredicateSeesKeyTransformedName(t *testing.T) {
+	var seen []string
+	predicate := func(fieldName string, value interface{}) bool {
+		seen = append(seen, fieldName)
+		return true
+	}
+
+	_, err := Marshal(&testProfile{ID: "1", Bio: "hi", Nick: "mo"},
+		WithAttributePredicate(predicate), WithKeyTransform(strings.ToUpper))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := []string{"BIO", "NICKNAME"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Fatalf("got %v, want %v", seen, want)
+	}
+}
+
+func TestMarshalSkipIncludedOmitsRelatedResources(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Alice", Articles: []*testArticle{{ID: "10", Title: "Hello"}}}
+
+	doc, err := Marshal(person, SkipIncluded())
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if len(doc.Included) != 0 {
+		t.Fatalf("expected no included resources, got %+v", doc.Included)
+	}
+
+	rel, ok := doc.Data.DataObject.Relationships["articles"]
+	if !ok || rel.Data == nil || len(rel.Data.DataArray) != 1 || rel.Data.DataArray[0].ID != "10" {
+		t.Fatalf("expected the relationship reference to still be built, got %+v", rel)
+	}
+}
+
+func TestMarshalWithRelationshipLinkTemplatesGeneratesLinksForUnloadedRelationship(t *testing.T) {
+	article := &testArticle{ID: "10", Title: "Hello"}
+
+	doc, err := Marshal(article, WithRelationshipLinkTemplates("https://example.com"))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	rel, ok := doc.Data.DataObject.Relationships["author"]
+	if !ok {
+		t.Fatal("expected an author relationship even though it's unloaded")
+	}
+	if rel.Data != nil {
+		t.Fatalf("expected no linkage for an unloaded relationship, got %+v", rel.Data)
+	}
+	if rel.Links == nil {
+		t.Fatal("expected generated links")
+	}
+	if rel.Links.Self != "https://example.com/articles/10/relationships/author" {
+		t.Fatalf("unexpected self link: %q", rel.Links.Self)
+	}
+	if rel.Links.Related != "https://example.com/articles/10/author" {
+		t.Fatalf("unexpected related link: %q", rel.Links.Related)
+	}
+}
+
+func TestMarshalWithRelationshipLinkTemplatesLeavesLoadedRelationshipAlone(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	doc, err := Marshal(article, WithRelationshipLinkTemplates("https://example.com"))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	rel := doc.Data.DataObject.Relationships["author"]
+	if rel.Data == nil || rel.Data.DataObject == nil || rel.Data.DataObject.ID != "1" {
+		t.Fatalf("expected loaded linkage to survive, got %+v", rel.Data)
+	}
+	if rel.Links != nil {
+		t.Fatalf("expected no generated links for an already-loaded relationship, got %+v", rel.Links)
+	}
+}
+
+func TestMarshalWithoutRelationshipLinkTemplatesLeavesUnloadedRelationshipEmpty(t *testing.T) {
+	article := &testArticle{ID: "10", Title: "Hello"}
+
+	doc, err := Marshal(article)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	rel := doc.Data.DataObject.Relationships["author"]
+	if rel.Data != nil || rel.Links != nil {
+		t.Fatalf("expected an empty relationship by default, got %+v", rel)
+	}
+}
+
+func TestMarshalWithLinkTransformerRewritesResourceAndRelationshipLinks(t *testing.T) {
+	prefix := func(rel, href string) string {
+		return "https://cdn.example.com" + strings.TrimPrefix(href, "https://api.example.com")
+	}
+
+	widget := &testLinkableResource{ID: "1", Name: "Gadget"}
+	doc, err := Marshal(widget, WithLinkTransformer(prefix))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if got, want := doc.Data.DataObject.Links.Self, "https://cdn.example.com/widgets/1"; got != want {
+		t.Fatalf("resource self link = %q, want %q", got, want)
+	}
+
+	article := &testArticle{ID: "10", Title: "Hello"}
+	doc, err = Marshal(article, WithRelationshipLinkTemplates("https://api.example.com"), WithLinkTransformer(prefix))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	rel := doc.Data.DataObject.Relationships["author"]
+	if got, want := rel.Links.Self, "https://cdn.example.com/articles/10/relationships/author"; got != want {
+		t.Fatalf("relationship self link = %q, want %q", got, want)
+	}
+	if got, want := rel.Links.Related, "https://cdn.example.com/articles/10/author"; got != want {
+		t.Fatalf("relationship related link = %q, want %q", got, want)
+	}
+}
+
+type testGizmo struct {
+	ID   string `jsonapi:"primary,gizmos"`
+	Name string `jsonapi:"attr,name"`
+}
+
+type testGizmoWithPart struct {
+	ID   string     `jsonapi:"primary,gizmowithparts"`
+	Name string     `jsonapi:"attr,name"`
+	Part *testGizmo `jsonapi:"relation,part"`
+}
+
+func TestRegisterLinksGeneratesSelfLinkWithoutPerCallConfiguration(t *testing.T) {
+	RegisterLinks("gizmos", LinkTemplates{Base: "https://example.com"})
+
+	doc, err := Marshal(&testGizmo{ID: "1", Name: "Widget"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if got, want := doc.Data.DataObject.Links.Self, "https://example.com/gizmos/1"; got != want {
+		t.Fatalf("resource self link = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterLinksGeneratesRelationshipLinksWithoutPerCallConfiguration(t *testing.T) {
+	RegisterLinks("gizmowithparts", LinkTemplates{Base: "https://example.com"})
+
+	doc, err := Marshal(&testGizmoWithPart{ID: "1", Name: "Gadget"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	rel, ok := doc.Data.DataObject.Relationships["part"]
+	if !ok || rel.Links == nil {
+		t.Fatalf("expected generated relationship links, got %+v", rel)
+	}
+	if got, want := rel.Links.Self, "https://example.com/gizmowithparts/1/relationships/part"; got != want {
+		t.Fatalf("relationship self link = %q, want %q", got, want)
+	}
+	if got, want := rel.Links.Related, "https://example.com/gizmowithparts/1/part"; got != want {
+		t.Fatalf("relationship related link = %q, want %q", got, want)
+	}
+}
+
+func TestMarshalWithoutRegisterLinksLeavesResourceLinksNil(t *testing.T) {
+	doc, err := Marshal(&testArticle{ID: "10", Title: "Hello"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Links != nil {
+		t.Fatalf("expected no self link for an unregistered type, got %+v", doc.Data.DataObject.Links)
+	}
+}
+
+func TestMarshalWithLoadedRelationshipsOmitsDataForUnrequestedRelationship(t *testing.T) {
+	article := &testArticleWithComments{
+		ID: "10", Title: "Hello",
+		Author:   &testPerson{ID: "1", Name: "Michele"},
+		Comments: []*testComment{{ID: "1", Body: "Nice!"}},
+	}
+
+	doc, err := Marshal(article,
+		WithLoadedRelationships(IncludeTree{"author": {}}),
+		WithRelationshipLinkTemplates("https://example.com"),
+	)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	author := doc.Data.DataObject.Relationships["author"]
+	if author.Data == nil || author.Data.DataObject == nil || author.Data.DataObject.ID != "1" {
+		t.Fatalf("expected the requested author relationship to carry data, got %+v", author.Data)
+	}
+
+	comments := doc.Data.DataObject.Relationships["comments"]
+	if comments.Data != nil {
+		t.Fatalf("expected the unrequested comments relationship to omit data, got %+v", comments.Data)
+	}
+	if comments.Links == nil || comments.Links.Self == "" {
+		t.Fatalf("expected the unrequested comments relationship to still carry links, got %+v", comments.Links)
+	}
+
+	for _, included := range doc.Included {
+		if included.Type == "comments" {
+			t.Fatalf("expected the unrequested comments resource not to be sideloaded, got %+v", included)
+		}
+	}
+}
+
+func TestMarshalWithoutLoadedRelationshipsLoadsEverything(t *testing.T) {
+	article := &testArticleWithComments{
+		ID: "10", Title: "Hello",
+		Author:   &testPerson{ID: "1", Name: "Michele"},
+		Comments: []*testComment{{ID: "1", Body: "Nice!"}},
+	}
+
+	doc, err := Marshal(article)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Relationships["comments"].Data == nil {
+		t.Fatal("expected comments to be loaded by default")
+	}
+	if len(doc.Included) != 2 {
+		t.Fatalf("expected both relationships to be sideloaded, got %+v", doc.Included)
+	}
+}
+
+func TestMarshalIncludedOrderIsDeterministicAcrossRuns(t *testing.T) {
+	people := []*testPerson{
+		{ID: "1", Name: "Alice", Articles: []*testArticle{{ID: "10", Title: "A"}, {ID: "11", Title: "B"}}},
+		{ID: "2", Name: "Bob", Articles: []*testArticle{{ID: "12", Title: "C"}}},
+		{ID: "3", Name: "Carl", Articles: []*testArticle{{ID: "13", Title: "D"}}},
+	}
+
+	want := []string{"articles:10", "articles:11", "articles:12", "articles:13"}
+
+	for i := 0; i < 20; i++ {
+		doc, err := Marshal(people)
+		if err != nil {
+			t.Fatalf("Marshal returned error: %v", err)
+		}
+
+		got := make([]string, len(doc.Included))
+		for j, d := range doc.Included {
+			got[j] = d.Type + ":" + d.ID
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: Included order = %v, want %v (in encounter order)", i, got, want)
+		}
+	}
+}
+
+func TestMarshalSliceProducesDataArray(t *testing.T) {
+	people := []*testPerson{
+		{ID: "1", Name: "Michele"},
+		{ID: "2", Name: "Ada"},
+	}
+
+	doc, err := Marshal(people)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if len(doc.Data.DataArray) != 2 {
+		t.Fatalf("expected a data array of 2, got %+v", doc.Data.DataArray)
+	}
+	if doc.Data.DataArray[0].ID != "1" || doc.Data.DataArray[1].ID != "2" {
+		t.Fatalf("unexpected data array order: %+v", doc.Data.DataArray)
+	}
+}
+
+type testCommentable interface {
+	isCommentable()
+}
+
+type testVideo struct {
+	ID    string `jsonapi:"primary,videos"`
+	Title string `jsonapi:"attr,title"`
+}
+
+func (*testVideo) isCommentable() {}
+
+type testComment struct {
+	ID      string          `jsonapi:"primary,comments"`
+	Body    string          `jsonapi:"attr,body"`
+	Subject testCommentable `jsonapi:"relation,subject"`
+}
+
+func TestUnmarshalPolymorphicRelationViaTypeRegistry(t *testing.T) {
+	RegisterType("videos", &testVideo{})
+
+	video := &testVideo{ID: "5", Title: "Intro"}
+	comment := &testComment{ID: "1", Body: "nice", Subject: video}
+
+	doc, err := Marshal(comment)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got testComment
+	if err := Unmarshal(doc, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	gotVideo, ok := got.Subject.(*testVideo)
+	if !ok {
+		t.Fatalf("expected Subject to decode as *testVideo, got %T", got.Subject)
+	}
+	if gotVideo.ID != "5" || gotVideo.Title != "Intro" {
+		t.Fatalf("unexpected video: %+v", gotVideo)
+	}
+}
+
+type testPhoto struct {
+	ID  string `jsonapi:"primary,photos"`
+	URL string `jsonapi:"attr,url"`
+}
+
+func (*testPhoto) isCommentable() {}
+
+func TestRelationshipToOneReturnsLinkage(t *testing.T) {
+	rel := Relationship{Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "photos", ID: "7"}}}
+
+	rd, ok := rel.ToOne()
+	if !ok {
+		t.Fatal("expected ToOne to report a linkage")
+	}
+	if rd.Type != "photos" || rd.ID != "7" {
+		t.Fatalf("unexpected linkage: %+v", rd)
+	}
+}
+
+func TestRelationshipToOneFalseForExplicitNullAndToMany(t *testing.T) {
+	if _, ok := (Relationship{ExplicitNull: true}).ToOne(); ok {
+		t.Fatal("expected ToOne to report false for an explicit null relationship")
+	}
+	if _, ok := (Relationship{}).ToOne(); ok {
+		t.Fatal("expected ToOne to report false for an absent relationship")
+	}
+
+	toMany := Relationship{Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "photos", ID: "1"}}}}
+	if _, ok := toMany.ToOne(); ok {
+		t.Fatal("expected ToOne to report false for a to-many relationship")
+	}
+}
+
+func TestRelationshipIsLoaded(t *testing.T) {
+	linksOnly := Relationship{Links: &Links{Related: "https://example.com/articles/1/comments"}}
+	if linksOnly.IsLoaded() {
+		t.Fatal("expected a links-only relationship (no \"data\" member) to report not loaded")
+	}
+
+	cases := []Relationship{
+		{ExplicitNull: true},
+		{Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}},
+		{Data: &RelationshipDataContainer{DataArray: []RelationshipData{}}},
+	}
+	for i, rel := range cases {
+		if !rel.IsLoaded() {
+			t.Fatalf("case %d: expected IsLoaded to report true for %+v", i, rel)
+		}
+	}
+}
+
+func TestRelationshipDecodePolymorphicResolvesRegisteredType(t *testing.T) {
+	RegisterType("videos", &testVideo{})
+	RegisterType("photos", &testPhoto{})
+
+	video := &testVideo{ID: "5", Title: "Intro"}
+	comment := &testComment{ID: "1", Body: "nice", Subject: video}
+
+	doc, err := Marshal(comment)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	index := IndexIncluded(doc.Included)
+	rel := doc.Data.DataObject.Relationships["subject"]
+
+	decoded, err := rel.DecodePolymorphic(index)
+	if err != nil {
+		t.Fatalf("DecodePolymorphic returned error: %v", err)
+	}
+
+	gotVideo, ok := decoded.(*testVideo)
+	if !ok {
+		t.Fatalf("expected *testVideo, got %T", decoded)
+	}
+	if gotVideo.ID != "5" || gotVideo.Title != "Intro" {
+		t.Fatalf("unexpected video: %+v", gotVideo)
+	}
+}
+
+func TestRelationshipDecodePolymorphicReturnsNilForAbsentLinkage(t *testing.T) {
+	decoded, err := (Relationship{}).DecodePolymorphic(map[string]Data{})
+	if err != nil {
+		t.Fatalf("DecodePolymorphic returned error: %v", err)
+	}
+	if decoded != nil {
+		t.Fatalf("expected nil, got %v", decoded)
+	}
+}
+
+func TestRelationshipDecodePolymorphicErrorsOnUnregisteredType(t *testing.T) {
+	index := map[string]Data{"widgets:1": {Type: "widgets", ID: "1"}}
+	rel := Relationship{Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "widgets", ID: "1"}}}
+
+	if _, err := rel.DecodePolymorphic(index); err == nil {
+		t.Fatal("expected an error for an unregistered type")
+	}
+}
+
+func TestIndexIncludedLooksUpByTypeAndID(t *testing.T) {
+	included := []Data{
+		{Type: "people", ID: "1"},
+		{Type: "articles", ID: "10"},
+	}
+
+	index := IndexIncluded(included)
+
+	if _, ok := index["people:1"]; !ok {
+		t.Fatal("expected people:1 in the index")
+	}
+	if _, ok := index["articles:10"]; !ok {
+		t.Fatal("expected articles:10 in the index")
+	}
+	if len(index) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(index))
+	}
+}
+
+func TestDecodeIncludedByTypePartitionsByRegisteredType(t *testing.T) {
+	RegisterType("people", &testPerson{})
+	RegisterType("videos", &testVideo{})
+
+	doc := &Document{
+		Included: []Data{
+			{Type: "people", ID: "1", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+			{Type: "people", ID: "2", Attributes: json.RawMessage(`{"name":"Bob"}`)},
+			{Type: "videos", ID: "5", Attributes: json.RawMessage(`{"title":"Intro"}`)},
+		},
+	}
+
+	groups, err := DecodeIncludedByType(doc)
+	if err != nil {
+		t.Fatalf("DecodeIncludedByType returned error: %v", err)
+	}
+
+	people, ok := groups["people"].([]testPerson)
+	if !ok || len(people) != 2 {
+		t.Fatalf("expected 2 people, got %+v", groups["people"])
+	}
+	if people[0].Name != "Alice" || people[1].Name != "Bob" {
+		t.Fatalf("unexpected people: %+v", people)
+	}
+
+	videos, ok := groups["videos"].([]testVideo)
+	if !ok || len(videos) != 1 || videos[0].Title != "Intro" {
+		t.Fatalf("unexpected videos: %+v", groups["videos"])
+	}
+}
+
+func TestDecodeIncludedByTypeErrorsOnUnregisteredType(t *testing.T) {
+	doc := &Document{
+		Included: []Data{{Type: "unregistered-type-for-test", ID: "1"}},
+	}
+
+	if _, err := DecodeIncludedByType(doc); err == nil {
+		t.Fatal("expected an error for an unregistered type")
+	}
+}
+
+func TestDecodeIncludedPartialSeparatesKnownFromUnknownTypes(t *testing.T) {
+	RegisterType("people", &testPerson{})
+
+	doc := &Document{
+		Included: []Data{
+			{Type: "people", ID: "1", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+			{Type: "widgets", ID: "7", Attributes: json.RawMessage(`{"color":"red"}`)},
+		},
+	}
+
+	decoded, unknown, err := DecodeIncludedPartial(doc)
+	if err != nil {
+		t.Fatalf("DecodeIncludedPartial returned error: %v", err)
+	}
+
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 decoded resource, got %d", len(decoded))
+	}
+	person, ok := decoded[0].(*testPerson)
+	if !ok || person.Name != "Alice" {
+		t.Fatalf("unexpected decoded resource: %+v", decoded[0])
+	}
+
+	if len(unknown) != 1 || unknown[0].Type != "widgets" || unknown[0].ID != "7" {
+		t.Fatalf("unexpected unknown resources: %+v", unknown)
+	}
+}
+
+func TestDecodeIncludedPartialWithNoUnknownTypesReturnsEmptyUnknown(t *testing.T) {
+	RegisterType("people", &testPerson{})
+
+	doc := &Document{
+		Included: []Data{
+			{Type: "people", ID: "1", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+		},
+	}
+
+	decoded, unknown, err := DecodeIncludedPartial(doc)
+	if err != nil {
+		t.Fatalf("DecodeIncludedPartial returned error: %v", err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 decoded resource, got %d", len(decoded))
+	}
+	if len(unknown) != 0 {
+		t.Fatalf("expected no unknown resources, got %+v", unknown)
+	}
+}
+
+func TestUnmarshalIntoSliceOfPointers(t *testing.T) {
+	people := []*testPerson{
+		{ID: "1", Name: "Michele"},
+		{ID: "2", Name: "Ada"},
+	}
+
+	doc, err := Marshal(people)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got []*testPerson
+	if err := Unmarshal(doc, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if len(got) != 2 || got[0].Name != "Michele" || got[1].Name != "Ada" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestUnmarshalTypeNormalizerCatchesMismatchedType(t *testing.T) {
+	SetTypeNormalizer(LowercaseTypeNormalizer)
+	defer SetTypeNormalizer(nil)
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "People", ID: "1"}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person); err != nil {
+		t.Fatalf("expected casing mismatch to be tolerated by LowercaseTypeNormalizer, got %v", err)
+	}
+	if person.ID != "1" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+
+	doc.Data.DataObject.Type = "articles"
+	if err := Unmarshal(doc, &person); err == nil {
+		t.Fatal("expected a genuine type mismatch to be rejected")
+	}
+}
+
+func TestUnmarshalWithoutTypeNormalizerIgnoresType(t *testing.T) {
+	SetTypeNormalizer(nil)
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "anything", ID: "1"}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person); err != nil {
+		t.Fatalf("expected no type check by default, got %v", err)
+	}
+}
+
+func TestUnmarshalWithExpectedTypeFillsInMissingType(t *testing.T) {
+	SetTypeNormalizer(LowercaseTypeNormalizer)
+	defer SetTypeNormalizer(nil)
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{ID: "1", Attributes: mustRawMessage(t, `{"name":"Alice"}`)}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person, WithExpectedType("people")); err != nil {
+		t.Fatalf("expected a missing type to be filled in by WithExpectedType, got %v", err)
+	}
+	if person.ID != "1" || person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+}
+
+func TestUnmarshalWithoutExpectedTypeRejectsMissingTypeUnderNormalizer(t *testing.T) {
+	SetTypeNormalizer(LowercaseTypeNormalizer)
+	defer SetTypeNormalizer(nil)
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{ID: "1"}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person); err == nil {
+		t.Fatal("expected a missing type to be rejected without WithExpectedType")
+	}
+}
+
+func TestUnmarshalWithExpectedTypeLeavesNonEmptyTypeAlone(t *testing.T) {
+	SetTypeNormalizer(LowercaseTypeNormalizer)
+	defer SetTypeNormalizer(nil)
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person, WithExpectedType("people")); err == nil {
+		t.Fatal("expected a genuine type mismatch to still be rejected even with WithExpectedType set")
+	}
+}
+
+func TestUnmarshalWithCaseInsensitiveAttributesMatchesDifferingCase(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "people", ID: "1", Attributes: mustRawMessage(t, `{"Title":"ignored","Name":"Alice"}`),
+	}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person, WithCaseInsensitiveAttributes()); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+}
+
+func TestUnmarshalWithoutCaseInsensitiveAttributesIgnoresMismatchedCase(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "people", ID: "1", Attributes: mustRawMessage(t, `{"Name":"Alice"}`),
+	}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if person.Name != "" {
+		t.Fatalf("expected name to be left unset without the option, got %+v", person)
+	}
+}
+
+func TestUnmarshalWithCaseInsensitiveAttributesRejectsCollidingKeys(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "people", ID: "1", Attributes: mustRawMessage(t, `{"name":"Alice","Name":"Bob"}`),
+	}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person, WithCaseInsensitiveAttributes()); err == nil {
+		t.Fatal("expected an error for keys differing only by case")
+	}
+}
+
+func TestUnmarshalWithContainerCoercionAcceptsSingleObjectForSlice(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "people", ID: "1", Attributes: mustRawMessage(t, `{"name":"Alice"}`),
+	}}}
+
+	var people []testPerson
+	if err := Unmarshal(doc, &people, WithContainerCoercion()); err != nil {
+		t.Fatalf("expected a single object to coerce into a one-element slice, got %v", err)
+	}
+	if len(people) != 1 || people[0].ID != "1" || people[0].Name != "Alice" {
+		t.Fatalf("unexpected people: %+v", people)
+	}
+}
+
+func TestUnmarshalWithoutContainerCoercionRejectsSingleObjectForSlice(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "1"}}}
+
+	var people []testPerson
+	if err := Unmarshal(doc, &people); err == nil {
+		t.Fatal("expected a single object to be rejected for a slice destination without WithContainerCoercion")
+	}
+}
+
+func TestUnmarshalWithContainerCoercionAcceptsOneElementArrayForObject(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "people", ID: "1", Attributes: mustRawMessage(t, `{"name":"Alice"}`)},
+	}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person, WithContainerCoercion()); err != nil {
+		t.Fatalf("expected a one-element array to coerce into a single object, got %v", err)
+	}
+	if person.ID != "1" || person.Name != "Alice" {
+		t.Fatalf("unexpected person: %+v", person)
+	}
+}
+
+func TestUnmarshalWithContainerCoercionRejectsMultiElementArrayForObject(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "people", ID: "1"},
+		{Type: "people", ID: "2"},
+	}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person, WithContainerCoercion()); err == nil {
+		t.Fatal("expected a multi-element array to still be rejected for a single-object destination")
+	}
+}
+
+func TestUnmarshalWithoutContainerCoercionRejectsOneElementArrayForObject(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: []Data{
+		{Type: "people", ID: "1"},
+	}}}
+
+	var person testPerson
+	if err := Unmarshal(doc, &person); err == nil {
+		t.Fatal("expected a one-element array to be rejected for an object destination without WithContainerCoercion")
+	}
+}
+
+func TestUnmarshalWithLenientScalarCoercionParsesStringBoolAndInt(t *testing.T) {
+	type testAccount struct {
+		ID     string `jsonapi:"primary,accounts"`
+		Active bool   `jsonapi:"attr,active"`
+		Count  int    `jsonapi:"attr,count"`
+	}
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "accounts", ID: "1", Attributes: mustRawMessage(t, `{"active":"true","count":"5"}`),
+	}}}
+
+	var account testAccount
+	if err := Unmarshal(doc, &account, WithLenientScalarCoercion()); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if !account.Active || account.Count != 5 {
+		t.Fatalf("unexpected account: %+v", account)
+	}
+}
+
+func TestUnmarshalWithoutLenientScalarCoercionRejectsStringBool(t *testing.T) {
+	type testAccount struct {
+		ID     string `jsonapi:"primary,accounts"`
+		Active bool   `jsonapi:"attr,active"`
+	}
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "accounts", ID: "1", Attributes: mustRawMessage(t, `{"active":"true"}`),
+	}}}
+
+	var account testAccount
+	if err := Unmarshal(doc, &account); err == nil {
+		t.Fatal("expected a string-encoded bool to be rejected without WithLenientScalarCoercion")
+	}
+}
+
+func TestUnmarshalWithLenientScalarCoercionStillRejectsUnparseableString(t *testing.T) {
+	type testAccount struct {
+		ID    string `jsonapi:"primary,accounts"`
+		Count int    `jsonapi:"attr,count"`
+	}
+
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "accounts", ID: "1", Attributes: mustRawMessage(t, `{"count":"not-a-number"}`),
+	}}}
+
+	var account testAccount
+	if err := Unmarshal(doc, &account, WithLenientScalarCoercion()); err == nil {
+		t.Fatal("expected a genuinely unparseable string to still be rejected")
+	}
+}
+
+type testAuditedArticle struct {
+	ID        string `jsonapi:"primary,articles"`
+	Title     string `jsonapi:"attr,title"`
+	CreatedAt string `jsonapi:"attr,createdAt,readonly"`
+}
+
+func TestUnmarshalWithoutReadOnlyPolicySetsReadOnlyFieldAsNormal(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles", ID: "1",
+		Attributes: mustRawMessage(t, `{"title":"Hello","createdAt":"2020-01-01"}`),
+	}}}
+
+	var article testAuditedArticle
+	if err := Unmarshal(doc, &article); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if article.CreatedAt != "2020-01-01" {
+		t.Fatalf("expected createdAt to be set without a read-only policy, got %+v", article)
+	}
+}
+
+func TestUnmarshalIgnoreReadOnlyDropsReadOnlyFieldSilently(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles", ID: "1",
+		Attributes: mustRawMessage(t, `{"title":"Hello","createdAt":"2020-01-01"}`),
+	}}}
+
+	article := testAuditedArticle{CreatedAt: "2019-01-01"}
+	if err := Unmarshal(doc, &article, WithReadOnlyPolicy(IgnoreReadOnly)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if article.Title != "Hello" {
+		t.Fatalf("expected title to still be set, got %+v", article)
+	}
+	if article.CreatedAt != "2019-01-01" {
+		t.Fatalf("expected createdAt to be left untouched, got %+v", article)
+	}
+}
+
+func TestUnmarshalRejectReadOnlyFailsWithPointerToField(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles", ID: "1",
+		Attributes: mustRawMessage(t, `{"title":"Hello","createdAt":"2020-01-01"}`),
+	}}}
+
+	var article testAuditedArticle
+	err := Unmarshal(doc, &article, WithReadOnlyPolicy(RejectReadOnly))
+	if err == nil {
+		t.Fatal("expected an error for a readonly field present in input")
+	}
+	roErr, ok := err.(*ReadOnlyFieldError)
+	if !ok {
+		t.Fatalf("expected *ReadOnlyFieldError, got %T", err)
+	}
+	if len(roErr.Errors) != 1 || roErr.Errors[0].Source.Pointer != "/data/attributes/createdAt" {
+		t.Fatalf("unexpected errors: %+v", roErr.Errors)
+	}
+}
+
+func TestUnmarshalRejectReadOnlyAllowsInputWithoutReadOnlyField(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles", ID: "1",
+		Attributes: mustRawMessage(t, `{"title":"Hello"}`),
+	}}}
+
+	var article testAuditedArticle
+	if err := Unmarshal(doc, &article, WithReadOnlyPolicy(RejectReadOnly)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if article.Title != "Hello" {
+		t.Fatalf("unexpected article: %+v", article)
+	}
+}
+
+func TestUnmarshalRoundTripsRelationshipsFromIncluded(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+	person.Articles = []*testArticle{article}
+
+	doc, err := Marshal(article)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got testArticle
+	if err := Unmarshal(doc, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if got.ID != "10" || got.Title != "Hello" {
+		t.Fatalf("unexpected primary resource: %+v", got)
+	}
+	if got.Author == nil || got.Author.ID != "1" || got.Author.Name != "Michele" {
+		t.Fatalf("expected the author relationship to be populated from included, got %+v", got.Author)
+	}
+}
+
+func TestMarshalSparseFieldsRestrictsAttributesAndRelationships(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	doc, err := Marshal(article, SparseFields(FilterFields{"articles": {"title"}}))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := doc.Data.DataObject.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := attrs["title"]; !ok {
+		t.Fatalf("expected title to survive sparse fieldsets, got %+v", attrs)
+	}
+	if len(doc.Data.DataObject.Relationships) != 0 {
+		t.Fatalf("expected the author relationship to be dropped, got %+v", doc.Data.DataObject.Relationships)
+	}
+}
+
+func TestMarshalSparseFieldsLeavesUnlistedTypesUnrestricted(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	doc, err := Marshal(article, SparseFields(FilterFields{"articles": {"title"}}))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	author := doc.Included[0]
+	var authorAttrs map[string]interface{}
+	if err := author.UnmarshalAttributes(&authorAttrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := authorAttrs["name"]; !ok {
+		t.Fatalf("expected people type to keep all fields since it's absent from FilterFields, got %+v", authorAttrs)
+	}
+}
+
+type testAccount struct {
+	ID        string `jsonapi:"primary,accounts"`
+	Name      string `jsonapi:"attr,name"`
+	APISecret string `jsonapi:"attr,api_secret"`
+}
+
+func TestMarshalAttributeAllowlistDropsAttributeOutsidePolicy(t *testing.T) {
+	SetAttributeAllowlist(FilterFields{"accounts": {"name"}})
+	defer SetAttributeAllowlist(nil)
+
+	doc, err := Marshal(&testAccount{ID: "1", Name: "Michele", APISecret: "shh"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := doc.Data.DataObject.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := attrs["api_secret"]; ok {
+		t.Fatalf("expected api_secret to be dropped by the allowlist, got %+v", attrs)
+	}
+	if _, ok := attrs["name"]; !ok {
+		t.Fatalf("expected name to survive the allowlist, got %+v", attrs)
+	}
+}
+
+func TestMarshalAttributeAllowlistLeavesUnlistedTypesUnrestricted(t *testing.T) {
+	SetAttributeAllowlist(FilterFields{"accounts": {"name"}})
+	defer SetAttributeAllowlist(nil)
+
+	doc, err := Marshal(&testPerson{ID: "1", Name: "Michele"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := doc.Data.DataObject.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := attrs["name"]; !ok {
+		t.Fatalf("expected people type to keep all fields since it's absent from the allowlist, got %+v", attrs)
+	}
+}
+
+func TestMarshalWithoutAttributeAllowlistKeepsEverything(t *testing.T) {
+	SetAttributeAllowlist(nil)
+
+	doc, err := Marshal(&testAccount{ID: "1", Name: "Michele", APISecret: "shh"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := doc.Data.DataObject.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := attrs["api_secret"]; !ok {
+		t.Fatalf("expected api_secret to survive with no allowlist installed, got %+v", attrs)
+	}
+}
+
+type testArticleWithComments struct {
+	ID       string         `jsonapi:"primary,articles"`
+	Title    string         `jsonapi:"attr,title"`
+	Author   *testPerson    `jsonapi:"relation,author"`
+	Comments []*testComment `jsonapi:"relation,comments"`
+}
+
+func TestMarshalSparseFieldsKeepsNamedRelationshipAndDropsUnlisted(t *testing.T) {
+	article := &testArticleWithComments{
+		ID: "10", Title: "Hello",
+		Author:   &testPerson{ID: "1", Name: "Michele"},
+		Comments: []*testComment{{ID: "1", Body: "Nice!"}},
+	}
+
+	doc, err := Marshal(article, SparseFields(FilterFields{"articles": {"author"}}))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	rels := doc.Data.DataObject.Relationships
+	if _, ok := rels["author"]; !ok {
+		t.Fatalf("expected author to survive sparse fieldsets, got %+v", rels)
+	}
+	if _, ok := rels["comments"]; ok {
+		t.Fatalf("expected comments to be dropped, got %+v", rels)
+	}
+}
+
+func TestDocumentApplyFieldsetsNarrowsAttributesAndRelationships(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	doc, err := Marshal(article)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if err := doc.ApplyFieldsets(FilterFields{"articles": {"title"}}); err != nil {
+		t.Fatalf("ApplyFieldsets returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := doc.Data.DataObject.UnmarshalAttributes(&attrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := attrs["title"]; !ok {
+		t.Fatalf("expected title to survive the fieldset, got %+v", attrs)
+	}
+	if len(doc.Data.DataObject.Relationships) != 0 {
+		t.Fatalf("expected the author relationship to be dropped, got %+v", doc.Data.DataObject.Relationships)
+	}
+}
+
+func TestDocumentApplyFieldsetsNarrowsIncludedResourcesByType(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	doc, err := Marshal(article)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if err := doc.ApplyFieldsets(FilterFields{"people": {}}); err != nil {
+		t.Fatalf("ApplyFieldsets returned error: %v", err)
+	}
+
+	author := doc.Included[0]
+	var authorAttrs map[string]interface{}
+	if err := author.UnmarshalAttributes(&authorAttrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := authorAttrs["name"]; ok {
+		t.Fatalf("expected name to be dropped, got %+v", authorAttrs)
+	}
+}
+
+func TestDocumentApplyFieldsetsLeavesUnlistedTypesUnrestricted(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Michele"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	doc, err := Marshal(article)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if err := doc.ApplyFieldsets(FilterFields{"articles": {"title"}}); err != nil {
+		t.Fatalf("ApplyFieldsets returned error: %v", err)
+	}
+
+	author := doc.Included[0]
+	var authorAttrs map[string]interface{}
+	if err := author.UnmarshalAttributes(&authorAttrs); err != nil {
+		t.Fatalf("UnmarshalAttributes returned error: %v", err)
+	}
+	if _, ok := authorAttrs["name"]; !ok {
+		t.Fatalf("expected people type to keep all fields since it's absent from FilterFields, got %+v", authorAttrs)
+	}
+}
+
+func TestDocumentApplyFieldsetsOnCollection(t *testing.T) {
+	people := []testPerson{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}}
+
+	doc, err := Marshal(people)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if err := doc.ApplyFieldsets(FilterFields{"people": {}}); err != nil {
+		t.Fatalf("ApplyFieldsets returned error: %v", err)
+	}
+
+	for i := range doc.Data.DataArray {
+		var attrs map[string]interface{}
+		if err := doc.Data.DataArray[i].UnmarshalAttributes(&attrs); err != nil {
+			t.Fatalf("UnmarshalAttributes returned error: %v", err)
+		}
+		if len(attrs) != 0 {
+			t.Fatalf("expected all attributes to be dropped, got %+v", attrs)
+		}
+	}
+}
+
+func TestMarshalWithCollectionCountSetsMetaOnCollections(t *testing.T) {
+	people := []testPerson{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}}
+
+	doc, err := Marshal(people, WithCollectionCount(""))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Meta["count"] != 2 {
+		t.Fatalf("unexpected meta: %+v", doc.Meta)
+	}
+}
+
+func TestMarshalWithCollectionCountUsesCustomKey(t *testing.T) {
+	people := []testPerson{{ID: "1", Name: "Alice"}}
+
+	doc, err := Marshal(people, WithCollectionCount("total"))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Meta["total"] != 1 {
+		t.Fatalf("unexpected meta: %+v", doc.Meta)
+	}
+}
+
+func TestMarshalWithCollectionCountOmittedForSingleResource(t *testing.T) {
+	person := testPerson{ID: "1", Name: "Alice"}
+
+	doc, err := Marshal(person, WithCollectionCount(""))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Meta != nil {
+		t.Fatalf("expected no meta for a single resource, got %+v", doc.Meta)
+	}
+}
+
+func TestMarshalWithTypeNamerOverridesTagName(t *testing.T) {
+	person := &testPerson{ID: "1", Name: "Alice"}
+	article := &testArticle{ID: "10", Title: "Hello", Author: person}
+
+	upper := TypeNamer(func(rt reflect.Type) string {
+		return strings.ToUpper(rt.Name())
+	})
+
+	doc, err := Marshal(article, WithTypeNamer(upper))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Type != "TESTARTICLE" {
+		t.Fatalf("unexpected primary type: %q", doc.Data.DataObject.Type)
+	}
+	if doc.Included[0].Type != "TESTPERSON" {
+		t.Fatalf("unexpected included type: %q", doc.Included[0].Type)
+	}
+	if rel := doc.Data.DataObject.Relationships["author"]; rel.Data.DataObject.Type != "TESTPERSON" {
+		t.Fatalf("unexpected relationship linkage type: %q", rel.Data.DataObject.Type)
+	}
+}
+
+func TestMarshalWithoutTypeNamerUsesTag(t *testing.T) {
+	person := testPerson{ID: "1", Name: "Alice"}
+
+	doc, err := Marshal(person)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Type != "people" {
+		t.Fatalf("unexpected primary type: %q", doc.Data.DataObject.Type)
+	}
+}
+
+type testLinkableResource struct {
+	ID   string `jsonapi:"primary,widgets"`
+	Name string `jsonapi:"attr,name"`
+}
+
+func (w *testLinkableResource) JSONAPILinks() *Links {
+	return &Links{Self: "https://api.example.com/widgets/" + w.ID}
+}
+
+func (w *testLinkableResource) JSONAPIMeta() map[string]interface{} {
+	return map[string]interface{}{"source": "hook"}
+}
+
+func TestMarshalAppliesLinkableResourceAndMarshalerHooks(t *testing.T) {
+	widget := &testLinkableResource{ID: "1", Name: "Gadget"}
+
+	doc, err := Marshal(widget)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Links == nil || doc.Data.DataObject.Links.Self != "https://api.example.com/widgets/1" {
+		t.Fatalf("expected JSONAPILinks to set a self link, got %+v", doc.Data.DataObject.Links)
+	}
+	if doc.Data.DataObject.Meta["source"] != "hook" {
+		t.Fatalf("expected JSONAPIMeta to set meta, got %+v", doc.Data.DataObject.Meta)
+	}
+}
+
+func TestMarshalResourceWithoutHooksHasNoLinksOrMeta(t *testing.T) {
+	doc, err := Marshal(testPerson{ID: "1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Links != nil || doc.Data.DataObject.Meta != nil {
+		t.Fatalf("expected no links or meta for a resource without hooks, got %+v", doc.Data.DataObject)
+	}
+}
+
+// testCustomResource implements both ResourceMarshaler and
+// ResourceUnmarshaler, storing its amount as cents internally but exposing
+// it on the wire as a single "amount" attribute already formatted as a
+// decimal string -- the kind of irregular shape a `jsonapi` tag can't
+// express, which is what the escape hatch is for.
+type testCustomResource struct {
+	ID    string
+	Cents int64
+}
+
+func (r *testCustomResource) MarshalJSONAPI() (*Data, error) {
+	attrs, err := json.Marshal(map[string]string{"amount": fmt.Sprintf("%d.%02d", r.Cents/100, r.Cents%100)})
+	if err != nil {
+		return nil, err
+	}
+	return &Data{Type: "payments", ID: r.ID, Attributes: attrs}, nil
+}
+
+func (r *testCustomResource) UnmarshalJSONAPI(d *Data) error {
+	var attrs map[string]string
+	if err := json.Unmarshal(d.Attributes, &attrs); err != nil {
+		return err
+	}
+	var whole, frac int64
+	if _, err := fmt.Sscanf(attrs["amount"], "%d.%d", &whole, &frac); err != nil {
+		return err
+	}
+	r.ID = d.ID
+	r.Cents = whole*100 + frac
+	return nil
+}
+
+func TestMarshalUnmarshalRoundTripThroughResourceMarshalerHooks(t *testing.T) {
+	doc, err := Marshal(&testCustomResource{ID: "1", Cents: 1999})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Type != "payments" || doc.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected data: %+v", doc.Data.DataObject)
+	}
+
+	var attrs map[string]string
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if attrs["amount"] != "19.99" {
+		t.Fatalf("amount = %q, want %q", attrs["amount"], "19.99")
+	}
+
+	var out testCustomResource
+	if err := Unmarshal(doc, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if out.ID != "1" || out.Cents != 1999 {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+}
+
+func TestTypeMetaForCachesFieldLayout(t *testing.T) {
+	typeMetaCache.Delete(reflect.TypeOf(testPerson{}))
+
+	first := typeMetaFor(reflect.TypeOf(testPerson{}))
+	second := typeMetaFor(reflect.TypeOf(testPerson{}))
+
+	if first != second {
+		t.Fatal("expected typeMetaFor to return the same cached *structTypeMeta")
+	}
+	if first.primaryIndex < 0 || first.primaryTag.Name != "people" {
+		t.Fatalf("unexpected primary field metadata: %+v", first)
+	}
+	if len(first.attrs) != 1 || first.attrs[0].Tag.Name != "name" {
+		t.Fatalf("unexpected attr metadata: %+v", first.attrs)
+	}
+	if len(first.relations) != 1 || first.relations[0].Tag.Name != "articles" {
+		t.Fatalf("unexpected relation metadata: %+v", first.relations)
+	}
+}
+
+func BenchmarkStructToDataCachedTypeMeta(b *testing.B) {
+	rv := reflect.ValueOf(testPerson{ID: "1", Name: "Michele"})
+	typeMetaFor(rv.Type())
+
+	for i := 0; i < b.N; i++ {
+		if _, err := structToData(rv, newIncludedSet(), map[string]bool{}, nil, nil, TimeFormatRFC3339, nil, nil, nil, "", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStructToDataUncachedTypeMeta(b *testing.B) {
+	rv := reflect.ValueOf(testPerson{ID: "1", Name: "Michele"})
+
+	for i := 0; i < b.N; i++ {
+		typeMetaCache.Delete(rv.Type())
+		if _, err := structToData(rv, newIncludedSet(), map[string]bool{}, nil, nil, TimeFormatRFC3339, nil, nil, nil, "", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// testPolymorphicEvent is a single-table-inheritance model: one Go struct
+// backs several resource types, distinguished by a discriminator field, so
+// the resource type has to be derived per instance instead of statically
+// from the `jsonapi:"primary,..."` tag.
+type testPolymorphicEvent struct {
+	ID   string `jsonapi:"primary,events"`
+	Kind string `jsonapi:"attr,kind"`
+}
+
+func (e *testPolymorphicEvent) JSONAPIType() string {
+	return e.Kind
+}
+
+func TestMarshalTypeOverriderDerivesTypePerInstance(t *testing.T) {
+	events := []*testPolymorphicEvent{
+		{ID: "1", Kind: "clicks"},
+		{ID: "2", Kind: "views"},
+	}
+
+	doc, err := Marshal(events)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataArray[0].Type != "clicks" || doc.Data.DataArray[1].Type != "views" {
+		t.Fatalf("unexpected types: %+v", doc.Data.DataArray)
+	}
+}
+
+type testPolymorphicEventNoOverride struct {
+	ID   string `jsonapi:"primary,events"`
+	Kind string `jsonapi:"attr,kind"`
+}
+
+func TestMarshalFallsBackToTagWithoutTypeOverrider(t *testing.T) {
+	doc, err := Marshal(testPolymorphicEventNoOverride{ID: "1", Kind: "clicks"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Type != "events" {
+		t.Fatalf("expected fallback to tag type %q, got %q", "events", doc.Data.DataObject.Type)
+	}
+}
+
+func TestMarshalTypeOverriderReturningEmptyStringFallsBackToTag(t *testing.T) {
+	doc, err := Marshal(&testPolymorphicEvent{ID: "1", Kind: ""})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Type != "events" {
+		t.Fatalf("expected fallback to tag type %q, got %q", "events", doc.Data.DataObject.Type)
+	}
+}
+
+type testResourceMetaArticle struct {
+	ID     string                  `jsonapi:"primary,articles"`
+	Title  string                  `jsonapi:"attr,title"`
+	Author *testResourceMetaPerson `jsonapi:"relation,author"`
+}
+
+type testResourceMetaPerson struct {
+	ID   string `jsonapi:"primary,people"`
+	Name string `jsonapi:"attr,name"`
+}
+
+func TestMarshalWithResourceMetaAttachesPerResourceMeta(t *testing.T) {
+	article := testResourceMetaArticle{
+		ID:     "1",
+		Title:  "Hello",
+		Author: &testResourceMetaPerson{ID: "9", Name: "Alice"},
+	}
+
+	meta := WithResourceMeta(func(resource interface{}) map[string]interface{} {
+		switch v := resource.(type) {
+		case testResourceMetaArticle:
+			return map[string]interface{}{"editable": true}
+		case testResourceMetaPerson:
+			_ = v
+			return nil
+		}
+		return nil
+	})
+
+	doc, err := Marshal(article, meta)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	if doc.Data.DataObject.Meta["editable"] != true {
+		t.Fatalf("expected article meta to be set, got %+v", doc.Data.DataObject.Meta)
+	}
+	if len(doc.Included) != 1 || doc.Included[0].Meta != nil {
+		t.Fatalf("expected included author to have no meta, got %+v", doc.Included)
+	}
+}
+
+type testResourceMetaHookedPerson struct {
+	ID   string `jsonapi:"primary,people"`
+	Name string `jsonapi:"attr,name"`
+}
+
+func (testResourceMetaHookedPerson) JSONAPIMeta() map[string]interface{} {
+	return map[string]interface{}{"source": "hook"}
+}
+
+func TestMarshalWithResourceMetaReturningNilLeavesHookMetaIntact(t *testing.T) {
+	person := testResourceMetaHookedPerson{ID: "9", Name: "Alice"}
+
+	meta := WithResourceMeta(func(resource interface{}) map[string]interface{} {
+		return nil
+	})
+
+	doc, err := Marshal(person, meta)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Meta["source"] != "hook" {
+		t.Fatalf("expected JSONAPIMeta hook's meta to survive a nil ResourceMetaFunc, got %+v", doc.Data.DataObject.Meta)
+	}
+}
+
+func TestMarshalWithResourceMetaTakesPrecedenceOverHook(t *testing.T) {
+	person := testResourceMetaHookedPerson{ID: "9", Name: "Alice"}
+
+	meta := WithResourceMeta(func(resource interface{}) map[string]interface{} {
+		return map[string]interface{}{"source": "callback"}
+	})
+
+	doc, err := Marshal(person, meta)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Meta["source"] != "callback" {
+		t.Fatalf("expected ResourceMetaFunc to take precedence over JSONAPIMeta, got %+v", doc.Data.DataObject.Meta)
+	}
+}
+
+type testMetaTaggedPerson struct {
+	ID       string `jsonapi:"primary,people"`
+	Name     string `jsonapi:"attr,name"`
+	Revision int    `jsonapi:"meta,revision"`
+	Note     string `jsonapi:"meta,note,omitempty"`
+}
+
+func TestMarshalPopulatesMetaFromTaggedFields(t *testing.T) {
+	person := testMetaTaggedPerson{ID: "9", Name: "Alice", Revision: 3}
+
+	doc, err := Marshal(person)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Meta["revision"] != 3 {
+		t.Fatalf("expected revision meta entry, got %+v", doc.Data.DataObject.Meta)
+	}
+	if _, ok := doc.Data.DataObject.Meta["note"]; ok {
+		t.Fatalf("expected empty omitempty meta field to be omitted, got %+v", doc.Data.DataObject.Meta)
+	}
+}
+
+func TestMarshalIncludesOmitemptyMetaFieldWhenNonZero(t *testing.T) {
+	person := testMetaTaggedPerson{ID: "9", Name: "Alice", Revision: 3, Note: "flagged"}
+
+	doc, err := Marshal(person)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Meta["note"] != "flagged" {
+		t.Fatalf("expected note meta entry, got %+v", doc.Data.DataObject.Meta)
+	}
+}
+
+type testMetaTaggedAndHookedPerson struct {
+	ID       string `jsonapi:"primary,people"`
+	Name     string `jsonapi:"attr,name"`
+	Revision int    `jsonapi:"meta,revision"`
+}
+
+func (testMetaTaggedAndHookedPerson) JSONAPIMeta() map[string]interface{} {
+	return map[string]interface{}{"source": "hook"}
+}
+
+func TestMarshalMergesHookMetaOverTaggedMeta(t *testing.T) {
+	person := testMetaTaggedAndHookedPerson{ID: "9", Name: "Alice", Revision: 3}
+
+	doc, err := Marshal(person)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.Meta["revision"] != 3 {
+		t.Fatalf("expected tagged meta to survive alongside hook meta, got %+v", doc.Data.DataObject.Meta)
+	}
+	if doc.Data.DataObject.Meta["source"] != "hook" {
+		t.Fatalf("expected hook meta to be present, got %+v", doc.Data.DataObject.Meta)
+	}
+}
+
+// testUUIDResource is a composite/binary-key model: its primary field
+// isn't a string, so it implements IDStringer/IDParser to control how its
+// id is rendered as and parsed from the JSON:API "id" member.
+type testUUIDResource struct {
+	ID   [16]byte `jsonapi:"primary,uuid-resources"`
+	Name string   `jsonapi:"attr,name"`
+}
+
+func (r testUUIDResource) JSONAPIID() string {
+	return hex.EncodeToString(r.ID[:])
+}
+
+func (r *testUUIDResource) JSONAPISetID(id string) error {
+	decoded, err := hex.DecodeString(id)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(r.ID) {
+		return fmt.Errorf("expected a %d-byte id, got %d", len(r.ID), len(decoded))
+	}
+	copy(r.ID[:], decoded)
+	return nil
+}
+
+func TestMarshalIDStringerEncodesBinaryID(t *testing.T) {
+	resource := testUUIDResource{
+		ID:   [16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		Name: "widget",
+	}
+
+	doc, err := Marshal(&resource)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := "0102030405060708090a0b0c0d0e0f10"
+	if doc.Data.DataObject.ID != want {
+		t.Fatalf("got id %q, want %q", doc.Data.DataObject.ID, want)
+	}
+}
+
+func TestUnmarshalIDParserDecodesBinaryID(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{
+		Type:       "uuid-resources",
+		ID:         "0102030405060708090a0b0c0d0e0f10",
+		Attributes: mustRawMessage(t, `{"name":"widget"}`),
+	}}}
+
+	var resource testUUIDResource
+	if err := Unmarshal(doc, &resource); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	want := [16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	if resource.ID != want {
+		t.Fatalf("got id %v, want %v", resource.ID, want)
+	}
+	if resource.Name != "widget" {
+		t.Fatalf("unexpected name: %q", resource.Name)
+	}
+}
+
+func TestMarshalWithoutIDStringerFallsBackToFmtRendering(t *testing.T) {
+	type testIntIDResource struct {
+		ID int `jsonapi:"primary,int-resources"`
+	}
+
+	doc, err := Marshal(&testIntIDResource{ID: 42})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "42" {
+		t.Fatalf("got id %q, want %q", doc.Data.DataObject.ID, "42")
+	}
+}
+
+func TestMarshalInt64IDSerializesAsJSONString(t *testing.T) {
+	type testInt64IDResource struct {
+		ID int64 `jsonapi:"primary,int64-resources"`
+	}
+
+	doc, err := Marshal(&testInt64IDResource{ID: 9223372036854775807})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "9223372036854775807" {
+		t.Fatalf("got id %q, want %q", doc.Data.DataObject.ID, "9223372036854775807")
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if !strings.Contains(string(raw), `"id":"9223372036854775807"`) {
+		t.Fatalf("expected id to be a JSON string, got %s", raw)
+	}
+}
+
+func TestMarshalUintIDRenders(t *testing.T) {
+	type testUintIDResource struct {
+		ID uint `jsonapi:"primary,uint-resources"`
+	}
+
+	doc, err := Marshal(&testUintIDResource{ID: 42})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "42" {
+		t.Fatalf("got id %q, want %q", doc.Data.DataObject.ID, "42")
+	}
+}
+
+type testStringerID struct {
+	Hi, Lo uint64
+}
+
+func (id testStringerID) String() string {
+	return fmt.Sprintf("%x%x", id.Hi, id.Lo)
+}
+
+func TestMarshalFmtStringerIDRenders(t *testing.T) {
+	type testStringerIDResource struct {
+		ID testStringerID `jsonapi:"primary,stringer-resources"`
+	}
+
+	doc, err := Marshal(&testStringerIDResource{ID: testStringerID{Hi: 1, Lo: 2}})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "12" {
+		t.Fatalf("got id %q, want %q", doc.Data.DataObject.ID, "12")
+	}
+}
+
+func TestMarshalRejectsUnsupportedPrimaryFieldKind(t *testing.T) {
+	type testUnsupportedIDResource struct {
+		ID []byte `jsonapi:"primary,unsupported-resources"`
+	}
+
+	_, err := Marshal(&testUnsupportedIDResource{ID: []byte("x")})
+	if err == nil {
+		t.Fatal("expected an error for an id field with no supported encoding")
+	}
+	if !strings.Contains(err.Error(), "unsupported primary field kind") {
+		t.Fatalf("expected an unsupported-kind error, got %v", err)
+	}
+}
+
+func TestMarshalWithCreateModeOmitsZeroIntID(t *testing.T) {
+	type testIntIDResource struct {
+		ID   int    `jsonapi:"primary,int-resources"`
+		Name string `jsonapi:"attr,name"`
+	}
+
+	doc, err := Marshal(&testIntIDResource{Name: "widget"}, WithCreateMode())
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "" {
+		t.Fatalf("expected id to be omitted, got %q", doc.Data.DataObject.ID)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(raw), `"id"`) {
+		t.Fatalf("expected no id key in output, got %s", raw)
+	}
+}
+
+func TestMarshalWithCreateModeKeepsNonZeroID(t *testing.T) {
+	doc, err := Marshal(&testPerson{ID: "9", Name: "Alice"}, WithCreateMode())
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "9" {
+		t.Fatalf("expected the existing id to survive, got %q", doc.Data.DataObject.ID)
+	}
+}
+
+func TestMarshalWithoutCreateModeEmitsZeroIntIDVerbatim(t *testing.T) {
+	type testIntIDResource struct {
+		ID int `jsonapi:"primary,int-resources"`
+	}
+
+	doc, err := Marshal(&testIntIDResource{})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if doc.Data.DataObject.ID != "0" {
+		t.Fatalf("expected the zero id to be sent verbatim without create mode, got %q", doc.Data.DataObject.ID)
+	}
+}
+
+type testSnakeCasePerson struct {
+	ID       string `jsonapi:"primary,people"`
+	FullName string `jsonapi:"attr,full_name"`
+}
+
+func TestCamelCaseKeys(t *testing.T) {
+	cases := map[string]string{
+		"full_name":  "fullName",
+		"id":         "id",
+		"created_at": "createdAt",
+		"a_b_c":      "aBC",
+	}
+	for in, want := range cases {
+		if got := CamelCaseKeys(in); got != want {
+			t.Errorf("CamelCaseKeys(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSnakeCaseKeys(t *testing.T) {
+	cases := map[string]string{
+		"fullName":  "full_name",
+		"id":        "id",
+		"createdAt": "created_at",
+		"ABC":       "a_b_c",
+	}
+	for in, want := range cases {
+		if got := SnakeCaseKeys(in); got != want {
+			t.Errorf("SnakeCaseKeys(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMarshalWithKeyTransformEmitsCamelCaseAttributeKeys(t *testing.T) {
+	doc, err := Marshal(&testSnakeCasePerson{ID: "1", FullName: "Alice"}, WithKeyTransform(CamelCaseKeys))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if attrs["fullName"] != "Alice" {
+		t.Fatalf("expected fullName key, got %+v", attrs)
+	}
+	if _, ok := attrs["full_name"]; ok {
+		t.Fatalf("expected no snake_case key, got %+v", attrs)
+	}
+}
+
+func TestMarshalUnmarshalRoundTripsWithMatchingKeyTransforms(t *testing.T) {
+	doc, err := Marshal(&testSnakeCasePerson{ID: "1", FullName: "Alice"}, WithKeyTransform(CamelCaseKeys))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got testSnakeCasePerson
+	if err := Unmarshal(doc, &got, WithUnmarshalKeyTransform(CamelCaseKeys)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.ID != "1" || got.FullName != "Alice" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestUnmarshalWithoutMatchingKeyTransformLeavesAttributeZero(t *testing.T) {
+	doc, err := Marshal(&testSnakeCasePerson{ID: "1", FullName: "Alice"}, WithKeyTransform(CamelCaseKeys))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got testSnakeCasePerson
+	if err := Unmarshal(doc, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.FullName != "" {
+		t.Fatalf("expected FullName to be left zero without a matching transform, got %q", got.FullName)
+	}
+}
+
+type testAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type testPersonWithAddress struct {
+	ID      string      `jsonapi:"primary,people"`
+	Name    string      `jsonapi:"attr,name"`
+	Address testAddress `jsonapi:"attr,address"`
+}
+
+func TestMarshalNestedStructAttributeProducesNestedJSONObject(t *testing.T) {
+	doc, err := Marshal(&testPersonWithAddress{
+		ID:      "1",
+		Name:    "Alice",
+		Address: testAddress{City: "Springfield", Zip: "00000"},
+	})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs struct {
+		Name    string      `json:"name"`
+		Address testAddress `json:"address"`
+	}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if attrs.Address != (testAddress{City: "Springfield", Zip: "00000"}) {
+		t.Fatalf("expected address to be serialized as a nested object, got %+v", attrs.Address)
+	}
+}
+
+func TestMarshalUnmarshalRoundTripsNestedStructAttribute(t *testing.T) {
+	doc, err := Marshal(&testPersonWithAddress{
+		ID:      "1",
+		Name:    "Alice",
+		Address: testAddress{City: "Springfield", Zip: "00000"},
+	})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got testPersonWithAddress
+	if err := Unmarshal(doc, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Address != (testAddress{City: "Springfield", Zip: "00000"}) {
+		t.Fatalf("unexpected address after round trip: %+v", got.Address)
+	}
+}
@@ -0,0 +1,112 @@
+package jsonapi
+
+import (
+	"errors"
+	"reflect"
+)
+
+// MergeOption configures Document.Merge's behavior.
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	allowDataOverwrite bool
+}
+
+// AllowDataOverwrite tells Merge to replace d's primary data with other's
+// rather than erroring when both documents carry conflicting primary data.
+func AllowDataOverwrite() MergeOption {
+	return func(o *mergeOptions) { o.allowDataOverwrite = true }
+}
+
+// errConflictingData is returned by Merge when both documents carry primary
+// data and it differs, unless AllowDataOverwrite was passed.
+var errConflictingData = errors.New("jsonapi: documents have conflicting primary data")
+
+// Merge merges other into d, for servers that assemble a response by
+// combining documents built from separate data sources. Included resources
+// are appended and deduped (see DedupeIncluded); Meta is combined with
+// other's value winning on a shared key; Links are combined the same way.
+// Primary Data present on both sides that differs is an error unless
+// AllowDataOverwrite is passed, in which case other's Data replaces d's.
+func (d *Document) Merge(other *Document, opts ...MergeOption) error {
+	if other == nil {
+		return nil
+	}
+
+	o := mergeOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch {
+	case d.Data == nil:
+		d.Data = other.Data
+	case other.Data != nil && !reflect.DeepEqual(d.Data, other.Data):
+		if !o.allowDataOverwrite {
+			return errConflictingData
+		}
+		d.Data = other.Data
+	}
+
+	if len(other.Included) > 0 {
+		d.Included = append(d.Included, other.Included...)
+		d.DedupeIncluded()
+	}
+
+	if len(other.Meta) > 0 {
+		if d.Meta == nil {
+			d.Meta = map[string]interface{}{}
+		}
+		for key, value := range other.Meta {
+			d.Meta[key] = value
+		}
+	}
+
+	d.Links = mergeLinks(d.Links, other.Links)
+
+	return nil
+}
+
+// mergeLinks combines a and b into a single *Links, with b's non-empty
+// well-known fields and Objects entries winning over a's on a shared key. A
+// nil a returns a clone of b; a nil b returns a itself, uncloned.
+func mergeLinks(a, b *Links) *Links {
+	if b == nil {
+		return a
+	}
+	if a == nil {
+		return b.Clone()
+	}
+
+	merged := *a
+	if b.Self != "" {
+		merged.Self = b.Self
+	}
+	if b.Related != "" {
+		merged.Related = b.Related
+	}
+	if b.First != "" {
+		merged.First = b.First
+	}
+	if b.Previous != "" {
+		merged.Previous = b.Previous
+	}
+	if b.Next != "" {
+		merged.Next = b.Next
+	}
+	if b.Last != "" {
+		merged.Last = b.Last
+	}
+
+	if len(b.Objects) > 0 {
+		merged.Objects = make(map[string]Link, len(a.Objects)+len(b.Objects))
+		for k, v := range a.Objects {
+			merged.Objects[k] = v
+		}
+		for k, v := range b.Objects {
+			merged.Objects[k] = v
+		}
+	}
+
+	return &merged
+}
@@ -0,0 +1,75 @@
+package jsonapi
+
+import "testing"
+
+func TestDocumentMergeCombinesIncludedAndDedupes(t *testing.T) {
+	d := &Document{
+		Data:     &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Included: []Data{{Type: "people", ID: "9", Attributes: rawAttrs(`{"name":"Alice"}`)}},
+	}
+	other := &Document{
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: rawAttrs(`{"name":"Alice"}`)},
+			{Type: "tags", ID: "3"},
+		},
+	}
+
+	if err := d.Merge(other); err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	if len(d.Included) != 2 {
+		t.Fatalf("expected 2 deduped included resources, got %+v", d.Included)
+	}
+}
+
+func TestDocumentMergeCombinesMetaOtherWins(t *testing.T) {
+	d := &Document{Meta: map[string]interface{}{"count": 1, "stable": "a"}}
+	other := &Document{Meta: map[string]interface{}{"count": 2}}
+
+	if err := d.Merge(other); err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	if d.Meta["count"] != 2 || d.Meta["stable"] != "a" {
+		t.Fatalf("unexpected meta: %+v", d.Meta)
+	}
+}
+
+func TestDocumentMergeCombinesLinks(t *testing.T) {
+	d := &Document{Links: &Links{Self: "/articles/1", Next: "/articles/1?page=2"}}
+	other := &Document{Links: &Links{Self: "/articles/1/canonical"}}
+
+	if err := d.Merge(other); err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	if d.Links.Self != "/articles/1/canonical" || d.Links.Next != "/articles/1?page=2" {
+		t.Fatalf("unexpected links: %+v", d.Links)
+	}
+}
+
+func TestDocumentMergeRejectsConflictingData(t *testing.T) {
+	d := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+	other := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "2"}}}
+
+	if err := d.Merge(other); err == nil {
+		t.Fatal("expected an error for conflicting primary data")
+	}
+}
+
+func TestDocumentMergeAllowDataOverwrite(t *testing.T) {
+	d := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+	other := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "2"}}}
+
+	if err := d.Merge(other, AllowDataOverwrite()); err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+	if d.Data.DataObject.ID != "2" {
+		t.Fatalf("expected other's data to win, got %+v", d.Data.DataObject)
+	}
+}
+
+func rawAttrs(s string) []byte {
+	return []byte(s)
+}
@@ -0,0 +1,233 @@
+package jsonapi
+
+// AtomicMediaType is the media type used by requests and responses that
+// carry the Atomic Operations extension.
+const AtomicMediaType = `application/vnd.api+json;ext="https://jsonapi.org/ext/atomic"`
+
+// Supported Operation.Op values.
+const (
+	OpAdd    = "add"
+	OpUpdate = "update"
+	OpRemove = "remove"
+)
+
+// OperationRef identifies the target of an Operation: either a resource (and
+// optionally one of its relationships), addressed by type+id or by a
+// client-generated local id (lid).
+type OperationRef struct {
+	Type         string `json:"type,omitempty"`
+	ID           string `json:"id,omitempty"`
+	LID          string `json:"lid,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// Operation is one entry of an atomic:operations envelope.
+type Operation struct {
+	Op   string         `json:"op"`
+	Ref  *OperationRef  `json:"ref,omitempty"`
+	Href string         `json:"href,omitempty"`
+	Data *DataContainer `json:"data,omitempty"`
+}
+
+// OperationsDocument is the request envelope for the Atomic Operations
+// extension: an ordered list of operations carried under "atomic:operations".
+type OperationsDocument struct {
+	Operations []Operation
+}
+
+type operationsEnvelope struct {
+	Operations []Operation `json:"atomic:operations"`
+}
+
+// MarshalJSON returns the JSON encoding of the atomic:operations envelope.
+func (d *OperationsDocument) MarshalJSON() ([]byte, error) {
+	return marshalJSON(operationsEnvelope{Operations: d.Operations})
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded atomic:operations envelope.
+func (d *OperationsDocument) UnmarshalJSON(payload []byte) error {
+	var env operationsEnvelope
+	if err := unmarshalJSON(payload, &env); err != nil {
+		return err
+	}
+	d.Operations = env.Operations
+	return nil
+}
+
+// OperationResult is the outcome of applying the Operation at the same index
+// in the request's atomic:operations list.
+type OperationResult struct {
+	Data *DataContainer         `json:"data,omitempty"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// ResultsDocument is the response envelope for the Atomic Operations
+// extension: an ordered list of results carried under "atomic:results".
+type ResultsDocument struct {
+	Results []OperationResult
+}
+
+type resultsEnvelope struct {
+	Results []OperationResult `json:"atomic:results"`
+}
+
+// MarshalJSON returns the JSON encoding of the atomic:results envelope.
+func (d *ResultsDocument) MarshalJSON() ([]byte, error) {
+	return marshalJSON(resultsEnvelope{Results: d.Results})
+}
+
+// UnmarshalJSON unmarshals the JSON-encoded atomic:results envelope.
+func (d *ResultsDocument) UnmarshalJSON(payload []byte) error {
+	var env resultsEnvelope
+	if err := unmarshalJSON(payload, &env); err != nil {
+		return err
+	}
+	d.Results = env.Results
+	return nil
+}
+
+// Resource returns r's created or updated resource, if its result carries
+// one. A "remove" operation's result, or any result carrying only meta,
+// reports false.
+func (r OperationResult) Resource() (*Data, bool) {
+	if r.Data == nil || r.Data.DataObject == nil {
+		return nil, false
+	}
+	return r.Data.DataObject, true
+}
+
+// LIDMapping pairs doc's operations with results by index and returns the
+// "type:lid" -> server id mapping for every operation that requested a
+// client-generated lid and has a corresponding resource in results —
+// ready to pass to Document.RemapIDs. This is the client-side counterpart
+// to the lid resolution Process performs mid-batch on the server: once
+// atomic:results comes back, a client that built its next request with the
+// same lids it sent to the server can resolve them to real ids in one call.
+// Extra results beyond len(doc.Operations), or vice versa, are ignored.
+func (doc *OperationsDocument) LIDMapping(results *ResultsDocument) map[string]string {
+	mapping := map[string]string{}
+
+	for i, op := range doc.Operations {
+		if i >= len(results.Results) {
+			break
+		}
+		lid := requestLID(op)
+		if lid == "" {
+			continue
+		}
+		data, ok := results.Results[i].Resource()
+		if !ok {
+			continue
+		}
+		mapping[data.Type+":"+lid] = data.ID
+	}
+
+	return mapping
+}
+
+// OperationHandler applies a single atomic operation on behalf of Process,
+// returning the affected resource, if any.
+type OperationHandler interface {
+	Apply(op Operation) (*Data, error)
+}
+
+// Process applies each operation in doc, in order, via h, stopping at the
+// first error. An operation may name the resource it concerns with a
+// client-generated local id (lid) instead of a server id — in Ref.LID, or in
+// Data's own resource/relationship identifiers, the standard way an "add"
+// for one resource is referenced from a relationship in a later operation
+// before the server has assigned it an id. Process resolves every such
+// forward reference to the real id once the lid's "add" has been applied.
+func Process(doc *OperationsDocument, h OperationHandler) (*ResultsDocument, error) {
+	lids := map[string]string{}
+	results := &ResultsDocument{}
+
+	for _, op := range doc.Operations {
+		resolveLIDs(&op, lids)
+		lid := requestLID(op)
+
+		data, err := h.Apply(op)
+		if err != nil {
+			return nil, err
+		}
+
+		result := OperationResult{}
+		if data != nil {
+			result.Data = &DataContainer{DataObject: data}
+			if lid != "" {
+				lids[lid] = data.ID
+			}
+		}
+		results.Results = append(results.Results, result)
+	}
+
+	return results, nil
+}
+
+// requestLID returns the client-generated local id op's own data identifies
+// itself with, if any — Ref.LID for a ref-addressed operation, or
+// Data.DataObject.LID for an "add" operation that introduces a new resource.
+func requestLID(op Operation) string {
+	if op.Ref != nil && op.Ref.LID != "" {
+		return op.Ref.LID
+	}
+	if op.Data != nil && op.Data.DataObject != nil && op.Data.DataObject.LID != "" {
+		return op.Data.DataObject.LID
+	}
+	return ""
+}
+
+// resolveLIDs rewrites every lid-only identifier in op — Ref, Data's own
+// identifier, and Data's relationship identifiers — to the server id lids
+// has recorded for that lid, so operations later in the batch can refer to a
+// resource an earlier "add" operation hasn't been assigned a server id for
+// yet.
+func resolveLIDs(op *Operation, lids map[string]string) {
+	if op.Ref != nil && op.Ref.LID != "" && op.Ref.ID == "" {
+		if id, ok := lids[op.Ref.LID]; ok {
+			op.Ref.ID = id
+		}
+	}
+
+	if op.Data == nil {
+		return
+	}
+	if op.Data.DataObject != nil {
+		resolveDataLID(op.Data.DataObject, lids)
+	}
+	for i := range op.Data.DataArray {
+		resolveDataLID(&op.Data.DataArray[i], lids)
+	}
+}
+
+// resolveDataLID resolves d's own lid-only identifier and those of its
+// relationships against lids.
+func resolveDataLID(d *Data, lids map[string]string) {
+	if d.ID == "" && d.LID != "" {
+		if id, ok := lids[d.LID]; ok {
+			d.ID = id
+		}
+	}
+
+	for key, rel := range d.Relationships {
+		if rel.Data == nil {
+			continue
+		}
+		if rel.Data.DataObject != nil {
+			resolveRelationshipLID(rel.Data.DataObject, lids)
+		}
+		for i := range rel.Data.DataArray {
+			resolveRelationshipLID(&rel.Data.DataArray[i], lids)
+		}
+		d.Relationships[key] = rel
+	}
+}
+
+// resolveRelationshipLID resolves rd's lid-only identifier against lids.
+func resolveRelationshipLID(rd *RelationshipData, lids map[string]string) {
+	if rd.ID == "" && rd.LID != "" {
+		if id, ok := lids[rd.LID]; ok {
+			rd.ID = id
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type recordingHandler struct {
+	nextID int
+	seen   []Operation
+}
+
+func (h *recordingHandler) Apply(op Operation) (*Data, error) {
+	h.seen = append(h.seen, op)
+	h.nextID++
+
+	switch op.Op {
+	case OpAdd:
+		d := op.Data.DataObject
+		d.ID = "server-" + d.Type
+		d.LID = ""
+		return d, nil
+	default:
+		return op.Data.DataObject, nil
+	}
+}
+
+func TestProcessResolvesLIDAcrossOperations(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{
+				Op: OpAdd,
+				Data: &DataContainer{DataObject: &Data{
+					Type: "people",
+					LID:  "1",
+				}},
+			},
+			{
+				Op: OpAdd,
+				Data: &DataContainer{DataObject: &Data{
+					Type: "articles",
+					Relationships: map[string]Relationship{
+						"author": {
+							Data: &RelationshipDataContainer{
+								DataObject: &RelationshipData{Type: "people", LID: "1"},
+							},
+						},
+					},
+				}},
+			},
+		},
+	}
+
+	h := &recordingHandler{}
+	results, err := Process(doc, h)
+	if err != nil {
+		t.Fatalf("Process returned error: %v", err)
+	}
+	if len(results.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results.Results))
+	}
+
+	authorRef := h.seen[1].Data.DataObject.Relationships["author"].Data.DataObject
+	if authorRef.ID != "server-people" {
+		t.Fatalf("expected the second op's author relationship to resolve to the first op's server id, got %+v", authorRef)
+	}
+	if authorRef.LID != "1" {
+		t.Fatalf("expected the original lid to be preserved alongside the resolved id, got %+v", authorRef)
+	}
+}
+
+func TestProcessResolvesRefLID(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{
+				Op:  OpAdd,
+				Ref: &OperationRef{Type: "people", LID: "1"},
+				Data: &DataContainer{DataObject: &Data{
+					Type: "people",
+				}},
+			},
+			{
+				Op:  OpUpdate,
+				Ref: &OperationRef{Type: "people", LID: "1"},
+				Data: &DataContainer{DataObject: &Data{
+					Type: "people",
+				}},
+			},
+		},
+	}
+
+	h := &recordingHandler{}
+	if _, err := Process(doc, h); err != nil {
+		t.Fatalf("Process returned error: %v", err)
+	}
+
+	if h.seen[1].Ref.ID != "server-people" {
+		t.Fatalf("expected the second op's ref to resolve to the first op's server id, got %+v", h.seen[1].Ref)
+	}
+}
+
+type failingHandler struct {
+	failOn int
+	err    error
+	calls  int
+}
+
+func (h *failingHandler) Apply(op Operation) (*Data, error) {
+	h.calls++
+	if h.calls-1 == h.failOn {
+		return nil, h.err
+	}
+	return op.Data.DataObject, nil
+}
+
+func TestProcessStopsOnFirstError(t *testing.T) {
+	wantErr := errors.New("boom")
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "people"}}},
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "articles"}}},
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "comments"}}},
+		},
+	}
+
+	h := &failingHandler{failOn: 1, err: wantErr}
+	results, err := Process(doc, h)
+
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected Process to return the handler's error, got %v", err)
+	}
+	if results != nil {
+		t.Fatalf("expected no results on error, got %+v", results)
+	}
+	if h.calls != 2 {
+		t.Fatalf("expected Process to stop after the failing operation, handler was called %d times", h.calls)
+	}
+}
+
+func TestOperationsEnvelopeMarshalUnmarshal(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "people", LID: "1"}}},
+		},
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["atomic:operations"]; !ok {
+		t.Fatalf(`expected the envelope to use the "atomic:operations" key, got %s`, raw)
+	}
+
+	var got OperationsDocument
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal into OperationsDocument returned error: %v", err)
+	}
+	if len(got.Operations) != 1 || got.Operations[0].Data.DataObject.LID != "1" {
+		t.Fatalf("unexpected round-tripped operations: %+v", got.Operations)
+	}
+}
+
+func TestOperationRefRemoveByTypeAndID(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{Op: OpRemove, Ref: &OperationRef{Type: "people", ID: "9"}},
+		},
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got OperationsDocument
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if len(got.Operations) != 1 {
+		t.Fatalf("expected 1 operation, got %d", len(got.Operations))
+	}
+	op := got.Operations[0]
+	if op.Op != OpRemove || op.Ref == nil || op.Ref.Type != "people" || op.Ref.ID != "9" {
+		t.Fatalf("unexpected round-tripped operation: %+v", op)
+	}
+	if op.Data != nil {
+		t.Fatalf("expected no data on a remove operation, got %+v", op.Data)
+	}
+}
+
+func TestResultsEnvelopeMarshalUnmarshal(t *testing.T) {
+	doc := &ResultsDocument{
+		Results: []OperationResult{
+			{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "server-people"}}},
+		},
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if _, ok := out["atomic:results"]; !ok {
+		t.Fatalf(`expected the envelope to use the "atomic:results" key, got %s`, raw)
+	}
+
+	var got ResultsDocument
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal into ResultsDocument returned error: %v", err)
+	}
+	if len(got.Results) != 1 || got.Results[0].Data.DataObject.ID != "server-people" {
+		t.Fatalf("unexpected round-tripped results: %+v", got.Results)
+	}
+}
+
+func TestOperationResultResourceReportsFalseWithoutData(t *testing.T) {
+	r := OperationResult{Meta: map[string]interface{}{"ok": true}}
+
+	if _, ok := r.Resource(); ok {
+		t.Fatal("expected Resource to report false for a meta-only result")
+	}
+}
+
+func TestOperationResultResourceReturnsCreatedResource(t *testing.T) {
+	r := OperationResult{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "server-people"}}}
+
+	data, ok := r.Resource()
+	if !ok || data.Type != "people" || data.ID != "server-people" {
+		t.Fatalf("unexpected resource: %+v, ok=%v", data, ok)
+	}
+}
+
+func TestOperationsDocumentLIDMappingCorrelatesByIndex(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "people", LID: "temp-1"}}},
+		},
+	}
+	results := &ResultsDocument{
+		Results: []OperationResult{
+			{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "server-1"}}},
+		},
+	}
+
+	mapping := doc.LIDMapping(results)
+	if mapping["people:temp-1"] != "server-1" {
+		t.Fatalf("unexpected mapping: %+v", mapping)
+	}
+}
+
+func TestOperationsDocumentLIDMappingRemapsFollowUpDocument(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "people", LID: "temp-1"}}},
+		},
+	}
+	results := &ResultsDocument{
+		Results: []OperationResult{
+			{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "server-1"}}},
+		},
+	}
+	mapping := doc.LIDMapping(results)
+
+	followUp := &Document{Data: &DataContainer{DataObject: &Data{
+		Type: "articles",
+		ID:   "1",
+		Relationships: map[string]Relationship{
+			"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", LID: "temp-1"}}},
+		},
+	}}}
+	followUp.RemapIDs(mapping)
+
+	got := followUp.Data.DataObject.Relationships["author"].Data.DataObject
+	if got.ID != "server-1" {
+		t.Fatalf("expected the relationship lid to resolve to server-1, got %+v", got)
+	}
+}
+
+func TestOperationsDocumentLIDMappingSkipsOperationsWithoutLID(t *testing.T) {
+	doc := &OperationsDocument{
+		Operations: []Operation{
+			{Op: OpAdd, Data: &DataContainer{DataObject: &Data{Type: "people"}}},
+		},
+	}
+	results := &ResultsDocument{
+		Results: []OperationResult{
+			{Data: &DataContainer{DataObject: &Data{Type: "people", ID: "server-1"}}},
+		},
+	}
+
+	if mapping := doc.LIDMapping(results); len(mapping) != 0 {
+		t.Fatalf("expected no mapping entries, got %+v", mapping)
+	}
+}
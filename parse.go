@@ -0,0 +1,24 @@
+package jsonapi
+
+// ParseDocument unmarshals data into a Document, tolerating a leading UTF-8
+// byte order mark the way Bind does, and runs Validate on the result so
+// callers get a structurally sound Document or a clear error rather than
+// one they still have to check by hand. The package's exported Unmarshal
+// already decodes a Document into a tagged struct, so this function can't
+// share its name; ParseDocument is the one-shot []byte-to-*Document
+// counterpart callers reach for when they just want the document itself.
+//
+// ParseDocument never panics on arbitrary input, including malformed or
+// truncated JSON, making it a suitable target for fuzzing.
+func ParseDocument(data []byte) (*Document, error) {
+	var doc Document
+	if err := unmarshalJSON(stripBOM(data), &doc); err != nil {
+		return nil, newMalformedError(err)
+	}
+
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
@@ -0,0 +1,104 @@
+package jsonapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDocumentDecodesValidDocument(t *testing.T) {
+	doc, err := ParseDocument([]byte(`{"data":{"type":"people","id":"1"}}`))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+	data, ok := doc.One()
+	if !ok || data.ID != "1" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
+
+func TestParseDocumentStripsLeadingBOM(t *testing.T) {
+	payload := append([]byte{0xEF, 0xBB, 0xBF}, []byte(`{"data":{"type":"people","id":"1"}}`)...)
+
+	doc, err := ParseDocument(payload)
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+	if _, ok := doc.One(); !ok {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
+
+func TestParseDocumentRejectsMalformedJSON(t *testing.T) {
+	var malformed *MalformedError
+	_, err := ParseDocument([]byte(`{not json`))
+	if err == nil {
+		t.Fatal("expected an error for malformed JSON")
+	}
+	if !errors.As(err, &malformed) {
+		t.Fatalf("expected a *MalformedError, got %T: %v", err, err)
+	}
+}
+
+func TestParseDocumentRejectsDocumentWithNoTopLevelMember(t *testing.T) {
+	if _, err := ParseDocument([]byte(`{}`)); err == nil {
+		t.Fatal("expected an error for a document with no data/errors/meta")
+	}
+}
+
+func TestParseDocumentRejectsDataAndErrorsTogether(t *testing.T) {
+	payload := []byte(`{"data":{"type":"people","id":"1"},"errors":[{"status":"404"}]}`)
+	if _, err := ParseDocument(payload); err == nil {
+		t.Fatal("expected an error for a document with both data and errors")
+	}
+}
+
+func TestParseDocumentNeverPanicsOnArbitraryInput(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		{},
+		[]byte("null"),
+		[]byte("true"),
+		[]byte("42"),
+		[]byte(`"hello"`),
+		[]byte(`[]`),
+		[]byte(`{`),
+		[]byte(`{"data":`),
+		[]byte(`{"data":[1,2,3]}`),
+		[]byte(`{"data":{"type":1}}`),
+		{0xff, 0xfe, 0xfd},
+	}
+
+	for _, in := range inputs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("ParseDocument panicked on %q: %v", in, r)
+				}
+			}()
+			ParseDocument(in)
+		}()
+	}
+}
+
+func FuzzParseDocument(f *testing.F) {
+	seeds := []string{
+		`{"data":{"type":"people","id":"1"}}`,
+		`{"data":[{"type":"people","id":"1"}]}`,
+		`{"errors":[{"status":"404"}]}`,
+		`{"data":null}`,
+		`{}`,
+		`not json at all`,
+	}
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("ParseDocument panicked on %q: %v", data, r)
+			}
+		}()
+		ParseDocument(data)
+	})
+}
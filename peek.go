@@ -0,0 +1,113 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ErrNoPrimaryData is returned by PeekType when payload has no "data"
+// member, e.g. a meta-only or errors-only document.
+var ErrNoPrimaryData = errors.New(`jsonapi: document has no "data" member`)
+
+// PeekType scans payload for its primary resource type and whether "data"
+// is an array, without unmarshaling attributes or relationships. It lets a
+// router dispatch to the right handler/decoder before paying for a full
+// Unmarshal. It returns ErrNoPrimaryData for a meta-only or errors-only
+// document, and ("", true, nil) for an empty array.
+func PeekType(payload []byte) (resourceType string, isArray bool, err error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+
+	t, err := dec.Token()
+	if err != nil {
+		return "", false, err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return "", false, errors.New("jsonapi: expected a JSON object")
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return "", false, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return "", false, errors.New("jsonapi: expected an object key")
+		}
+		if key != "data" {
+			var discard json.RawMessage
+			if err := dec.Decode(&discard); err != nil {
+				return "", false, err
+			}
+			continue
+		}
+
+		t, err = dec.Token()
+		if err != nil {
+			return "", false, err
+		}
+		switch d := t.(type) {
+		case json.Delim:
+			if d == '[' {
+				if !dec.More() {
+					return "", true, nil
+				}
+				typ, err := peekResourceType(dec)
+				return typ, true, err
+			}
+			if d == '{' {
+				typ, err := peekResourceFields(dec)
+				return typ, false, err
+			}
+			return "", false, errors.New(`jsonapi: "data" must be an object, array, or null`)
+		case nil:
+			return "", false, ErrNoPrimaryData
+		default:
+			return "", false, errors.New(`jsonapi: "data" must be an object, array, or null`)
+		}
+	}
+
+	return "", false, ErrNoPrimaryData
+}
+
+// peekResourceType reads a resource object's "type" member, starting at the
+// object's opening '{'.
+func peekResourceType(dec *json.Decoder) (string, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return "", errors.New("jsonapi: expected a resource object")
+	}
+	return peekResourceFields(dec)
+}
+
+// peekResourceFields reads a resource object's "type" member, having
+// already consumed the object's opening '{'.
+func peekResourceFields(dec *json.Decoder) (string, error) {
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return "", errors.New("jsonapi: expected an object key")
+		}
+		if key == "type" {
+			var typ string
+			if err := dec.Decode(&typ); err != nil {
+				return "", err
+			}
+			return typ, nil
+		}
+
+		var discard json.RawMessage
+		if err := dec.Decode(&discard); err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New(`jsonapi: resource object missing "type"`)
+}
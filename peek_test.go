@@ -0,0 +1,93 @@
+package jsonapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPeekTypeSingleResource(t *testing.T) {
+	payload := []byte(`{"data":{"type":"people","id":"1","attributes":{"name":"Alice"}}}`)
+
+	typ, isArray, err := PeekType(payload)
+	if err != nil {
+		t.Fatalf("PeekType returned error: %v", err)
+	}
+	if typ != "people" || isArray {
+		t.Fatalf("expected (people, false), got (%q, %v)", typ, isArray)
+	}
+}
+
+func TestPeekTypeArrayResource(t *testing.T) {
+	payload := []byte(`{"data":[{"type":"people","id":"1"},{"type":"people","id":"2"}]}`)
+
+	typ, isArray, err := PeekType(payload)
+	if err != nil {
+		t.Fatalf("PeekType returned error: %v", err)
+	}
+	if typ != "people" || !isArray {
+		t.Fatalf("expected (people, true), got (%q, %v)", typ, isArray)
+	}
+}
+
+func TestPeekTypeEmptyArray(t *testing.T) {
+	payload := []byte(`{"data":[]}`)
+
+	typ, isArray, err := PeekType(payload)
+	if err != nil {
+		t.Fatalf("PeekType returned error: %v", err)
+	}
+	if typ != "" || !isArray {
+		t.Fatalf("expected (\"\", true), got (%q, %v)", typ, isArray)
+	}
+}
+
+func TestPeekTypeNullData(t *testing.T) {
+	payload := []byte(`{"data":null}`)
+
+	_, _, err := PeekType(payload)
+	if !errors.Is(err, ErrNoPrimaryData) {
+		t.Fatalf("expected ErrNoPrimaryData, got %v", err)
+	}
+}
+
+func TestPeekTypeMetaOnlyDocument(t *testing.T) {
+	payload := []byte(`{"meta":{"count":0}}`)
+
+	_, _, err := PeekType(payload)
+	if !errors.Is(err, ErrNoPrimaryData) {
+		t.Fatalf("expected ErrNoPrimaryData, got %v", err)
+	}
+}
+
+func TestPeekTypeErrorsOnlyDocument(t *testing.T) {
+	payload := []byte(`{"errors":[{"title":"boom"}]}`)
+
+	_, _, err := PeekType(payload)
+	if !errors.Is(err, ErrNoPrimaryData) {
+		t.Fatalf("expected ErrNoPrimaryData, got %v", err)
+	}
+}
+
+func TestPeekTypeIgnoresAttributesBeforeType(t *testing.T) {
+	payload := []byte(`{"data":{"id":"1","attributes":{"name":"Alice"},"type":"people"}}`)
+
+	typ, isArray, err := PeekType(payload)
+	if err != nil {
+		t.Fatalf("PeekType returned error: %v", err)
+	}
+	if typ != "people" || isArray {
+		t.Fatalf("expected (people, false), got (%q, %v)", typ, isArray)
+	}
+}
+
+func TestPeekTypeStopsBeforeDecodingLargeAttributes(t *testing.T) {
+	payload := []byte(`{"data":{"type":"people","id":"1","attributes":{"bio":` + `not valid json for attributes but PeekType never looks at it{{{` + `}}}`)
+
+	typ, isArray, err := PeekType(payload)
+	if err != nil {
+		t.Fatalf("PeekType returned error: %v", err)
+	}
+	if typ != "people" || isArray {
+		t.Fatalf("expected (people, false), got (%q, %v)", typ, isArray)
+	}
+}
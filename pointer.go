@@ -0,0 +1,34 @@
+package jsonapi
+
+import "strings"
+
+// Pointer is a sequence of JSON pointer (RFC 6901) reference tokens, letting
+// callers build a pointer to a nested location (e.g. an error source, or a
+// Lint finding) one segment at a time instead of hand-assembling a string.
+type Pointer []string
+
+// String returns p's canonical JSON pointer representation, e.g.
+// Pointer{"data", "attributes", "name"}.String() == "/data/attributes/name".
+// Each segment is escaped per RFC 6901: "~" becomes "~0" and "/" becomes
+// "~1". An empty Pointer returns "", the pointer to the whole document.
+func (p Pointer) String() string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, segment := range p {
+		b.WriteByte('/')
+		b.WriteString(escapePointerSegment(segment))
+	}
+	return b.String()
+}
+
+// escapePointerSegment escapes "~" and "/" in segment per RFC 6901. The "~"
+// replacement must run first, or a literal "/" turned into "~1" would itself
+// get re-escaped by the "~" pass.
+func escapePointerSegment(segment string) string {
+	segment = strings.ReplaceAll(segment, "~", "~0")
+	segment = strings.ReplaceAll(segment, "/", "~1")
+	return segment
+}
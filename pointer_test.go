@@ -0,0 +1,35 @@
+package jsonapi
+
+import "testing"
+
+func TestPointerStringJoinsSegments(t *testing.T) {
+	p := Pointer{"data", "attributes", "name"}
+
+	if got := p.String(); got != "/data/attributes/name" {
+		t.Fatalf("unexpected pointer: %q", got)
+	}
+}
+
+func TestPointerStringOnEmptyPointer(t *testing.T) {
+	var p Pointer
+
+	if got := p.String(); got != "" {
+		t.Fatalf("expected an empty string, got %q", got)
+	}
+}
+
+func TestPointerStringEscapesTildeAndSlash(t *testing.T) {
+	p := Pointer{"data", "attributes", "a/b~c"}
+
+	if got := p.String(); got != "/data/attributes/a~1b~0c" {
+		t.Fatalf("unexpected pointer: %q", got)
+	}
+}
+
+func TestPointerStringEscapesTildeBeforeSlash(t *testing.T) {
+	p := Pointer{"~1"}
+
+	if got := p.String(); got != "/~01" {
+		t.Fatalf("unexpected pointer: %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package jsonapi
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Pointers enumerates the JSON pointer (RFC 6901) of every scalar value
+// (string, number, bool or null) reachable in d's own marshaled JSON --
+// data and included resources' attributes, meta and links, relationship
+// linkage and meta, the document's own top-level meta and links, and its
+// errors, if any. This is finer-grained than Diff, which only names which
+// attributes or relationships changed rather than pointing at exactly where
+// inside them. Each pointer segment is escaped per RFC 6901; see Pointer.
+// The result is sorted for a deterministic order. A document that fails to
+// marshal (e.g. one with both Data and Errors set) returns nil.
+func (d *Document) Pointers() []string {
+	b, err := marshalJSON(d)
+	if err != nil {
+		return nil
+	}
+
+	var v interface{}
+	if err := unmarshalJSON(b, &v); err != nil {
+		return nil
+	}
+
+	var out []string
+	walkPointers(nil, v, &out)
+	sort.Strings(out)
+	return out
+}
+
+// walkPointers appends the JSON pointer of every scalar value reachable
+// from v to out, recursing through JSON objects and arrays.
+func walkPointers(prefix Pointer, v interface{}, out *[]string) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for key, val := range vv {
+			walkPointers(append(prefix, key), val, out)
+		}
+	case []interface{}:
+		for i, val := range vv {
+			walkPointers(append(prefix, strconv.Itoa(i)), val, out)
+		}
+	default:
+		*out = append(*out, prefix.String())
+	}
+}
@@ -0,0 +1,111 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentPointersEnumeratesAttributesMetaAndLinks(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type:       "articles",
+			ID:         "1",
+			Attributes: json.RawMessage(`{"title":"Hello","views":42}`),
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+			},
+			Meta:  map[string]interface{}{"rev": 3},
+			Links: &Links{Self: "https://api.example.com/articles/1"},
+		}},
+		Meta: map[string]interface{}{"requestID": "abc"},
+	}
+
+	got := doc.Pointers()
+
+	want := []string{
+		"/data/attributes/title",
+		"/data/attributes/views",
+		"/data/id",
+		"/data/links/self",
+		"/data/meta/rev",
+		"/data/relationships/author/data/id",
+		"/data/relationships/author/data/type",
+		"/data/type",
+		"/meta/requestID",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pointers() = %v, want %v", got, want)
+	}
+}
+
+func TestDocumentPointersEscapesSpecialCharactersInKeys(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type:       "articles",
+			ID:         "1",
+			Attributes: json.RawMessage(`{"a/b":"x","c~d":"y"}`),
+		}},
+	}
+
+	got := doc.Pointers()
+
+	for _, want := range []string{"/data/attributes/a~1b", "/data/attributes/c~0d"} {
+		found := false
+		for _, p := range got {
+			if p == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected %s in %v", want, got)
+		}
+	}
+}
+
+func TestDocumentPointersCoversIncludedAndArrayData(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"A"}`)},
+			{Type: "articles", ID: "2", Attributes: json.RawMessage(`{"title":"B"}`)},
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+		},
+	}
+
+	got := doc.Pointers()
+
+	want := []string{
+		"/data/0/attributes/title",
+		"/data/0/id",
+		"/data/0/type",
+		"/data/1/attributes/title",
+		"/data/1/id",
+		"/data/1/type",
+		"/included/0/attributes/name",
+		"/included/0/id",
+		"/included/0/type",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pointers() = %v, want %v", got, want)
+	}
+}
+
+func TestDocumentPointersOnEmptyDocumentReturnsEmpty(t *testing.T) {
+	doc := &Document{}
+	if got := doc.Pointers(); len(got) != 0 {
+		t.Fatalf("expected no pointers, got %v", got)
+	}
+}
+
+func TestDocumentPointersReturnsNilWhenDocumentFailsToMarshal(t *testing.T) {
+	doc := &Document{
+		Data:   &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Errors: []ErrorObject{{Status: "500"}},
+	}
+
+	if got := doc.Pointers(); got != nil {
+		t.Fatalf("expected nil for a document with both Data and Errors, got %v", got)
+	}
+}
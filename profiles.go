@@ -0,0 +1,114 @@
+package jsonapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// LinkProfile is the JSON:API 1.1 link relation type used to advertise a
+// profile URI in a document's top-level links, as an alternative to listing
+// it in the "jsonapi.profile" member or a Content-Type media-type parameter.
+const LinkProfile = "profile"
+
+// registeredProfiles maps a profile URI to the short keyword handlers use to
+// detect it, as populated by RegisterProfile.
+var registeredProfiles = map[string]string{}
+
+// RegisterProfile associates a profile URI with a short keyword, so handlers
+// can call Document.HasProfile(name) instead of hard-coding the full URI.
+// Typically called once at startup for each profile a server or client
+// supports, e.g.
+// RegisterProfile("https://jsonapi.org/profiles/ethanresnick/cursor-pagination", "cursor-pagination").
+func RegisterProfile(uri, name string) {
+	registeredProfiles[uri] = name
+}
+
+// HasProfile reports whether d advertises the profile registered under name,
+// via either its "jsonapi.profile" member or a top-level "profile" link
+// relation.
+func (d *Document) HasProfile(name string) bool {
+	for _, uri := range d.profileURIs() {
+		if registeredProfiles[uri] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// profileURIs collects every profile URI d advertises, from its
+// "jsonapi.profile" member and any top-level "profile" link relation.
+func (d *Document) profileURIs() []string {
+	var uris []string
+	if d.JSONAPI != nil {
+		uris = append(uris, d.JSONAPI.Profile...)
+	}
+	if d.Links != nil {
+		for _, link := range d.Links.Objects {
+			if link.Rel == LinkProfile {
+				uris = append(uris, link.Href)
+			}
+		}
+	}
+	return uris
+}
+
+// profilesContextKey is the request context key NegotiateProfiles stores
+// the negotiated profile names under.
+type profilesContextKey struct{}
+
+// NegotiateProfiles wraps next with a check of the request's Accept header
+// for the "profile" media-type parameter introduced in JSON:API 1.1,
+// resolving each URI it lists that's been registered via RegisterProfile to
+// its short name and storing the resulting list in the request context.
+// This lets a single endpoint behave differently under a requested profile
+// (e.g. switch to cursor-based pagination) without parsing the Accept
+// header itself — call RequestProfiles or RequestHasProfile from the
+// handler to read it back. A request with no Accept header, one that
+// doesn't parse as the JSON:API media type, or one naming no registered
+// profile passes through with none set.
+func NegotiateProfiles(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		if accept == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, uris, err := ParseMediaType(accept)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var names []string
+		for _, uri := range uris {
+			if name, ok := registeredProfiles[uri]; ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), profilesContextKey{}, names)))
+	})
+}
+
+// RequestProfiles returns the profile short names NegotiateProfiles
+// resolved from r's Accept header, or nil if none were negotiated.
+func RequestProfiles(r *http.Request) []string {
+	names, _ := r.Context().Value(profilesContextKey{}).([]string)
+	return names
+}
+
+// RequestHasProfile reports whether name is among the profiles
+// NegotiateProfiles resolved for r.
+func RequestHasProfile(r *http.Request, name string) bool {
+	for _, n := range RequestProfiles(r) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package jsonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasProfileViaJSONAPIMember(t *testing.T) {
+	RegisterProfile("https://jsonapi.org/profiles/ethanresnick/cursor-pagination", "cursor-pagination")
+
+	doc := &Document{JSONAPI: &JSONAPIObject{
+		Profile: []string{"https://jsonapi.org/profiles/ethanresnick/cursor-pagination"},
+	}}
+
+	if !doc.HasProfile("cursor-pagination") {
+		t.Fatal("expected HasProfile to find the profile advertised in jsonapi.profile")
+	}
+	if doc.HasProfile("something-else") {
+		t.Fatal("expected HasProfile to report false for an unregistered keyword")
+	}
+}
+
+func TestHasProfileViaLinkRelation(t *testing.T) {
+	RegisterProfile("https://example.com/profiles/audit-trail", "audit-trail")
+
+	doc := &Document{Links: &Links{Objects: map[string]Link{
+		"profile": {Href: "https://example.com/profiles/audit-trail", Rel: LinkProfile},
+	}}}
+
+	if !doc.HasProfile("audit-trail") {
+		t.Fatal("expected HasProfile to find the profile advertised via a profile link relation")
+	}
+}
+
+func TestHasProfileOnDocumentWithNoProfiles(t *testing.T) {
+	var doc Document
+	if doc.HasProfile("anything") {
+		t.Fatal("expected HasProfile to report false when no profile is advertised")
+	}
+}
+
+func TestNegotiateProfilesResolvesCursorPaginationProfile(t *testing.T) {
+	RegisterProfile("https://jsonapi.org/profiles/ethanresnick/cursor-pagination", "cursor-pagination")
+
+	var gotProfiles []string
+	var hasProfile bool
+	handler := NegotiateProfiles(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotProfiles = RequestProfiles(r)
+		hasProfile = RequestHasProfile(r, "cursor-pagination")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", `application/vnd.api+json;profile="https://jsonapi.org/profiles/ethanresnick/cursor-pagination"`)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !hasProfile {
+		t.Fatal("expected RequestHasProfile to report true for the negotiated cursor-pagination profile")
+	}
+	if len(gotProfiles) != 1 || gotProfiles[0] != "cursor-pagination" {
+		t.Fatalf("unexpected negotiated profiles: %v", gotProfiles)
+	}
+}
+
+func TestNegotiateProfilesPassesThroughWithoutAcceptHeader(t *testing.T) {
+	var gotProfiles []string
+	handler := NegotiateProfiles(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotProfiles = RequestProfiles(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotProfiles != nil {
+		t.Fatalf("expected no negotiated profiles, got %v", gotProfiles)
+	}
+}
+
+func TestNegotiateProfilesIgnoresUnregisteredProfile(t *testing.T) {
+	var hasProfile bool
+	handler := NegotiateProfiles(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hasProfile = RequestHasProfile(r, "cursor-pagination")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", `application/vnd.api+json;profile="https://example.com/profiles/unregistered"`)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if hasProfile {
+		t.Fatal("expected RequestHasProfile to report false for an unregistered profile URI")
+	}
+}
+
+func TestRequestProfilesWithoutNegotiationReturnsNil(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := RequestProfiles(req); got != nil {
+		t.Fatalf("expected nil profiles, got %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package jsonapi
+
+// PruneUnreferencedIncluded removes entries from d.Included that aren't
+// reachable from the primary data's relationships, following linkage
+// transitively through the included resources themselves. This trims a
+// compound document down to what the primary data actually needs, for a
+// server that over-includes (e.g. always attaching a fixed include set
+// regardless of what's actually linked).
+func (d *Document) PruneUnreferencedIncluded() {
+	if len(d.Included) == 0 {
+		return
+	}
+
+	reachable := reachableIncluded(d)
+
+	out := make([]Data, 0, len(d.Included))
+	for _, inc := range d.Included {
+		if reachable[inc.Type+":"+inc.ID] {
+			out = append(out, inc)
+		}
+	}
+	d.Included = out
+}
+
+// UnreferencedIncluded returns the identifier of every entry in d.Included
+// that isn't reachable from the primary data's relationships, following
+// linkage transitively through the included resources themselves --
+// PruneUnreferencedIncluded's report-only counterpart. Full linkage (every
+// included resource reachable this way) is a spec recommendation, so a
+// server can assert UnreferencedIncluded returns none in a test without
+// mutating the document the way PruneUnreferencedIncluded would.
+func (d *Document) UnreferencedIncluded() []RelationshipData {
+	if len(d.Included) == 0 {
+		return nil
+	}
+
+	reachable := reachableIncluded(d)
+
+	var out []RelationshipData
+	for _, inc := range d.Included {
+		if !reachable[inc.Type+":"+inc.ID] {
+			out = append(out, RelationshipData{Type: inc.Type, ID: inc.ID})
+		}
+	}
+	return out
+}
+
+// reachableIncluded returns the "type:id" keys of every entry in d.Included
+// reachable from d's primary data by following relationship linkage
+// transitively, through the included resources themselves.
+func reachableIncluded(d *Document) map[string]bool {
+	byKey := make(map[string]Data, len(d.Included))
+	for _, inc := range d.Included {
+		byKey[inc.Type+":"+inc.ID] = inc
+	}
+
+	var primary []Data
+	switch {
+	case d.Data == nil:
+	case d.Data.DataObject != nil:
+		primary = []Data{*d.Data.DataObject}
+	case d.Data.DataArray != nil:
+		primary = d.Data.DataArray
+	}
+
+	reachable := map[string]bool{}
+	var queue []string
+	enqueue := func(res Data) {
+		for _, rel := range res.Relationships {
+			for _, rd := range relationshipLinkage(rel) {
+				key := rd.Type + ":" + rd.ID
+				if reachable[key] {
+					continue
+				}
+				reachable[key] = true
+				queue = append(queue, key)
+			}
+		}
+	}
+
+	for _, res := range primary {
+		enqueue(res)
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		if inc, ok := byKey[key]; ok {
+			enqueue(inc)
+		}
+	}
+
+	return reachable
+}
+
+// relationshipLinkage returns the resource identifiers carried by rel's
+// "data" member, whether to-one or to-many.
+func relationshipLinkage(rel Relationship) []RelationshipData {
+	if rel.Data == nil {
+		return nil
+	}
+	if rel.Data.DataObject != nil {
+		return []RelationshipData{*rel.Data.DataObject}
+	}
+	return rel.Data.DataArray
+}
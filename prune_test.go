@@ -0,0 +1,172 @@
+package jsonapi
+
+import "testing"
+
+func TestPruneUnreferencedIncludedKeepsTransitivelyReachableChain(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "a", ID: "1",
+			Relationships: map[string]Relationship{
+				"b": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "b", ID: "2"}}},
+			},
+		}},
+		Included: []Data{
+			{
+				Type: "b", ID: "2",
+				Relationships: map[string]Relationship{
+					"c": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "c", ID: "3"}}},
+				},
+			},
+			{Type: "c", ID: "3"},
+			{Type: "d", ID: "4"},
+		},
+	}
+
+	doc.PruneUnreferencedIncluded()
+
+	if len(doc.Included) != 2 {
+		t.Fatalf("expected b and c to survive, got %+v", doc.Included)
+	}
+	for _, key := range []string{"b:2", "c:3"} {
+		found := false
+		for _, inc := range doc.Included {
+			if inc.Type+":"+inc.ID == key {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected %s to be kept, got %+v", key, doc.Included)
+		}
+	}
+}
+
+func TestPruneUnreferencedIncludedKeepsToManyLinkage(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"comments": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{
+					{Type: "comments", ID: "1"},
+					{Type: "comments", ID: "2"},
+				}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "comments", ID: "1"},
+			{Type: "comments", ID: "2"},
+			{Type: "comments", ID: "3"},
+		},
+	}
+
+	doc.PruneUnreferencedIncluded()
+
+	if len(doc.Included) != 2 {
+		t.Fatalf("expected only the two linked comments to survive, got %+v", doc.Included)
+	}
+}
+
+func TestPruneUnreferencedIncludedOnEmptyIncludedIsNoop(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+
+	doc.PruneUnreferencedIncluded()
+
+	if doc.Included != nil {
+		t.Fatalf("expected Included to remain nil, got %+v", doc.Included)
+	}
+}
+
+func TestUnreferencedIncludedReportsUnreachableEntries(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "a", ID: "1",
+			Relationships: map[string]Relationship{
+				"b": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "b", ID: "2"}}},
+			},
+		}},
+		Included: []Data{
+			{Type: "b", ID: "2"},
+			{Type: "d", ID: "4"},
+		},
+	}
+
+	got := doc.UnreferencedIncluded()
+
+	if len(got) != 1 || got[0].Type != "d" || got[0].ID != "4" {
+		t.Fatalf("expected only d:4 to be reported, got %+v", got)
+	}
+	if len(doc.Included) != 2 {
+		t.Fatal("expected UnreferencedIncluded not to mutate Included")
+	}
+}
+
+func TestUnreferencedIncludedReturnsNoneWhenFullyLinked(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "a", ID: "1",
+			Relationships: map[string]Relationship{
+				"b": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "b", ID: "2"}}},
+			},
+		}},
+		Included: []Data{{Type: "b", ID: "2"}},
+	}
+
+	if got := doc.UnreferencedIncluded(); len(got) != 0 {
+		t.Fatalf("expected no unreferenced included, got %+v", got)
+	}
+}
+
+func TestUnreferencedIncludedOnEmptyIncludedReturnsNil(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "a", ID: "1"}}}
+
+	if got := doc.UnreferencedIncluded(); got != nil {
+		t.Fatalf("expected nil, got %+v", got)
+	}
+}
+
+func TestPruneUnreferencedIncludedTerminatesOnTwoNodeCycle(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "a", ID: "1",
+			Relationships: map[string]Relationship{
+				"b": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "b", ID: "2"}}},
+			},
+		}},
+		Included: []Data{
+			{
+				Type: "b", ID: "2",
+				Relationships: map[string]Relationship{
+					"a": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "a", ID: "1"}}},
+				},
+			},
+		},
+	}
+
+	doc.PruneUnreferencedIncluded()
+
+	if len(doc.Included) != 1 || doc.Included[0].Type != "b" || doc.Included[0].ID != "2" {
+		t.Fatalf("expected the cycle to terminate with b kept, got %+v", doc.Included)
+	}
+}
+
+func TestPruneUnreferencedIncludedOnDataArray(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{
+				Type: "articles", ID: "1",
+				Relationships: map[string]Relationship{
+					"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "9"}}},
+				},
+			},
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9"},
+			{Type: "people", ID: "10"},
+		},
+	}
+
+	doc.PruneUnreferencedIncluded()
+
+	if len(doc.Included) != 1 || doc.Included[0].ID != "9" {
+		t.Fatalf("unexpected included: %+v", doc.Included)
+	}
+}
@@ -0,0 +1,1188 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortField is one entry of the `sort` query parameter.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// SortFields is an ordered list of sort criteria, most significant first,
+// as parsed from the `sort` query parameter.
+type SortFields []SortField
+
+// Compare compares two resources' attribute maps according to s, returning
+// a negative number if a sorts before b, a positive number if after, and
+// zero if they compare equal on every field. A field missing from one map
+// but not the other sorts the map missing it last, regardless of Desc.
+// Values are compared as strings, float64s or time.Times depending on
+// their dynamic type, falling back to a string comparison of their
+// fmt.Sprint representation for anything else or for mismatched types.
+func (s SortFields) Compare(a, b map[string]interface{}) int {
+	for _, field := range s {
+		av, aok := a[field.Field]
+		bv, bok := b[field.Field]
+
+		switch {
+		case !aok && !bok:
+			continue
+		case !aok:
+			return 1
+		case !bok:
+			return -1
+		}
+
+		c := compareSortValues(av, bv)
+		if c == 0 {
+			continue
+		}
+		if field.Desc {
+			c = -c
+		}
+		return c
+	}
+	return 0
+}
+
+// SQL renders s as the column list of a SQL "ORDER BY" clause (without the
+// "ORDER BY" keyword itself), translating each field through mapping -- an
+// allowlist from sort-field name to trusted column name or expression -- so
+// a caller can build a query straight from client-supplied sort criteria
+// without ever interpolating client input into SQL. It returns an error
+// naming the first field with no entry in mapping rather than silently
+// skipping it, since a sort criterion dropped without the caller's
+// knowledge could serve a client unintentionally (and invisibly) unsorted
+// data. An empty s renders as "".
+func (s SortFields) SQL(mapping map[string]string) (string, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+
+	parts := make([]string, 0, len(s))
+	for _, field := range s {
+		col, ok := mapping[field.Field]
+		if !ok {
+			return "", fmt.Errorf("jsonapi: sort field %q is not in the allowlist mapping", field.Field)
+		}
+		dir := "ASC"
+		if field.Desc {
+			dir = "DESC"
+		}
+		parts = append(parts, col+" "+dir)
+	}
+
+	return strings.Join(parts, ", "), nil
+}
+
+// SortData sorts data in place according to s, comparing each resource's
+// decoded Attributes. A resource whose Attributes fail to decode sorts as
+// if it had no attributes at all.
+func SortData(data []Data, s SortFields) {
+	attrs := make([]map[string]interface{}, len(data))
+	for i := range data {
+		_ = data[i].UnmarshalAttributes(&attrs[i])
+	}
+
+	order := make([]int, len(data))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return s.Compare(attrs[order[i]], attrs[order[j]]) < 0
+	})
+
+	sorted := make([]Data, len(data))
+	for i, idx := range order {
+		sorted[i] = data[idx]
+	}
+	copy(data, sorted)
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			default:
+				return 0
+			}
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			switch {
+			case av.Before(bv):
+				return -1
+			case av.After(bv):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// reservedQueryParams are the JSON:API standard query parameter base names,
+// exempt from the implementation-specific naming rule that
+// ValidateQueryParams enforces.
+var reservedQueryParams = map[string]bool{
+	"include": true, "fields": true, "sort": true, "page": true, "filter": true,
+}
+
+var implementationParamNameRegexp = regexp.MustCompile(`^[a-z]+$`)
+
+// ValidateQueryParams checks q against the JSON:API naming rule for
+// implementation-specific query parameters: per the spec, such a parameter
+// name must contain at least one character that isn't a lowercase ASCII
+// letter (a dash, a bracket, etc.), so it can't collide with a future
+// official reserved parameter. The standard parameters (include, fields,
+// sort, page, filter) and their bracketed forms (e.g. fields[articles]) are
+// exempt. It returns an error listing every offending name, or nil if q is
+// conformant.
+func ValidateQueryParams(q url.Values) error {
+	var invalid []string
+
+	for name := range q {
+		base := name
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			base = name[:i]
+		}
+		if reservedQueryParams[base] {
+			continue
+		}
+		if implementationParamNameRegexp.MatchString(name) {
+			invalid = append(invalid, name)
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	sort.Strings(invalid)
+	return fmt.Errorf("jsonapi: query parameter name(s) violate the JSON:API naming rule for implementation-specific parameters (must contain a non a-z character): %s", strings.Join(invalid, ", "))
+}
+
+// IncludeTree is a nested tree of relationship paths parsed from the
+// `include` query parameter. `include=author,comments.user` produces
+// {"author": {}, "comments": {"user": {}}}.
+type IncludeTree map[string]IncludeTree
+
+// MaxDepth returns t's deepest nesting level: 0 for an empty tree, 1 for
+// `include=author`, 2 for `include=comments.user`, and so on.
+func (t IncludeTree) MaxDepth() int {
+	if len(t) == 0 {
+		return 0
+	}
+
+	max := 0
+	for _, child := range t {
+		if d := child.MaxDepth(); d > max {
+			max = d
+		}
+	}
+	return max + 1
+}
+
+// ValidateIncludeDepth checks that t's deepest nesting level doesn't exceed
+// maxDepth, returning an error if it does. There is no limit by default;
+// ParseQuery never rejects a deeply nested `include`. A server that wants
+// to protect itself from an expensive `include=a.b.c.d.e` should call this
+// itself, typically right after ParseQuery, and respond 400 Bad Request if
+// it returns an error.
+func ValidateIncludeDepth(t IncludeTree, maxDepth int) error {
+	if depth := t.MaxDepth(); depth > maxDepth {
+		return fmt.Errorf("jsonapi: include depth %d exceeds the maximum of %d", depth, maxDepth)
+	}
+	return nil
+}
+
+// RelationshipTypeSchema maps a resource type to its relationship names and
+// each one's target resource type. It's the minimum information
+// IncludedTypes needs to resolve an IncludeTree to the set of resource
+// types it will pull in, without reflecting over a live struct the way
+// Describe does.
+type RelationshipTypeSchema map[string]map[string]string
+
+// IncludedTypes returns the distinct resource types include will pull into
+// a compound document when starting from a resource of type rootType,
+// resolving each relationship name to its target type via schema at every
+// level of the tree. This lets a server work out, before it assembles a
+// response, which types an `include` query parameter will touch, so it can
+// pre-authorize or pre-load them. Order is not significant -- IncludeTree
+// is itself a map, so traversal order isn't deterministic. A path segment
+// with no matching entry in schema at its point in the tree contributes
+// nothing and its own subtree is not walked, the same as an unrecognized
+// include is usually just dropped.
+func IncludedTypes(include IncludeTree, schema RelationshipTypeSchema, rootType string) []string {
+	var types []string
+	seen := map[string]bool{}
+
+	var walk func(tree IncludeTree, typ string)
+	walk = func(tree IncludeTree, typ string) {
+		rels, ok := schema[typ]
+		if !ok {
+			return
+		}
+		for name, subtree := range tree {
+			target, ok := rels[name]
+			if !ok {
+				continue
+			}
+			if !seen[target] {
+				seen[target] = true
+				types = append(types, target)
+			}
+			walk(subtree, target)
+		}
+	}
+	walk(include, rootType)
+
+	return types
+}
+
+// CheckIncludes validates that doc actually sideloaded, into "included",
+// every resource its primary data's relationships link to for each path
+// named in includes (a parsed `include` query parameter, typically from
+// ParseQuery), returning the dotted paths -- "author", "comments.user" --
+// for which at least one primary resource's linkage points at a resource
+// missing from doc.Included. This is meant for a server to test its own
+// compound-document assembly: a handler that parses `include=author` but
+// forgets to sideload the author should have that bug caught by a test
+// asserting CheckIncludes returns nil. A path whose relationship has no
+// linkage at all on any primary resource (a to-one left null, or a
+// to-many never populated) isn't reported missing -- there's nothing it
+// could have sideloaded. Order is not significant.
+func CheckIncludes(doc *Document, includes IncludeTree) []string {
+	var resources []Data
+	if one, ok := doc.One(); ok {
+		resources = []Data{*one}
+	} else if many, ok := doc.Many(); ok {
+		resources = many
+	}
+
+	index := IndexIncluded(doc.Included)
+	seen := map[string]bool{}
+	var missing []string
+
+	var walk func(tree IncludeTree, prefix string, resources []Data)
+	walk = func(tree IncludeTree, prefix string, resources []Data) {
+		for name, subtree := range tree {
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+
+			var targets []Data
+			for _, res := range resources {
+				rel, ok := res.Relationships[name]
+				if !ok || rel.Data == nil {
+					continue
+				}
+
+				linked := rel.Data.DataArray
+				if rel.Data.DataObject != nil {
+					linked = []RelationshipData{*rel.Data.DataObject}
+				}
+
+				for _, rd := range linked {
+					target, ok := index[rd.Type+":"+rd.ID]
+					if !ok {
+						if !seen[path] {
+							seen[path] = true
+							missing = append(missing, path)
+						}
+						continue
+					}
+					targets = append(targets, target)
+				}
+			}
+
+			walk(subtree, path, targets)
+		}
+	}
+	walk(includes, "", resources)
+
+	return missing
+}
+
+// PageStrategy identifies which JSON:API pagination strategy a request used.
+type PageStrategy int
+
+const (
+	// PageStrategyNone means no page parameters were present.
+	PageStrategyNone PageStrategy = iota
+	// PageStrategyNumber is page[number]/page[size] based pagination.
+	PageStrategyNumber
+	// PageStrategyCursor is page[after]/page[before]/page[limit] based
+	// pagination.
+	PageStrategyCursor
+)
+
+// Page holds the parsed `page[...]` query parameters, supporting both the
+// number/size and cursor pagination strategies.
+type Page struct {
+	Strategy PageStrategy
+	Number   int
+	Size     int
+	After    string
+	Before   string
+	Limit    int
+
+	// ResultCount is the number of resources actually returned for this
+	// page, set by the caller (not parsed from the query) before calling
+	// BuildLinks or BuildRelationshipLinks with an UnknownTotal total. It
+	// decides whether "next" is emitted when the total count isn't known:
+	// a page as full as Size implies more may follow, a short page implies
+	// this is the last one. It's ignored when total is known.
+	ResultCount int
+}
+
+// UnknownTotal is passed as BuildLinks' or BuildRelationshipLinks' total
+// when the total number of matching resources is expensive or impossible
+// to compute (e.g. a keyset-paginated or streaming source). "first" and
+// "last", which both require a total to compute, are omitted; "next" is
+// instead derived from Page.ResultCount.
+const UnknownTotal = -1
+
+// Query holds the parsed JSON:API standard query parameters: fields,
+// include, sort, page and filter.
+type Query struct {
+	Fields  FilterFields
+	Include IncludeTree
+	Sort    []SortField
+	Page    Page
+	Filter  FilterParams
+}
+
+// FilterParams holds the parsed `filter[field]=value` query parameters, the
+// common (though spec-unspecified) convention for filtering. Each field maps
+// to every value supplied for it, whether repeated (`filter[a]=1&filter[a]=2`)
+// or comma-separated (`filter[a]=1,2`).
+type FilterParams map[string][]string
+
+// ParseQuery extracts every `filter[...]` key out of q into f, analogous to
+// FilterFields.ParseQuery for `fields[...]`.
+func (f FilterParams) ParseQuery(q url.Values) {
+	for k := range q {
+		m := filterParamRegexp.FindStringSubmatch(k)
+		if m == nil {
+			continue
+		}
+		f[m[1]] = append(f[m[1]], splitCSV(q, k)...)
+	}
+}
+
+// splitCSV returns the values of q[key], split on commas and with repeated
+// values joined first, so `?key=a,b` and `?key=a&key=b` parse identically.
+// Each part is trimmed and empty parts are dropped. It's shared by every
+// query-parameter parser that accepts either form: FilterFields.ParseQuery,
+// FilterParams.ParseQuery, and ParseQuery's own handling of `include` and
+// `sort`.
+func splitCSV(q url.Values, key string) []string {
+	v, ok := q[key]
+	if !ok {
+		return nil
+	}
+
+	var out []string
+	for _, part := range strings.Split(strings.Join(v, ","), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		out = append(out, part)
+	}
+	return out
+}
+
+var (
+	pageParamRegexp   = regexp.MustCompile(`(?i)^page\[([^\]]+)]$`)
+	filterParamRegexp = regexp.MustCompile(`(?i)^filter\[([^\]]+)]$`)
+)
+
+// ValidateFields checks that every resource type named in a `fields[...]`
+// query parameter is one of knownTypes, returning an error naming the first
+// unknown type it finds. Callers that expose a fixed set of resource types
+// can use this to reject a `fields[bogus]=x` request instead of silently
+// ignoring it.
+func (f FilterFields) ValidateFields(knownTypes ...string) error {
+	allowed := map[string]bool{}
+	for _, t := range knownTypes {
+		allowed[t] = true
+	}
+
+	for t := range f {
+		if !allowed[t] {
+			return fmt.Errorf("jsonapi: unknown resource type %q in a fields[] parameter", t)
+		}
+	}
+
+	return nil
+}
+
+// Columns returns the DB columns backing the fields requested for typ under
+// f, resolved through mapping (attribute name -> column name), always
+// including "id" first. A field with no entry in mapping, and a type with no
+// entry in f, both contribute nothing beyond "id" — mapping is an allowlist,
+// so this never returns a column not explicitly named by the caller,
+// closing off SQL injection via a crafted `fields[...]` query parameter.
+func (f FilterFields) Columns(typ string, mapping map[string]string) []string {
+	columns := []string{"id"}
+
+	for _, name := range f[typ] {
+		if col, ok := mapping[name]; ok {
+			columns = append(columns, col)
+		}
+	}
+
+	return columns
+}
+
+// Intersect returns a new FilterFields allowing a type's fields only where
+// both f and other allow them, for composing a policy-level restriction
+// with a client's own `fields[...]` request so the client can never see
+// more than the policy allows. A type missing from one side is
+// unrestricted there, so it keeps the other side's list unchanged --
+// intersecting a list with "every field" is the list itself -- while a
+// type present in both intersects their allowed lists.
+func (f FilterFields) Intersect(other FilterFields) FilterFields {
+	out := FilterFields{}
+	for typ, want := range f {
+		if ow, ok := other[typ]; ok {
+			out[typ] = intersectFieldNames(want, ow)
+		} else {
+			out[typ] = append([]string(nil), want...)
+		}
+	}
+	for typ, ow := range other {
+		if _, ok := f[typ]; !ok {
+			out[typ] = append([]string(nil), ow...)
+		}
+	}
+	return out
+}
+
+// Union returns a new FilterFields allowing a type's fields if either f or
+// other allows them, for reconciling two independently-derived fieldsets
+// back into one request. A type missing from one side is unrestricted
+// there, so the union is unrestricted too -- union with "every field" is
+// every field -- while a type present in both merges their allowed lists.
+func (f FilterFields) Union(other FilterFields) FilterFields {
+	out := FilterFields{}
+	for typ, want := range f {
+		ow, ok := other[typ]
+		if !ok {
+			continue
+		}
+		out[typ] = unionFieldNames(want, ow)
+	}
+	for typ, ow := range other {
+		if _, ok := f[typ]; !ok {
+			continue
+		}
+		if _, done := out[typ]; done {
+			continue
+		}
+		out[typ] = unionFieldNames(f[typ], ow)
+	}
+	return out
+}
+
+// intersectFieldNames returns the field names present in both a and b,
+// preserving a's order.
+func intersectFieldNames(a, b []string) []string {
+	bset := make(map[string]bool, len(b))
+	for _, name := range b {
+		bset[name] = true
+	}
+
+	var out []string
+	for _, name := range a {
+		if bset[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// unionFieldNames returns the deduplicated field names present in a or b,
+// preserving first-seen order.
+func unionFieldNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, name := range append(append([]string{}, a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// ParseQuery parses the `fields`, `include`, `sort`, `page` and `filter`
+// JSON:API query-parameter families out of q in a single call.
+func ParseQuery(q url.Values) *Query {
+	query := &Query{
+		Fields: FilterFields{},
+		Filter: FilterParams{},
+	}
+	query.Fields.ParseQuery(q)
+	query.Filter.ParseQuery(q)
+
+	pageParams := map[string]string{}
+
+	for k, v := range q {
+		switch {
+		case pageParamRegexp.MatchString(k):
+			m := pageParamRegexp.FindStringSubmatch(k)
+			pageParams[strings.ToLower(m[1])] = strings.Join(v, ",")
+		case strings.EqualFold(k, "include"):
+			query.Include = parseInclude(splitCSV(q, k))
+		case strings.EqualFold(k, "sort"):
+			query.Sort = parseSort(splitCSV(q, k))
+		}
+	}
+
+	query.Page = parsePage(pageParams)
+
+	return query
+}
+
+// parsePage derives a Page from the page[...] params collected by ParseQuery.
+// Collecting every key first and deciding the strategy in one fixed-order
+// pass (rather than mutating q.Page while ranging over url.Values, whose
+// iteration order is randomized) keeps the result deterministic even when a
+// request mixes the number/size and cursor families; cursor params win when
+// both are present, since after/before/limit are unambiguous markers of
+// cursor pagination while number/size default to 1/0.
+func parsePage(params map[string]string) Page {
+	var page Page
+
+	_, hasAfter := params["after"]
+	_, hasBefore := params["before"]
+	_, hasLimit := params["limit"]
+
+	if hasAfter || hasBefore || hasLimit {
+		page.Strategy = PageStrategyCursor
+		page.After = params["after"]
+		page.Before = params["before"]
+		page.Limit, _ = strconv.Atoi(params["limit"])
+		return page
+	}
+
+	if number, ok := params["number"]; ok {
+		page.Strategy = PageStrategyNumber
+		page.Number, _ = strconv.Atoi(number)
+	}
+	if size, ok := params["size"]; ok {
+		page.Strategy = PageStrategyNumber
+		page.Size, _ = strconv.Atoi(size)
+	}
+
+	return page
+}
+
+// parseInclude turns a list of dot-nested `include` paths, already split on
+// commas by splitCSV, into an IncludeTree.
+func parseInclude(paths []string) IncludeTree {
+	tree := IncludeTree{}
+
+	for _, path := range paths {
+		node := tree
+		for _, segment := range strings.Split(path, ".") {
+			if node[segment] == nil {
+				node[segment] = IncludeTree{}
+			}
+			node = node[segment]
+		}
+	}
+
+	return tree
+}
+
+// parseSort turns a list of `sort` field names, already split on commas by
+// splitCSV, into an ordered list of SortFields, treating a leading "-" as
+// descending order. A field named more than once keeps only its first
+// occurrence.
+func parseSort(raw []string) []SortField {
+	var fields []SortField
+	seen := map[string]bool{}
+	for _, f := range raw {
+		desc := strings.HasPrefix(f, "-")
+		name := strings.TrimPrefix(f, "-")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		fields = append(fields, SortField{Field: name, Desc: desc})
+	}
+
+	return fields
+}
+
+// QueryParams holds the parsed and validated JSON:API query-parameter
+// families, as returned by ParseAndValidateQuery: fields, sort, include
+// paths, filter and pagination.
+type QueryParams struct {
+	Fields       FilterFields
+	SortFields   SortFields
+	IncludePaths IncludeTree
+	Filter       FilterParams
+	Pagination   Pagination
+}
+
+// ParseAndValidateQuery parses every query-parameter family ParseQuery does,
+// plus page[...] via the stricter, error-returning ParsePagination, and runs
+// ValidateQueryParams over q, in one call. Unlike ParseQuery, which never
+// fails, this is for handlers that want a single entry point covering both
+// parsing and validation: every check runs regardless of whether an
+// earlier one failed, so an implementation-specific parameter name that
+// breaks ValidateQueryParams' naming rule and an invalid page[size] are
+// both reported rather than only the first one found. A non-nil error
+// wraps every failure (via errors.Join) and can be passed straight to
+// ErrorsFromError to render them together as a single JSON:API error
+// document.
+func ParseAndValidateQuery(q url.Values) (*QueryParams, error) {
+	params := &QueryParams{
+		Fields: FilterFields{},
+		Filter: FilterParams{},
+	}
+	params.Fields.ParseQuery(q)
+	params.Filter.ParseQuery(q)
+
+	for k := range q {
+		switch {
+		case strings.EqualFold(k, "include"):
+			params.IncludePaths = parseInclude(splitCSV(q, k))
+		case strings.EqualFold(k, "sort"):
+			params.SortFields = parseSort(splitCSV(q, k))
+		}
+	}
+
+	var errs []error
+	if err := ValidateQueryParams(q); err != nil {
+		errs = append(errs, err)
+	}
+
+	pagination, err := ParsePagination(q)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	params.Pagination = pagination
+
+	if joined := errors.Join(errs...); joined != nil {
+		return nil, joined
+	}
+
+	return params, nil
+}
+
+// BuildLinks emits self/first/prev/next/last pagination links for base,
+// according to the page strategy the query used. total is the total number
+// of matching resources; it drives first/last for number-based pagination
+// and is ignored for cursor-based pagination, where next/prev are only
+// emitted when the corresponding cursor was present on the request. Pass
+// UnknownTotal when total can't be computed; see Page.ResultCount.
+func (q *Query) BuildLinks(base *url.URL, total int) *Links {
+	return buildPageLinks(base, q.Page, total)
+}
+
+// BuildRelationshipLinks builds first/prev/next/last pagination links for a
+// paginated to-many relationship, the same way BuildLinks does for a
+// top-level collection. selfURL is the relationship's own URL, e.g.
+// "https://api.example.com/articles/1/comments"; p is the page the caller
+// is rendering and total is the total number of related resources. Pass
+// UnknownTotal when total can't be computed; see Page.ResultCount.
+func BuildRelationshipLinks(selfURL string, p Page, total int) (*Links, error) {
+	base, err := url.Parse(selfURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildPageLinks(base, p, total), nil
+}
+
+func buildPageLinks(base *url.URL, p Page, total int) *Links {
+	links := &Links{Self: base.String()}
+
+	switch p.Strategy {
+	case PageStrategyNumber:
+		number := p.Number
+		if number <= 0 {
+			number = 1
+		}
+
+		if total == UnknownTotal {
+			size := p.Size
+			if number > 1 {
+				links.Previous = pageURL(base, number-1, size)
+			}
+			if size > 0 && p.ResultCount >= size {
+				links.Next = pageURL(base, number+1, size)
+			}
+			break
+		}
+
+		size := p.Size
+		if size <= 0 {
+			size = total
+		}
+
+		lastPage := 1
+		if size > 0 {
+			lastPage = (total + size - 1) / size
+			if lastPage < 1 {
+				lastPage = 1
+			}
+		}
+
+		links.First = pageURL(base, 1, size)
+		links.Last = pageURL(base, lastPage, size)
+		if number > 1 {
+			links.Previous = pageURL(base, number-1, size)
+		}
+		if number < lastPage {
+			links.Next = pageURL(base, number+1, size)
+		}
+	case PageStrategyCursor:
+		if p.Before != "" {
+			links.Previous = cursorURL(base, "before", p.Before, p.Limit)
+		}
+		if p.After != "" {
+			links.Next = cursorURL(base, "after", p.After, p.Limit)
+		}
+	}
+
+	return links
+}
+
+func pageURL(base *url.URL, number, size int) string {
+	u := *base
+	v := u.Query()
+	v.Set("page[number]", strconv.Itoa(number))
+	if size > 0 {
+		v.Set("page[size]", strconv.Itoa(size))
+	}
+	u.RawQuery = v.Encode()
+	return u.String()
+}
+
+func cursorURL(base *url.URL, key, value string, limit int) string {
+	u := *base
+	v := u.Query()
+	v.Set("page["+key+"]", value)
+	if limit > 0 {
+		v.Set("page[limit]", strconv.Itoa(limit))
+	}
+	u.RawQuery = v.Encode()
+	return u.String()
+}
+
+// Pagination describes how to slice an in-memory []Data for Paginate,
+// either by page Number/Size or by Offset/Limit. Number and Size take
+// precedence when either is nonzero; otherwise Offset/Limit drives the
+// slice. A zero Size or Limit means "the rest of the collection" rather
+// than an empty page.
+type Pagination struct {
+	Number int
+	Size   int
+	Offset int
+	Limit  int
+}
+
+// PaginationError is returned by ParsePagination when a request's page[...]
+// parameters are ambiguous or out of range. It implements StatusCoder so
+// ErrorsFromError renders it as an HTTP 400.
+type PaginationError struct {
+	msg string
+}
+
+func (e *PaginationError) Error() string {
+	return "jsonapi: " + e.msg
+}
+
+func (e *PaginationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParsePagination parses the page[number], page[size], page[offset], and
+// page[limit] query parameters into a Pagination for use with Paginate. It
+// rejects a request that mixes the page-based strategy (number/size) with
+// the offset-based one (offset/limit), since the two are mutually exclusive
+// ways of addressing the same collection and combining them is ambiguous.
+// It also rejects a non-positive size or limit, which would otherwise slice
+// data into an empty or nonsensical page.
+func ParsePagination(q url.Values) (Pagination, error) {
+	_, hasNumber := q["page[number]"]
+	_, hasSize := q["page[size]"]
+	_, hasOffset := q["page[offset]"]
+	_, hasLimit := q["page[limit]"]
+
+	if (hasNumber || hasSize) && (hasOffset || hasLimit) {
+		return Pagination{}, &PaginationError{msg: "cannot mix page[number]/page[size] with page[offset]/page[limit]"}
+	}
+
+	var p Pagination
+
+	if hasNumber {
+		p.Number, _ = strconv.Atoi(q.Get("page[number]"))
+	}
+	if hasSize {
+		p.Size, _ = strconv.Atoi(q.Get("page[size]"))
+		if p.Size <= 0 {
+			return Pagination{}, &PaginationError{msg: "page[size] must be positive"}
+		}
+	}
+	if hasOffset {
+		p.Offset, _ = strconv.Atoi(q.Get("page[offset]"))
+	}
+	if hasLimit {
+		p.Limit, _ = strconv.Atoi(q.Get("page[limit]"))
+		if p.Limit <= 0 {
+			return Pagination{}, &PaginationError{msg: "page[limit] must be positive"}
+		}
+	}
+
+	return p, nil
+}
+
+// Paginate slices data according to p and returns the slice alongside
+// "first"/"prev"/"next"/"last" links describing its position in the full
+// collection, the same links BuildLinks would build for a request-driven
+// page. Since there's no request URL to anchor them to for an in-memory
+// collection, the links are relative query strings with their brackets
+// query-escaped (e.g. "?page%5Bnumber%5D=2&page%5Bsize%5D=10") rather than
+// absolute URLs; a caller
+// that wants absolute links can resolve them against its own base URL. A
+// page or offset past the end of data returns an empty slice along with
+// correct boundary links rather than an error.
+func Paginate(data []Data, p Pagination) ([]Data, *Links) {
+	total := len(data)
+
+	if p.Number > 0 || p.Size > 0 {
+		return paginateByPage(data, p, total)
+	}
+	return paginateByOffset(data, p, total)
+}
+
+func paginateByPage(data []Data, p Pagination, total int) ([]Data, *Links) {
+	links := buildPageLinks(&url.URL{}, Page{Strategy: PageStrategyNumber, Number: p.Number, Size: p.Size}, total)
+
+	number := p.Number
+	if number <= 0 {
+		number = 1
+	}
+	size := p.Size
+	if size <= 0 {
+		size = total
+	}
+	if size <= 0 {
+		return []Data{}, links
+	}
+
+	start := (number - 1) * size
+	if start < 0 || start >= total {
+		return []Data{}, links
+	}
+	end := start + size
+	if end > total {
+		end = total
+	}
+	return data[start:end], links
+}
+
+func paginateByOffset(data []Data, p Pagination, total int) ([]Data, *Links) {
+	links := buildOffsetLinks(&url.URL{}, p, total)
+
+	offset := p.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	limit := p.Limit
+
+	if offset >= total {
+		return []Data{}, links
+	}
+	if limit <= 0 {
+		return data[offset:], links
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return data[offset:end], links
+}
+
+// SplitPages splits doc's primary collection into a sequence of per-page
+// documents of at most size resources each, using Paginate to slice the
+// data and compute every page's first/prev/next/last links. Included
+// resources, top-level meta and the "jsonapi" member are copied onto every
+// page, with pagination info added to each page's meta using the default
+// PageMeta key names. This is meant for pre-rendering and caching the
+// pages of a large, already-assembled collection document rather than
+// re-querying per page. It returns nil for a Document whose data isn't a
+// collection (see Document.Many) or for a non-positive size.
+func SplitPages(doc *Document, size int) []*Document {
+	data, ok := doc.Many()
+	if !ok || size <= 0 {
+		return nil
+	}
+
+	total := len(data)
+	numPages := (total + size - 1) / size
+	if numPages < 1 {
+		numPages = 1
+	}
+
+	keys := defaultPageMetaKeys()
+	pages := make([]*Document, numPages)
+	for i := 0; i < numPages; i++ {
+		pageData, links := Paginate(data, Pagination{Number: i + 1, Size: size})
+
+		page := &Document{
+			Data:    &DataContainer{DataArray: pageData},
+			Links:   links,
+			JSONAPI: doc.JSONAPI.Clone(),
+			Meta:    cloneMeta(doc.Meta),
+		}
+		if len(doc.Included) > 0 {
+			page.Included = append([]Data(nil), doc.Included...)
+		}
+		page.SetMeta(keys.CurrentPage, i+1)
+		page.SetMeta(keys.TotalPages, numPages)
+		page.SetMeta(keys.PerPage, size)
+		page.SetMeta(keys.Total, total)
+
+		pages[i] = page
+	}
+
+	return pages
+}
+
+// buildOffsetLinks builds "first"/"prev"/"next"/"last" links for an
+// offset/limit page anchored at base, the offset/limit counterpart to
+// buildPageLinks.
+func buildOffsetLinks(base *url.URL, p Pagination, total int) *Links {
+	links := &Links{}
+
+	offset := p.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	limit := p.Limit
+
+	if limit <= 0 {
+		links.First = offsetURL(base, 0, limit)
+		return links
+	}
+
+	lastOffset := ((total - 1) / limit) * limit
+	if lastOffset < 0 {
+		lastOffset = 0
+	}
+
+	links.First = offsetURL(base, 0, limit)
+	links.Last = offsetURL(base, lastOffset, limit)
+	if offset > 0 {
+		prevOffset := offset - limit
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+		links.Previous = offsetURL(base, prevOffset, limit)
+	}
+	if offset+limit < total {
+		links.Next = offsetURL(base, offset+limit, limit)
+	}
+
+	return links
+}
+
+// WriteLinkHeader writes an RFC 5988 HTTP "Link" header to w describing p's
+// position in baseURL's collection, the same first/prev/next/last links
+// BuildLinks and Paginate express in the response document itself, for a
+// client that reads pagination off the Link header instead. total is the
+// total number of matching resources; pass UnknownTotal to omit "first" and
+// "last", which both require it, the same as BuildLinks. A rel with no link
+// at this boundary (e.g. "prev" on the first page) is omitted entirely.
+func (p Pagination) WriteLinkHeader(w http.ResponseWriter, baseURL string, total int) error {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+
+	var links *Links
+	if p.Number > 0 || p.Size > 0 {
+		links = buildPageLinks(base, Page{Strategy: PageStrategyNumber, Number: p.Number, Size: p.Size}, total)
+		links.Self = ""
+	} else {
+		links = buildOffsetLinks(base, p, total)
+		if total == UnknownTotal {
+			links.First, links.Last = "", ""
+		}
+	}
+
+	if header := linkHeader(links); header != "" {
+		w.Header().Set("Link", header)
+	}
+	return nil
+}
+
+// linkHeader formats links' first/prev/next/last members as an RFC 5988
+// "Link" header value, e.g. `<url>; rel="first", <url>; rel="next"`,
+// omitting any rel with no link.
+func linkHeader(links *Links) string {
+	var parts []string
+	for _, rel := range []struct {
+		href string
+		name string
+	}{
+		{links.First, LinkFirst},
+		{links.Previous, LinkPrev},
+		{links.Next, LinkNext},
+		{links.Last, LinkLast},
+	} {
+		if rel.href == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, rel.href, rel.name))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func offsetURL(base *url.URL, offset, limit int) string {
+	u := *base
+	v := u.Query()
+	v.Set("page[offset]", strconv.Itoa(offset))
+	if limit > 0 {
+		v.Set("page[limit]", strconv.Itoa(limit))
+	}
+	u.RawQuery = v.Encode()
+	return u.String()
+}
+
+// PageInfo holds pagination details extracted from a response document's
+// top-level meta by PageMeta, for a client driving UI pagination (page
+// indicators, a "load more" button) without digging through Document.Meta
+// by hand.
+type PageInfo struct {
+	CurrentPage int
+	TotalPages  int
+	PerPage     int
+	Total       int
+}
+
+// PageMetaKeys names the meta keys PageMeta reads pagination info from.
+// There's no single convention for these across servers, so every field
+// defaults to a common name (see defaultPageMetaKeys) and can be overridden
+// with WithPageMetaKeys; a field left as "" keeps its default.
+type PageMetaKeys struct {
+	CurrentPage string
+	TotalPages  string
+	PerPage     string
+	Total       string
+}
+
+func defaultPageMetaKeys() PageMetaKeys {
+	return PageMetaKeys{
+		CurrentPage: "currentPage",
+		TotalPages:  "totalPages",
+		PerPage:     "perPage",
+		Total:       "total",
+	}
+}
+
+// PageMetaOption configures PageMeta's behavior.
+type PageMetaOption func(*PageMetaKeys)
+
+// WithPageMetaKeys overrides the meta keys PageMeta looks for. Any field
+// left as "" in keys keeps its default.
+func WithPageMetaKeys(keys PageMetaKeys) PageMetaOption {
+	return func(k *PageMetaKeys) {
+		if keys.CurrentPage != "" {
+			k.CurrentPage = keys.CurrentPage
+		}
+		if keys.TotalPages != "" {
+			k.TotalPages = keys.TotalPages
+		}
+		if keys.PerPage != "" {
+			k.PerPage = keys.PerPage
+		}
+		if keys.Total != "" {
+			k.Total = keys.Total
+		}
+	}
+}
+
+// PageMeta extracts pagination info from d.Meta into a typed PageInfo,
+// using the common "currentPage"/"totalPages"/"perPage"/"total" key names
+// by default; pass WithPageMetaKeys to match a server's own convention. It
+// returns false if none of the recognized keys are present in d.Meta.
+func (d *Document) PageMeta(opts ...PageMetaOption) (PageInfo, bool) {
+	keys := defaultPageMetaKeys()
+	for _, opt := range opts {
+		opt(&keys)
+	}
+
+	var info PageInfo
+	found := false
+
+	if v, ok := metaInt(d.Meta, keys.CurrentPage); ok {
+		info.CurrentPage = v
+		found = true
+	}
+	if v, ok := metaInt(d.Meta, keys.TotalPages); ok {
+		info.TotalPages = v
+		found = true
+	}
+	if v, ok := metaInt(d.Meta, keys.PerPage); ok {
+		info.PerPage = v
+		found = true
+	}
+	if v, ok := metaInt(d.Meta, keys.Total); ok {
+		info.Total = v
+		found = true
+	}
+
+	return info, found
+}
+
+// metaInt reads key from m as an int, accepting the float64 a Document's
+// Meta holds after a JSON round trip as well as an int set directly by
+// code constructing the Document in-process.
+func metaInt(m map[string]interface{}, key string) (int, bool) {
+	v, ok := m[key]
+	if !ok {
+		return 0, false
+	}
+
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,1209 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseQueryPageStrategyIsDeterministic(t *testing.T) {
+	q := url.Values{
+		"page[number]": {"3"},
+		"page[size]":   {"10"},
+		"page[after]":  {"cursor-abc"},
+	}
+
+	for i := 0; i < 20; i++ {
+		query := ParseQuery(q)
+		if query.Page.Strategy != PageStrategyCursor {
+			t.Fatalf("iteration %d: expected cursor strategy to win when mixed with number/size, got %v", i, query.Page.Strategy)
+		}
+		if query.Page.After != "cursor-abc" {
+			t.Fatalf("iteration %d: expected after=cursor-abc, got %q", i, query.Page.After)
+		}
+	}
+}
+
+func TestParseQueryFieldsReusesFilterFields(t *testing.T) {
+	q := url.Values{"fields[articles]": {"title,body"}}
+
+	query := ParseQuery(q)
+
+	want := []string{"title", "body"}
+	got := query.Fields["articles"]
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseQuerySortDescendingAndDedup(t *testing.T) {
+	q := url.Values{"sort": {"-created,title,-created"}}
+
+	query := ParseQuery(q)
+
+	want := []SortField{{Field: "created", Desc: true}, {Field: "title", Desc: false}}
+	if len(query.Sort) != len(want) {
+		t.Fatalf("got %+v, want %+v", query.Sort, want)
+	}
+	for i := range want {
+		if query.Sort[i] != want[i] {
+			t.Fatalf("got %+v, want %+v", query.Sort, want)
+		}
+	}
+}
+
+func TestFilterFieldsParseQueryTrimsWhitespaceAndDropsEmpty(t *testing.T) {
+	f := FilterFields{}
+	f.ParseQuery(url.Values{"fields[articles]": {"title, body,"}})
+
+	want := []string{"title", "body"}
+	got := f["articles"]
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFilterFieldsValidateFields(t *testing.T) {
+	q := url.Values{"fields[articles]": {"title"}, "fields[bogus]": {"x"}}
+	query := ParseQuery(q)
+
+	if err := query.Fields.ValidateFields("articles", "people"); err == nil {
+		t.Fatal("expected an error for the unknown bogus type")
+	}
+
+	q2 := url.Values{"fields[articles]": {"title"}}
+	query2 := ParseQuery(q2)
+	if err := query2.Fields.ValidateFields("articles", "people"); err != nil {
+		t.Fatalf("expected no error for known types, got %v", err)
+	}
+}
+
+func TestFilterFieldsColumnsResolvesKnownFieldsAndAlwaysIncludesID(t *testing.T) {
+	f := FilterFields{"articles": {"title", "body"}}
+	mapping := map[string]string{"title": "title", "body": "body_text"}
+
+	got := f.Columns("articles", mapping)
+	want := []string{"id", "title", "body_text"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFilterFieldsColumnsDropsFieldsNotInMapping(t *testing.T) {
+	f := FilterFields{"articles": {"title", "secret"}}
+	mapping := map[string]string{"title": "title"}
+
+	got := f.Columns("articles", mapping)
+	want := []string{"id", "title"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFilterFieldsColumnsWithNoRequestedFieldsReturnsJustID(t *testing.T) {
+	f := FilterFields{}
+	got := f.Columns("articles", map[string]string{"title": "title"})
+	if len(got) != 1 || got[0] != "id" {
+		t.Fatalf("got %v, want [id]", got)
+	}
+}
+
+func TestFilterFieldsIntersectKeepsOnlyFieldsAllowedByBoth(t *testing.T) {
+	policy := FilterFields{"articles": {"title", "body", "secret"}}
+	requested := FilterFields{"articles": {"title", "secret", "views"}}
+
+	got := policy.Intersect(requested)
+	want := []string{"title", "secret"}
+	if !reflect.DeepEqual(got["articles"], want) {
+		t.Fatalf("got %v, want %v", got["articles"], want)
+	}
+}
+
+func TestFilterFieldsIntersectOnDisjointSetsYieldsEmpty(t *testing.T) {
+	policy := FilterFields{"articles": {"title"}}
+	requested := FilterFields{"articles": {"body"}}
+
+	got := policy.Intersect(requested)
+	if len(got["articles"]) != 0 {
+		t.Fatalf("expected no overlap, got %v", got["articles"])
+	}
+}
+
+func TestFilterFieldsIntersectTreatsMissingTypeAsUnrestricted(t *testing.T) {
+	policy := FilterFields{"articles": {"title", "body"}}
+	requested := FilterFields{}
+
+	got := policy.Intersect(requested)
+	want := []string{"title", "body"}
+	if !reflect.DeepEqual(got["articles"], want) {
+		t.Fatalf("got %v, want %v", got["articles"], want)
+	}
+}
+
+func TestFilterFieldsUnionMergesOverlappingAllowedFields(t *testing.T) {
+	a := FilterFields{"articles": {"title", "body"}}
+	b := FilterFields{"articles": {"body", "secret"}}
+
+	got := a.Union(b)
+	want := []string{"title", "body", "secret"}
+	if !reflect.DeepEqual(got["articles"], want) {
+		t.Fatalf("got %v, want %v", got["articles"], want)
+	}
+}
+
+func TestFilterFieldsUnionTreatsMissingTypeAsUnrestricted(t *testing.T) {
+	a := FilterFields{"articles": {"title"}}
+	b := FilterFields{}
+
+	got := a.Union(b)
+	if _, ok := got["articles"]; ok {
+		t.Fatalf("expected articles to be unrestricted in the union, got %v", got["articles"])
+	}
+}
+
+func TestSplitCSVTreatsCommaAndRepeatedIdentically(t *testing.T) {
+	comma := splitCSV(url.Values{"key": {"a,b,c"}}, "key")
+	repeated := splitCSV(url.Values{"key": {"a", "b", "c"}}, "key")
+
+	want := []string{"a", "b", "c"}
+	for _, got := range [][]string{comma, repeated} {
+		if len(got) != len(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestSplitCSVTrimsWhitespaceAndDropsEmpty(t *testing.T) {
+	got := splitCSV(url.Values{"key": {"a, b,,c "}}, "key")
+	want := []string{"a", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSplitCSVOnMissingKeyReturnsNil(t *testing.T) {
+	if got := splitCSV(url.Values{}, "key"); got != nil {
+		t.Fatalf("expected nil, got %v", got)
+	}
+}
+
+func TestParseQueryIncludeCommaAndRepeatedAreEquivalent(t *testing.T) {
+	comma := ParseQuery(url.Values{"include": {"author,comments"}})
+	repeated := ParseQuery(url.Values{"include": {"author", "comments"}})
+
+	for _, name := range []string{"author", "comments"} {
+		if comma.Include[name] == nil {
+			t.Fatalf("comma-separated include missing %q: %+v", name, comma.Include)
+		}
+		if repeated.Include[name] == nil {
+			t.Fatalf("repeated include missing %q: %+v", name, repeated.Include)
+		}
+	}
+}
+
+func TestParseQuerySortCommaAndRepeatedAreEquivalent(t *testing.T) {
+	comma := ParseQuery(url.Values{"sort": {"-created,title"}})
+	repeated := ParseQuery(url.Values{"sort": {"-created", "title"}})
+
+	want := []SortField{{Field: "created", Desc: true}, {Field: "title", Desc: false}}
+	for _, got := range [][]SortField{comma.Sort, repeated.Sort} {
+		if len(got) != len(want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		}
+	}
+}
+
+func TestFilterFieldsParseQueryCommaAndRepeatedAreEquivalent(t *testing.T) {
+	comma := FilterFields{}
+	comma.ParseQuery(url.Values{"fields[articles]": {"title,body"}})
+
+	repeated := FilterFields{}
+	repeated.ParseQuery(url.Values{"fields[articles]": {"title", "body"}})
+
+	want := []string{"title", "body"}
+	for _, got := range [][]string{comma["articles"], repeated["articles"]} {
+		if len(got) != len(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestFilterParamsParseQueryCommaAndRepeated(t *testing.T) {
+	q := url.Values{
+		"filter[status]": {"open,pending"},
+		"filter[tag]":    {"urgent", "bug"},
+	}
+
+	f := FilterParams{}
+	f.ParseQuery(q)
+
+	want := []string{"open", "pending"}
+	got := f["status"]
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	wantTags := []string{"urgent", "bug"}
+	gotTags := f["tag"]
+	if len(gotTags) != len(wantTags) {
+		t.Fatalf("got %v, want %v", gotTags, wantTags)
+	}
+	for i := range wantTags {
+		if gotTags[i] != wantTags[i] {
+			t.Fatalf("got %v, want %v", gotTags, wantTags)
+		}
+	}
+}
+
+func TestParseQueryPopulatesFilter(t *testing.T) {
+	q := url.Values{"filter[status]": {"open,pending"}}
+
+	query := ParseQuery(q)
+
+	if len(query.Filter["status"]) != 2 {
+		t.Fatalf("expected ParseQuery to populate Filter, got %+v", query.Filter)
+	}
+}
+
+func TestParseQueryIncludeTree(t *testing.T) {
+	q := url.Values{"include": {"author,comments.user"}}
+
+	query := ParseQuery(q)
+
+	if _, ok := query.Include["author"]; !ok {
+		t.Fatal("expected author in the include tree")
+	}
+	comments, ok := query.Include["comments"]
+	if !ok {
+		t.Fatal("expected comments in the include tree")
+	}
+	if _, ok := comments["user"]; !ok {
+		t.Fatalf("expected comments.user nested in the tree, got %+v", comments)
+	}
+}
+
+func TestIncludeTreeMaxDepth(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"empty", "", 0},
+		{"single level", "author", 1},
+		{"two levels", "comments.user", 2},
+		{"deepest branch wins", "author,comments.user.avatar", 3},
+	}
+
+	for _, c := range cases {
+		tree := ParseQuery(url.Values{"include": {c.query}}).Include
+		if got := tree.MaxDepth(); got != c.want {
+			t.Errorf("%s: MaxDepth() = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestValidateIncludeDepthRejectsTooDeepInclude(t *testing.T) {
+	tree := ParseQuery(url.Values{"include": {"a.b.c.d.e"}}).Include
+
+	if err := ValidateIncludeDepth(tree, 3); err == nil {
+		t.Fatal("expected an error for an include deeper than the configured max")
+	}
+	if err := ValidateIncludeDepth(tree, 5); err != nil {
+		t.Fatalf("expected no error within the configured max, got %v", err)
+	}
+}
+
+func TestIncludedTypesResolvesNestedIncludePaths(t *testing.T) {
+	tree := ParseQuery(url.Values{"include": {"author,comments.author"}}).Include
+
+	schema := RelationshipTypeSchema{
+		"articles": {"author": "people", "comments": "comments"},
+		"comments": {"author": "people"},
+	}
+
+	types := IncludedTypes(tree, schema, "articles")
+
+	got := map[string]bool{}
+	for _, typ := range types {
+		got[typ] = true
+	}
+	if len(got) != 2 || !got["people"] || !got["comments"] {
+		t.Fatalf("expected exactly [people comments], got %v", types)
+	}
+}
+
+func TestIncludedTypesIgnoresPathsWithoutASchemaEntry(t *testing.T) {
+	tree := ParseQuery(url.Values{"include": {"unknown.nested"}}).Include
+
+	schema := RelationshipTypeSchema{
+		"articles": {"author": "people"},
+	}
+
+	types := IncludedTypes(tree, schema, "articles")
+
+	if len(types) != 0 {
+		t.Fatalf("expected no types for an unrecognized include path, got %v", types)
+	}
+}
+
+func TestIncludedTypesStopsAtUnknownResourceType(t *testing.T) {
+	types := IncludedTypes(IncludeTree{"author": {}}, RelationshipTypeSchema{}, "articles")
+
+	if len(types) != 0 {
+		t.Fatalf("expected no types when rootType has no schema entry, got %v", types)
+	}
+}
+
+func TestCheckIncludesReportsMissingInclude(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}},
+			},
+		}},
+	}
+
+	missing := CheckIncludes(doc, IncludeTree{"author": {}})
+
+	if len(missing) != 1 || missing[0] != "author" {
+		t.Fatalf("expected [author] missing, got %v", missing)
+	}
+}
+
+func TestCheckIncludesReportsNothingWhenSideloaded(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}},
+			},
+		}},
+		Included: []Data{{Type: "people", ID: "1"}},
+	}
+
+	if missing := CheckIncludes(doc, IncludeTree{"author": {}}); len(missing) != 0 {
+		t.Fatalf("expected no missing includes, got %v", missing)
+	}
+}
+
+func TestCheckIncludesIgnoresRelationshipWithoutLinkage(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{"author": {}},
+		}},
+	}
+
+	if missing := CheckIncludes(doc, IncludeTree{"author": {}}); len(missing) != 0 {
+		t.Fatalf("expected no missing includes for an unlinked relationship, got %v", missing)
+	}
+}
+
+func TestCheckIncludesReportsNestedPathAndStopsDeeperWalkWhenParentMissing(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1",
+			Relationships: map[string]Relationship{
+				"comments": {Data: &RelationshipDataContainer{DataArray: []RelationshipData{{Type: "comments", ID: "1"}}}},
+			},
+		}},
+	}
+
+	missing := CheckIncludes(doc, IncludeTree{"comments": {"author": {}}})
+
+	if len(missing) != 1 || missing[0] != "comments" {
+		t.Fatalf("expected only [comments] missing, got %v", missing)
+	}
+}
+
+func TestCheckIncludesWalksCollectionPrimaryData(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "articles", ID: "1", Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "1"}}},
+			}},
+			{Type: "articles", ID: "2", Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{DataObject: &RelationshipData{Type: "people", ID: "2"}}},
+			}},
+		}},
+		Included: []Data{{Type: "people", ID: "1"}},
+	}
+
+	missing := CheckIncludes(doc, IncludeTree{"author": {}})
+
+	if len(missing) != 1 || missing[0] != "author" {
+		t.Fatalf("expected [author] missing once despite two articles, got %v", missing)
+	}
+}
+
+func TestParseQueryCursorPageStrategy(t *testing.T) {
+	q := url.Values{"page[after]": {"abc"}, "page[limit]": {"5"}}
+
+	query := ParseQuery(q)
+
+	if query.Page.Strategy != PageStrategyCursor {
+		t.Fatalf("expected PageStrategyCursor, got %v", query.Page.Strategy)
+	}
+	if query.Page.After != "abc" || query.Page.Limit != 5 || query.Page.Before != "" {
+		t.Fatalf("unexpected page: %+v", query.Page)
+	}
+}
+
+func TestParseQueryNumberPageStrategy(t *testing.T) {
+	q := url.Values{"page[number]": {"3"}, "page[size]": {"10"}}
+
+	query := ParseQuery(q)
+
+	if query.Page.Strategy != PageStrategyNumber {
+		t.Fatalf("expected PageStrategyNumber, got %v", query.Page.Strategy)
+	}
+	if query.Page.Number != 3 || query.Page.Size != 10 {
+		t.Fatalf("unexpected page: %+v", query.Page)
+	}
+}
+
+func TestBuildLinksNumberPaginationZeroTotal(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/articles")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	query := ParseQuery(url.Values{"page[number]": {"1"}, "page[size]": {"10"}})
+	links := query.BuildLinks(base, 0)
+
+	if got := pageNumber(t, links.First); got != 1 {
+		t.Fatalf("expected first page 1 for an empty result set, got %d", got)
+	}
+	if got := pageNumber(t, links.Last); got != 1 {
+		t.Fatalf("expected last page 1 for an empty result set, got %d", got)
+	}
+	if links.Next != "" || links.Previous != "" {
+		t.Fatalf("expected no prev/next for a single empty page, got %+v", links)
+	}
+}
+
+func TestBuildLinksCursorPagination(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/articles")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	query := ParseQuery(url.Values{
+		"page[after]": {"abc"},
+		"page[limit]": {"5"},
+	})
+
+	links := query.BuildLinks(base, 0)
+
+	if links.Self != base.String() {
+		t.Fatalf("unexpected self link: %q", links.Self)
+	}
+	if links.Next == "" {
+		t.Fatal("expected a next link for cursor pagination with an after cursor")
+	}
+
+	nextURL, err := url.Parse(links.Next)
+	if err != nil {
+		t.Fatalf("url.Parse(next): %v", err)
+	}
+	if got := nextURL.Query().Get("page[after]"); got != "abc" {
+		t.Fatalf("expected next link to carry page[after]=abc, got %q", got)
+	}
+	if got := nextURL.Query().Get("page[limit]"); got != "5" {
+		t.Fatalf("expected next link to carry page[limit]=5, got %q", got)
+	}
+	if links.Previous != "" {
+		t.Fatal("expected no prev link when no before cursor was supplied")
+	}
+}
+
+func TestBuildLinksNumberPagination(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/articles")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	const total = 25
+	const size = 10
+
+	cases := []struct {
+		name      string
+		number    int
+		wantFirst int
+		wantLast  int
+		wantPrev  int
+		wantNext  int
+		hasPrev   bool
+		hasNext   bool
+	}{
+		{name: "first page", number: 1, wantFirst: 1, wantLast: 3, hasPrev: false, wantNext: 2, hasNext: true},
+		{name: "middle page", number: 2, wantFirst: 1, wantLast: 3, wantPrev: 1, hasPrev: true, wantNext: 3, hasNext: true},
+		{name: "last page", number: 3, wantFirst: 1, wantLast: 3, wantPrev: 2, hasPrev: true, hasNext: false},
+	}
+
+	for _, c := range cases {
+		query := ParseQuery(url.Values{
+			"page[number]": {strconv.Itoa(c.number)},
+			"page[size]":   {strconv.Itoa(size)},
+		})
+
+		links := query.BuildLinks(base, total)
+
+		if links.Self != base.String() {
+			t.Fatalf("%s: unexpected self link: %q", c.name, links.Self)
+		}
+		if got := pageNumber(t, links.First); got != c.wantFirst {
+			t.Fatalf("%s: expected first page %d, got %d", c.name, c.wantFirst, got)
+		}
+		if got := pageNumber(t, links.Last); got != c.wantLast {
+			t.Fatalf("%s: expected last page %d, got %d", c.name, c.wantLast, got)
+		}
+
+		if c.hasPrev {
+			if got := pageNumber(t, links.Previous); got != c.wantPrev {
+				t.Fatalf("%s: expected prev page %d, got %d", c.name, c.wantPrev, got)
+			}
+		} else if links.Previous != "" {
+			t.Fatalf("%s: expected no prev link, got %q", c.name, links.Previous)
+		}
+
+		if c.hasNext {
+			if got := pageNumber(t, links.Next); got != c.wantNext {
+				t.Fatalf("%s: expected next page %d, got %d", c.name, c.wantNext, got)
+			}
+		} else if links.Next != "" {
+			t.Fatalf("%s: expected no next link, got %q", c.name, links.Next)
+		}
+	}
+}
+
+func TestBuildRelationshipLinksNumberPagination(t *testing.T) {
+	links, err := BuildRelationshipLinks(
+		"https://api.example.com/articles/1/comments",
+		Page{Strategy: PageStrategyNumber, Number: 2, Size: 10},
+		25,
+	)
+	if err != nil {
+		t.Fatalf("BuildRelationshipLinks returned error: %v", err)
+	}
+
+	if got := pageNumber(t, links.First); got != 1 {
+		t.Fatalf("expected first page 1, got %d", got)
+	}
+	if got := pageNumber(t, links.Last); got != 3 {
+		t.Fatalf("expected last page 3, got %d", got)
+	}
+	if got := pageNumber(t, links.Previous); got != 1 {
+		t.Fatalf("expected prev page 1, got %d", got)
+	}
+	if got := pageNumber(t, links.Next); got != 3 {
+		t.Fatalf("expected next page 3, got %d", got)
+	}
+}
+
+func TestBuildRelationshipLinksRejectsInvalidURL(t *testing.T) {
+	if _, err := BuildRelationshipLinks("://not-a-url", Page{}, 0); err == nil {
+		t.Fatal("expected an error for an invalid self URL")
+	}
+}
+
+func TestBuildLinksUnknownTotalOmitsFirstAndLast(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/articles")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	query := ParseQuery(url.Values{"page[number]": {"2"}, "page[size]": {"10"}})
+	links := query.BuildLinks(base, UnknownTotal)
+
+	if links.First != "" || links.Last != "" {
+		t.Fatalf("expected no first/last for an unknown total, got %+v", links)
+	}
+	if got := pageNumber(t, links.Previous); got != 1 {
+		t.Fatalf("expected prev page 1, got %d", got)
+	}
+}
+
+func TestBuildLinksUnknownTotalEmitsNextOnFullPage(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/articles")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	query := ParseQuery(url.Values{"page[number]": {"1"}, "page[size]": {"10"}})
+	query.Page.ResultCount = 10
+
+	links := query.BuildLinks(base, UnknownTotal)
+
+	if got := pageNumber(t, links.Next); got != 2 {
+		t.Fatalf("expected next page 2 for a full page, got %d", got)
+	}
+}
+
+func TestBuildLinksUnknownTotalOmitsNextOnShortPage(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/articles")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	query := ParseQuery(url.Values{"page[number]": {"1"}, "page[size]": {"10"}})
+	query.Page.ResultCount = 4
+
+	links := query.BuildLinks(base, UnknownTotal)
+
+	if links.Next != "" {
+		t.Fatalf("expected no next link for a short page, got %q", links.Next)
+	}
+}
+
+func TestSortFieldsCompareStringsAndMissingSortsLast(t *testing.T) {
+	s := SortFields{{Field: "name"}}
+
+	if c := s.Compare(map[string]interface{}{"name": "alice"}, map[string]interface{}{"name": "bob"}); c >= 0 {
+		t.Fatalf("expected alice < bob, got %d", c)
+	}
+	if c := s.Compare(map[string]interface{}{}, map[string]interface{}{"name": "bob"}); c <= 0 {
+		t.Fatalf("expected a missing field to sort last, got %d", c)
+	}
+}
+
+func TestSortFieldsCompareDescending(t *testing.T) {
+	s := SortFields{{Field: "score", Desc: true}}
+
+	if c := s.Compare(map[string]interface{}{"score": float64(5)}, map[string]interface{}{"score": float64(3)}); c >= 0 {
+		t.Fatalf("expected descending 5 < 3, got %d", c)
+	}
+}
+
+func TestSortFieldsCompareTieBreaksOnSecondField(t *testing.T) {
+	s := SortFields{{Field: "group"}, {Field: "name"}}
+
+	a := map[string]interface{}{"group": "x", "name": "b"}
+	b := map[string]interface{}{"group": "x", "name": "a"}
+	if c := s.Compare(a, b); c <= 0 {
+		t.Fatalf("expected a to sort after b on the tie-break field, got %d", c)
+	}
+}
+
+func TestSortFieldsSQLRendersOrderByColumnsThroughAllowlist(t *testing.T) {
+	s := SortFields{{Field: "name"}, {Field: "score", Desc: true}}
+	mapping := map[string]string{"name": "users.name", "score": "users.score"}
+
+	got, err := s.SQL(mapping)
+	if err != nil {
+		t.Fatalf("SQL returned error: %v", err)
+	}
+	if want := "users.name ASC, users.score DESC"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSortFieldsSQLRejectsFieldNotInMapping(t *testing.T) {
+	s := SortFields{{Field: "password"}}
+
+	if _, err := s.SQL(map[string]string{"name": "users.name"}); err == nil {
+		t.Fatal("expected an error for a sort field not in the allowlist mapping")
+	}
+}
+
+func TestSortFieldsSQLOnEmptyFieldsReturnsEmptyString(t *testing.T) {
+	var s SortFields
+
+	got, err := s.SQL(map[string]string{"name": "users.name"})
+	if err != nil {
+		t.Fatalf("SQL returned error: %v", err)
+	}
+	if got != "" {
+		t.Fatalf("expected an empty clause, got %q", got)
+	}
+}
+
+func TestSortDataOrdersResourcesByAttributes(t *testing.T) {
+	data := []Data{
+		{Type: "people", ID: "1", Attributes: json.RawMessage(`{"name":"Charlie"}`)},
+		{Type: "people", ID: "2", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+		{Type: "people", ID: "3", Attributes: json.RawMessage(`{"name":"Bob"}`)},
+	}
+
+	SortData(data, SortFields{{Field: "name"}})
+
+	want := []string{"2", "3", "1"}
+	for i, id := range want {
+		if data[i].ID != id {
+			t.Fatalf("unexpected order: %+v", data)
+		}
+	}
+}
+
+func TestValidateQueryParamsExemptsReservedParams(t *testing.T) {
+	q := url.Values{
+		"include":          {"author"},
+		"fields[articles]": {"title"},
+		"page[number]":     {"1"},
+		"filter[status]":   {"open"},
+		"sort":             {"title"},
+	}
+
+	if err := ValidateQueryParams(q); err != nil {
+		t.Fatalf("expected no error for reserved params, got %v", err)
+	}
+}
+
+func TestValidateQueryParamsFlagsLowercaseOnlyNames(t *testing.T) {
+	q := url.Values{"foo": {"1"}, "bar": {"2"}}
+
+	err := ValidateQueryParams(q)
+	if err == nil {
+		t.Fatal("expected an error for implementation params with only lowercase letters")
+	}
+	if !strings.Contains(err.Error(), "bar") || !strings.Contains(err.Error(), "foo") {
+		t.Fatalf("expected the error to name both offending params, got %v", err)
+	}
+}
+
+func TestValidateQueryParamsAllowsNonLowercaseCustomNames(t *testing.T) {
+	q := url.Values{"customParam": {"1"}, "custom-param": {"2"}}
+
+	if err := ValidateQueryParams(q); err != nil {
+		t.Fatalf("expected no error for conformant custom params, got %v", err)
+	}
+}
+
+func pageNumber(t *testing.T, rawURL string) int {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", rawURL, err)
+	}
+	n, err := strconv.Atoi(u.Query().Get("page[number]"))
+	if err != nil {
+		t.Fatalf("page[number] in %q: %v", rawURL, err)
+	}
+	return n
+}
+
+func TestDocumentPageMetaWithDefaultKeys(t *testing.T) {
+	doc := &Document{Meta: map[string]interface{}{
+		"currentPage": float64(2),
+		"totalPages":  float64(5),
+		"perPage":     float64(10),
+		"total":       float64(42),
+	}}
+
+	info, ok := doc.PageMeta()
+	if !ok {
+		t.Fatal("expected PageMeta to report the meta as recognized")
+	}
+	want := PageInfo{CurrentPage: 2, TotalPages: 5, PerPage: 10, Total: 42}
+	if info != want {
+		t.Fatalf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestDocumentPageMetaWithCustomKeys(t *testing.T) {
+	doc := &Document{Meta: map[string]interface{}{
+		"page":       float64(2),
+		"page-count": float64(5),
+	}}
+
+	info, ok := doc.PageMeta(WithPageMetaKeys(PageMetaKeys{CurrentPage: "page", TotalPages: "page-count"}))
+	if !ok {
+		t.Fatal("expected PageMeta to report the meta as recognized")
+	}
+	if info.CurrentPage != 2 || info.TotalPages != 5 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestDocumentPageMetaReturnsFalseWhenNoRecognizedKeysPresent(t *testing.T) {
+	doc := &Document{Meta: map[string]interface{}{"unrelated": "value"}}
+
+	info, ok := doc.PageMeta()
+	if ok {
+		t.Fatalf("expected PageMeta to report no recognized keys, got %+v", info)
+	}
+}
+
+func TestDocumentPageMetaWithNilMetaReturnsFalse(t *testing.T) {
+	doc := &Document{}
+
+	if _, ok := doc.PageMeta(); ok {
+		t.Fatal("expected PageMeta to report false for a nil Meta")
+	}
+}
+
+func paginateTestData(n int) []Data {
+	data := make([]Data, n)
+	for i := range data {
+		data[i] = Data{Type: "articles", ID: strconv.Itoa(i + 1)}
+	}
+	return data
+}
+
+func TestPaginateByPageNumberAndSize(t *testing.T) {
+	page, links := Paginate(paginateTestData(25), Pagination{Number: 2, Size: 10})
+
+	if len(page) != 10 || page[0].ID != "11" || page[9].ID != "20" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if pageNumber(t, links.First) != 1 || pageNumber(t, links.Last) != 3 {
+		t.Fatalf("unexpected first/last links: %+v", links)
+	}
+	if pageNumber(t, links.Previous) != 1 || pageNumber(t, links.Next) != 3 {
+		t.Fatalf("unexpected prev/next links: %+v", links)
+	}
+}
+
+func TestPaginateByPageOutOfRangeReturnsEmptySliceWithBoundaryLinks(t *testing.T) {
+	page, links := Paginate(paginateTestData(25), Pagination{Number: 10, Size: 10})
+
+	if len(page) != 0 {
+		t.Fatalf("expected an empty page, got %+v", page)
+	}
+	if pageNumber(t, links.First) != 1 || pageNumber(t, links.Last) != 3 {
+		t.Fatalf("unexpected first/last links: %+v", links)
+	}
+}
+
+func TestPaginateByPageDefaultsNumberToOne(t *testing.T) {
+	page, _ := Paginate(paginateTestData(5), Pagination{Size: 2})
+
+	if len(page) != 2 || page[0].ID != "1" || page[1].ID != "2" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+}
+
+func TestPaginateByOffsetAndLimit(t *testing.T) {
+	page, links := Paginate(paginateTestData(25), Pagination{Offset: 10, Limit: 10})
+
+	if len(page) != 10 || page[0].ID != "11" || page[9].ID != "20" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	first, err := url.Parse(links.First)
+	if err != nil {
+		t.Fatalf("url.Parse(first): %v", err)
+	}
+	if first.Query().Get("page[offset]") != "0" {
+		t.Fatalf("unexpected first link: %q", links.First)
+	}
+
+	prev, err := url.Parse(links.Previous)
+	if err != nil {
+		t.Fatalf("url.Parse(prev): %v", err)
+	}
+	if prev.Query().Get("page[offset]") != "0" {
+		t.Fatalf("unexpected previous link: %q", links.Previous)
+	}
+
+	next, err := url.Parse(links.Next)
+	if err != nil {
+		t.Fatalf("url.Parse(next): %v", err)
+	}
+	if next.Query().Get("page[offset]") != "20" {
+		t.Fatalf("unexpected next link: %q", links.Next)
+	}
+
+	last, err := url.Parse(links.Last)
+	if err != nil {
+		t.Fatalf("url.Parse(last): %v", err)
+	}
+	if last.Query().Get("page[offset]") != "20" {
+		t.Fatalf("unexpected last link: %q", links.Last)
+	}
+}
+
+func TestPaginateByOffsetPastEndReturnsEmptySlice(t *testing.T) {
+	page, links := Paginate(paginateTestData(5), Pagination{Offset: 100, Limit: 10})
+
+	if len(page) != 0 {
+		t.Fatalf("expected an empty page, got %+v", page)
+	}
+	if links.First == "" {
+		t.Fatalf("expected a first link even for an out-of-range offset, got %+v", links)
+	}
+}
+
+func TestPaginateByOffsetWithoutLimitReturnsRestOfCollection(t *testing.T) {
+	page, _ := Paginate(paginateTestData(5), Pagination{Offset: 2})
+
+	if len(page) != 3 || page[0].ID != "3" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+}
+
+func TestWriteLinkHeaderNumberPagination(t *testing.T) {
+	rec := httptest.NewRecorder()
+	p := Pagination{Number: 2, Size: 10}
+	if err := p.WriteLinkHeader(rec, "https://api.example.com/articles", 25); err != nil {
+		t.Fatalf("WriteLinkHeader returned error: %v", err)
+	}
+
+	header := rec.Header().Get("Link")
+	for _, want := range []string{`rel="first"`, `rel="prev"`, `rel="next"`, `rel="last"`} {
+		if !strings.Contains(header, want) {
+			t.Fatalf("expected header to contain %s, got %q", want, header)
+		}
+	}
+	if !strings.Contains(header, "page%5Bnumber%5D=1") && !strings.Contains(header, "page[number]=1") {
+		t.Fatalf("expected first link to point at page 1, got %q", header)
+	}
+}
+
+func TestWriteLinkHeaderOmitsRelsAtBoundaries(t *testing.T) {
+	rec := httptest.NewRecorder()
+	p := Pagination{Number: 1, Size: 10}
+	if err := p.WriteLinkHeader(rec, "https://api.example.com/articles", 10); err != nil {
+		t.Fatalf("WriteLinkHeader returned error: %v", err)
+	}
+
+	header := rec.Header().Get("Link")
+	if strings.Contains(header, `rel="prev"`) || strings.Contains(header, `rel="next"`) {
+		t.Fatalf("expected prev/next to be omitted on the only page, got %q", header)
+	}
+	if !strings.Contains(header, `rel="first"`) || !strings.Contains(header, `rel="last"`) {
+		t.Fatalf("expected first/last to be present, got %q", header)
+	}
+}
+
+func TestWriteLinkHeaderUnknownTotalOmitsFirstAndLast(t *testing.T) {
+	rec := httptest.NewRecorder()
+	p := Pagination{Offset: 0, Limit: 10}
+	if err := p.WriteLinkHeader(rec, "https://api.example.com/articles", UnknownTotal); err != nil {
+		t.Fatalf("WriteLinkHeader returned error: %v", err)
+	}
+
+	header := rec.Header().Get("Link")
+	if strings.Contains(header, `rel="first"`) || strings.Contains(header, `rel="last"`) {
+		t.Fatalf("expected first/last to be omitted for an unknown total, got %q", header)
+	}
+}
+
+func TestWriteLinkHeaderOffsetPagination(t *testing.T) {
+	rec := httptest.NewRecorder()
+	p := Pagination{Offset: 10, Limit: 10}
+	if err := p.WriteLinkHeader(rec, "https://api.example.com/articles", 25); err != nil {
+		t.Fatalf("WriteLinkHeader returned error: %v", err)
+	}
+
+	header := rec.Header().Get("Link")
+	for _, want := range []string{`rel="first"`, `rel="prev"`, `rel="next"`, `rel="last"`} {
+		if !strings.Contains(header, want) {
+			t.Fatalf("expected header to contain %s, got %q", want, header)
+		}
+	}
+}
+
+func TestPaginateEmptyCollection(t *testing.T) {
+	page, links := Paginate(nil, Pagination{Number: 1, Size: 10})
+
+	if len(page) != 0 {
+		t.Fatalf("expected an empty page, got %+v", page)
+	}
+	if pageNumber(t, links.First) != 1 || pageNumber(t, links.Last) != 1 {
+		t.Fatalf("unexpected first/last links: %+v", links)
+	}
+}
+
+func TestParsePaginationMixingNumberAndOffsetReturnsError(t *testing.T) {
+	_, err := ParsePagination(url.Values{
+		"page[number]": {"1"},
+		"page[offset]": {"10"},
+	})
+	if err == nil {
+		t.Fatal("expected an error mixing page[number] and page[offset]")
+	}
+	if sc, ok := err.(StatusCoder); !ok || sc.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("expected a StatusCoder returning 400, got %v", err)
+	}
+}
+
+func TestParsePaginationRejectsNonPositiveSize(t *testing.T) {
+	if _, err := ParsePagination(url.Values{"page[size]": {"0"}}); err == nil {
+		t.Fatal("expected an error for page[size]=0")
+	}
+	if _, err := ParsePagination(url.Values{"page[size]": {"-5"}}); err == nil {
+		t.Fatal("expected an error for a negative page[size]")
+	}
+}
+
+func TestParsePaginationRejectsNonPositiveLimit(t *testing.T) {
+	if _, err := ParsePagination(url.Values{"page[limit]": {"0"}}); err == nil {
+		t.Fatal("expected an error for page[limit]=0")
+	}
+}
+
+func TestParsePaginationAcceptsCleanNumberSizeRequest(t *testing.T) {
+	p, err := ParsePagination(url.Values{
+		"page[number]": {"2"},
+		"page[size]":   {"10"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p != (Pagination{Number: 2, Size: 10}) {
+		t.Fatalf("unexpected pagination: %+v", p)
+	}
+}
+
+func TestParsePaginationAcceptsCleanOffsetLimitRequest(t *testing.T) {
+	p, err := ParsePagination(url.Values{
+		"page[offset]": {"20"},
+		"page[limit]":  {"10"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p != (Pagination{Offset: 20, Limit: 10}) {
+		t.Fatalf("unexpected pagination: %+v", p)
+	}
+}
+
+func TestParseAndValidateQueryExercisesEveryParser(t *testing.T) {
+	q := url.Values{
+		"fields[articles]": {"title,body"},
+		"sort":             {"-created,title"},
+		"include":          {"author,comments.user"},
+		"filter[status]":   {"published"},
+		"page[offset]":     {"20"},
+		"page[limit]":      {"10"},
+	}
+
+	params, err := ParseAndValidateQuery(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := params.Fields["articles"], []string{"title", "body"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields[articles] = %v, want %v", got, want)
+	}
+	wantSort := []SortField{{Field: "created", Desc: true}, {Field: "title"}}
+	if !reflect.DeepEqual(params.SortFields, SortFields(wantSort)) {
+		t.Errorf("SortFields = %v, want %v", params.SortFields, wantSort)
+	}
+	wantInclude := IncludeTree{"author": {}, "comments": {"user": {}}}
+	if !reflect.DeepEqual(params.IncludePaths, wantInclude) {
+		t.Errorf("IncludePaths = %v, want %v", params.IncludePaths, wantInclude)
+	}
+	if got, want := params.Filter["status"], []string{"published"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter[status] = %v, want %v", got, want)
+	}
+	if params.Pagination != (Pagination{Offset: 20, Limit: 10}) {
+		t.Errorf("Pagination = %+v, want {Offset:20 Limit:10}", params.Pagination)
+	}
+}
+
+func TestParseAndValidateQueryAggregatesErrorsAcrossCategories(t *testing.T) {
+	q := url.Values{
+		"bogus":        {"x"},
+		"page[number]": {"1"},
+		"page[offset]": {"10"},
+	}
+
+	params, err := ParseAndValidateQuery(q)
+	if err == nil {
+		t.Fatal("expected an aggregated error")
+	}
+	if params != nil {
+		t.Fatalf("expected nil params on error, got %+v", params)
+	}
+
+	doc := ErrorsFromError(err)
+	if len(doc.Errors) != 2 {
+		t.Fatalf("expected 2 errors in the document, got %d: %+v", len(doc.Errors), doc.Errors)
+	}
+}
+
+func TestSplitPagesSplitsTenItemsIntoPagesOfFour(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataArray: paginateTestData(10)}}
+
+	pages := SplitPages(doc, 4)
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(pages))
+	}
+	if len(pages[0].Data.DataArray) != 4 || len(pages[1].Data.DataArray) != 4 || len(pages[2].Data.DataArray) != 2 {
+		t.Fatalf("unexpected page sizes: %d, %d, %d", len(pages[0].Data.DataArray), len(pages[1].Data.DataArray), len(pages[2].Data.DataArray))
+	}
+	if pages[0].Data.DataArray[0].ID != "1" || pages[2].Data.DataArray[1].ID != "10" {
+		t.Fatalf("unexpected page contents: %+v / %+v", pages[0].Data.DataArray, pages[2].Data.DataArray)
+	}
+
+	if pages[0].Links.Previous != "" || pageNumber(t, pages[0].Links.Next) != 2 || pageNumber(t, pages[0].Links.First) != 1 || pageNumber(t, pages[0].Links.Last) != 3 {
+		t.Fatalf("unexpected links on first page: %+v", pages[0].Links)
+	}
+	if pageNumber(t, pages[1].Links.Previous) != 1 || pageNumber(t, pages[1].Links.Next) != 3 {
+		t.Fatalf("unexpected links on middle page: %+v", pages[1].Links)
+	}
+	if pageNumber(t, pages[2].Links.Previous) != 2 || pages[2].Links.Next != "" {
+		t.Fatalf("unexpected links on last page: %+v", pages[2].Links)
+	}
+
+	info, ok := pages[1].PageMeta()
+	if !ok || info.CurrentPage != 2 || info.TotalPages != 3 || info.PerPage != 4 || info.Total != 10 {
+		t.Fatalf("unexpected page meta: %+v, ok=%v", info, ok)
+	}
+}
+
+func TestSplitPagesReturnsNilForNonCollectionOrNonPositiveSize(t *testing.T) {
+	single := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+	if pages := SplitPages(single, 4); pages != nil {
+		t.Fatalf("expected nil for a non-collection document, got %+v", pages)
+	}
+
+	collection := &Document{Data: &DataContainer{DataArray: paginateTestData(10)}}
+	if pages := SplitPages(collection, 0); pages != nil {
+		t.Fatalf("expected nil for a non-positive size, got %+v", pages)
+	}
+}
@@ -0,0 +1,56 @@
+package jsonapi
+
+import "encoding/json"
+
+// RawDocument captures a JSON:API document as its raw top-level member map
+// rather than decoding it into Document's typed fields, so a pass-through
+// proxy or gateway can forward it unchanged — including members no struct
+// in this package models, such as a vendor-specific "jsonapi" object,
+// extension members, or custom links — while still allowing a handful of
+// targeted edits before re-serializing. Get/Set/Delete touch only the
+// members a caller names, and every other member round-trips through its
+// json.RawMessage with its value unchanged; re-serializing compacts
+// insignificant whitespace, escapes <, > and & inside strings, and writes
+// the top-level members in sorted key order, as encoding/json does for any
+// map of raw messages.
+type RawDocument map[string]json.RawMessage
+
+// ParseRawDocument parses payload into a RawDocument, capturing every
+// top-level member as-is without interpreting any of them.
+func ParseRawDocument(payload []byte) (RawDocument, error) {
+	var m map[string]json.RawMessage
+	if err := unmarshalJSON(payload, &m); err != nil {
+		return nil, err
+	}
+	return RawDocument(m), nil
+}
+
+// MarshalJSON returns the JSON encoding of d, re-serializing every captured
+// member from the value it was parsed (or last Set) with, compacted as
+// described on RawDocument.
+func (d RawDocument) MarshalJSON() ([]byte, error) {
+	return marshalJSON(map[string]json.RawMessage(d))
+}
+
+// Get returns the raw JSON of member, and whether it was present.
+func (d RawDocument) Get(member string) (json.RawMessage, bool) {
+	raw, ok := d[member]
+	return raw, ok
+}
+
+// Set replaces member with the JSON encoding of v, a targeted edit that
+// leaves every other member untouched.
+func (d RawDocument) Set(member string, v interface{}) error {
+	raw, err := marshalJSON(v)
+	if err != nil {
+		return err
+	}
+	d[member] = raw
+	return nil
+}
+
+// Delete removes member from d entirely, as opposed to setting it to an
+// explicit JSON null.
+func (d RawDocument) Delete(member string) {
+	delete(d, member)
+}
@@ -0,0 +1,90 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRawDocumentRoundTripsUnknownTopLevelMember(t *testing.T) {
+	payload := []byte(`{"data":{"type":"articles","id":"1"},"x-vendor-extension":{"foo":"bar"}}`)
+
+	doc, err := ParseRawDocument(payload)
+	if err != nil {
+		t.Fatalf("ParseRawDocument returned error: %v", err)
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+
+	ext, ok := got["x-vendor-extension"]
+	if !ok {
+		t.Fatal("expected the unknown member to survive re-serialization")
+	}
+	if string(ext) != `{"foo":"bar"}` {
+		t.Fatalf("unexpected extension payload: %s", ext)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(got["data"], &data); err != nil {
+		t.Fatalf("Unmarshal(data) returned error: %v", err)
+	}
+	if data["type"] != "articles" || data["id"] != "1" {
+		t.Fatalf("unexpected data: %+v", data)
+	}
+}
+
+func TestRawDocumentSetReplacesOnlyNamedMember(t *testing.T) {
+	doc, err := ParseRawDocument([]byte(`{"data":{"type":"articles","id":"1"},"meta":{"count":1}}`))
+	if err != nil {
+		t.Fatalf("ParseRawDocument returned error: %v", err)
+	}
+
+	if err := doc.Set("meta", map[string]interface{}{"count": 2}); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+
+	raw, ok := doc.Get("meta")
+	if !ok {
+		t.Fatal("expected meta to be present")
+	}
+	if string(raw) != `{"count":2}` {
+		t.Fatalf("unexpected meta: %s", raw)
+	}
+
+	dataRaw, ok := doc.Get("data")
+	if !ok || string(dataRaw) != `{"type":"articles","id":"1"}` {
+		t.Fatalf("expected data to be untouched, got %s", dataRaw)
+	}
+}
+
+func TestRawDocumentDeleteRemovesMemberEntirely(t *testing.T) {
+	doc, err := ParseRawDocument([]byte(`{"data":{"type":"articles","id":"1"},"meta":{"count":1}}`))
+	if err != nil {
+		t.Fatalf("ParseRawDocument returned error: %v", err)
+	}
+
+	doc.Delete("meta")
+
+	if _, ok := doc.Get("meta"); ok {
+		t.Fatal("expected meta to be gone after Delete")
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("Unmarshal(out) returned error: %v", err)
+	}
+	if _, ok := got["meta"]; ok {
+		t.Fatalf("expected meta to be absent from the re-serialized payload, got %+v", got)
+	}
+}
@@ -0,0 +1,56 @@
+package jsonapi
+
+import (
+	"net/url"
+	"strings"
+)
+
+// BuildResourceSelfLink builds the "{base}/{type}/{id}" URL a resource's own
+// "self" link should carry. typ and id are URL-escaped, so a resource type
+// or id containing a character like "/" or "?" can't corrupt the resulting
+// path.
+func BuildResourceSelfLink(base, typ, id string) string {
+	return strings.TrimSuffix(base, "/") + "/" + url.PathEscape(typ) + "/" + url.PathEscape(id)
+}
+
+// BuildRelationshipSelfLinks builds the "self" and "related" links a
+// relationship object should carry, per the spec's recommendation that
+// every relationship expose both: "{base}/{type}/{id}/relationships/{name}"
+// for self, and "{base}/{type}/{id}/{name}" for related. typ, id and name
+// are URL-escaped, so a resource type or id containing a character like "/"
+// or "?" can't corrupt the resulting path.
+func BuildRelationshipSelfLinks(base, typ, id, name string) *Links {
+	resource := BuildResourceSelfLink(base, typ, id)
+	return &Links{
+		Self:    resource + "/relationships/" + url.PathEscape(name),
+		Related: resource + "/" + url.PathEscape(name),
+	}
+}
+
+// LinkTemplates holds the base URL registered for a resource type via
+// RegisterLinks, used to generate that resource's own "self" link and, by
+// default, its relationships' "self"/"related" links during Marshal.
+type LinkTemplates struct {
+	// Base is the API's root URL, the same value a per-call
+	// WithRelationshipLinkTemplates(base) would use.
+	Base string
+}
+
+// linkRegistry maps a resource type name to the LinkTemplates registered
+// for it via RegisterLinks, process-wide scoping like typeRegistry and
+// fieldCodecs.
+var linkRegistry = map[string]LinkTemplates{}
+
+// RegisterLinks associates typ, a JSON:API resource type name, with
+// templates so every resource of that type Marshal encodes gets a "self"
+// link, and its relationships get generated "self"/"related" links the same
+// way WithRelationshipLinkTemplates(templates.Base) would produce, without
+// passing that option at every call site. A call's own
+// WithRelationshipLinkTemplates, if given, still takes precedence for that
+// call's relationship links; RegisterLinks only supplies the default. Call
+// it once per resource type, typically at program startup; a type with no
+// registered templates is unaffected, the same as before RegisterLinks
+// existed.
+func RegisterLinks(typ string, templates LinkTemplates) {
+	linkRegistry[typ] = templates
+}
@@ -0,0 +1,33 @@
+package jsonapi
+
+import "testing"
+
+func TestBuildRelationshipSelfLinksBuildsSelfAndRelated(t *testing.T) {
+	links := BuildRelationshipSelfLinks("https://api.example.com", "articles", "1", "comments")
+
+	if links.Self != "https://api.example.com/articles/1/relationships/comments" {
+		t.Fatalf("unexpected self link: %q", links.Self)
+	}
+	if links.Related != "https://api.example.com/articles/1/comments" {
+		t.Fatalf("unexpected related link: %q", links.Related)
+	}
+}
+
+func TestBuildRelationshipSelfLinksTrimsTrailingSlashOnBase(t *testing.T) {
+	links := BuildRelationshipSelfLinks("https://api.example.com/", "articles", "1", "comments")
+
+	if links.Self != "https://api.example.com/articles/1/relationships/comments" {
+		t.Fatalf("unexpected self link: %q", links.Self)
+	}
+}
+
+func TestBuildRelationshipSelfLinksEscapesIDsAndNames(t *testing.T) {
+	links := BuildRelationshipSelfLinks("https://api.example.com", "articles", "weird/id?x", "comments")
+
+	if links.Self != "https://api.example.com/articles/weird%2Fid%3Fx/relationships/comments" {
+		t.Fatalf("unexpected self link: %q", links.Self)
+	}
+	if links.Related != "https://api.example.com/articles/weird%2Fid%3Fx/comments" {
+		t.Fatalf("unexpected related link: %q", links.Related)
+	}
+}
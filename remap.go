@@ -0,0 +1,58 @@
+package jsonapi
+
+// RemapIDs rewrites every resource and relationship identifier in d that's
+// keyed by a client-generated lid in mapping, to the server-assigned id
+// mapping records for it. mapping keys are "type:lid"; values are the
+// server id the lid was assigned. This is the Document-wide counterpart to
+// the per-request lid resolution Process does for atomic operations: once a
+// batch of "add" operations has run and the server knows what id each lid
+// became, a document built client-side with lids -- or a response document
+// still carrying them -- can have every reference rewritten to real ids in
+// one pass.
+func (d *Document) RemapIDs(mapping map[string]string) {
+	if d == nil || d.Data == nil {
+		return
+	}
+
+	if d.Data.DataObject != nil {
+		remapData(d.Data.DataObject, mapping)
+	}
+	for i := range d.Data.DataArray {
+		remapData(&d.Data.DataArray[i], mapping)
+	}
+	for i := range d.Included {
+		remapData(&d.Included[i], mapping)
+	}
+}
+
+// remapData rewrites d's own lid-keyed identifier and those of its
+// relationships against mapping.
+func remapData(d *Data, mapping map[string]string) {
+	if d.LID != "" {
+		if id, ok := mapping[d.Type+":"+d.LID]; ok {
+			d.ID = id
+		}
+	}
+
+	for key, rel := range d.Relationships {
+		if rel.Data == nil {
+			continue
+		}
+		if rel.Data.DataObject != nil {
+			remapRelationshipData(rel.Data.DataObject, mapping)
+		}
+		for i := range rel.Data.DataArray {
+			remapRelationshipData(&rel.Data.DataArray[i], mapping)
+		}
+		d.Relationships[key] = rel
+	}
+}
+
+// remapRelationshipData rewrites rd's lid-keyed identifier against mapping.
+func remapRelationshipData(rd *RelationshipData, mapping map[string]string) {
+	if rd.LID != "" {
+		if id, ok := mapping[rd.Type+":"+rd.LID]; ok {
+			rd.ID = id
+		}
+	}
+}
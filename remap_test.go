@@ -0,0 +1,61 @@
+package jsonapi
+
+import "testing"
+
+func TestDocumentRemapIDsRewritesCreateAndItsReferencingRelationship(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "comments", ID: "1",
+			Relationships: map[string]Relationship{
+				"author": {Data: &RelationshipDataContainer{
+					DataObject: &RelationshipData{Type: "people", LID: "temp-author"},
+				}},
+			},
+		}},
+		Included: []Data{
+			{Type: "people", LID: "temp-author"},
+		},
+	}
+
+	doc.RemapIDs(map[string]string{"people:temp-author": "42"})
+
+	if doc.Included[0].ID != "42" {
+		t.Fatalf("expected the included create to get the assigned id, got %+v", doc.Included[0])
+	}
+	author := doc.Data.DataObject.Relationships["author"].Data.DataObject
+	if author.ID != "42" {
+		t.Fatalf("expected the relationship reference to the lid to be rewritten, got %+v", author)
+	}
+}
+
+func TestDocumentRemapIDsOnDataArrayRewritesEachEntry(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "people", LID: "a"},
+			{Type: "people", LID: "b"},
+		}},
+	}
+
+	doc.RemapIDs(map[string]string{"people:a": "1", "people:b": "2"})
+
+	if doc.Data.DataArray[0].ID != "1" || doc.Data.DataArray[1].ID != "2" {
+		t.Fatalf("unexpected result: %+v", doc.Data.DataArray)
+	}
+}
+
+func TestDocumentRemapIDsLeavesUnmappedLIDsUntouched(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{Type: "people", LID: "unknown"}},
+	}
+
+	doc.RemapIDs(map[string]string{"people:other": "1"})
+
+	if doc.Data.DataObject.ID != "" {
+		t.Fatalf("expected id to remain empty, got %q", doc.Data.DataObject.ID)
+	}
+}
+
+func TestDocumentRemapIDsOnNilDataIsNoop(t *testing.T) {
+	doc := &Document{}
+	doc.RemapIDs(map[string]string{"people:a": "1"})
+}
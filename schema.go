@@ -0,0 +1,182 @@
+package jsonapi
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// jsonSchema is the decoded form of the JSON Schema keywords ValidateSchema
+// understands: type, required, properties, items, enum and the
+// string/number bound keywords. JSON Schema's full vocabulary (refs,
+// combinators, conditional schemas, format) is out of scope — this covers
+// the shapes a server is likely to declare for a resource's attributes,
+// not a general-purpose validator.
+type jsonSchema struct {
+	Type       interface{}           `json:"type"`
+	Required   []string              `json:"required"`
+	Properties map[string]jsonSchema `json:"properties"`
+	Items      *jsonSchema           `json:"items"`
+	Enum       []interface{}         `json:"enum"`
+	Minimum    *float64              `json:"minimum"`
+	Maximum    *float64              `json:"maximum"`
+	MinLength  *int                  `json:"minLength"`
+	MaxLength  *int                  `json:"maxLength"`
+	Pattern    string                `json:"pattern"`
+}
+
+// SchemaValidationError is returned by Data.ValidateSchema when a
+// resource's attributes fail one or more of schema's checks. Errors holds
+// one ErrorObject per violation, each sourced to the JSON pointer of the
+// attribute that failed, suitable for passing straight to MarshalErrors.
+type SchemaValidationError struct {
+	Errors []ErrorObject
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("jsonapi: %d schema violation(s)", len(e.Errors))
+}
+
+// ValidateSchema decodes d's attributes and validates them against schema,
+// a JSON Schema document, returning a *SchemaValidationError naming every
+// violation by its JSON pointer under "/data/attributes" if any are found,
+// or nil if the attributes satisfy schema. This lets a server declare an
+// attribute shape once, as data, instead of hand-writing a check per field.
+func (d *Data) ValidateSchema(schema []byte) error {
+	var s jsonSchema
+	if err := unmarshalJSON(schema, &s); err != nil {
+		return fmt.Errorf("jsonapi: invalid JSON Schema: %w", err)
+	}
+
+	attrs, err := d.AttributesMap()
+	if err != nil {
+		return err
+	}
+
+	var errs []ErrorObject
+	validateAgainstSchema(&s, attrs, "/data/attributes", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &SchemaValidationError{Errors: errs}
+}
+
+// validateAgainstSchema checks value against s, appending one ErrorObject
+// per violation to errs, each sourced to pointer (or a child of it, for a
+// nested object or array element).
+func validateAgainstSchema(s *jsonSchema, value interface{}, pointer string, errs *[]ErrorObject) {
+	if !schemaTypeMatches(s.Type, value) {
+		*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("must be of type %v", s.Type)))
+		return
+	}
+
+	if len(s.Enum) > 0 && !enumContains(s.Enum, value) {
+		*errs = append(*errs, NewValidationError(pointer, "must be one of the allowed values"))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, NewValidationError(pointer+"/"+name, "is required"))
+			}
+		}
+		for name, child := range s.Properties {
+			if fv, ok := v[name]; ok {
+				validateAgainstSchema(&child, fv, pointer+"/"+name, errs)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, elem := range v {
+				validateAgainstSchema(s.Items, elem, fmt.Sprintf("%s/%d", pointer, i), errs)
+			}
+		}
+	case string:
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("must be at least %d characters", *s.MinLength)))
+		}
+		if s.MaxLength != nil && len(v) > *s.MaxLength {
+			*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("must be at most %d characters", *s.MaxLength)))
+		}
+		if s.Pattern != "" {
+			if matched, err := regexp.MatchString(s.Pattern, v); err != nil {
+				*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("invalid pattern %q: %v", s.Pattern, err)))
+			} else if !matched {
+				*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("must match pattern %q", s.Pattern)))
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("must be >= %v", *s.Minimum)))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*errs = append(*errs, NewValidationError(pointer, fmt.Sprintf("must be <= %v", *s.Maximum)))
+		}
+	}
+}
+
+// enumContains reports whether value equals one of allowed. A map or slice
+// entry in allowed is skipped rather than compared with ==, which would
+// panic on an uncomparable type; ValidateSchema's enum support is scoped to
+// scalar values (null, boolean, number, string).
+func enumContains(allowed []interface{}, value interface{}) bool {
+	for _, v := range allowed {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		default:
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemaTypeMatches reports whether value's JSON type satisfies schemaType,
+// a JSON Schema "type" keyword value: a single type name, a list of
+// alternatives, or nil/empty, which imposes no constraint.
+func schemaTypeMatches(schemaType interface{}, value interface{}) bool {
+	switch t := schemaType.(type) {
+	case nil:
+		return true
+	case string:
+		return jsonValueIsType(value, t)
+	case []interface{}:
+		for _, alt := range t {
+			if name, ok := alt.(string); ok && jsonValueIsType(value, name) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// jsonValueIsType reports whether value, decoded by encoding/json (via
+// interface{}), satisfies typeName -- one of "null", "boolean", "integer",
+// "number", "string", "array" or "object". "number" accepts any JSON
+// number; "integer" additionally requires it to have no fractional part,
+// since JSON itself has no distinct integer type.
+func jsonValueIsType(value interface{}, typeName string) bool {
+	switch v := value.(type) {
+	case nil:
+		return typeName == "null"
+	case bool:
+		return typeName == "boolean"
+	case float64:
+		if typeName == "number" {
+			return true
+		}
+		return typeName == "integer" && v == float64(int64(v))
+	case string:
+		return typeName == "string"
+	case []interface{}:
+		return typeName == "array"
+	case map[string]interface{}:
+		return typeName == "object"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,142 @@
+package jsonapi
+
+import (
+	"testing"
+)
+
+func TestDataValidateSchemaRejectsMissingRequiredField(t *testing.T) {
+	d := &Data{Type: "people"}
+	if err := d.SetAttributesMap(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("SetAttributesMap returned error: %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"required": ["name", "email"],
+		"properties": {
+			"name": {"type": "string"},
+			"email": {"type": "string"}
+		}
+	}`)
+
+	err := d.ValidateSchema(schema)
+	if err == nil {
+		t.Fatal("expected a schema validation error for the missing email field")
+	}
+
+	sve, ok := err.(*SchemaValidationError)
+	if !ok {
+		t.Fatalf("expected *SchemaValidationError, got %T: %v", err, err)
+	}
+	if len(sve.Errors) != 1 {
+		t.Fatalf("expected exactly one violation, got %+v", sve.Errors)
+	}
+	if sve.Errors[0].Source.Pointer != "/data/attributes/email" {
+		t.Fatalf("unexpected pointer: %q", sve.Errors[0].Source.Pointer)
+	}
+}
+
+func TestDataValidateSchemaPassesWhenAttributesSatisfySchema(t *testing.T) {
+	d := &Data{Type: "people"}
+	if err := d.SetAttributesMap(map[string]interface{}{"name": "Alice", "age": 30.0}); err != nil {
+		t.Fatalf("SetAttributesMap returned error: %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0}
+		}
+	}`)
+
+	if err := d.ValidateSchema(schema); err != nil {
+		t.Fatalf("expected no violations, got %v", err)
+	}
+}
+
+func TestDataValidateSchemaChecksNestedPropertiesAndBounds(t *testing.T) {
+	d := &Data{Type: "people"}
+	if err := d.SetAttributesMap(map[string]interface{}{
+		"name": "Al",
+		"address": map[string]interface{}{
+			"zip": "abc",
+		},
+	}); err != nil {
+		t.Fatalf("SetAttributesMap returned error: %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 3},
+			"address": {
+				"type": "object",
+				"properties": {
+					"zip": {"type": "string", "pattern": "^[0-9]+$"}
+				}
+			}
+		}
+	}`)
+
+	err := d.ValidateSchema(schema)
+	sve, ok := err.(*SchemaValidationError)
+	if !ok {
+		t.Fatalf("expected *SchemaValidationError, got %T: %v", err, err)
+	}
+
+	pointers := map[string]bool{}
+	for _, e := range sve.Errors {
+		pointers[e.Source.Pointer] = true
+	}
+	if !pointers["/data/attributes/name"] {
+		t.Errorf("expected a violation for the short name, got %+v", sve.Errors)
+	}
+	if !pointers["/data/attributes/address/zip"] {
+		t.Errorf("expected a violation for the non-numeric zip, got %+v", sve.Errors)
+	}
+}
+
+func TestDataValidateSchemaRejectsEnumViolation(t *testing.T) {
+	d := &Data{Type: "people"}
+	if err := d.SetAttributesMap(map[string]interface{}{"status": "deleted"}); err != nil {
+		t.Fatalf("SetAttributesMap returned error: %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"status": {"type": "string", "enum": ["active", "inactive"]}
+		}
+	}`)
+
+	err := d.ValidateSchema(schema)
+	sve, ok := err.(*SchemaValidationError)
+	if !ok || len(sve.Errors) != 1 {
+		t.Fatalf("expected exactly one enum violation, got %v", err)
+	}
+}
+
+func TestDataValidateSchemaChecksArrayItems(t *testing.T) {
+	d := &Data{Type: "people"}
+	if err := d.SetAttributesMap(map[string]interface{}{"tags": []interface{}{"ok", 5.0}}); err != nil {
+		t.Fatalf("SetAttributesMap returned error: %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`)
+
+	err := d.ValidateSchema(schema)
+	sve, ok := err.(*SchemaValidationError)
+	if !ok || len(sve.Errors) != 1 {
+		t.Fatalf("expected exactly one violation for the non-string item, got %v", err)
+	}
+	if sve.Errors[0].Source.Pointer != "/data/attributes/tags/1" {
+		t.Fatalf("unexpected pointer: %q", sve.Errors[0].Source.Pointer)
+	}
+}
@@ -0,0 +1,38 @@
+package jsonapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// MarshalIndentStable encodes doc as indented JSON with deterministic key
+// ordering throughout the document: struct-backed members (the top-level
+// members, a resource's type/id/attributes/...) come out in their fixed
+// field order, and Meta maps (including nested interface{} maps), Links
+// objects and relationship maps serialize with their keys sorted, since
+// encoding/json sorts every map[string]T it encounters. A resource's
+// Attributes is a json.RawMessage and keeps the key order it already has;
+// Marshal builds it from a map, so its keys come out sorted, but
+// hand-assembled Attributes are not reordered. MarshalIndentStable exists to make that
+// guarantee explicit and easy to call for golden tests, ETags, or any other
+// use case that depends on byte-for-byte reproducible output.
+func MarshalIndentStable(doc *Document) ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// ETag returns a strong HTTP ETag for d: a quoted, hex-encoded SHA-256 hash
+// of d's canonical serialization (the output of MarshalIndentStable). The
+// value changes whenever an attribute, relationship, or meta value changes,
+// but is invariant to map key ordering, so it is safe to use as the ETag
+// header in a response and compare against a request's If-None-Match to
+// answer with 304 Not Modified.
+func (d *Document) ETag() (string, error) {
+	canonical, err := MarshalIndentStable(d)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
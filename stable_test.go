@@ -0,0 +1,55 @@
+package jsonapi
+
+import "testing"
+
+func TestMarshalIndentStableIsReproducibleAcrossRuns(t *testing.T) {
+	doc, err := Marshal(testPerson{ID: "1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	doc.Meta = map[string]interface{}{"z": 1, "a": map[string]interface{}{"y": 1, "b": 2}}
+
+	first, err := MarshalIndentStable(doc)
+	if err != nil {
+		t.Fatalf("MarshalIndentStable returned error: %v", err)
+	}
+	second, err := MarshalIndentStable(doc)
+	if err != nil {
+		t.Fatalf("MarshalIndentStable returned error: %v", err)
+	}
+
+	if string(first) != string(second) {
+		t.Fatalf("expected identical output across calls, got:\n%s\nvs\n%s", first, second)
+	}
+}
+
+func TestDocumentETagIsStableAndChangesWithContent(t *testing.T) {
+	doc, err := Marshal(testPerson{ID: "1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	first, err := doc.ETag()
+	if err != nil {
+		t.Fatalf("ETag returned error: %v", err)
+	}
+	second, err := doc.ETag()
+	if err != nil {
+		t.Fatalf("ETag returned error: %v", err)
+	}
+	if first != second {
+		t.Fatalf("expected the same ETag across calls, got %q and %q", first, second)
+	}
+	if len(first) < 2 || first[0] != '"' || first[len(first)-1] != '"' {
+		t.Fatalf("expected a quoted ETag, got %q", first)
+	}
+
+	doc.Meta = map[string]interface{}{"changed": true}
+	third, err := doc.ETag()
+	if err != nil {
+		t.Fatalf("ETag returned error: %v", err)
+	}
+	if third == first {
+		t.Fatal("expected ETag to change when the document's content changes")
+	}
+}
@@ -0,0 +1,79 @@
+package jsonapi
+
+import "sort"
+
+// ResourceStore is an in-memory, type+id indexed cache of decoded resources,
+// for clients that want to build a local graph from one or more compound
+// documents and resolve relationships by lookup instead of re-walking
+// Included each time. It is the client-side counterpart to IndexIncluded.
+type ResourceStore struct {
+	byType map[string]map[string]*Data
+}
+
+// NewResourceStore returns an empty ResourceStore.
+func NewResourceStore() *ResourceStore {
+	return &ResourceStore{byType: map[string]map[string]*Data{}}
+}
+
+// Add indexes d by its type and id, overwriting any resource already stored
+// under the same type+id. It is a no-op if d is nil.
+func (s *ResourceStore) Add(d *Data) {
+	if d == nil {
+		return
+	}
+	byID, ok := s.byType[d.Type]
+	if !ok {
+		byID = map[string]*Data{}
+		s.byType[d.Type] = byID
+	}
+	byID[d.ID] = d
+}
+
+// Get looks up the resource stored under type t and id, reporting whether
+// it was found.
+func (s *ResourceStore) Get(t, id string) (*Data, bool) {
+	byID, ok := s.byType[t]
+	if !ok {
+		return nil, false
+	}
+	d, ok := byID[id]
+	return d, ok
+}
+
+// All returns every resource stored under type t, sorted by id.
+func (s *ResourceStore) All(t string) []*Data {
+	byID := s.byType[t]
+	if len(byID) == 0 {
+		return nil
+	}
+
+	out := make([]*Data, 0, len(byID))
+	for _, d := range byID {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// Load adds doc's primary data (whether a single resource or a collection)
+// and its Included array to the store, so one call ingests everything a
+// compound document carries.
+func (s *ResourceStore) Load(doc *Document) {
+	if doc == nil {
+		return
+	}
+
+	if doc.Data != nil {
+		if doc.Data.DataObject != nil {
+			s.Add(doc.Data.DataObject)
+		}
+		for i := range doc.Data.DataArray {
+			s.Add(&doc.Data.DataArray[i])
+		}
+	}
+
+	for i := range doc.Included {
+		s.Add(&doc.Included[i])
+	}
+}
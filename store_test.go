@@ -0,0 +1,103 @@
+package jsonapi
+
+import "testing"
+
+func TestResourceStoreAddAndGet(t *testing.T) {
+	s := NewResourceStore()
+	s.Add(&Data{Type: "people", ID: "1"})
+
+	got, ok := s.Get("people", "1")
+	if !ok {
+		t.Fatal("expected resource to be found")
+	}
+	if got.Type != "people" || got.ID != "1" {
+		t.Fatalf("unexpected resource: %+v", got)
+	}
+
+	if _, ok := s.Get("people", "2"); ok {
+		t.Fatal("expected no resource for unknown id")
+	}
+	if _, ok := s.Get("articles", "1"); ok {
+		t.Fatal("expected no resource for unknown type")
+	}
+}
+
+func TestResourceStoreAddOverwritesSameTypeAndID(t *testing.T) {
+	s := NewResourceStore()
+	s.Add(&Data{Type: "people", ID: "1", Meta: map[string]interface{}{"v": 1}})
+	s.Add(&Data{Type: "people", ID: "1", Meta: map[string]interface{}{"v": 2}})
+
+	got, _ := s.Get("people", "1")
+	if got.Meta["v"] != 2 {
+		t.Fatalf("expected the later Add to win, got %+v", got.Meta)
+	}
+}
+
+func TestResourceStoreAddNilIsNoOp(t *testing.T) {
+	s := NewResourceStore()
+	s.Add(nil)
+
+	if got := s.All("people"); got != nil {
+		t.Fatalf("expected no resources, got %+v", got)
+	}
+}
+
+func TestResourceStoreAllReturnsSortedByID(t *testing.T) {
+	s := NewResourceStore()
+	s.Add(&Data{Type: "people", ID: "2"})
+	s.Add(&Data{Type: "people", ID: "1"})
+	s.Add(&Data{Type: "articles", ID: "9"})
+
+	people := s.All("people")
+	if len(people) != 2 || people[0].ID != "1" || people[1].ID != "2" {
+		t.Fatalf("unexpected order: %+v", people)
+	}
+}
+
+func TestResourceStoreLoadIngestsPrimaryAndIncluded(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Included: []Data{
+			{Type: "people", ID: "9"},
+			{Type: "comments", ID: "5"},
+		},
+	}
+
+	s := NewResourceStore()
+	s.Load(doc)
+
+	if _, ok := s.Get("articles", "1"); !ok {
+		t.Fatal("expected primary resource to be loaded")
+	}
+	if _, ok := s.Get("people", "9"); !ok {
+		t.Fatal("expected included person to be loaded")
+	}
+	if _, ok := s.Get("comments", "5"); !ok {
+		t.Fatal("expected included comment to be loaded")
+	}
+}
+
+func TestResourceStoreLoadIngestsPrimaryArray(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataArray: []Data{
+			{Type: "people", ID: "1"},
+			{Type: "people", ID: "2"},
+		}},
+	}
+
+	s := NewResourceStore()
+	s.Load(doc)
+
+	if len(s.All("people")) != 2 {
+		t.Fatalf("expected 2 people, got %+v", s.All("people"))
+	}
+}
+
+func TestResourceStoreLoadNilDocumentIsNoOp(t *testing.T) {
+	s := NewResourceStore()
+	s.Load(nil)
+
+	if got := s.All("people"); got != nil {
+		t.Fatalf("expected no resources, got %+v", got)
+	}
+}
@@ -0,0 +1,374 @@
+package jsonapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// EncodeOption configures MarshalDocumentTo.
+type EncodeOption func(*encodeOptions)
+
+type encodeOptions struct {
+	maxSize int64
+}
+
+// WithMaxSize caps the number of bytes MarshalDocumentTo will write.
+// Exceeding it aborts the encode and returns ErrDocumentTooLarge; bytes
+// already written to w before the limit was hit are not retracted, since w
+// may not support that (an http.ResponseWriter can't un-send a response).
+// This guards a server against accidentally serializing a huge document —
+// e.g. one with an unbounded "included" — rather than supporting partial or
+// truncated output, which would produce invalid JSON.
+func WithMaxSize(max int64) EncodeOption {
+	return func(o *encodeOptions) { o.maxSize = max }
+}
+
+// ErrDocumentTooLarge is returned by MarshalDocumentTo when WithMaxSize is
+// set and doc's serialized size exceeds it.
+var ErrDocumentTooLarge = errors.New("jsonapi: document exceeds configured maximum size")
+
+// MarshalDocumentTo encodes doc to w using json.Encoder rather than
+// json.Marshal, so a large already-built document's serialized bytes
+// stream straight to w instead of being buffered into one single byte
+// slice first. It sets no headers or status of its own; WriteDocument
+// handles that for an HTTP response. For a document whose "included" is
+// itself too large to hold in memory, use Encoder instead, which builds
+// "included" incrementally rather than requiring doc.Included up front.
+// Like json.Encoder.Encode, it writes a trailing newline after the
+// document, unlike json.Marshal.
+func MarshalDocumentTo(w io.Writer, doc *Document, opts ...EncodeOption) error {
+	var o encodeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.maxSize <= 0 {
+		return json.NewEncoder(w).Encode(doc)
+	}
+
+	cw := &countingWriter{w: w, max: o.maxSize}
+	if err := json.NewEncoder(cw).Encode(doc); err != nil {
+		if cw.exceeded {
+			return ErrDocumentTooLarge
+		}
+		return err
+	}
+	return nil
+}
+
+// countingWriter wraps an io.Writer, failing the write that would push the
+// running total past max rather than letting an unbounded document keep
+// streaming out.
+type countingWriter struct {
+	w        io.Writer
+	max      int64
+	written  int64
+	exceeded bool
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.written+int64(len(p)) > cw.max {
+		cw.exceeded = true
+		return 0, ErrDocumentTooLarge
+	}
+	n, err := cw.w.Write(p)
+	cw.written += int64(n)
+	return n, err
+}
+
+// Encoder writes a Document to a stream, encoding "included" one resource at
+// a time instead of buffering the whole slice in memory. It exists
+// alongside json.Marshal(*Document) for responses with large compound
+// documents; small payloads can keep using the Document type directly.
+type Encoder struct {
+	w        io.Writer
+	meta     map[string]interface{}
+	wroteAny bool
+	inIncl   bool
+	wroteInc bool
+	done     bool
+	err      error
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// WriteHeader writes doc's "data", "errors", "links" and "jsonapi" members
+// and opens the "included" array. doc.Included is ignored; stream included
+// resources with WriteIncluded instead. doc.Meta is written by Close.
+func (e *Encoder) WriteHeader(doc *Document) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.wroteAny || e.inIncl {
+		return e.fail(errors.New("jsonapi: WriteHeader called more than once"))
+	}
+	if doc.Data != nil && doc.Errors != nil {
+		return e.fail(errDataAndErrors)
+	}
+
+	if err := e.writeRaw("{"); err != nil {
+		return err
+	}
+
+	if doc.Data != nil {
+		if err := e.writeField("data", doc.Data); err != nil {
+			return err
+		}
+	}
+	if doc.Errors != nil {
+		if err := e.writeField("errors", doc.Errors); err != nil {
+			return err
+		}
+	}
+	if doc.Links != nil {
+		if err := e.writeField("links", doc.Links); err != nil {
+			return err
+		}
+	}
+	if doc.JSONAPI != nil {
+		if err := e.writeField("jsonapi", doc.JSONAPI); err != nil {
+			return err
+		}
+	}
+
+	if e.wroteAny {
+		if err := e.writeRaw(","); err != nil {
+			return err
+		}
+	}
+	if err := e.writeRaw(`"included":[`); err != nil {
+		return err
+	}
+	e.wroteAny = true
+	e.inIncl = true
+	e.meta = doc.Meta
+
+	return nil
+}
+
+// WriteIncluded appends one resource to the streaming "included" array.
+func (e *Encoder) WriteIncluded(d Data) error {
+	if e.err != nil {
+		return e.err
+	}
+	if !e.inIncl {
+		return e.fail(errors.New("jsonapi: WriteIncluded called before WriteHeader"))
+	}
+
+	if e.wroteInc {
+		if err := e.writeRaw(","); err != nil {
+			return err
+		}
+	}
+
+	b, err := marshalJSON(d)
+	if err != nil {
+		return e.fail(err)
+	}
+	if err := e.writeRaw(string(b)); err != nil {
+		return err
+	}
+	e.wroteInc = true
+
+	return nil
+}
+
+// Close closes the "included" array, writes the document's "meta" (as
+// passed to WriteHeader) and closes the envelope. Call it exactly once,
+// after all calls to WriteIncluded.
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if !e.inIncl || e.done {
+		return e.fail(errors.New("jsonapi: Close called before WriteHeader or more than once"))
+	}
+	e.done = true
+
+	if err := e.writeRaw("]"); err != nil {
+		return err
+	}
+
+	if e.meta != nil {
+		if err := e.writeField("meta", e.meta); err != nil {
+			return err
+		}
+	}
+
+	return e.writeRaw("}")
+}
+
+func (e *Encoder) writeField(key string, v interface{}) error {
+	b, err := marshalJSON(v)
+	if err != nil {
+		return e.fail(err)
+	}
+
+	if e.wroteAny {
+		if err := e.writeRaw(","); err != nil {
+			return err
+		}
+	}
+	if err := e.writeRaw(`"` + key + `":` + string(b)); err != nil {
+		return err
+	}
+	e.wroteAny = true
+
+	return nil
+}
+
+func (e *Encoder) writeRaw(s string) error {
+	if _, err := io.WriteString(e.w, s); err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+func (e *Encoder) fail(err error) error {
+	e.err = err
+	return err
+}
+
+// Decoder reads a Document from a stream, invoking a callback for each
+// element of "included" instead of collecting them into doc.Included.
+type Decoder struct {
+	dec         *json.Decoder
+	maxIncluded int
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithMaxIncluded caps the number of "included" elements a Decoder will
+// accept; Decode returns ErrTooManyIncluded as soon as it reads one past
+// the limit, without buffering the rest of the array, so a malicious or
+// misbehaving server can't exhaust memory by streaming an unbounded
+// "included" array. The default, zero, is unlimited, matching prior
+// behavior.
+func WithMaxIncluded(max int) DecoderOption {
+	return func(d *Decoder) { d.maxIncluded = max }
+}
+
+// NewDecoder returns a Decoder that reads from r. A leading UTF-8 byte
+// order mark, which some clients send despite the JSON spec disallowing
+// one, is discarded before the first token is read.
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{dec: json.NewDecoder(stripBOMReader(r))}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// ErrTooManyIncluded is returned by Decoder.Decode when "included" carries
+// more elements than the Decoder's WithMaxIncluded limit allows.
+var ErrTooManyIncluded = errors.New("jsonapi: included count exceeds configured maximum")
+
+// stripBOMReader peeks the first three bytes of r and discards them if they
+// are a UTF-8 BOM, returning r unchanged otherwise. encoding/json.Decoder
+// has no equivalent of bytes.TrimPrefix since it consumes its input as a
+// stream, so the BOM has to be peeled off the Reader itself.
+func stripBOMReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if peeked, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(peeked, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
+
+// Decode reads the document envelope from the stream into doc, calling fn
+// once per element of "included" as it is read rather than buffering the
+// whole array. It returns an error if the document has both "data" and
+// "errors" populated, the same rule Document.UnmarshalJSON enforces.
+func (dec *Decoder) Decode(doc *Document, fn func(Data) error) error {
+	t, err := dec.dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return errors.New("jsonapi: expected a JSON object")
+	}
+
+	for dec.dec.More() {
+		t, err := dec.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return errors.New("jsonapi: expected an object key")
+		}
+
+		switch key {
+		case "included":
+			if err := dec.decodeIncluded(fn); err != nil {
+				return err
+			}
+		case "data":
+			if err := dec.dec.Decode(&doc.Data); err != nil {
+				return err
+			}
+		case "errors":
+			if err := dec.dec.Decode(&doc.Errors); err != nil {
+				return err
+			}
+		case "links":
+			if err := dec.dec.Decode(&doc.Links); err != nil {
+				return err
+			}
+		case "jsonapi":
+			if err := dec.dec.Decode(&doc.JSONAPI); err != nil {
+				return err
+			}
+		case "meta":
+			if err := dec.dec.Decode(&doc.Meta); err != nil {
+				return err
+			}
+		default:
+			var discard json.RawMessage
+			if err := dec.dec.Decode(&discard); err != nil {
+				return err
+			}
+		}
+	}
+
+	if doc.Data != nil && doc.Errors != nil {
+		return errDataAndErrors
+	}
+
+	_, err = dec.dec.Token() // closing '}'
+	return err
+}
+
+func (dec *Decoder) decodeIncluded(fn func(Data) error) error {
+	t, err := dec.dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return errors.New(`jsonapi: expected "included" to be an array`)
+	}
+
+	for count := 0; dec.dec.More(); count++ {
+		if dec.maxIncluded > 0 && count >= dec.maxIncluded {
+			return ErrTooManyIncluded
+		}
+
+		var d Data
+		if err := dec.dec.Decode(&d); err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.dec.Token() // closing ']'
+	return err
+}
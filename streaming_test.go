@@ -0,0 +1,288 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+)
+
+func TestMarshalDocumentToStreamsEquivalentJSON(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{DataObject: &Data{
+			Type: "articles", ID: "1", Attributes: json.RawMessage(`{"title":"Hello"}`),
+		}},
+		Included: []Data{
+			{Type: "people", ID: "9", Attributes: json.RawMessage(`{"name":"Alice"}`)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := MarshalDocumentTo(&buf, doc); err != nil {
+		t.Fatalf("MarshalDocumentTo returned error: %v", err)
+	}
+
+	want, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+
+	if got := bytes.TrimRight(buf.Bytes(), "\n"); !bytes.Equal(got, want) {
+		t.Fatalf("MarshalDocumentTo wrote %s, want %s", got, want)
+	}
+}
+
+func TestMarshalDocumentToWithMaxSizeRejectsLargeIncluded(t *testing.T) {
+	doc := buildLargeIncludedDocument(1000)
+
+	var buf bytes.Buffer
+	err := MarshalDocumentTo(&buf, doc, WithMaxSize(100))
+	if !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("expected ErrDocumentTooLarge, got %v", err)
+	}
+}
+
+func TestMarshalDocumentToWithMaxSizeAllowsDocumentUnderLimit(t *testing.T) {
+	doc := &Document{Data: &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}}}
+
+	var buf bytes.Buffer
+	if err := MarshalDocumentTo(&buf, doc, WithMaxSize(1<<20)); err != nil {
+		t.Fatalf("MarshalDocumentTo returned error: %v", err)
+	}
+
+	want, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	if got := bytes.TrimRight(buf.Bytes(), "\n"); !bytes.Equal(got, want) {
+		t.Fatalf("MarshalDocumentTo wrote %s, want %s", got, want)
+	}
+}
+
+func buildLargeIncludedDocument(n int) *Document {
+	included := make([]Data, n)
+	for i := range included {
+		included[i] = Data{
+			Type:       "people",
+			ID:         strconv.Itoa(i),
+			Attributes: json.RawMessage(`{"name":"Person"}`),
+		}
+	}
+	return &Document{
+		Data:     &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Included: included,
+	}
+}
+
+func BenchmarkMarshalDocumentToLargeIncluded(b *testing.B) {
+	doc := buildLargeIncludedDocument(10000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := MarshalDocumentTo(io.Discard, doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONMarshalLargeIncluded(b *testing.B) {
+	doc := buildLargeIncludedDocument(10000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncoderDecoderRoundTrip(t *testing.T) {
+	doc := &Document{
+		Data:  &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Links: &Links{Self: "https://example.com/articles/1"},
+		Meta:  map[string]interface{}{"count": float64(2)},
+	}
+	included := []Data{
+		{Type: "people", ID: "9"},
+		{Type: "comments", ID: "42"},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.WriteHeader(doc); err != nil {
+		t.Fatalf("WriteHeader returned error: %v", err)
+	}
+	for _, d := range included {
+		if err := enc.WriteIncluded(d); err != nil {
+			t.Fatalf("WriteIncluded returned error: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	var got Document
+	var gotIncluded []Data
+	dec := NewDecoder(&buf)
+	err := dec.Decode(&got, func(d Data) error {
+		gotIncluded = append(gotIncluded, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+
+	if got.Data == nil || got.Data.DataObject == nil || got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected decoded data: %+v", got.Data)
+	}
+	if got.Links == nil || got.Links.Self != doc.Links.Self {
+		t.Fatalf("unexpected decoded links: %+v", got.Links)
+	}
+	if got.Meta["count"] != float64(2) {
+		t.Fatalf("unexpected decoded meta: %+v", got.Meta)
+	}
+
+	if len(gotIncluded) != len(included) {
+		t.Fatalf("expected %d included resources, got %d", len(included), len(gotIncluded))
+	}
+	for i, d := range included {
+		if gotIncluded[i].Type != d.Type || gotIncluded[i].ID != d.ID {
+			t.Fatalf("unexpected included[%d]: %+v", i, gotIncluded[i])
+		}
+	}
+}
+
+func TestEncoderWriteHeaderRejectsDataAndErrors(t *testing.T) {
+	doc := &Document{
+		Data:   &DataContainer{DataObject: &Data{Type: "articles", ID: "1"}},
+		Errors: []ErrorObject{{Title: "boom"}},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.WriteHeader(doc); !errors.Is(err, errDataAndErrors) {
+		t.Fatalf("expected errDataAndErrors, got %v", err)
+	}
+}
+
+func TestDecoderStopsOnCallbackError(t *testing.T) {
+	raw := []byte(`{"data":{"type":"articles","id":"1"},"included":[{"type":"people","id":"9"},{"type":"people","id":"10"}]}`)
+
+	boom := errors.New("boom")
+	var seen int
+	dec := NewDecoder(bytes.NewReader(raw))
+	err := dec.Decode(&Document{}, func(Data) error {
+		seen++
+		return boom
+	})
+
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if seen != 1 {
+		t.Fatalf("expected the callback to stop after the first element, got %d calls", seen)
+	}
+}
+
+func TestDecoderWithMaxIncludedRejectsPastLimit(t *testing.T) {
+	raw := []byte(`{"data":{"type":"articles","id":"1"},"included":[{"type":"people","id":"9"},{"type":"people","id":"10"},{"type":"people","id":"11"}]}`)
+
+	var seen int
+	dec := NewDecoder(bytes.NewReader(raw), WithMaxIncluded(2))
+	err := dec.Decode(&Document{}, func(Data) error {
+		seen++
+		return nil
+	})
+
+	if !errors.Is(err, ErrTooManyIncluded) {
+		t.Fatalf("expected ErrTooManyIncluded, got %v", err)
+	}
+	if seen != 2 {
+		t.Fatalf("expected the callback to run exactly 2 times before the cap hit, got %d", seen)
+	}
+}
+
+func TestDecoderWithMaxIncludedAllowsExactlyTheLimit(t *testing.T) {
+	raw := []byte(`{"data":{"type":"articles","id":"1"},"included":[{"type":"people","id":"9"},{"type":"people","id":"10"}]}`)
+
+	var seen int
+	dec := NewDecoder(bytes.NewReader(raw), WithMaxIncluded(2))
+	err := dec.Decode(&Document{}, func(Data) error {
+		seen++
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if seen != 2 {
+		t.Fatalf("expected 2 callback calls, got %d", seen)
+	}
+}
+
+func TestDecoderWithoutMaxIncludedIsUnlimited(t *testing.T) {
+	raw := []byte(`{"data":{"type":"articles","id":"1"},"included":[{"type":"people","id":"9"},{"type":"people","id":"10"},{"type":"people","id":"11"}]}`)
+
+	var seen int
+	dec := NewDecoder(bytes.NewReader(raw))
+	err := dec.Decode(&Document{}, func(Data) error {
+		seen++
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if seen != 3 {
+		t.Fatalf("expected 3 callback calls, got %d", seen)
+	}
+}
+
+func TestDecoderHandlesIncludedBeforeData(t *testing.T) {
+	raw := []byte(`{"included":[{"type":"people","id":"9"}],"data":{"type":"articles","id":"1"}}`)
+
+	var got Document
+	var gotIncluded []Data
+	dec := NewDecoder(bytes.NewReader(raw))
+	err := dec.Decode(&got, func(d Data) error {
+		gotIncluded = append(gotIncluded, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+
+	if got.Data == nil || got.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected decoded data: %+v", got.Data)
+	}
+	if len(gotIncluded) != 1 || gotIncluded[0].ID != "9" {
+		t.Fatalf("unexpected included: %+v", gotIncluded)
+	}
+}
+
+func TestDecoderRejectsDataAndErrors(t *testing.T) {
+	raw := []byte(`{"data":{"type":"articles","id":"1"},"errors":[{"title":"boom"}],"included":[]}`)
+
+	var doc Document
+	dec := NewDecoder(bytes.NewReader(raw))
+	err := dec.Decode(&doc, func(Data) error { return nil })
+	if !errors.Is(err, errDataAndErrors) {
+		t.Fatalf("expected errDataAndErrors, got %v", err)
+	}
+}
+
+func TestDecoderTreatsLeadingUTF8BOMAsWhitespace(t *testing.T) {
+	raw := append([]byte("\xEF\xBB\xBF"), []byte(`{"data":{"type":"articles","id":"1"},"included":[]}`)...)
+
+	var doc Document
+	dec := NewDecoder(bytes.NewReader(raw))
+	err := dec.Decode(&doc, func(Data) error { return nil })
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if doc.Data == nil || doc.Data.DataObject == nil || doc.Data.DataObject.ID != "1" {
+		t.Fatalf("unexpected decoded data: %+v", doc.Data)
+	}
+}
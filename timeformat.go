@@ -0,0 +1,214 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeFormat selects how Marshal and Unmarshal represent a time.Time (or
+// *time.Time) attribute. The zero value, TimeFormatRFC3339, leaves
+// encoding/json's own behavior in place, since time.Time already marshals to
+// and parses from an RFC 3339 string on its own.
+type TimeFormat int
+
+const (
+	// TimeFormatRFC3339 is the default: time.Time's own MarshalJSON and
+	// UnmarshalJSON, an RFC 3339 string.
+	TimeFormatRFC3339 TimeFormat = iota
+	// TimeFormatUnixSeconds represents a time.Time attribute as a JSON
+	// number of seconds since the Unix epoch.
+	TimeFormatUnixSeconds
+	// TimeFormatDateOnly represents a time.Time attribute as a
+	// "2006-01-02" string, dropping the time of day.
+	TimeFormatDateOnly
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+var timePtrType = reflect.TypeOf(&time.Time{})
+
+// formatAttrValue returns the value structToData should put into attrs for
+// fv, preferring a codec registered with RegisterFieldCodec for fv's type,
+// then applying format to time.Time and *time.Time fields. Every other
+// field is returned as-is, the same as before TimeFormat existed.
+func formatAttrValue(fv reflect.Value, format TimeFormat) (interface{}, error) {
+	if codec, ok := fieldCodecs[fv.Type()]; ok {
+		return codec.encode(fv.Interface())
+	}
+
+	if format == TimeFormatRFC3339 {
+		return fv.Interface(), nil
+	}
+
+	switch v := fv.Interface().(type) {
+	case time.Time:
+		return formatTime(v, format), nil
+	case *time.Time:
+		if v == nil {
+			return v, nil
+		}
+		return formatTime(*v, format), nil
+	default:
+		return fv.Interface(), nil
+	}
+}
+
+// formatTime renders t according to format.
+func formatTime(t time.Time, format TimeFormat) interface{} {
+	switch format {
+	case TimeFormatUnixSeconds:
+		return t.Unix()
+	case TimeFormatDateOnly:
+		return t.Format("2006-01-02")
+	default:
+		return t
+	}
+}
+
+// setAttrValue decodes raw into fv, preferring a codec registered with
+// RegisterFieldCodec for fv's type, then parsing time.Time and *time.Time
+// fields according to format instead of assuming an RFC 3339 string. loc
+// selects the *time.Location a format-ambiguous value like TimeFormatDateOnly
+// is interpreted in; it's ignored by formats, like TimeFormatRFC3339, whose
+// string already carries its own offset. If coerceScalars is set (see
+// WithLenientScalarCoercion) and fv is a bool, int, uint or float field
+// sent as a JSON string, the string is parsed into fv's type instead of
+// being rejected as a type mismatch. Every other field is decoded with
+// json.Unmarshal, the same as before TimeFormat existed.
+func setAttrValue(fv reflect.Value, raw json.RawMessage, format TimeFormat, loc *time.Location, coerceScalars bool) error {
+	if codec, ok := fieldCodecs[fv.Type()]; ok {
+		v, err := codec.decode(raw)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || !rv.Type().AssignableTo(fv.Type()) {
+			return fmt.Errorf("jsonapi: field codec for %s returned %v, want assignable to %s", fv.Type(), v, fv.Type())
+		}
+		fv.Set(rv)
+		return nil
+	}
+
+	if format != TimeFormatRFC3339 {
+		switch fv.Type() {
+		case timeType:
+			t, err := parseTime(raw, format, loc)
+			if err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(t))
+			return nil
+		case timePtrType:
+			if string(raw) == "null" {
+				fv.Set(reflect.Zero(fv.Type()))
+				return nil
+			}
+			t, err := parseTime(raw, format, loc)
+			if err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(&t))
+			return nil
+		}
+	}
+
+	if coerceScalars {
+		consumed, err := setCoercedScalar(fv, raw)
+		if err != nil {
+			return err
+		}
+		if consumed {
+			return nil
+		}
+	}
+
+	ptr := reflect.New(fv.Type())
+	if err := unmarshalJSON(raw, ptr.Interface()); err != nil {
+		return err
+	}
+	fv.Set(ptr.Elem())
+	return nil
+}
+
+// setCoercedScalar sets fv, a bool, int, uint or float field, from raw when
+// raw is a JSON string encoding a value of that kind (e.g. "true" or "5"),
+// and reports whether it did so. It reports false without error for any fv
+// kind it doesn't handle or any raw that isn't a JSON string, leaving the
+// caller to fall back to its normal decode path. A string that is handled
+// but fails to parse as fv's kind (e.g. "abc" for an int field) is an
+// error, not a silent no-op, so a genuinely malformed value still surfaces.
+func setCoercedScalar(fv reflect.Value, raw json.RawMessage) (bool, error) {
+	kind := fv.Kind()
+	switch kind {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return false, nil
+	}
+
+	switch kind {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return true, fmt.Errorf("jsonapi: cannot coerce %q into a bool: %w", s, err)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("jsonapi: cannot coerce %q into %s: %w", s, fv.Type(), err)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("jsonapi: cannot coerce %q into %s: %w", s, fv.Type(), err)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return true, fmt.Errorf("jsonapi: cannot coerce %q into %s: %w", s, fv.Type(), err)
+		}
+		fv.SetFloat(n)
+	}
+
+	return true, nil
+}
+
+// parseTime parses raw according to format. loc, if non-nil, is the
+// location a format-ambiguous value like TimeFormatDateOnly is interpreted
+// in; it defaults to time.UTC, matching encoding/json's own UTC default for
+// an offset-free RFC 3339 string.
+func parseTime(raw json.RawMessage, format TimeFormat, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch format {
+	case TimeFormatUnixSeconds:
+		var sec int64
+		if err := unmarshalJSON(raw, &sec); err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	case TimeFormatDateOnly:
+		var s string
+		if err := unmarshalJSON(raw, &s); err != nil {
+			return time.Time{}, err
+		}
+		return time.ParseInLocation("2006-01-02", s, loc)
+	default:
+		var t time.Time
+		err := unmarshalJSON(raw, &t)
+		return t, err
+	}
+}
@@ -0,0 +1,202 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type testEvent struct {
+	ID        string     `jsonapi:"primary,events"`
+	Name      string     `jsonapi:"attr,name"`
+	StartedAt time.Time  `jsonapi:"attr,started-at"`
+	EndedAt   *time.Time `jsonapi:"attr,ended-at"`
+}
+
+func TestMarshalDefaultTimeFormatIsRFC3339(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	event := &testEvent{ID: "1", Name: "launch", StartedAt: at}
+
+	doc, err := Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attrs["started-at"] != at.Format(time.RFC3339) {
+		t.Fatalf("unexpected started-at: %v", attrs["started-at"])
+	}
+}
+
+func TestMarshalWithTimeFormatUnixSeconds(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	event := &testEvent{ID: "1", Name: "launch", StartedAt: at}
+
+	doc, err := Marshal(event, WithTimeFormat(TimeFormatUnixSeconds))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attrs["started-at"] != float64(at.Unix()) {
+		t.Fatalf("unexpected started-at: %v", attrs["started-at"])
+	}
+}
+
+func TestMarshalWithTimeFormatDateOnly(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	event := &testEvent{ID: "1", Name: "launch", StartedAt: at}
+
+	doc, err := Marshal(event, WithTimeFormat(TimeFormatDateOnly))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attrs["started-at"] != "2024-03-15" {
+		t.Fatalf("unexpected started-at: %v", attrs["started-at"])
+	}
+}
+
+func TestMarshalWithTimeFormatLeavesNilPointerAsNull(t *testing.T) {
+	event := &testEvent{ID: "1", Name: "launch"}
+
+	doc, err := Marshal(event, WithTimeFormat(TimeFormatUnixSeconds))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(doc.Data.DataObject.Attributes, &attrs); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attrs["ended-at"] != nil {
+		t.Fatalf("expected ended-at to be null, got %v", attrs["ended-at"])
+	}
+}
+
+func TestMarshalUnmarshalRoundTripsUnixSecondsTimeFormat(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	ended := at.Add(time.Hour)
+	event := &testEvent{ID: "1", Name: "launch", StartedAt: at, EndedAt: &ended}
+
+	doc, err := Marshal(event, WithTimeFormat(TimeFormatUnixSeconds))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	var out testEvent
+	if err := Unmarshal(doc, &out, WithUnmarshalTimeFormat(TimeFormatUnixSeconds)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if !out.StartedAt.Equal(at) {
+		t.Fatalf("StartedAt = %v, want %v", out.StartedAt, at)
+	}
+	if out.EndedAt == nil || !out.EndedAt.Equal(ended) {
+		t.Fatalf("EndedAt = %v, want %v", out.EndedAt, ended)
+	}
+}
+
+func TestUnmarshalWithTimeFormatDateOnlyTruncatesTimeOfDay(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{
+			DataObject: &Data{
+				Type:       "events",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"name":"launch","started-at":"2024-03-15"}`),
+			},
+		},
+	}
+
+	var out testEvent
+	if err := Unmarshal(doc, &out, WithUnmarshalTimeFormat(TimeFormatDateOnly)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	want := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	if !out.StartedAt.Equal(want) {
+		t.Fatalf("StartedAt = %v, want %v", out.StartedAt, want)
+	}
+}
+
+func TestUnmarshalWithTimeFormatDateOnlyDefaultsToUTC(t *testing.T) {
+	doc := &Document{
+		Data: &DataContainer{
+			DataObject: &Data{
+				Type:       "events",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"name":"launch","started-at":"2024-03-15"}`),
+			},
+		},
+	}
+
+	var out testEvent
+	if err := Unmarshal(doc, &out, WithUnmarshalTimeFormat(TimeFormatDateOnly)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if out.StartedAt.Location() != time.UTC {
+		t.Fatalf("expected a date-only value to default to UTC, got %v", out.StartedAt.Location())
+	}
+}
+
+func TestUnmarshalWithTimeFormatDateOnlyUsesConfiguredLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+
+	doc := &Document{
+		Data: &DataContainer{
+			DataObject: &Data{
+				Type:       "events",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"name":"launch","started-at":"2024-03-15"}`),
+			},
+		},
+	}
+
+	var out testEvent
+	if err := Unmarshal(doc, &out, WithUnmarshalTimeFormat(TimeFormatDateOnly), WithUnmarshalTimeLocation(loc)); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	want := time.Date(2024, 3, 15, 0, 0, 0, 0, loc)
+	if !out.StartedAt.Equal(want) {
+		t.Fatalf("StartedAt = %v, want %v", out.StartedAt, want)
+	}
+	if out.StartedAt.Location() != loc {
+		t.Fatalf("expected StartedAt's location to be %v, got %v", loc, out.StartedAt.Location())
+	}
+}
+
+func TestUnmarshalWithoutTimeFormatOptionDefaultsToRFC3339(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	doc := &Document{
+		Data: &DataContainer{
+			DataObject: &Data{
+				Type:       "events",
+				ID:         "1",
+				Attributes: json.RawMessage(`{"name":"launch","started-at":"` + at.Format(time.RFC3339) + `"}`),
+			},
+		},
+	}
+
+	var out testEvent
+	if err := Unmarshal(doc, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if !out.StartedAt.Equal(at) {
+		t.Fatalf("StartedAt = %v, want %v", out.StartedAt, at)
+	}
+}
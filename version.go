@@ -0,0 +1,63 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// DefaultVersionMetaKey is the meta key ResourceVersion and VersionMatches
+// read from by default.
+const DefaultVersionMetaKey = "version"
+
+// versionMetaKey is the active meta key ResourceVersion and VersionMatches
+// read a resource's optimistic-concurrency version from.
+var versionMetaKey = DefaultVersionMetaKey
+
+// SetVersionMetaKey changes the meta key ResourceVersion and VersionMatches
+// read a resource's optimistic-concurrency version from. It defaults to
+// DefaultVersionMetaKey ("version"); call it once at startup if your
+// server embeds it under a different name (e.g. "revision" or "etag").
+// Like SetTypeNormalizer, this is a process-wide setting.
+func SetVersionMetaKey(key string) {
+	versionMetaKey = key
+}
+
+// ResourceVersion returns d's optimistic-concurrency version -- the value
+// of its Meta[versionMetaKey] (see SetVersionMetaKey), coerced to a string
+// since servers commonly embed it as either a JSON string or number. It
+// reports false for a nil d or one carrying no such meta key.
+func (d *Data) ResourceVersion() (string, bool) {
+	if d == nil || d.Meta == nil {
+		return "", false
+	}
+	v, ok := d.Meta[versionMetaKey]
+	if !ok {
+		return "", false
+	}
+	return versionToString(v), true
+}
+
+// VersionMatches reports whether d's ResourceVersion equals version. A d
+// with no version meta never matches, even against an empty version
+// string, since "no version recorded" and "version is empty" are distinct.
+func (d *Data) VersionMatches(version string) bool {
+	got, ok := d.ResourceVersion()
+	return ok && got == version
+}
+
+// versionToString renders a decoded meta value as the string a version
+// comparison or an If-Match header needs, regardless of whether the server
+// encoded it as a JSON string or number.
+func versionToString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,55 @@
+package jsonapi
+
+import "testing"
+
+func TestResourceVersionReadsStringMeta(t *testing.T) {
+	d := &Data{Meta: map[string]interface{}{"version": "abc123"}}
+	got, ok := d.ResourceVersion()
+	if !ok || got != "abc123" {
+		t.Fatalf("ResourceVersion() = (%q, %v), want (%q, true)", got, ok, "abc123")
+	}
+}
+
+func TestResourceVersionCoercesNumberMeta(t *testing.T) {
+	d := &Data{Meta: map[string]interface{}{"version": float64(7)}}
+	got, ok := d.ResourceVersion()
+	if !ok || got != "7" {
+		t.Fatalf("ResourceVersion() = (%q, %v), want (%q, true)", got, ok, "7")
+	}
+}
+
+func TestResourceVersionReportsFalseWithoutMeta(t *testing.T) {
+	if _, ok := (&Data{}).ResourceVersion(); ok {
+		t.Fatal("expected ResourceVersion to report false with no meta")
+	}
+	if _, ok := (*Data)(nil).ResourceVersion(); ok {
+		t.Fatal("expected ResourceVersion to report false on a nil Data")
+	}
+}
+
+func TestVersionMatches(t *testing.T) {
+	d := &Data{Meta: map[string]interface{}{"version": "v2"}}
+	if !d.VersionMatches("v2") {
+		t.Fatal("expected VersionMatches(\"v2\") to be true")
+	}
+	if d.VersionMatches("v3") {
+		t.Fatal("expected VersionMatches(\"v3\") to be false")
+	}
+	if (&Data{}).VersionMatches("") {
+		t.Fatal("expected a Data with no version meta to never match, even against an empty version")
+	}
+}
+
+func TestSetVersionMetaKeyChangesLookupKey(t *testing.T) {
+	defer SetVersionMetaKey(DefaultVersionMetaKey)
+
+	SetVersionMetaKey("revision")
+	d := &Data{Meta: map[string]interface{}{"revision": "rev-5"}}
+	got, ok := d.ResourceVersion()
+	if !ok || got != "rev-5" {
+		t.Fatalf("ResourceVersion() = (%q, %v), want (%q, true)", got, ok, "rev-5")
+	}
+	if !d.VersionMatches("rev-5") {
+		t.Fatal("expected VersionMatches to use the configured meta key")
+	}
+}